@@ -0,0 +1,487 @@
+package admin
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/permissions"
+	"github.com/prior-it/apollo/server"
+)
+
+// Admin is a back-office for users, organisations and permission groups.
+// Create one with [New] and attach it to a server with [Mount].
+type Admin struct {
+	users         core.UserService
+	organisations core.OrganisationService
+	permissions   permissions.Service
+	base          string
+}
+
+// New creates a new admin panel on top of the specified services.
+func New(
+	users core.UserService,
+	organisations core.OrganisationService,
+	permissions permissions.Service,
+) *Admin {
+	return &Admin{
+		users:         users,
+		organisations: organisations,
+		permissions:   permissions,
+	}
+}
+
+// Mount attaches all admin pages to the server along the specified pattern and returns the admin group.
+// Every page requires a logged in user with the matching Apollo permission, e.g. PermViewAllUsers to list users and
+// PermEditPermissionGroupPermissions to change the permission matrix. Only admins can grant or revoke admin rights.
+//
+// # Example
+//
+//	admin.Mount(server, "/admin", admin.New(userService, organisationService, permissionService))
+func Mount[state server.State](
+	srv *server.Server[state],
+	pattern string,
+	admin *Admin,
+) *server.Server[state] {
+	admin.base = strings.TrimSuffix(pattern, "/")
+	viewUsers := server.Requires(permissions.PermViewAllUsers)
+	editUsers := server.Requires(permissions.PermEditAllUsers)
+	viewOrgs := server.Requires(permissions.PermViewAllOrganisations)
+	editOrgs := server.Requires(permissions.PermEditAllOrganisations)
+	viewGroups := server.Requires(permissions.PermViewAllPermissionGroups)
+	editGroups := server.Requires(permissions.PermEditAllPermissionGroups)
+	editGroupPermissions := server.Requires(permissions.PermEditPermissionGroupPermissions)
+
+	group := srv.Group(pattern)
+	group.Get("/", handle[state](admin.dashboard))
+	group.Get("/users", handle[state](admin.listUsers), viewUsers)
+	group.Get("/users/{userID}", handle[state](admin.showUser), viewUsers)
+	group.Post("/users/{userID}", handle[state](admin.updateUser), editUsers)
+	group.Post("/users/{userID}/admin", handle[state](admin.toggleAdmin), editUsers)
+	group.Get("/organisations", handle[state](admin.listOrganisations), viewOrgs)
+	group.Post("/organisations", handle[state](admin.createOrganisation), editOrgs)
+	group.Get("/organisations/{orgID}", handle[state](admin.showOrganisation), viewOrgs)
+	group.Post("/organisations/{orgID}", handle[state](admin.renameOrganisation), editOrgs)
+	group.Post("/organisations/{orgID}/move", handle[state](admin.moveOrganisation), editOrgs)
+	group.Post("/organisations/{orgID}/members", handle[state](admin.addMember), editOrgs)
+	group.Post(
+		"/organisations/{orgID}/members/{userID}/remove",
+		handle[state](admin.removeMember),
+		editOrgs,
+	)
+	group.Get("/permissiongroups", handle[state](admin.listPermissionGroups), viewGroups)
+	group.Post("/permissiongroups", handle[state](admin.createPermissionGroup), editGroups)
+	group.Post(
+		"/permissiongroups/{groupID}",
+		handle[state](admin.updatePermissionGroup),
+		editGroupPermissions,
+	)
+	return group
+}
+
+func handle[state any](fn func(apollo *server.Apollo) error) server.Handler[state] {
+	return func(apollo *server.Apollo, _ state) error {
+		return fn(apollo)
+	}
+}
+
+// url returns the full url of an admin page.
+func (admin *Admin) url(format string, args ...any) string {
+	return admin.base + fmt.Sprintf(format, args...)
+}
+
+func pathID(apollo *server.Apollo, param string) (core.ID, error) {
+	id, err := core.ParseID(apollo.GetPath(param))
+	if err != nil {
+		return 0, errors.Join(core.ErrNotFound, fmt.Errorf("invalid %v: %w", param, err))
+	}
+	return id, nil
+}
+
+func (admin *Admin) dashboard(apollo *server.Apollo) error {
+	if err := apollo.RequiresLogin(); err != nil {
+		return err
+	}
+	return apollo.RenderPage(dashboardPage(admin), nil)
+}
+
+/**
+ * USERS
+ */
+
+func (admin *Admin) listUsers(apollo *server.Apollo) error {
+	query := strings.TrimSpace(apollo.GetQuery("q"))
+	users, err := admin.findUsers(apollo.Context(), query, apollo.GetQuery("cursor"))
+	if err != nil {
+		return err
+	}
+	if apollo.GetHeader("HX-Request") == "true" && apollo.GetHeader("HX-Target") == "users" {
+		return apollo.RenderComponent(userTable(admin, users, query))
+	}
+	return apollo.RenderPage(usersPage(admin, users, query), nil)
+}
+
+// findUsers searches users if there is a query, or lists all users page by page otherwise.
+func (admin *Admin) findUsers(
+	ctx context.Context,
+	query string,
+	cursor string,
+) (*core.Page[core.SearchResult[core.User]], error) {
+	if len(query) > 0 {
+		results, err := admin.users.SearchUsers(ctx, core.SearchQuery{Text: query})
+		if err != nil {
+			return nil, fmt.Errorf("could not search users: %w", err)
+		}
+		return &core.Page[core.SearchResult[core.User]]{
+			Items: results,
+			Total: uint64(len(results)),
+		}, nil
+	}
+	page, err := admin.users.ListUsers(ctx, core.ListQuery{Cursor: cursor})
+	if err != nil {
+		return nil, fmt.Errorf("could not list users: %w", err)
+	}
+	results := make([]core.SearchResult[core.User], len(page.Items))
+	for i, user := range page.Items {
+		results[i] = core.SearchResult[core.User]{Item: user}
+	}
+	return &core.Page[core.SearchResult[core.User]]{
+		Items:      results,
+		Total:      page.Total,
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+func (admin *Admin) showUser(apollo *server.Apollo) error {
+	id, err := pathID(apollo, "userID")
+	if err != nil {
+		return err
+	}
+	user, err := admin.users.GetUser(apollo.Context(), id)
+	if err != nil {
+		return fmt.Errorf("could not retrieve user %v: %w", id, err)
+	}
+	organisations, err := admin.organisations.ListOrganisationsForUser(apollo.Context(), id)
+	if err != nil {
+		return fmt.Errorf("could not list organisations for user %v: %w", id, err)
+	}
+	groups, err := admin.permissions.ListPermissionGroupsForUser(apollo.Context(), id)
+	if err != nil {
+		return fmt.Errorf("could not list permission groups for user %v: %w", id, err)
+	}
+	return apollo.RenderPage(userPage(admin, user, organisations, groups), nil)
+}
+
+type userForm struct {
+	Name  string `schema:"name"`
+	Email string `schema:"email"`
+	Lang  string `schema:"lang"`
+}
+
+func (admin *Admin) updateUser(apollo *server.Apollo) error {
+	id, err := pathID(apollo, "userID")
+	if err != nil {
+		return err
+	}
+	if err := apollo.CheckCSRF(); err != nil {
+		return err
+	}
+	var form userForm
+	if err := apollo.ParseBody(&form); err != nil {
+		return err
+	}
+	if _, err := core.ParseEmailAddress(form.Email); err != nil {
+		return errors.Join(core.ErrConflict, err)
+	}
+	_, err = admin.users.UpdateUser(apollo.Context(), id, core.UserUpdate{
+		Name:  &form.Name,
+		Email: &form.Email,
+		Lang:  &form.Lang,
+	})
+	if err != nil {
+		return fmt.Errorf("could not update user %v: %w", id, err)
+	}
+	apollo.Redirect(admin.url("/users/%v", id))
+	return nil
+}
+
+func (admin *Admin) toggleAdmin(apollo *server.Apollo) error {
+	// Only admins can hand out admin rights, otherwise anyone that can edit users could escalate their own rights
+	if !apollo.User.Admin {
+		return core.ErrForbidden
+	}
+	id, err := pathID(apollo, "userID")
+	if err != nil {
+		return err
+	}
+	if err := apollo.CheckCSRF(); err != nil {
+		return err
+	}
+	if id == apollo.User.ID {
+		return fmt.Errorf("admins cannot revoke their own admin rights: %w", core.ErrConflict)
+	}
+	user, err := admin.users.GetUser(apollo.Context(), id)
+	if err != nil {
+		return fmt.Errorf("could not retrieve user %v: %w", id, err)
+	}
+	user.Admin = !user.Admin
+	if err := admin.users.UpdateUserAdmin(apollo.Context(), id, user.Admin); err != nil {
+		return fmt.Errorf("could not update admin state of user %v: %w", id, err)
+	}
+	return apollo.RenderComponent(adminToggle(admin, user))
+}
+
+/**
+ * ORGANISATIONS
+ */
+
+func (admin *Admin) listOrganisations(apollo *server.Apollo) error {
+	if query := strings.TrimSpace(apollo.GetQuery("q")); len(query) > 0 {
+		results, err := admin.organisations.SearchOrganisations(
+			apollo.Context(),
+			core.SearchQuery{Text: query},
+		)
+		if err != nil {
+			return fmt.Errorf("could not search organisations: %w", err)
+		}
+		return apollo.RenderPage(organisationSearchPage(admin, results, query), nil)
+	}
+	organisations, err := core.ListAll(
+		apollo.Context(),
+		core.ListQuery{},
+		admin.organisations.ListOrganisations,
+	)
+	if err != nil {
+		return fmt.Errorf("could not list organisations: %w", err)
+	}
+	tree := core.BuildOrganisationTree(organisations)
+	return apollo.RenderPage(organisationsPage(admin, tree), nil)
+}
+
+type organisationForm struct {
+	Name     string `schema:"name"`
+	ParentID string `schema:"parent_id"`
+}
+
+// parent returns the selected parent organisation or nil if the organisation should be a root organisation.
+func (form *organisationForm) parent() (*core.OrganisationID, error) {
+	if len(form.ParentID) == 0 {
+		return nil, nil
+	}
+	id, err := core.ParseID(form.ParentID)
+	if err != nil {
+		return nil, errors.Join(core.ErrNotFound, err)
+	}
+	return &id, nil
+}
+
+func (admin *Admin) createOrganisation(apollo *server.Apollo) error {
+	if err := apollo.CheckCSRF(); err != nil {
+		return err
+	}
+	var form organisationForm
+	if err := apollo.ParseBody(&form); err != nil {
+		return err
+	}
+	parentID, err := form.parent()
+	if err != nil {
+		return err
+	}
+	org, err := admin.organisations.CreateOrganisation(apollo.Context(), form.Name, parentID)
+	if err != nil {
+		return fmt.Errorf("could not create organisation: %w", err)
+	}
+	apollo.Redirect(admin.url("/organisations/%v", org.ID))
+	return nil
+}
+
+func (admin *Admin) showOrganisation(apollo *server.Apollo) error {
+	id, err := pathID(apollo, "orgID")
+	if err != nil {
+		return err
+	}
+	ctx := apollo.Context()
+	org, err := admin.organisations.GetOrganisation(ctx, id)
+	if err != nil {
+		return fmt.Errorf("could not retrieve organisation %v: %w", id, err)
+	}
+	members, err := admin.organisations.ListMemberships(ctx, id)
+	if err != nil {
+		return fmt.Errorf("could not list members of organisation %v: %w", id, err)
+	}
+	tree, err := admin.organisations.GetOrganisationTree(ctx, id)
+	if err != nil {
+		return fmt.Errorf("could not retrieve descendants of organisation %v: %w", id, err)
+	}
+	return apollo.RenderPage(organisationPage(admin, org, members, tree.Children), nil)
+}
+
+func (admin *Admin) renameOrganisation(apollo *server.Apollo) error {
+	id, err := pathID(apollo, "orgID")
+	if err != nil {
+		return err
+	}
+	if err := apollo.CheckCSRF(); err != nil {
+		return err
+	}
+	var form organisationForm
+	if err := apollo.ParseBody(&form); err != nil {
+		return err
+	}
+	_, err = admin.organisations.UpdateOrganisation(apollo.Context(), id, form.Name)
+	if err != nil {
+		return fmt.Errorf("could not rename organisation %v: %w", id, err)
+	}
+	apollo.Redirect(admin.url("/organisations/%v", id))
+	return nil
+}
+
+func (admin *Admin) moveOrganisation(apollo *server.Apollo) error {
+	id, err := pathID(apollo, "orgID")
+	if err != nil {
+		return err
+	}
+	if err := apollo.CheckCSRF(); err != nil {
+		return err
+	}
+	var form organisationForm
+	if err := apollo.ParseBody(&form); err != nil {
+		return err
+	}
+	parentID, err := form.parent()
+	if err != nil {
+		return err
+	}
+	if _, err := admin.organisations.MoveOrganisation(apollo.Context(), id, parentID); err != nil {
+		return fmt.Errorf("could not move organisation %v: %w", id, err)
+	}
+	apollo.Redirect(admin.url("/organisations/%v", id))
+	return nil
+}
+
+type memberForm struct {
+	UserID core.UserID `schema:"user_id"`
+}
+
+func (admin *Admin) addMember(apollo *server.Apollo) error {
+	id, err := pathID(apollo, "orgID")
+	if err != nil {
+		return err
+	}
+	if err := apollo.CheckCSRF(); err != nil {
+		return err
+	}
+	var form memberForm
+	if err := apollo.ParseBody(&form); err != nil {
+		return errors.Join(core.ErrNotFound, err)
+	}
+	options := core.MembershipOptions{InvitedBy: &apollo.User.ID}
+	_, err = admin.organisations.AddMembership(apollo.Context(), form.UserID, id, options)
+	if err != nil {
+		return fmt.Errorf("could not add user %v to organisation %v: %w", form.UserID, id, err)
+	}
+	apollo.Redirect(admin.url("/organisations/%v", id))
+	return nil
+}
+
+func (admin *Admin) removeMember(apollo *server.Apollo) error {
+	orgID, err := pathID(apollo, "orgID")
+	if err != nil {
+		return err
+	}
+	userID, err := pathID(apollo, "userID")
+	if err != nil {
+		return err
+	}
+	if err := apollo.CheckCSRF(); err != nil {
+		return err
+	}
+	if err := admin.organisations.RemoveUser(apollo.Context(), userID, orgID); err != nil {
+		return fmt.Errorf("could not remove user %v from organisation %v: %w", userID, orgID, err)
+	}
+	// Returning an empty body removes the member's row
+	apollo.StatusCode(http.StatusOK)
+	return nil
+}
+
+/**
+ * PERMISSION GROUPS
+ */
+
+func (admin *Admin) listPermissionGroups(apollo *server.Apollo) error {
+	ctx := apollo.Context()
+	groups, err := admin.permissions.ListPermissionGroups(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list permission groups: %w", err)
+	}
+	perms, err := admin.permissions.ListPermissions(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list permissions: %w", err)
+	}
+	slices.Sort(perms)
+	slices.SortFunc(groups, func(a, b permissions.PermissionGroup) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return apollo.RenderPage(permissionGroupsPage(admin, groups, perms), nil)
+}
+
+type permissionGroupForm struct {
+	Name string `schema:"name"`
+}
+
+func (admin *Admin) createPermissionGroup(apollo *server.Apollo) error {
+	if err := apollo.CheckCSRF(); err != nil {
+		return err
+	}
+	var form permissionGroupForm
+	if err := apollo.ParseBody(&form); err != nil {
+		return err
+	}
+	group := &permissions.PermissionGroup{Name: form.Name}
+	_, err := admin.permissions.CreatePermissionGroup(apollo.Context(), group)
+	if err != nil {
+		return fmt.Errorf("could not create permission group: %w", err)
+	}
+	apollo.Redirect(admin.url("/permissiongroups"))
+	return nil
+}
+
+// updatePermissionGroup stores a single column of the permission matrix.
+// Every registered permission is disabled unless its checkbox was submitted.
+func (admin *Admin) updatePermissionGroup(apollo *server.Apollo) error {
+	id, err := pathID(apollo, "groupID")
+	if err != nil {
+		return err
+	}
+	if err := apollo.CheckCSRF(); err != nil {
+		return err
+	}
+	ctx := apollo.Context()
+	group, err := admin.permissions.GetPermissionGroup(ctx, id)
+	if err != nil {
+		return fmt.Errorf("could not retrieve permission group %v: %w", id, err)
+	}
+	perms, err := admin.permissions.ListPermissions(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list permissions: %w", err)
+	}
+	enabled := apollo.Request.PostForm["permission"]
+	group.Permissions = make(map[permissions.Permission]bool, len(perms))
+	for _, perm := range perms {
+		group.Permissions[perm] = slices.Contains(enabled, perm.String())
+	}
+	if err := admin.permissions.UpdatePermissionGroup(ctx, group); err != nil {
+		return fmt.Errorf("could not update permission group %v: %w", id, err)
+	}
+	apollo.Redirect(admin.url("/permissiongroups"))
+	return nil
+}
+
+func groupFormID(id permissions.PermissionGroupID) string {
+	return fmt.Sprintf("permissiongroup-%v", id)
+}
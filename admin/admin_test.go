@@ -0,0 +1,295 @@
+package admin_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prior-it/apollo/admin"
+	"github.com/prior-it/apollo/config"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/permissions"
+	"github.com/prior-it/apollo/server"
+	"github.com/stretchr/testify/assert"
+)
+
+type State struct{}
+
+func (s State) Close(_ context.Context) {}
+
+func TestMount(t *testing.T) {
+	s := server.New(State{}, &config.Config{})
+	admin.Mount(s, "/admin", admin.New(nil, nil, nil))
+
+	t.Run("ok: every page requires a permission", func(t *testing.T) {
+		t.Parallel()
+		for _, route := range s.Routes() {
+			if route.Pattern == "/admin/" {
+				continue
+			}
+			assert.NotEmpty(t, route.Requirements, route.Method+" "+route.Pattern)
+		}
+	})
+
+	t.Run("err: anonymous users cannot access the admin panel", func(t *testing.T) {
+		t.Parallel()
+		for _, path := range []string{"/admin/", "/admin/users", "/admin/permissiongroups"} {
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, http.StatusUnauthorized, recorder.Code, path)
+		}
+	})
+}
+
+// fakeUsers stores the admin state of users in memory.
+type fakeUsers struct {
+	core.UserService
+	admins map[core.UserID]bool
+}
+
+func (f *fakeUsers) GetUser(_ context.Context, id core.UserID) (*core.User, error) {
+	return &core.User{ID: id, Admin: f.admins[id]}, nil
+}
+
+func (f *fakeUsers) UpdateUserAdmin(_ context.Context, id core.UserID, isAdmin bool) error {
+	f.admins[id] = isAdmin
+	return nil
+}
+
+type membership struct {
+	UserID    core.UserID
+	OrgID     core.OrganisationID
+	InvitedBy *core.UserID
+}
+
+// fakeOrganisations records the members that are added and removed.
+type fakeOrganisations struct {
+	core.OrganisationService
+	added   []membership
+	removed []membership
+}
+
+func (f *fakeOrganisations) AddMembership(
+	_ context.Context,
+	userID core.UserID,
+	orgID core.OrganisationID,
+	options core.MembershipOptions,
+) (*core.Membership, error) {
+	f.added = append(f.added, membership{userID, orgID, options.InvitedBy})
+	return &core.Membership{}, nil
+}
+
+func (f *fakeOrganisations) RemoveUser(
+	_ context.Context,
+	userID core.UserID,
+	orgID core.OrganisationID,
+) error {
+	f.removed = append(f.removed, membership{UserID: userID, OrgID: orgID})
+	return nil
+}
+
+// fakePermissions grants a fixed set of global permissions and stores permission groups in memory.
+type fakePermissions struct {
+	permissions.Service
+	granted map[permissions.Permission]bool
+	perms   []permissions.Permission
+	groups  map[permissions.PermissionGroupID]*permissions.PermissionGroup
+}
+
+func (f *fakePermissions) RegisterPermission(_ context.Context, _ permissions.Permission) error {
+	return nil
+}
+
+func (f *fakePermissions) HasAny(
+	_ context.Context,
+	_ core.UserID,
+	permission permissions.Permission,
+) (bool, error) {
+	return f.granted[permission], nil
+}
+
+func (f *fakePermissions) ListPermissions(_ context.Context) ([]permissions.Permission, error) {
+	return f.perms, nil
+}
+
+func (f *fakePermissions) GetPermissionGroup(
+	_ context.Context,
+	id permissions.PermissionGroupID,
+) (*permissions.PermissionGroup, error) {
+	group, ok := f.groups[id]
+	if !ok {
+		return nil, core.ErrNotFound
+	}
+	copied := *group
+	return &copied, nil
+}
+
+func (f *fakePermissions) UpdatePermissionGroup(
+	_ context.Context,
+	group *permissions.PermissionGroup,
+) error {
+	f.groups[group.ID] = group
+	return nil
+}
+
+// session is a logged in browser session with the CSRF token of its last response.
+type session struct {
+	s       *server.Server[State]
+	cookies []*http.Cookie
+	token   string
+}
+
+// post submits a form as the session and returns the response.
+func (sess *session) post(path string, form url.Values) *http.Response {
+	form.Set(server.CsrfName, sess.token)
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range sess.cookies {
+		request.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	sess.s.ServeHTTP(recorder, request)
+	return recorder.Result()
+}
+
+func TestHandlers(t *testing.T) {
+	cfg := &config.Config{
+		App: config.AppConfig{
+			AuthenticationKey: "01234567890123456789012345678901",
+			EncryptionKey:     "01234567890123456789012345678901",
+			RequestTimeout:    10,
+			DisableI18n:       true,
+		},
+	}
+	viewA := permissions.Permission("test.view_a")
+	viewB := permissions.Permission("test.view_b")
+	viewC := permissions.Permission("test.view_c")
+
+	type fakes struct {
+		users         *fakeUsers
+		organisations *fakeOrganisations
+		permissions   *fakePermissions
+	}
+	// login creates a new server and logs in as the specified user
+	login := func(user core.User) (*session, fakes) {
+		email, err := core.ParseEmailAddress(fmt.Sprintf("user%v@example.com", user.ID))
+		assert.Nil(t, err)
+		user.Email = *email
+		f := fakes{
+			users:         &fakeUsers{admins: map[core.UserID]bool{user.ID: user.Admin}},
+			organisations: &fakeOrganisations{},
+			permissions: &fakePermissions{
+				granted: map[permissions.Permission]bool{permissions.PermEditAllUsers: true},
+				perms:   []permissions.Permission{viewA, viewB, viewC},
+				groups: map[permissions.PermissionGroupID]*permissions.PermissionGroup{
+					7: {
+						ID:          7,
+						Name:        "group",
+						Permissions: map[permissions.Permission]bool{viewB: true},
+					},
+				},
+			},
+		}
+		s := server.New(State{}, cfg).WithPermissionService(f.permissions)
+		s.AttachDefaultMiddleware()
+		s.Get("/login", func(apollo *server.Apollo, _ State) error {
+			if err := apollo.Login(&user); err != nil {
+				return err
+			}
+			_, err := apollo.Writer.Write([]byte(server.CSRFToken(apollo.Context())))
+			return err
+		})
+		admin.Mount(s, "/admin", admin.New(f.users, f.organisations, f.permissions))
+
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/login", nil))
+		response := recorder.Result()
+		token, err := io.ReadAll(response.Body)
+		assert.Nil(t, err)
+		return &session{s, response.Cookies(), string(token)}, f
+	}
+
+	t.Run("ok: toggle admin", func(t *testing.T) {
+		t.Parallel()
+		sess, f := login(core.User{ID: 1, Admin: true})
+		response := sess.post("/admin/users/2/admin", url.Values{})
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.True(t, f.users.admins[2], "The user should be an admin")
+	})
+
+	t.Run("err: only admins can toggle admin", func(t *testing.T) {
+		t.Parallel()
+		sess, f := login(core.User{ID: 1})
+		response := sess.post("/admin/users/2/admin", url.Values{})
+		assert.Equal(t, http.StatusForbidden, response.StatusCode)
+		assert.False(t, f.users.admins[2])
+	})
+
+	t.Run("err: admins cannot revoke their own admin rights", func(t *testing.T) {
+		t.Parallel()
+		sess, f := login(core.User{ID: 1, Admin: true})
+		response := sess.post("/admin/users/1/admin", url.Values{})
+		assert.Equal(t, http.StatusConflict, response.StatusCode)
+		assert.True(t, f.users.admins[1])
+	})
+
+	t.Run("err: invalid csrf token", func(t *testing.T) {
+		t.Parallel()
+		sess, f := login(core.User{ID: 1, Admin: true})
+		sess.token = "invalid"
+		response := sess.post("/admin/users/2/admin", url.Values{})
+		assert.NotEqual(t, http.StatusOK, response.StatusCode)
+		assert.False(t, f.users.admins[2])
+	})
+
+	t.Run("ok: update permission matrix column", func(t *testing.T) {
+		t.Parallel()
+		sess, f := login(core.User{ID: 1, Admin: true})
+		form := url.Values{"permission": {viewA.String(), viewC.String()}}
+		response := sess.post("/admin/permissiongroups/7", form)
+		assert.Equal(t, http.StatusSeeOther, response.StatusCode)
+		assert.Equal(t, map[permissions.Permission]bool{
+			viewA: true,
+			viewB: false,
+			viewC: true,
+		}, f.permissions.groups[7].Permissions, "Unchecked permissions should be disabled")
+	})
+
+	t.Run("err: update unknown permission group", func(t *testing.T) {
+		t.Parallel()
+		sess, _ := login(core.User{ID: 1, Admin: true})
+		response := sess.post("/admin/permissiongroups/8", url.Values{})
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("ok: add member", func(t *testing.T) {
+		t.Parallel()
+		sess, f := login(core.User{ID: 1, Admin: true})
+		response := sess.post("/admin/organisations/5/members", url.Values{"user_id": {"3"}})
+		assert.Equal(t, http.StatusSeeOther, response.StatusCode)
+		inviter := core.UserID(1)
+		assert.Equal(t, []membership{{3, 5, &inviter}}, f.organisations.added,
+			"The admin should be recorded as the inviter")
+	})
+
+	t.Run("ok: remove member", func(t *testing.T) {
+		t.Parallel()
+		sess, f := login(core.User{ID: 1, Admin: true})
+		response := sess.post("/admin/organisations/5/members/3/remove", url.Values{})
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, []membership{{UserID: 3, OrgID: 5}}, f.organisations.removed)
+	})
+
+	t.Run("err: members of invalid organisation", func(t *testing.T) {
+		t.Parallel()
+		sess, f := login(core.User{ID: 1, Admin: true})
+		response := sess.post("/admin/organisations/abc/members", url.Values{"user_id": {"3"}})
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+		assert.Empty(t, f.organisations.added)
+	})
+}
@@ -0,0 +1,4 @@
+// Package admin contains a mountable back-office for Apollo applications, built with templ and htmx.
+// It lists, searches and edits users, manages the organisation tree and its members, and edits permission groups
+// using a permission matrix.
+package admin
@@ -3,6 +3,7 @@ package bootstrap
 import (
 	"context"
 	"embed"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
@@ -15,6 +16,8 @@ import (
 	"github.com/posthog/posthog-go"
 	"github.com/prior-it/apollo/components"
 	"github.com/prior-it/apollo/config"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/permissions"
 	"github.com/prior-it/apollo/postgres"
 	"github.com/prior-it/apollo/server"
 )
@@ -28,6 +31,16 @@ type BootstrappedState[state server.State] interface {
 	Init(server *server.Server[state], cfg *config.Config, db *postgres.DB, posthog posthog.Client)
 }
 
+// TenantState is a BootstrappedState that stores data per tenant, see config.DatabaseConfig.Tenancy.
+//
+// Bootstrapping passes the tenancy to the state before Init, so the state can use it to create tenants.
+// If MigrateOnStartup is enabled, the tenant migrations run in every tenant schema after Init.
+type TenantState interface {
+	// TenantMigrations returns the app migrations that run in every tenant schema, see postgres.NewTenancy.
+	TenantMigrations() (migrations *embed.FS, folder string)
+	SetTenancy(tenancy *postgres.Tenancy)
+}
+
 // Minimal creates a new server and initializes all default systems.
 // The Minimal bootstrapper is perfect for very lightweight applications or (almost) static sites.
 //
@@ -101,10 +114,91 @@ func Full[state BootstrappedState[state]](
 		os.Exit(1)
 	}
 
+	// Add new users to the configured default permission group
+	if cfg.App.DefaultPermissionGroup > 0 {
+		db.OnUserCreated(postgres.AssignPermissionGroups(
+			permissions.PermissionGroupID(cfg.App.DefaultPermissionGroup),
+		))
+	}
+
+	// Periodically remove permission group memberships that have expired
+	schedule(logger, db, "apollo.permissions.cleanup", "@hourly",
+		permissions.CleanupExpiredMembershipsTask(postgres.NewPermissionService(db)))
+
+	// Periodically purge users and organisations once their soft delete retention period has passed
+	if cfg.Database.SoftDeleteRetention > 0 {
+		schedule(logger, db, "apollo.purge_deleted", "@hourly", core.PurgeDeletedTask(
+			postgres.NewUserService(db),
+			postgres.NewOrganisationService(db),
+			time.Duration(cfg.Database.SoftDeleteRetention)*24*time.Hour,
+		))
+	}
+
+	// Periodically remove the user data that was cached during logins that never finished
+	oauthAccounts := postgres.NewOauthAccountService(db)
+	schedule(logger, db, "apollo.login.cache_cleanup", "@hourly", func(ctx context.Context) error {
+		return oauthAccounts.DeleteOldCacheEntries(ctx, 24*time.Hour)
+	})
+
+	// Log out the sessions of users that have been deleted
+	s.WithUserService(postgres.NewUserService(db))
+
+	// Store the app's data of every tenant in its own schema
+	var tenancy *postgres.Tenancy
+	if cfg.Database.Tenancy != config.TenancyNone {
+		var migrations *embed.FS
+		var folder string
+		tenantState, ok := any(stt).(TenantState)
+		if ok {
+			migrations, folder = tenantState.TenantMigrations()
+		}
+		prefix := cfg.Database.TenantPrefix
+		tenancy, err = postgres.NewTenancy(db, prefix, migrations, folder, cfg.App.Debug)
+		if err != nil {
+			logger.Error("Could not initialize tenancy", "error", err)
+			os.Exit(1)
+		}
+		if ok {
+			tenantState.SetTenancy(tenancy)
+		}
+	}
+
 	stt.Init(s, cfg, db, posthog)
 
+	if tenancy != nil && cfg.Database.MigrateOnStartup {
+		if err := tenancy.MigrateAll(context.Background()); err != nil {
+			logger.Error("Could not migrate tenants", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Run the scheduled tasks, including the ones that the application scheduled during Init
+	scheduler := postgres.NewScheduler(db)
+	scheduler.Start(context.Background())
+	s.OnShutdown(scheduler.Stop)
+
 	s.AttachDefaultMiddleware()
 
+	// Use the organisation of the request instead of the session's active organisation
+	if cfg.App.OrganisationRouting != config.OrganisationRoutingNone {
+		resolver, err := organisationResolver(cfg)
+		if err != nil {
+			logger.Error("Could not initialize organisation routing", "error", err)
+			os.Exit(1)
+		}
+		s.UseStd(s.OrganisationMiddleware(postgres.NewOrganisationService(db), resolver))
+	}
+
+	// Run every request in the schema of its tenant
+	if tenancy != nil {
+		tenantMiddleware, err := tenancyMiddleware(cfg, tenancy)
+		if err != nil {
+			logger.Error("Could not initialize tenancy", "error", err)
+			os.Exit(1)
+		}
+		s.UseStd(tenantMiddleware)
+	}
+
 	// Enable sentry middleware
 	if cfg.Sentry.Enabled {
 		sentryHandler := sentryhttp.New(sentryhttp.Options{
@@ -132,6 +226,50 @@ func Full[state BootstrappedState[state]](
 	return s
 }
 
+// schedule registers one of apollo's own periodic tasks, which only fails if it was scheduled already.
+func schedule(logger *slog.Logger, db *postgres.DB, name string, spec string, task core.CronTask) {
+	if err := db.Schedule(name, spec, task); err != nil {
+		logger.Error("Could not schedule task", "task", name, "error", err)
+		os.Exit(1)
+	}
+}
+
+// organisationResolver returns the resolver for the configured organisation routing.
+func organisationResolver(cfg *config.Config) (server.OrganisationResolver, error) {
+	switch cfg.App.OrganisationRouting {
+	case config.OrganisationRoutingPath:
+		return server.PathOrganisation(server.DefaultOrganisationPrefix), nil
+	case config.OrganisationRoutingSubdomain:
+		return server.SubdomainOrganisation(cfg.App.Host, nil), nil
+	}
+	return nil, fmt.Errorf("unknown organisation routing %q", cfg.App.OrganisationRouting)
+}
+
+// tenancyMiddleware returns the middleware for the configured tenancy mode.
+func tenancyMiddleware(
+	cfg *config.Config,
+	tenancy *postgres.Tenancy,
+) (func(http.Handler) http.Handler, error) {
+	switch cfg.Database.Tenancy {
+	case config.TenancyOrganisation:
+		activeOrganisation := func(r *http.Request) *core.OrganisationID {
+			if !server.HasActiveOrganisation(r.Context()) {
+				return nil
+			}
+			id := server.OrganisationID(r.Context())
+			return &id
+		}
+		return tenancy.Middleware(tenancy.OrganisationTenant(activeOrganisation)), nil
+	case config.TenancySubdomain:
+		domain := cfg.Database.TenantDomain
+		if len(domain) == 0 {
+			domain = cfg.App.Host
+		}
+		return tenancy.Middleware(tenancy.SubdomainTenant(domain)), nil
+	}
+	return nil, fmt.Errorf("unknown tenancy mode %q", cfg.Database.Tenancy)
+}
+
 func createLogger(cfg *config.Config) *slog.Logger {
 	var logger *slog.Logger
 	loggerOptions := &slog.HandlerOptions{
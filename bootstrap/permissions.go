@@ -0,0 +1,48 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+
+	"github.com/prior-it/apollo/permissions"
+)
+
+// SeedPermissionGroups loads the permission group definitions from `file` in the config FS and reconciles them
+// with the database, see [permissions.SeedGroupDefinitions]. Any drift that had to be fixed is logged.
+//
+// # Example
+//
+//	err := bootstrap.SeedPermissionGroups(ctx, postgres.NewPermissionService(db), configFS, "permissions.toml")
+func SeedPermissionGroups(
+	ctx context.Context,
+	service permissions.Service,
+	configFS fs.FS,
+	file string,
+) error {
+	defs, err := permissions.LoadGroupDefinitions(configFS, file)
+	if err != nil {
+		return err
+	}
+	report, err := permissions.SeedGroupDefinitions(ctx, service, defs)
+	if err != nil {
+		return fmt.Errorf("could not seed permission groups: %w", err)
+	}
+	for _, id := range report.Created {
+		slog.Info("Created permission group", "id", id)
+	}
+	for _, drift := range report.Drifted {
+		slog.Warn(
+			"Permission group did not match its definition and was updated",
+			"id", drift.ID,
+			"name", drift.Name,
+			"missing", drift.Missing,
+			"extra", drift.Extra,
+		)
+	}
+	if len(report.Unmanaged) > 0 {
+		slog.Debug("Found permission groups without a definition", "ids", report.Unmanaged)
+	}
+	return nil
+}
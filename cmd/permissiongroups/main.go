@@ -0,0 +1,130 @@
+// Command permissiongroups exports, checks and seeds permission group definitions for an Apollo application.
+//
+// Usage:
+//
+//	permissiongroups [flags] export|check|seed
+//
+// The database connection is read from the config.toml file in the -config directory.
+// Export writes the current global permission groups to stdout in the -format format, check reports the differences
+// between -file and the database, and seed updates the database so it matches -file.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/prior-it/apollo/config"
+	"github.com/prior-it/apollo/permissions"
+	"github.com/prior-it/apollo/postgres"
+)
+
+var (
+	configDir string
+	file      string
+	format    string
+)
+
+func init() {
+	flag.Usage = helpMessage
+	flag.StringVar(&configDir, "config", ".", "Directory that contains config.toml")
+	flag.StringVar(&file, "file", "permissions.toml", "Permission group definitions (check, seed)")
+	flag.StringVar(&format, "format", "toml", "Output format, toml or yaml (export)")
+	flag.Parse()
+}
+
+func helpMessage() {
+	output := flag.CommandLine.Output()
+	fmt.Fprintf(output, "Usage of %s: [flags] export|check|seed\n\n", os.Args[0])
+	fmt.Fprintln(output, "Flags:")
+	flag.PrintDefaults()
+}
+
+var errDrift = errors.New("permission groups do not match their definitions")
+
+func main() {
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2) //nolint:mnd // Usage error
+	}
+	if err := run(context.Background(), flag.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, command string) error {
+	cfg, err := config.Load(os.DirFS(configDir))
+	if err != nil {
+		return err
+	}
+	db, err := postgres.NewDB(ctx, cfg.Database.URL, cfg.Database.Schema)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	service := postgres.NewPermissionService(db)
+
+	switch command {
+	case "export":
+		return export(ctx, service)
+	case "check":
+		return check(ctx, service, false)
+	case "seed":
+		return check(ctx, service, true)
+	}
+	return fmt.Errorf("unknown command %q", command)
+}
+
+func export(ctx context.Context, service permissions.Service) error {
+	defs, err := permissions.ExportGroupDefinitions(ctx, service)
+	if err != nil {
+		return err
+	}
+	data, err := permissions.MarshalGroupDefinitions(defs, permissions.DefinitionFormat(format))
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func check(ctx context.Context, service permissions.Service, seed bool) error {
+	defs, err := permissions.LoadGroupDefinitions(os.DirFS(configDir), file)
+	if err != nil {
+		return err
+	}
+	var report *permissions.SeedReport
+	if seed {
+		report, err = permissions.SeedGroupDefinitions(ctx, service, defs)
+	} else {
+		report, err = permissions.CheckGroupDefinitions(ctx, service, defs)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, id := range report.Created {
+		fmt.Printf("missing group %v\n", id)
+	}
+	for _, drift := range report.Drifted {
+		if drift.Name != nil {
+			fmt.Printf("group %v: name is %q\n", drift.ID, *drift.Name)
+		}
+		for _, permission := range drift.Missing {
+			fmt.Printf("group %v: missing permission %v\n", drift.ID, permission)
+		}
+		for _, permission := range drift.Extra {
+			fmt.Printf("group %v: extra permission %v\n", drift.ID, permission)
+		}
+	}
+	for _, id := range report.Unmanaged {
+		fmt.Printf("group %v has no definition\n", id)
+	}
+	if !seed && report.HasDrift() {
+		return errDrift
+	}
+	return nil
+}
@@ -82,8 +82,21 @@ type AppConfig struct {
 	DefaultPermissionGroup int    `                     mapstructure:"DEFAULTPERMGROUP"`
 	DisableI18n            bool
 	FallbackLang           string `default:"nl"`
+	// Resolve the active organisation of every request from its path or subdomain instead of the session
+	OrganisationRouting OrganisationRouting
 }
 
+type OrganisationRouting string
+
+const (
+	// The active organisation is always stored in the session
+	OrganisationRoutingNone OrganisationRouting = ""
+	// Requests to /o/{orgID}/... use that organisation
+	OrganisationRoutingPath OrganisationRouting = "path"
+	// Requests to {orgID}.{host} use that organisation
+	OrganisationRoutingSubdomain OrganisationRouting = "subdomain"
+)
+
 type SentryConfig struct {
 	Enabled      bool
 	DSN          string
@@ -109,10 +122,28 @@ type EmailConfig struct {
 	Notifications string
 }
 
+type TenancyMode string
+
+const (
+	// All data is stored in the configured schema
+	TenancyNone TenancyMode = ""
+	// Every organisation stores its data in its own schema, based on the active organisation
+	TenancyOrganisation TenancyMode = "organisation"
+	// Every subdomain of TenantDomain stores its data in its own schema
+	TenancySubdomain TenancyMode = "subdomain"
+)
+
 type DatabaseConfig struct {
 	URL              string
 	Schema           string `default:"public"`
 	MigrateOnStartup bool
+	Tenancy          TenancyMode
+	// Prefix of every tenant schema
+	TenantPrefix string `default:"tenant_"`
+	// Base domain for subdomain tenancy, defaults to the app's host
+	TenantDomain string
+	// Amount of days that soft deleted users and organisations are kept before they are purged, 0 keeps them forever
+	SoftDeleteRetention uint32 `default:"30"`
 }
 
 type LogConfig struct {
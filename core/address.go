@@ -38,5 +38,8 @@ type AddressService interface {
 	GetAddress(ctx context.Context, addressID AddressID) (*Address, error)
 	DeleteAddress(ctx context.Context, addressID AddressID) error
 	UpdateAddress(ctx context.Context, addressID AddressID, update AddressUpdateData) (*Address, error)
-	ListAddresses(ctx context.Context) ([]Address, error)
+	// Retrieve a page of addresses.
+	// Sort fields: "id" (default), "street", "postal_code", "city" and "country".
+	// Filters: "search" (street, postal code or city contains), "postal_code", "city" and "country".
+	ListAddresses(ctx context.Context, query ListQuery) (*Page[Address], error)
 }
@@ -0,0 +1,167 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+/**
+ * DOMAIN
+ */
+
+// Actor is whoever performs a mutation. The zero value is the system itself, e.g. a background job.
+type Actor struct {
+	// The user that performs the mutation, nil for the system
+	UserID *UserID
+	// The user that is impersonating UserID, if any
+	ImpersonatorID *UserID
+}
+
+// IsSystem returns true if the mutation is not performed by a user.
+func (a Actor) IsSystem() bool {
+	return a.UserID == nil
+}
+
+type actorContextKey struct{}
+
+// WithActor returns a context in which all mutations are attributed to the actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFromContext returns the actor of the context or the system actor if there is none.
+func ActorFromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorContextKey{}).(Actor)
+	return actor
+}
+
+// AuditAction is the kind of mutation that an audit entry records.
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditUpdate  AuditAction = "update"
+	AuditDelete  AuditAction = "delete"
+	AuditRestore AuditAction = "restore"
+	AuditPurge   AuditAction = "purge"
+	AuditErase   AuditAction = "erase"
+)
+
+// Entity types of the audit entries that Apollo records itself.
+const (
+	AuditUser                  = "user"
+	AuditOrganisation          = "organisation"
+	AuditMembership            = "membership"
+	AuditAddress               = "address"
+	AuditPermissionGroup       = "permission_group"
+	AuditPermissionGroupMember = "permission_group_member"
+	AuditObjectPermission      = "object_permission"
+	AuditWebhookEndpoint       = "webhook_endpoint"
+)
+
+// MembershipAuditID returns the entity id of a membership in the audit log: "{user id}:{organisation id}".
+func MembershipAuditID(userID UserID, orgID OrganisationID) string {
+	return fmt.Sprintf("%v:%v", userID, orgID)
+}
+
+// AuditChange is the value of a single field before and after a mutation.
+// Before is nil for created entities and After is nil for deleted entities.
+type AuditChange struct {
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// AuditChanges are the changed fields of an entity, by field name.
+type AuditChanges map[string]AuditChange
+
+// AuditEntry records a single mutation of an entity.
+type AuditEntry struct {
+	ID         ID
+	OccurredAt time.Time
+	Actor      Actor
+	Action     AuditAction
+	EntityType string
+	EntityID   string
+	Changes    AuditChanges
+}
+
+// AuditRecord describes a mutation that should be recorded.
+type AuditRecord struct {
+	Action     AuditAction
+	EntityType string
+	EntityID   string
+	// State of the entity before and after the mutation, nil if it did not exist.
+	// Both are encoded as JSON objects to determine which fields changed.
+	Before any
+	After  any
+}
+
+// Diff returns the fields that differ between the JSON representations of before and after.
+// Either of them can be nil, in which case all fields of the other are returned.
+func (r AuditRecord) Diff() (AuditChanges, error) {
+	before, err := auditFields(r.Before)
+	if err != nil {
+		return nil, err
+	}
+	after, err := auditFields(r.After)
+	if err != nil {
+		return nil, err
+	}
+	changes := make(AuditChanges)
+	for field, value := range before {
+		if !bytes.Equal(value, after[field]) {
+			changes[field] = AuditChange{Before: value, After: after[field]}
+		}
+	}
+	for field, value := range after {
+		if _, ok := before[field]; !ok {
+			changes[field] = AuditChange{After: value}
+		}
+	}
+	return changes, nil
+}
+
+// auditFields returns the JSON encoded fields of the value's JSON object representation.
+func auditFields(value any) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if value == nil {
+		return fields, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode audited value: %w", err)
+	}
+	if bytes.Equal(data, []byte("null")) {
+		return fields, nil
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("audited value is not a JSON object: %w", err)
+	}
+	// Compacting makes sure that equal values are encoded identically
+	for field, value := range fields {
+		var buffer bytes.Buffer
+		if err := json.Compact(&buffer, value); err != nil {
+			return nil, err
+		}
+		fields[field] = buffer.Bytes()
+	}
+	return fields, nil
+}
+
+/**
+ * APPLICATION
+ */
+
+type AuditService interface {
+	// Record a mutation that was performed by the actor of the context.
+	// Updates that do not change any field are not recorded.
+	Record(ctx context.Context, record AuditRecord) error
+	// Retrieve a page of audit entries.
+	// Sort fields: "time" (default) and "id".
+	// Filters: "actor" (user id of the actor or impersonator), "entity_type", "entity_id", "action", "from" and
+	// "until" (RFC 3339 times, until is exclusive).
+	ListAuditEntries(ctx context.Context, query ListQuery) (*Page[AuditEntry], error)
+}
@@ -0,0 +1,63 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditRecordDiff(t *testing.T) {
+	before := core.User{ID: 1, Name: "Alice", Lang: "nl"}
+	after := core.User{ID: 1, Name: "Alice", Lang: "nl", Admin: true}
+
+	t.Run("ok: update", func(t *testing.T) {
+		changes, err := core.AuditRecord{Before: before, After: after}.Diff()
+		assert.Nil(t, err)
+		assert.Equal(t, core.AuditChanges{
+			"Admin": {Before: json.RawMessage("false"), After: json.RawMessage("true")},
+		}, changes, "Only the changed fields should be recorded")
+	})
+
+	t.Run("ok: no changes", func(t *testing.T) {
+		changes, err := core.AuditRecord{Before: before, After: before}.Diff()
+		assert.Nil(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("ok: create and delete", func(t *testing.T) {
+		changes, err := core.AuditRecord{After: after}.Diff()
+		assert.Nil(t, err)
+		assert.Equal(t, json.RawMessage(`"Alice"`), changes["Name"].After)
+		assert.Nil(t, changes["Name"].Before)
+
+		changes, err = core.AuditRecord{Before: &before}.Diff()
+		assert.Nil(t, err)
+		assert.Equal(t, json.RawMessage(`"Alice"`), changes["Name"].Before)
+		assert.Nil(t, changes["Name"].After)
+
+		changes, err = core.AuditRecord{Before: (*core.User)(nil)}.Diff()
+		assert.Nil(t, err)
+		assert.Empty(t, changes, "A nil pointer should not record any fields")
+	})
+
+	t.Run("err: not an object", func(t *testing.T) {
+		_, err := core.AuditRecord{After: "Alice"}.Diff()
+		assert.NotNil(t, err)
+	})
+}
+
+func TestActorFromContext(t *testing.T) {
+	ctx := context.Background()
+	assert.True(t, core.ActorFromContext(ctx).IsSystem())
+
+	user := core.UserID(1)
+	impersonator := core.UserID(2)
+	ctx = core.WithActor(ctx, core.Actor{UserID: &user, ImpersonatorID: &impersonator})
+	actor := core.ActorFromContext(ctx)
+	assert.False(t, actor.IsSystem())
+	assert.Equal(t, user, *actor.UserID)
+	assert.Equal(t, impersonator, *actor.ImpersonatorID)
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidAddressType = errors.New("invalid address type")
+	ErrInvalidVatNumber   = errors.New("invalid VAT number")
+)
+
+// AddressType describes what an organisation uses one of its addresses for.
+// An organisation has at most one address of every type.
+type AddressType string
+
+const (
+	AddressBilling          AddressType = "billing"
+	AddressShipping         AddressType = "shipping"
+	AddressRegisteredOffice AddressType = "registered_office"
+)
+
+// ParseAddressType returns the address type with the specified name or ErrInvalidAddressType.
+func ParseAddressType(addressType string) (AddressType, error) {
+	switch t := AddressType(addressType); t {
+	case AddressBilling, AddressShipping, AddressRegisteredOffice:
+		return t, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidAddressType, addressType)
+}
+
+// BillingDetails contains the information that is needed to invoice an organisation.
+type BillingDetails struct {
+	OrganisationID OrganisationID
+	// The official name of the organisation, if it differs from its display name
+	LegalName *string
+	// Nil if the organisation has no VAT number
+	VatNumber VatNumber
+	Addresses map[AddressType]Address
+}
+
+// BillingDetailsUpdate lists the billing details that should change, nil fields are left untouched.
+type BillingDetailsUpdate struct {
+	// Set to an empty string to clear the legal name
+	LegalName *string
+	// This is validated with NewVatNumber, set to an empty string to clear the VAT number
+	VatNumber *string
+	// Addresses that should be created or replaced, the ID field of every address is ignored
+	Addresses map[AddressType]Address
+	// Addresses that should be unlinked and deleted
+	RemoveAddresses []AddressType
+}
@@ -0,0 +1,238 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCronSchedule = errors.New("invalid cron schedule")
+
+/**
+ * DOMAIN
+ */
+
+// CronSchedule determines when a periodic task runs, parsed from a cron expression by ParseCronSchedule.
+type CronSchedule struct {
+	spec   string
+	minute uint64
+	hour   uint64
+	day    uint64
+	month  uint64
+	// Bit 0 is Sunday
+	weekday uint64
+	// Whether the day of the month or week started with a "*", which changes how they are combined
+	anyDay     bool
+	anyWeekday bool
+}
+
+// Shorthands for common schedules.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField describes the values that a field of a cron expression accepts.
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names []string
+}
+
+var (
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDay    = cronField{name: "day of the month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: []string{
+		"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec",
+	}}
+	cronWeekday = cronField{name: "day of the week", min: 0, max: 7, names: []string{
+		"sun", "mon", "tue", "wed", "thu", "fri", "sat",
+	}}
+)
+
+// ParseCronSchedule parses a standard cron expression with five fields: minute, hour, day of the month, month and
+// day of the week. Every field accepts "*", values, ranges ("1-5"), steps ("*/15" or "0-30/10") and lists of those
+// ("1,15"). Months and days of the week can also be named ("jan", "mon"), Sunday is both 0 and 7.
+// If both the day of the month and the day of the week are restricted, either of them has to match.
+// The shorthands @yearly, @monthly, @weekly, @daily and @hourly are supported as well.
+func ParseCronSchedule(spec string) (*CronSchedule, error) {
+	expression := strings.TrimSpace(spec)
+	if descriptor, ok := cronDescriptors[strings.ToLower(expression)]; ok {
+		expression = descriptor
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 { //nolint:mnd // minute, hour, day, month and weekday
+		err := fmt.Errorf("expected 5 fields, got %v", len(fields))
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidCronSchedule, spec, err)
+	}
+	schedule := &CronSchedule{
+		spec:       spec,
+		anyDay:     strings.HasPrefix(fields[2], "*"),
+		anyWeekday: strings.HasPrefix(fields[4], "*"),
+	}
+	parts := []struct {
+		field cronField
+		bits  *uint64
+	}{
+		{cronMinute, &schedule.minute},
+		{cronHour, &schedule.hour},
+		{cronDay, &schedule.day},
+		{cronMonth, &schedule.month},
+		{cronWeekday, &schedule.weekday},
+	}
+	for i, part := range parts {
+		bits, err := part.field.parse(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidCronSchedule, spec, err)
+		}
+		*part.bits = bits
+	}
+	// Sunday can be written as 7 as well
+	if schedule.weekday&(1<<7) != 0 {
+		schedule.weekday |= 1
+	}
+	return schedule, nil
+}
+
+// parse returns the values that the field's expression matches as a bit set.
+func (f cronField) parse(expression string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expression, ",") {
+		values, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepText, f.name)
+			}
+		}
+		low, high := f.min, f.max
+		if values != "*" {
+			lowText, highText, isRange := strings.Cut(values, "-")
+			var err error
+			if low, err = f.value(lowText); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = f.value(highText); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// "5/10" means every 10 starting at 5
+				high = f.max
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q in %s", values, f.name)
+			}
+		}
+		for value := low; value <= high; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+// value parses a single value of the field, either as a number or as a name.
+func (f cronField) value(text string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(text, name) {
+			return i + f.min, nil
+		}
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil || value < f.min || value > f.max {
+		return 0, fmt.Errorf("invalid %s %q, expected %v-%v", f.name, text, f.min, f.max)
+	}
+	return value, nil
+}
+
+// Next returns the first time after the specified time that matches the schedule, in the same location, or the zero
+// time if the schedule never matches, e.g. for February 30th.
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// Every valid schedule matches within a few years, even the ones that only match on leap days
+	limit := t.Year() + 5 //nolint:mnd
+	for t.Year() <= limit {
+		if !hasBit(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !hasBit(s.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if !hasBit(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchesDay returns true if both the day of the month and the day of the week match, or either of them if both
+// are restricted.
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	day := hasBit(s.day, t.Day())
+	weekday := hasBit(s.weekday, int(t.Weekday()))
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+func hasBit(bits uint64, value int) bool {
+	return bits&(1<<value) != 0
+}
+
+// String returns the expression that the schedule was parsed from.
+func (s *CronSchedule) String() string {
+	return s.spec
+}
+
+type CronRunID = ID
+
+// CronRun is a single run of a scheduled task.
+type CronRun struct {
+	ID   CronRunID
+	Task string
+	// The time at which the task was scheduled to run, which is unique for every task
+	ScheduledAt time.Time
+	StartedAt   time.Time
+	// Nil while the task is running
+	FinishedAt *time.Time
+	// Nil if the task succeeded
+	Error *string
+	// The instance of the application that ran the task
+	Instance string
+}
+
+/**
+ * APPLICATION
+ */
+
+// CronTask is a task that runs periodically. Returning an error only records the failure, the task runs again at its
+// next scheduled time.
+type CronTask func(ctx context.Context) error
+
+type CronHistory interface {
+	// Retrieve a page of the runs of scheduled tasks.
+	// Sort fields: "time" (default, when the run started) and "id". Filters: "task" and "failed".
+	ListCronRuns(ctx context.Context, query ListQuery) (*Page[CronRun], error)
+}
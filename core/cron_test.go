@@ -0,0 +1,61 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronSchedule(t *testing.T) {
+	// Wednesday
+	start := time.Date(2024, time.December, 18, 10, 17, 30, 0, time.UTC)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", at(time.December, 18, 10, 18)},
+		{"*/15 * * * *", at(time.December, 18, 10, 30)},
+		{"0 * * * *", at(time.December, 18, 11, 0)},
+		{"@hourly", at(time.December, 18, 11, 0)},
+		{"30 2 * * *", at(time.December, 19, 2, 30)},
+		{"@daily", at(time.December, 19, 0, 0)},
+		{"0 9-17/4 * * *", at(time.December, 18, 13, 0)},
+		{"0,45 10 * * *", at(time.December, 18, 10, 45)},
+		{"0 0 * * mon", at(time.December, 23, 0, 0)},
+		{"0 0 * * 7", at(time.December, 22, 0, 0)},
+		{"0 0 * * 1-5", at(time.December, 19, 0, 0)},
+		{"0 0 1 * *", time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jun *", time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		// Either the day of the month or the day of the week
+		{"0 0 25 * fri", at(time.December, 20, 0, 0)},
+		{"0 0 29 feb *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		schedule, err := core.ParseCronSchedule(c.spec)
+		if assert.Nil(t, err, c.spec) {
+			assert.Equal(t, c.next, schedule.Next(start), c.spec)
+			assert.Equal(t, c.spec, schedule.String())
+		}
+	}
+
+	t.Run("ok: never", func(t *testing.T) {
+		schedule, err := core.ParseCronSchedule("0 0 30 feb *")
+		assert.Nil(t, err)
+		assert.True(t, schedule.Next(start).IsZero())
+	})
+
+	t.Run("err: invalid expressions", func(t *testing.T) {
+		for _, spec := range []string{
+			"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *",
+			"* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@often",
+		} {
+			_, err := core.ParseCronSchedule(spec)
+			assert.ErrorIs(t, err, core.ErrInvalidCronSchedule, spec)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+/**
+ * APPLICATION
+ */
+
+// PurgeDeletedTask returns a task that permanently deletes all users and organisations that were soft deleted longer
+// than the retention period ago.
+//
+// # Example
+//
+//	db.Schedule("purge_deleted", "@hourly", core.PurgeDeletedTask(users, organisations, 30*24*time.Hour))
+func PurgeDeletedTask(
+	users UserService,
+	organisations OrganisationService,
+	retention time.Duration,
+) CronTask {
+	return func(ctx context.Context) error {
+		before := time.Now().Add(-retention)
+		var errs []error
+		purged, err := users.PurgeDeletedUsers(ctx, before)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not purge deleted users: %w", err))
+		} else if purged > 0 {
+			slog.Debug("Purged deleted users", "amount", purged)
+		}
+		purged, err = organisations.PurgeDeletedOrganisations(ctx, before)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not purge deleted organisations: %w", err))
+		} else if purged > 0 {
+			slog.Debug("Purged deleted organisations", "amount", purged)
+		}
+		return errors.Join(errs...)
+	}
+}
@@ -24,6 +24,10 @@ func (email *EmailAddress) String() string {
 	return email.address
 }
 
+func (email EmailAddress) MarshalText() ([]byte, error) {
+	return []byte(email.address), nil
+}
+
 func (email *EmailAddress) UnmarshalText(text []byte) error {
 	add, err := ParseEmailAddress(string(text))
 	if err != nil {
@@ -73,3 +77,51 @@ type EmailService interface {
 	// SendRawMessage will send a raw gomail message using the existing configuration.
 	SendRawMessage(ctx context.Context, message *gomail.Message) error
 }
+
+// JobSendEmail is the kind of SendEmailJob.
+const JobSendEmail JobKind = "email.send"
+
+// SendEmailJob sends an e-mail in the background, see SendEmailLater.
+type SendEmailJob struct {
+	To      EmailAddress `json:"to"`
+	Subject string       `json:"subject"`
+	// The rendered HTML version, if any
+	HTML      string `json:"html,omitempty"`
+	Plaintext string `json:"plaintext"`
+}
+
+func (SendEmailJob) JobKind() JobKind { return JobSendEmail }
+
+// SendEmailLater renders the e-mail's template and enqueues a job that sends it, so the caller does not have to wait
+// for the mail server. The queue needs a handler for SendEmailJob, see SendEmailHandler.
+func SendEmailLater(
+	ctx context.Context,
+	queue JobQueue,
+	address EmailAddress,
+	subject string,
+	template *templ.Component,
+	plaintextMessage string,
+) error {
+	job := SendEmailJob{To: address, Subject: subject, Plaintext: plaintextMessage}
+	if template != nil {
+		var builder strings.Builder
+		if err := (*template).Render(ctx, &builder); err != nil {
+			return fmt.Errorf("could not render e-mail: %w", err)
+		}
+		job.HTML = builder.String()
+	}
+	_, err := queue.Enqueue(ctx, job, JobOptions{})
+	return err
+}
+
+// SendEmailHandler returns a job handler that sends the e-mails of SendEmailJob with the specified service.
+func SendEmailHandler(service EmailService) JobHandler[SendEmailJob] {
+	return func(ctx context.Context, job SendEmailJob) error {
+		var template *templ.Component
+		if len(job.HTML) > 0 {
+			html := templ.Raw(job.HTML)
+			template = &html
+		}
+		return service.SendEmail(ctx, job.To, job.Subject, template, job.Plaintext)
+	}
+}
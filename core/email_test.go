@@ -1,9 +1,12 @@
 package core_test
 
 import (
+	"context"
+	"encoding/json"
 	"strings"
 	"testing"
 
+	"github.com/a-h/templ"
 	"github.com/prior-it/apollo/core"
 	"github.com/prior-it/apollo/tests"
 	"github.com/stretchr/testify/assert"
@@ -62,3 +65,68 @@ func TestEmail(t *testing.T) {
 		assert.Equal(t, value, email.String())
 	})
 }
+
+// fakeJobQueue stores the jobs it enqueues instead of running them.
+type fakeJobQueue struct {
+	core.JobQueue
+	jobs []core.Job
+}
+
+func (q *fakeJobQueue) Enqueue(
+	_ context.Context,
+	job core.Job,
+	_ core.JobOptions,
+) (core.JobID, error) {
+	q.jobs = append(q.jobs, job)
+	return core.JobID(len(q.jobs)), nil
+}
+
+// fakeEmailService records the e-mails it sends.
+type fakeEmailService struct {
+	core.EmailService
+	html      string
+	plaintext string
+}
+
+func (s *fakeEmailService) SendEmail(
+	ctx context.Context,
+	_ core.EmailAddress,
+	_ string,
+	template *templ.Component,
+	plaintextMessage string,
+) error {
+	var builder strings.Builder
+	if template != nil {
+		if err := (*template).Render(ctx, &builder); err != nil {
+			return err
+		}
+	}
+	s.html, s.plaintext = builder.String(), plaintextMessage
+	return nil
+}
+
+func TestSendEmailLater(t *testing.T) {
+	ctx := context.Background()
+	address, err := core.ParseEmailAddress("someone@example.com")
+	tests.Check(err)
+	var template templ.Component = templ.Raw("<p>Hello</p>")
+
+	queue := &fakeJobQueue{}
+	err = core.SendEmailLater(ctx, queue, *address, "Hello", &template, "Hello")
+	assert.Nil(t, err)
+	if !assert.Len(t, queue.jobs, 1) {
+		return
+	}
+
+	// The job is stored as JSON until it runs
+	data, err := json.Marshal(queue.jobs[0])
+	tests.Check(err)
+	var job core.SendEmailJob
+	tests.Check(json.Unmarshal(data, &job))
+	assert.Equal(t, *address, job.To)
+
+	service := &fakeEmailService{}
+	assert.Nil(t, core.SendEmailHandler(service)(ctx, job))
+	assert.Equal(t, "<p>Hello</p>", service.html, "The rendered template should be sent")
+	assert.Equal(t, "Hello", service.plaintext)
+}
@@ -0,0 +1,241 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+/**
+ * DOMAIN
+ */
+
+// EventType identifies a kind of event, e.g. "user.created".
+type EventType string
+
+// Event is something that happened in the application that others can react to.
+// Events are encoded as JSON when they are stored in an outbox, so they should only contain exported data.
+type Event interface {
+	EventType() EventType
+}
+
+// Event types that Apollo emits itself.
+const (
+	EventUserCreated             EventType = "user.created"
+	EventUserAddedToOrganisation EventType = "organisation.user_added"
+	EventPermissionGroupChanged  EventType = "permission_group.changed"
+)
+
+// UserCreated is emitted whenever a new user is created, e.g. by UserService.CreateUser or when someone logs in with
+// a new external account.
+type UserCreated struct {
+	User User `json:"user"`
+}
+
+func (UserCreated) EventType() EventType { return EventUserCreated }
+
+// UserAddedToOrganisation is emitted whenever a user becomes a member of an organisation, regardless of the status
+// of the membership.
+type UserAddedToOrganisation struct {
+	UserID         UserID           `json:"user_id"`
+	OrganisationID OrganisationID   `json:"organisation_id"`
+	Status         MembershipStatus `json:"status"`
+}
+
+func (UserAddedToOrganisation) EventType() EventType { return EventUserAddedToOrganisation }
+
+func (e UserAddedToOrganisation) EventOrganisation() *OrganisationID { return &e.OrganisationID }
+
+// PermissionGroupChanged is emitted whenever a permission group is created, renamed, deleted or its permissions are
+// changed. Changes to the members of a group do not emit this event.
+type PermissionGroupChanged struct {
+	GroupID ID `json:"group_id"`
+	// The organisation that owns the group, nil for global groups
+	OrganisationID *OrganisationID `json:"organisation_id,omitempty"`
+	Deleted        bool            `json:"deleted"`
+}
+
+func (PermissionGroupChanged) EventType() EventType { return EventPermissionGroupChanged }
+
+func (e PermissionGroupChanged) EventOrganisation() *OrganisationID { return e.OrganisationID }
+
+// EventInfo describes an event that was delivered from an outbox.
+type EventInfo struct {
+	// Unique id of the event within its outbox, which stays the same when the event is delivered again
+	ID         string
+	OccurredAt time.Time
+	// The amount of earlier attempts to deliver the event
+	Attempts int
+}
+
+type eventInfoContextKey struct{}
+
+// WithEventInfo returns a context in which the event that is being delivered is described by info.
+func WithEventInfo(ctx context.Context, info EventInfo) context.Context {
+	return context.WithValue(ctx, eventInfoContextKey{}, info)
+}
+
+// EventInfoFromContext returns the description of the event that is being delivered, if it was delivered from an
+// outbox. Since delivery is at least once, subscribers can use the id to recognise events they already handled.
+func EventInfoFromContext(ctx context.Context) (EventInfo, bool) {
+	info, ok := ctx.Value(eventInfoContextKey{}).(EventInfo)
+	return info, ok
+}
+
+/**
+ * APPLICATION
+ */
+
+// EventHandler reacts to an event of type E.
+type EventHandler[E Event] func(ctx context.Context, event E) error
+
+type subscription struct {
+	handle func(ctx context.Context, event Event) error
+	async  bool
+}
+
+// EventBus delivers events to the subscribers within this process.
+// Synchronous subscribers run one after the other in the order in which they subscribed and their errors are
+// returned by Publish. Asynchronous subscribers run in their own goroutine and their errors are only logged.
+type EventBus struct {
+	lock          sync.RWMutex
+	subscriptions map[EventType][]subscription
+	all           []subscription
+	decoders      map[EventType]func(data []byte) (Event, error)
+	running       sync.WaitGroup
+}
+
+// NewEventBus creates an event bus that already knows every event type that Apollo emits.
+func NewEventBus() *EventBus {
+	bus := &EventBus{
+		subscriptions: make(map[EventType][]subscription),
+		decoders:      make(map[EventType]func(data []byte) (Event, error)),
+	}
+	RegisterEvent[UserCreated](bus)
+	RegisterEvent[UserAddedToOrganisation](bus)
+	RegisterEvent[PermissionGroupChanged](bus)
+	return bus
+}
+
+// RegisterEvent makes the bus able to decode events of type E, which is required to deliver them from an outbox.
+// Subscribing to an event type registers it as well, so this is only needed for events without typed subscribers.
+func RegisterEvent[E Event](bus *EventBus) {
+	var zero E
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	bus.decoders[zero.EventType()] = func(data []byte) (Event, error) {
+		var event E
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("could not decode %q event: %w", zero.EventType(), err)
+		}
+		return event, nil
+	}
+}
+
+// Subscribe registers a handler that runs synchronously whenever an event of type E is published.
+func Subscribe[E Event](bus *EventBus, handler EventHandler[E]) {
+	subscribe(bus, handler, false)
+}
+
+// SubscribeAsync registers a handler that runs in its own goroutine whenever an event of type E is published.
+// Its context only keeps the actor and the event info of the publisher, see detach.
+func SubscribeAsync[E Event](bus *EventBus, handler EventHandler[E]) {
+	subscribe(bus, handler, true)
+}
+
+func subscribe[E Event](bus *EventBus, handler EventHandler[E], async bool) {
+	RegisterEvent[E](bus)
+	var zero E
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	bus.subscriptions[zero.EventType()] = append(bus.subscriptions[zero.EventType()], subscription{
+		handle: func(ctx context.Context, event Event) error {
+			typed, ok := event.(E)
+			if !ok {
+				return fmt.Errorf("cannot handle %T as a %q event", event, zero.EventType())
+			}
+			return handler(ctx, typed)
+		},
+		async: async,
+	})
+}
+
+// SubscribeAll registers a handler that runs synchronously whenever any event is published, after the subscribers
+// of that specific event type.
+func (bus *EventBus) SubscribeAll(handler EventHandler[Event]) {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	bus.all = append(bus.all, subscription{handle: handler})
+}
+
+// Publish delivers an event to all of its subscribers and returns the errors of the synchronous subscribers.
+// Every synchronous subscriber runs, even if an earlier one returned an error.
+func (bus *EventBus) Publish(ctx context.Context, event Event) error {
+	bus.lock.RLock()
+	subscriptions := append(
+		append([]subscription(nil), bus.subscriptions[event.EventType()]...),
+		bus.all...,
+	)
+	bus.lock.RUnlock()
+
+	var errs []error
+	var detached context.Context
+	for _, sub := range subscriptions {
+		if !sub.async {
+			if err := sub.handle(ctx, event); err != nil {
+				errs = append(errs, fmt.Errorf("%q subscriber failed: %w", event.EventType(), err))
+			}
+			continue
+		}
+		if detached == nil {
+			detached = detach(ctx)
+		}
+		bus.running.Add(1)
+		go func(handle func(ctx context.Context, event Event) error) {
+			defer bus.running.Done()
+			if err := handle(detached, event); err != nil {
+				slog.Error(
+					"Asynchronous event subscriber failed",
+					"event", event.EventType(),
+					"error", err,
+				)
+			}
+		}(sub.handle)
+	}
+	return errors.Join(errs...)
+}
+
+// detach returns the context for asynchronous subscribers, which outlive the publisher, e.g. the request that
+// triggered the event. It only keeps the actor and the event info: the other values of the publisher's context, such
+// as its database transaction or tenant connection, are no longer usable once the publisher returns.
+func detach(ctx context.Context) context.Context {
+	detached := WithActor(context.Background(), ActorFromContext(ctx))
+	if info, ok := EventInfoFromContext(ctx); ok {
+		detached = WithEventInfo(detached, info)
+	}
+	return detached
+}
+
+// PublishJSON decodes a JSON encoded event of a registered type and publishes it.
+func (bus *EventBus) PublishJSON(ctx context.Context, eventType EventType, data []byte) error {
+	bus.lock.RLock()
+	decode, ok := bus.decoders[eventType]
+	bus.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown event type %q, register it with RegisterEvent", eventType)
+	}
+	event, err := decode(data)
+	if err != nil {
+		return err
+	}
+	return bus.Publish(ctx, event)
+}
+
+// Wait blocks until all asynchronous subscribers that are currently running have returned.
+func (bus *EventBus) Wait() {
+	bus.running.Wait()
+}
@@ -0,0 +1,133 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBus(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ok: synchronous subscribers", func(t *testing.T) {
+		bus := core.NewEventBus()
+		var received []core.UserID
+		core.Subscribe(bus, func(_ context.Context, event core.UserCreated) error {
+			received = append(received, event.User.ID)
+			return nil
+		})
+		core.Subscribe(bus, func(_ context.Context, _ core.PermissionGroupChanged) error {
+			t.Error("Subscribers of other events should not be called")
+			return nil
+		})
+		assert.Nil(t, bus.Publish(ctx, core.UserCreated{User: core.User{ID: 1}}))
+		assert.Nil(t, bus.Publish(ctx, core.UserCreated{User: core.User{ID: 2}}))
+		assert.Equal(t, []core.UserID{1, 2}, received)
+	})
+
+	t.Run("ok: asynchronous subscribers", func(t *testing.T) {
+		bus := core.NewEventBus()
+		var received atomic.Int32
+		core.SubscribeAsync(bus, func(_ context.Context, _ core.UserCreated) error {
+			received.Add(1)
+			return errors.New("asynchronous errors are only logged")
+		})
+		assert.Nil(t, bus.Publish(ctx, core.UserCreated{}))
+		bus.Wait()
+		assert.Equal(t, int32(1), received.Load())
+	})
+
+	t.Run("ok: asynchronous subscribers only keep the actor and event info", func(t *testing.T) {
+		type testContextKey struct{}
+		bus := core.NewEventBus()
+		userID := core.UserID(42)
+		info := core.EventInfo{ID: "1", Attempts: 1}
+		var actor core.Actor
+		var received core.EventInfo
+		var value any
+		core.SubscribeAsync(bus, func(ctx context.Context, _ core.UserCreated) error {
+			actor = core.ActorFromContext(ctx)
+			received, _ = core.EventInfoFromContext(ctx)
+			value = ctx.Value(testContextKey{})
+			return nil
+		})
+		publishCtx := context.WithValue(ctx, testContextKey{}, "transaction")
+		publishCtx = core.WithActor(publishCtx, core.Actor{UserID: &userID})
+		publishCtx = core.WithEventInfo(publishCtx, info)
+		assert.Nil(t, bus.Publish(publishCtx, core.UserCreated{}))
+		bus.Wait()
+		assert.Equal(t, &userID, actor.UserID)
+		assert.Equal(t, info, received)
+		assert.Nil(t, value, "Other values of the publisher's context should not be kept")
+	})
+
+	t.Run("ok: subscribe to all events", func(t *testing.T) {
+		bus := core.NewEventBus()
+		var received []core.EventType
+		bus.SubscribeAll(func(_ context.Context, event core.Event) error {
+			received = append(received, event.EventType())
+			return nil
+		})
+		assert.Nil(t, bus.Publish(ctx, core.UserCreated{}))
+		assert.Nil(t, bus.Publish(ctx, core.PermissionGroupChanged{}))
+		expected := []core.EventType{core.EventUserCreated, core.EventPermissionGroupChanged}
+		assert.Equal(t, expected, received)
+	})
+
+	t.Run("ok: publish JSON", func(t *testing.T) {
+		bus := core.NewEventBus()
+		var received *core.UserAddedToOrganisation
+		core.Subscribe(bus, func(_ context.Context, event core.UserAddedToOrganisation) error {
+			received = &event
+			return nil
+		})
+		data := []byte(`{"user_id": 1, "organisation_id": 2, "status": "active"}`)
+		assert.Nil(t, bus.PublishJSON(ctx, core.EventUserAddedToOrganisation, data))
+		assert.Equal(t, &core.UserAddedToOrganisation{
+			UserID:         1,
+			OrganisationID: 2,
+			Status:         core.MembershipActive,
+		}, received)
+	})
+
+	t.Run("ok: encoded events can be decoded", func(t *testing.T) {
+		bus := core.NewEventBus()
+		email, err := core.ParseEmailAddress("user@example.com")
+		assert.Nil(t, err)
+		event := core.UserCreated{User: core.User{ID: 1, Name: "User", Email: *email}}
+		var received core.UserCreated
+		core.Subscribe(bus, func(_ context.Context, event core.UserCreated) error {
+			received = event
+			return nil
+		})
+		data, err := json.Marshal(event)
+		assert.Nil(t, err)
+		assert.Nil(t, bus.PublishJSON(ctx, event.EventType(), data))
+		assert.Equal(t, event, received)
+	})
+
+	t.Run("err: subscriber fails", func(t *testing.T) {
+		bus := core.NewEventBus()
+		failure := errors.New("failure")
+		called := false
+		core.Subscribe(bus, func(_ context.Context, _ core.UserCreated) error {
+			return failure
+		})
+		core.Subscribe(bus, func(_ context.Context, _ core.UserCreated) error {
+			called = true
+			return nil
+		})
+		assert.ErrorIs(t, bus.Publish(ctx, core.UserCreated{}), failure)
+		assert.True(t, called, "Later subscribers should still be called")
+	})
+
+	t.Run("err: unknown event type", func(t *testing.T) {
+		bus := core.NewEventBus()
+		assert.NotNil(t, bus.PublishJSON(ctx, "unknown", []byte("{}")))
+	})
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+)
+
+var (
+	// ErrOrganisationCycle is returned when moving an organisation would make it its own ancestor.
+	ErrOrganisationCycle = errors.New("organisation cannot be moved below itself")
+	// ErrOrganisationHasDescendants is returned when deleting an organisation would remove too many descendants.
+	ErrOrganisationHasDescendants = errors.New("organisation has too many descendants to delete")
+)
+
+// DefaultDeleteCascadeLimit is the default amount of descendants that may be removed together with an organisation
+// by DeleteOrganisation. Use DeleteOrganisationTree to delete larger subtrees.
+const DefaultDeleteCascadeLimit = 10
+
+// OrganisationLevel is an organisation together with its distance to another organisation in the hierarchy.
+type OrganisationLevel struct {
+	Organisation
+	// The amount of steps between both organisations, a direct parent or child has depth 1.
+	Depth int
+}
+
+// OrganisationNode is an organisation together with all of its child organisations.
+type OrganisationNode struct {
+	Organisation Organisation
+	// The distance to the root of the tree, the root itself has depth 0.
+	Depth    int
+	Children []*OrganisationNode
+}
+
+// Size returns the amount of organisations in this subtree, including the node itself.
+func (node *OrganisationNode) Size() int {
+	size := 1
+	for _, child := range node.Children {
+		size += child.Size()
+	}
+	return size
+}
+
+// OrganisationTree is a forest of root organisations.
+type OrganisationTree []*OrganisationNode
+
+// BuildOrganisationTree arranges a flat list of organisations into a tree, sorted by name.
+// Organisations whose parent is not part of the list are treated as roots.
+func BuildOrganisationTree(organisations []Organisation) OrganisationTree {
+	nodes := make(map[OrganisationID]*OrganisationNode, len(organisations))
+	for _, org := range organisations {
+		nodes[org.ID] = &OrganisationNode{Organisation: org}
+	}
+	var roots OrganisationTree
+	for _, org := range organisations {
+		node := nodes[org.ID]
+		if org.ParentID != nil {
+			if parent, ok := nodes[*org.ParentID]; ok && parent != node {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	prepareNodes(roots, 0)
+	return roots
+}
+
+// prepareNodes sorts the nodes and sets their depth.
+func prepareNodes(nodes []*OrganisationNode, depth int) {
+	slices.SortFunc(nodes, func(a, b *OrganisationNode) int {
+		return cmp.Or(
+			cmp.Compare(a.Organisation.Name, b.Organisation.Name),
+			cmp.Compare(a.Organisation.ID, b.Organisation.ID),
+		)
+	})
+	for _, node := range nodes {
+		node.Depth = depth
+		prepareNodes(node.Children, depth+1)
+	}
+}
+
+// Find returns the node of the specified organisation or nil if it is not part of the tree.
+func (tree OrganisationTree) Find(id OrganisationID) *OrganisationNode {
+	for _, node := range tree {
+		if node.Organisation.ID == id {
+			return node
+		}
+		if found := OrganisationTree(node.Children).Find(id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
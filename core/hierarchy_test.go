@@ -0,0 +1,31 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrganisationTree(t *testing.T) {
+	root := core.OrganisationID(1)
+	child := core.OrganisationID(2)
+	missing := core.OrganisationID(99)
+	tree := core.BuildOrganisationTree([]core.Organisation{
+		{ID: 3, Name: "Grandchild", ParentID: &child},
+		{ID: child, Name: "Child", ParentID: &root},
+		{ID: root, Name: "Root"},
+		{ID: 4, Name: "Orphan", ParentID: &missing},
+	})
+
+	assert.Len(t, tree, 2, "Organisations without a known parent should be roots")
+	assert.Equal(t, "Orphan", tree[0].Organisation.Name, "Roots should be sorted by name")
+	assert.Equal(t, "Root", tree[1].Organisation.Name)
+	assert.Len(t, tree[1].Children, 1)
+	assert.Equal(t, "Grandchild", tree[1].Children[0].Children[0].Organisation.Name)
+
+	assert.Equal(t, "Child", tree.Find(child).Organisation.Name)
+	assert.Nil(t, tree.Find(missing))
+	assert.Equal(t, 2, tree.Find(3).Depth)
+	assert.Equal(t, 3, tree[1].Size())
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"strconv"
@@ -23,6 +24,14 @@ func (id *ID) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// UnmarshalJSON accepts both JSON numbers, as IDs are encoded, and strings.
+func (id *ID) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	return id.UnmarshalText(bytes.Trim(data, `"`))
+}
+
 // ParseID parses a string into an ID.
 func ParseID(id string) (ID, error) {
 	integerID, err := strconv.Atoi(id)
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// DefaultJobMaxAttempts is the amount of attempts after which a job is dead, unless its options specify otherwise.
+const DefaultJobMaxAttempts = 10
+
+/**
+ * DOMAIN
+ */
+
+// JobKind identifies a kind of background job, e.g. "email.send".
+type JobKind string
+
+// Job is work that runs in the background, outside of the request that enqueued it.
+// Jobs are encoded as JSON when they are enqueued, so they should only contain exported data.
+type Job interface {
+	JobKind() JobKind
+}
+
+type JobID = ID
+
+type JobOptions struct {
+	// The earliest time at which the job runs, or as soon as possible if this is zero
+	RunAt time.Time
+	// At most one job of the same kind with this key is pending or running at any time.
+	// Enqueueing another one returns the existing job instead. Leave empty to allow duplicates.
+	UniqueKey string
+	// The amount of attempts after which the job is dead, DefaultJobMaxAttempts if this is zero
+	MaxAttempts int
+}
+
+// JobInfo describes the job that is running.
+type JobInfo struct {
+	ID   JobID
+	Kind JobKind
+	// The current attempt, starting at 1
+	Attempt     int
+	MaxAttempts int
+}
+
+type jobInfoContextKey struct{}
+
+// WithJobInfo returns a context in which the job that is running is described by info.
+func WithJobInfo(ctx context.Context, info JobInfo) context.Context {
+	return context.WithValue(ctx, jobInfoContextKey{}, info)
+}
+
+// JobInfoFromContext returns the description of the job that is running, if any.
+// Since a job can run more than once, handlers can use it to recognise attempts that partially succeeded.
+func JobInfoFromContext(ctx context.Context) (JobInfo, bool) {
+	info, ok := ctx.Value(jobInfoContextKey{}).(JobInfo)
+	return info, ok
+}
+
+// DeadJob is a job that failed every attempt. It is kept until it is retried or deleted.
+type DeadJob struct {
+	ID        JobID
+	Kind      JobKind
+	Payload   json.RawMessage
+	UniqueKey *string
+	Attempts  int
+	LastError string
+	CreatedAt time.Time
+	DiedAt    time.Time
+}
+
+/**
+ * APPLICATION
+ */
+
+// JobHandler runs a job of type J. Returning an error makes the job run again later, until it runs out of attempts.
+type JobHandler[J Job] func(ctx context.Context, job J) error
+
+type JobQueue interface {
+	// Enqueue a job to run in the background and return its id.
+	// If the options specify a unique key that another pending or running job of the same kind has, this returns the
+	// id of that job instead.
+	Enqueue(ctx context.Context, job Job, options JobOptions) (JobID, error)
+	// Retrieve a page of the jobs that failed every attempt.
+	// Sort fields: "time" (default, when the job died) and "id". Filters: "kind".
+	ListDeadJobs(ctx context.Context, query ListQuery) (*Page[DeadJob], error)
+	// Run a dead job again as soon as possible, with all of its attempts.
+	// This returns ErrConflict if another job with the same unique key was enqueued in the meantime.
+	RetryDeadJob(ctx context.Context, id JobID) error
+	// Permanently delete a dead job.
+	DeleteDeadJob(ctx context.Context, id JobID) error
+}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+/**
+ * DOMAIN
+ */
+
+const (
+	// DefaultListLimit is the amount of items in a page if the query does not specify a limit.
+	DefaultListLimit = 50
+	// MaxListLimit is the largest amount of items that a single page can contain.
+	MaxListLimit = 1000
+)
+
+var ErrInvalidListQuery = errors.New("invalid list query")
+
+type SortDirection string
+
+const (
+	SortAscending  SortDirection = "asc"
+	SortDescending SortDirection = "desc"
+)
+
+// ListQuery specifies which page of a list should be retrieved.
+// Every list method supports its own set of sort fields and filters and returns ErrInvalidListQuery for unknown ones.
+type ListQuery struct {
+	// Maximum amount of items in the page, defaults to DefaultListLimit and cannot exceed MaxListLimit.
+	Limit int
+	// Cursor of the page to retrieve, as returned in Page.NextCursor. An empty cursor retrieves the first page.
+	Cursor string
+	// Field to sort on, defaults to the method's default sort field. Ties are always sorted by id.
+	Sort string
+	// Sort direction, defaults to SortAscending.
+	Direction SortDirection
+	// Only include items that match all filters, e.g. {"search": "acme"}.
+	Filters map[string]string
+}
+
+// PageSize returns the amount of items that should be retrieved for the query.
+func (q ListQuery) PageSize() int {
+	if q.Limit <= 0 {
+		return DefaultListLimit
+	}
+	return min(q.Limit, MaxListLimit)
+}
+
+// Descending returns true if the query should be sorted in descending order.
+func (q ListQuery) Descending() bool {
+	return q.Direction == SortDescending
+}
+
+// Validate returns ErrInvalidListQuery if the query's direction is unknown or if its cursor is invalid.
+func (q ListQuery) Validate() error {
+	if q.Direction != "" && q.Direction != SortAscending && q.Direction != SortDescending {
+		return fmt.Errorf("%w: unknown sort direction %q", ErrInvalidListQuery, q.Direction)
+	}
+	if _, err := q.DecodeCursor(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Cursor is the position in a sorted list after which the next page starts.
+// The sort field and direction are stored in the cursor so it cannot be used with a differently sorted query.
+type Cursor struct {
+	Sort      string        `json:"s"`
+	Direction SortDirection `json:"d"`
+	// Sort value of the last item on the previous page
+	Value string `json:"v"`
+	// ID of the last item on the previous page
+	ID ID `json:"i,string"`
+}
+
+// Encode returns the opaque representation of the cursor that can be used in ListQuery.Cursor.
+func (c Cursor) Encode() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		panic(fmt.Sprintf("cannot encode cursor: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor returns the query's cursor or nil if the query retrieves the first page.
+// This returns ErrInvalidListQuery if the cursor is malformed or if it belongs to a differently sorted query.
+func (q ListQuery) DecodeCursor() (*Cursor, error) {
+	if len(q.Cursor) == 0 {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor: %w", ErrInvalidListQuery, err)
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor: %w", ErrInvalidListQuery, err)
+	}
+	if cursor.Sort != q.Sort || cursor.Direction != q.Direction {
+		return nil, fmt.Errorf("%w: the cursor belongs to another sort order", ErrInvalidListQuery)
+	}
+	return &cursor, nil
+}
+
+// Page is a single page of a list.
+type Page[T any] struct {
+	Items []T
+	// Total amount of items that match the query's filters, across all pages
+	Total uint64
+	// Cursor of the next page or an empty string if this is the last page
+	NextCursor string
+}
+
+// HasNext returns true if there is a page after this one.
+func (p *Page[T]) HasNext() bool {
+	return len(p.NextCursor) > 0
+}
+
+/**
+ * APPLICATION
+ */
+
+// ListAll retrieves every page of the query and returns all items.
+// Only use this for lists that are known to be small, e.g. in tests.
+func ListAll[T any](
+	ctx context.Context,
+	query ListQuery,
+	list func(ctx context.Context, query ListQuery) (*Page[T], error),
+) ([]T, error) {
+	query.Limit = MaxListLimit
+	var items []T
+	for {
+		page, err := list(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+		if !page.HasNext() {
+			return items, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// ParseListQuery parses a list query from URL query parameters, e.g.
+// "?limit=20&cursor=...&sort=name&direction=desc&filter[search]=acme".
+func ParseListQuery(values url.Values) (ListQuery, error) {
+	query := ListQuery{
+		Cursor:    values.Get("cursor"),
+		Sort:      values.Get("sort"),
+		Direction: SortDirection(strings.ToLower(values.Get("direction"))),
+	}
+	if limit := values.Get("limit"); len(limit) > 0 {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return query, fmt.Errorf("%w: invalid limit: %w", ErrInvalidListQuery, err)
+		}
+		query.Limit = l
+	}
+	for key := range values {
+		name, ok := strings.CutPrefix(key, "filter[")
+		if !ok || !strings.HasSuffix(name, "]") {
+			continue
+		}
+		if query.Filters == nil {
+			query.Filters = make(map[string]string)
+		}
+		query.Filters[strings.TrimSuffix(name, "]")] = values.Get(key)
+	}
+	return query, query.Validate()
+}
@@ -0,0 +1,97 @@
+package core_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListQuery(t *testing.T) {
+	t.Run("ok: page size", func(t *testing.T) {
+		assert.Equal(t, core.DefaultListLimit, core.ListQuery{}.PageSize())
+		assert.Equal(t, 10, core.ListQuery{Limit: 10}.PageSize())
+		assert.Equal(t, core.MaxListLimit, core.ListQuery{Limit: core.MaxListLimit + 1}.PageSize())
+	})
+
+	t.Run("ok: cursor", func(t *testing.T) {
+		cursor := core.Cursor{Sort: "name", Direction: core.SortDescending, Value: "Zoë", ID: 42}
+		query := core.ListQuery{
+			Sort:      "name",
+			Direction: core.SortDescending,
+			Cursor:    cursor.Encode(),
+		}
+		decoded, err := query.DecodeCursor()
+		assert.Nil(t, err)
+		assert.Equal(t, cursor, *decoded)
+
+		decoded, err = core.ListQuery{}.DecodeCursor()
+		assert.Nil(t, err)
+		assert.Nil(t, decoded, "An empty cursor should start at the first page")
+	})
+
+	t.Run("err: cursor of another sort order", func(t *testing.T) {
+		cursor := core.Cursor{Sort: "name", Value: "Zoë", ID: 42}.Encode()
+		_, err := core.ListQuery{Sort: "email", Cursor: cursor}.DecodeCursor()
+		assert.ErrorIs(t, err, core.ErrInvalidListQuery)
+		descending := core.ListQuery{Sort: "name", Direction: core.SortDescending, Cursor: cursor}
+		_, err = descending.DecodeCursor()
+		assert.ErrorIs(t, err, core.ErrInvalidListQuery)
+		_, err = core.ListQuery{Cursor: "not a cursor"}.DecodeCursor()
+		assert.ErrorIs(t, err, core.ErrInvalidListQuery)
+	})
+
+	t.Run("ok: parse", func(t *testing.T) {
+		values, err := url.ParseQuery(
+			"limit=20&sort=name&direction=DESC&filter[search]=acme&filter[lang]=nl",
+		)
+		assert.Nil(t, err)
+		query, err := core.ParseListQuery(values)
+		assert.Nil(t, err)
+		assert.Equal(t, core.ListQuery{
+			Limit:     20,
+			Sort:      "name",
+			Direction: core.SortDescending,
+			Filters:   map[string]string{"search": "acme", "lang": "nl"},
+		}, query)
+	})
+
+	t.Run("err: parse", func(t *testing.T) {
+		for _, raw := range []string{"limit=many", "direction=up", "cursor=invalid"} {
+			values, err := url.ParseQuery(raw)
+			assert.Nil(t, err)
+			_, err = core.ParseListQuery(values)
+			assert.ErrorIs(t, err, core.ErrInvalidListQuery, raw)
+		}
+	})
+}
+
+func TestListAll(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	var queries []core.ListQuery
+	list := func(_ context.Context, query core.ListQuery) (*core.Page[int], error) {
+		queries = append(queries, query)
+		start := 0
+		if len(query.Cursor) > 0 {
+			start = 3
+		}
+		page := &core.Page[int]{
+			Items: items[start:min(start+3, len(items))],
+			Total: uint64(len(items)),
+		}
+		if start == 0 {
+			page.NextCursor = "next"
+		}
+		return page, nil
+	}
+
+	result, err := core.ListAll(context.Background(), core.ListQuery{Limit: 1}, list)
+	assert.Nil(t, err)
+	assert.Equal(t, items, result)
+	if assert.Len(t, queries, 2) {
+		assert.Equal(t, core.MaxListLimit, queries[0].Limit)
+		assert.Equal(t, "next", queries[1].Cursor)
+	}
+}
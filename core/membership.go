@@ -0,0 +1,58 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidMembershipStatus = errors.New("invalid membership status")
+
+// MembershipStatus is the state of a user's membership of an organisation.
+// Only active members are returned by e.g. ListUsersInOrganisation and GetMember.
+type MembershipStatus string
+
+const (
+	// The user has been invited but has not joined the organisation yet
+	MembershipPending MembershipStatus = "pending"
+	// The user is a member of the organisation
+	MembershipActive MembershipStatus = "active"
+	// The user used to be a member of the organisation
+	MembershipLeft MembershipStatus = "left"
+)
+
+// ParseMembershipStatus returns the membership status with the specified name or ErrInvalidMembershipStatus.
+func ParseMembershipStatus(status string) (MembershipStatus, error) {
+	switch s := MembershipStatus(status); s {
+	case MembershipPending, MembershipActive, MembershipLeft:
+		return s, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidMembershipStatus, status)
+}
+
+// Membership describes how a user belongs to an organisation.
+type Membership struct {
+	User           User
+	OrganisationID OrganisationID
+	Status         MembershipStatus
+	// When the membership was created or, for accepted invitations, when it became active
+	JoinedAt time.Time
+	// The user that added this member, if known
+	InvitedBy *UserID
+	// An optional title that is shown for this member within the organisation, e.g. "Treasurer".
+	// This is purely informational and does not grant any permissions.
+	Role *string
+}
+
+// IsActive returns true if the user is currently a member of the organisation.
+func (m *Membership) IsActive() bool {
+	return m.Status == MembershipActive
+}
+
+// MembershipOptions contains the optional metadata of a new membership.
+type MembershipOptions struct {
+	// The zero value is treated as MembershipActive
+	Status    MembershipStatus
+	InvitedBy *UserID
+	Role      *string
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"time"
 )
 
 /**
@@ -41,22 +42,101 @@ type OrganisationService interface {
 	GetOrganisation(ctx context.Context, id OrganisationID) (*Organisation, error)
 	// Update an existing organisation and return the result.
 	UpdateOrganisation(ctx context.Context, id OrganisationID, name string) (*Organisation, error)
-	// Retrieve all existing organisations.
-	ListOrganisations(ctx context.Context) ([]Organisation, error)
+	// Retrieve a page of organisations.
+	// Sort fields: "name" (default) and "id". Filters: "search" (name contains), "parent" (parent id) and "deleted"
+	// (true to only list soft deleted organisations, defaults to false).
+	ListOrganisations(ctx context.Context, query ListQuery) (*Page[Organisation], error)
+	// Search organisations by name or legal name, matching words, fragments and small typos.
+	// Results are sorted by relevance and highlight the "name" field.
+	SearchOrganisations(
+		ctx context.Context,
+		query SearchQuery,
+	) ([]SearchResult[Organisation], error)
 	// Retrieve the amount of existing organisations.
 	GetAmountOfOrganisations(ctx context.Context) (uint64, error)
-	// Delete the organisation with the specified id or ErrOrganisationDoesNotExist if no such organisation exists.
+	// Soft delete the organisation with the specified id, hiding it from every other method until it is restored.
+	// All descendants are deleted as well, but if there are more than the configured limit this returns
+	// ErrOrganisationHasDescendants (and ErrConflict) without deleting anything.
 	DeleteOrganisation(ctx context.Context, id OrganisationID) error
-	// List the organisations a user belongs to or ErrUserDoesNotExist if no such user exists
+	// Soft delete the organisation with the specified id together with all of its descendants, regardless of their
+	// amount.
+	DeleteOrganisationTree(ctx context.Context, id OrganisationID) error
+	// Restore a soft deleted organisation together with the descendants that were deleted with it, or return
+	// ErrNotFound if no such deleted organisation exists. This returns ErrConflict if its parent is still deleted.
+	RestoreOrganisation(ctx context.Context, id OrganisationID) error
+	// Permanently delete the organisation with the specified id, its descendants and their addresses, regardless of
+	// whether they were soft deleted.
+	PurgeOrganisation(ctx context.Context, id OrganisationID) error
+	// Permanently delete all organisations that were soft deleted before the specified moment and return the amount
+	// of deleted subtrees.
+	PurgeDeletedOrganisations(ctx context.Context, before time.Time) (uint64, error)
+	// Change the parent of an organisation, or turn it into a root organisation if parentID is nil.
+	// This returns ErrOrganisationCycle (and ErrConflict) if the new parent is the organisation itself or one of its
+	// descendants.
+	MoveOrganisation(
+		ctx context.Context,
+		id OrganisationID,
+		parentID *OrganisationID,
+	) (*Organisation, error)
+	// List the direct children of an organisation.
+	ListOrganisationChildren(ctx context.Context, parentID OrganisationID) ([]Organisation, error)
+	// List all ancestors of an organisation, starting with its parent and ending with the root organisation.
+	ListAncestors(ctx context.Context, id OrganisationID) ([]OrganisationLevel, error)
+	// List all descendants of an organisation, ordered by depth and name.
+	ListDescendants(ctx context.Context, id OrganisationID) ([]OrganisationLevel, error)
+	// Retrieve an organisation together with all of its descendants as a tree.
+	GetOrganisationTree(ctx context.Context, id OrganisationID) (*OrganisationNode, error)
+	// List the organisations a user actively belongs to or ErrUserDoesNotExist if no such user exists
 	ListOrganisationsForUser(ctx context.Context, id UserID) ([]Organisation, error)
-	// List the users that belong to an organisation or ErrOrganisationDoesNotExist if no such organisation exists
-	ListUsersInOrganisation(ctx context.Context, id OrganisationID) ([]User, error)
-	// Get user for a specific organisation, throws ErrNotFound if the user is not a member
+	// List a page of the active members of an organisation, supporting the same sort fields and filters as
+	// UserService.ListUsers.
+	ListUsersInOrganisation(
+		ctx context.Context,
+		id OrganisationID,
+		query ListQuery,
+	) (*Page[User], error)
+	// Get user for a specific organisation, throws ErrNotFound if the user is not an active member
 	GetMember(ctx context.Context, UserID UserID, OrgID OrganisationID) (*User, error)
-	// Return a User for the given organisation and email or ErrNotFound if no such member exisits
+	// Return a User for the given organisation and email or ErrNotFound if no such active member exisits
 	GetMemberByEmail(ctx context.Context, OrgID OrganisationID, email EmailAddress) (*User, error)
-	// Add user to an existing organisation
+	// Add user to an existing organisation as an active member
 	AddUser(ctx context.Context, UserID UserID, OrgID OrganisationID) error
-	// Remove user from an organisation
+	// Add user to an existing organisation with the specified membership metadata
+	AddMembership(
+		ctx context.Context,
+		UserID UserID,
+		OrgID OrganisationID,
+		options MembershipOptions,
+	) (*Membership, error)
+	// Retrieve the membership of a user in an organisation, regardless of its status, or ErrNotFound
+	GetMembership(ctx context.Context, UserID UserID, OrgID OrganisationID) (*Membership, error)
+	// List all memberships of an organisation regardless of their status, ordered by the moment they joined
+	ListMemberships(ctx context.Context, OrgID OrganisationID) ([]Membership, error)
+	// List all memberships of a user regardless of their status
+	ListMembershipsForUser(ctx context.Context, UserID UserID) ([]Membership, error)
+	// Change the status of a membership, e.g. to accept an invitation or to mark that a user left
+	UpdateMembershipStatus(
+		ctx context.Context,
+		UserID UserID,
+		OrgID OrganisationID,
+		status MembershipStatus,
+	) (*Membership, error)
+	// Change or clear (nil) the display role of a membership
+	UpdateMembershipRole(
+		ctx context.Context,
+		UserID UserID,
+		OrgID OrganisationID,
+		role *string,
+	) (*Membership, error)
+	// Retrieve the billing details of an organisation or ErrNotFound if no such organisation exists
+	GetBillingDetails(ctx context.Context, OrgID OrganisationID) (*BillingDetails, error)
+	// Update the billing details of an organisation in a single transaction and return the result.
+	// This returns ErrInvalidVatNumber if the VAT number cannot be parsed, in which case nothing is changed.
+	UpdateBillingDetails(
+		ctx context.Context,
+		OrgID OrganisationID,
+		update BillingDetailsUpdate,
+	) (*BillingDetails, error)
+	// Remove user from an organisation, this deletes the membership instead of marking it as left
 	RemoveUser(ctx context.Context, UserID UserID, OrgID OrganisationID) error
 }
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+)
+
+/**
+ * DOMAIN
+ */
+
+// UserData is everything that is stored about a user, as exported for a subject access request.
+type UserData struct {
+	ExportedAt       time.Time                 `json:"exported_at"`
+	Profile          UserProfileData           `json:"profile"`
+	Accounts         []UserAccountData         `json:"accounts"`
+	Memberships      []UserMembershipData      `json:"memberships"`
+	PermissionGroups []UserPermissionGroupData `json:"permission_groups"`
+	AccountCache     []UserAccountCacheData    `json:"account_cache"`
+	// Apollo only links addresses to organisations, so these are the addresses of the organisations that the user is
+	// an active member of.
+	Addresses []UserAddressData `json:"addresses"`
+	// Data that the application contributed to the export, by name
+	App map[string]any `json:"app,omitempty"`
+}
+
+type UserProfileData struct {
+	ID        UserID     `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Admin     bool       `json:"admin"`
+	Lang      string     `json:"lang"`
+	Joined    time.Time  `json:"joined"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// UserAccountData is an external (OAuth) account that the user logs in with.
+type UserAccountData struct {
+	Provider   string `json:"provider"`
+	ProviderID string `json:"provider_id"`
+}
+
+type UserMembershipData struct {
+	OrganisationID   OrganisationID   `json:"organisation_id"`
+	OrganisationName string           `json:"organisation_name"`
+	Status           MembershipStatus `json:"status"`
+	Role             *string          `json:"role,omitempty"`
+	JoinedAt         time.Time        `json:"joined_at"`
+	InvitedBy        *UserID          `json:"invited_by,omitempty"`
+}
+
+type UserPermissionGroupData struct {
+	ID   ID      `json:"id"`
+	Name *string `json:"name,omitempty"`
+	// The organisation in which the user is a member of the group, nil for global groups
+	OrganisationID *OrganisationID `json:"organisation_id,omitempty"`
+	ValidFrom      *time.Time      `json:"valid_from,omitempty"`
+	ValidUntil     *time.Time      `json:"valid_until,omitempty"`
+}
+
+// UserAccountCacheData is the temporarily cached profile of an external account, stored while logging in.
+type UserAccountCacheData struct {
+	Name       *string   `json:"name,omitempty"`
+	Email      *string   `json:"email,omitempty"`
+	Provider   string    `json:"provider"`
+	ProviderID string    `json:"provider_id"`
+	Created    time.Time `json:"created"`
+}
+
+type UserAddressData struct {
+	OrganisationID OrganisationID `json:"organisation_id"`
+	Type           AddressType    `json:"type"`
+	Street         string         `json:"street"`
+	Number         string         `json:"number"`
+	PostalCode     string         `json:"postal_code"`
+	City           string         `json:"city"`
+	Country        string         `json:"country"`
+	ExtraLine      *string        `json:"extra_line,omitempty"`
+}
+
+// ErasureMode specifies how the data of a user is erased.
+type ErasureMode string
+
+const (
+	// Replace the user's personal data with placeholders but keep the user, so references to it stay intact.
+	ErasureAnonymise ErasureMode = "anonymise"
+	// Permanently delete the user together with everything that refers to it.
+	ErasureDelete ErasureMode = "delete"
+)
+
+// ErasureRequest describes a request to erase the data of a user.
+type ErasureRequest struct {
+	Mode ErasureMode
+	// The user that handled the request, if any
+	RequestedBy *UserID
+	// Why the data was erased, e.g. a ticket number. This should not contain any personal data.
+	Reason *string
+}
+
+// UserErasure records that the data of a user was erased.
+type UserErasure struct {
+	ID          ID
+	UserID      UserID
+	Mode        ErasureMode
+	RequestedBy *UserID
+	Reason      *string
+	ErasedAt    time.Time
+}
+
+// AnonymousUserEmail returns the placeholder e-mail address of an anonymised user.
+// The domain is reserved, so it can never belong to anyone.
+func AnonymousUserEmail(id UserID) string {
+	return fmt.Sprintf("deleted-user-%v@anonymised.invalid", id)
+}
+
+// AnonymousUserName is the placeholder name of an anonymised user.
+const AnonymousUserName = "Deleted user"
+
+/**
+ * APPLICATION
+ */
+
+type PrivacyService interface {
+	// Gather everything that is stored about a user, including soft deleted users, or return ErrNotFound if no such
+	// user exists.
+	ExportUserData(ctx context.Context, id UserID) (*UserData, error)
+	// Anonymise or delete the data of a user, including soft deleted users, and record the request.
+	// Everything happens in a single transaction, so either all data is erased or nothing is.
+	// This returns ErrNotFound if no such user exists.
+	EraseUserData(ctx context.Context, id UserID, request ErasureRequest) (*UserErasure, error)
+	// List the erasures of a user, oldest first.
+	ListUserErasures(ctx context.Context, id UserID) ([]UserErasure, error)
+}
+
+// WriteUserDataJSON writes the user data as indented JSON.
+func WriteUserDataJSON(w io.Writer, data *UserData) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return fmt.Errorf("could not encode user data: %w", err)
+	}
+	return nil
+}
+
+// WriteUserDataZip writes the user data as a ZIP archive that contains the Apollo data in "user.json" and the data
+// of every application contributor in "app/{name}.json".
+func WriteUserDataZip(w io.Writer, data *UserData) error {
+	archive := zip.NewWriter(w)
+	apollo := *data
+	apollo.App = nil
+	if err := writeZipJSON(archive, "user.json", &apollo, data.ExportedAt); err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(data.App)) {
+		err := writeZipJSON(archive, "app/"+name+".json", data.App[name], data.ExportedAt)
+		if err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("could not write user data archive: %w", err)
+	}
+	return nil
+}
+
+func writeZipJSON(archive *zip.Writer, name string, value any, modified time.Time) error {
+	file, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return fmt.Errorf("could not create %q in user data archive: %w", name, err)
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Errorf("could not encode %q in user data archive: %w", name, err)
+	}
+	return nil
+}
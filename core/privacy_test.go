@@ -0,0 +1,44 @@
+package core_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteUserDataZip(t *testing.T) {
+	data := &core.UserData{
+		ExportedAt: time.Date(2024, 12, 12, 9, 0, 0, 0, time.UTC),
+		Profile:    core.UserProfileData{ID: 7, Name: "Jane", Email: "jane@example.com"},
+		App:        map[string]any{"notes": []string{"first"}},
+	}
+
+	var buffer bytes.Buffer
+	assert.Nil(t, core.WriteUserDataZip(&buffer, data))
+
+	archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	assert.Nil(t, err)
+	files := make(map[string][]byte)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		assert.Nil(t, err)
+		files[file.Name], err = io.ReadAll(reader)
+		assert.Nil(t, err)
+	}
+	assert.Len(t, files, 2)
+
+	var user map[string]any
+	assert.Nil(t, json.Unmarshal(files["user.json"], &user))
+	assert.Equal(t, "jane@example.com", user["profile"].(map[string]any)["email"])
+	assert.NotContains(t, user, "app", "Application data should be stored in separate files")
+
+	var notes []string
+	assert.Nil(t, json.Unmarshal(files["app/notes.json"], &notes))
+	assert.Equal(t, []string{"first"}, notes)
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+/**
+ * DOMAIN
+ */
+
+// DefaultSearchLimit is the amount of results that a search returns if the query does not specify a limit.
+const DefaultSearchLimit = 20
+
+// SearchQuery specifies what to search for.
+type SearchQuery struct {
+	// Text to search for, this matches whole words as well as fragments and small typos.
+	Text string
+	// Maximum amount of results, defaults to DefaultSearchLimit and cannot exceed MaxListLimit.
+	Limit int
+	// Only search the active members of this organisation, when searching users.
+	OrganisationID *OrganisationID
+	// Only search the organisations that this user is an active member of, when searching organisations.
+	UserID *UserID
+}
+
+// PageSize returns the maximum amount of results that should be retrieved for the query.
+func (q SearchQuery) PageSize() int {
+	if q.Limit <= 0 {
+		return DefaultSearchLimit
+	}
+	return min(q.Limit, MaxListLimit)
+}
+
+// IsEmpty returns true if the query does not contain any text to search for.
+func (q SearchQuery) IsEmpty() bool {
+	return len(strings.TrimSpace(q.Text)) == 0
+}
+
+// SearchResult is a single result of a search, results are sorted by descending rank.
+type SearchResult[T any] struct {
+	Item T
+	// Relevance of the result, higher is better
+	Rank float32
+	// Highlighted text of the fields that were searched, by field name, e.g. "name"
+	Highlights map[string]Highlight
+}
+
+// TextSegment is a part of a highlighted text.
+type TextSegment struct {
+	Text  string
+	Match bool
+}
+
+// Highlight is a text that is split into the segments that match a search query and those that do not.
+type Highlight []TextSegment
+
+// HighlightText splits the text into segments that contain one of the query's words and segments that do not,
+// ignoring case.
+func HighlightText(text string, query string) Highlight {
+	runes := []rune(text)
+	lower := toLowerRunes(text)
+	matched := make([]bool, len(runes))
+	for _, word := range strings.Fields(query) {
+		term := toLowerRunes(word)
+		for i := 0; i+len(term) <= len(lower); i++ {
+			if slices.Equal(lower[i:i+len(term)], term) {
+				for j := i; j < i+len(term); j++ {
+					matched[j] = true
+				}
+			}
+		}
+	}
+
+	var highlight Highlight
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i == len(runes) || matched[i] != matched[start] {
+			segment := TextSegment{Text: string(runes[start:i]), Match: matched[start]}
+			highlight = append(highlight, segment)
+			start = i
+		}
+	}
+	return highlight
+}
+
+// toLowerRunes lowercases every rune separately, so the positions of runes do not change.
+func toLowerRunes(text string) []rune {
+	runes := []rune(text)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// Matches returns true if any part of the text matches the query.
+func (h Highlight) Matches() bool {
+	for _, segment := range h {
+		if segment.Match {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the full text without highlighting.
+func (h Highlight) String() string {
+	var builder strings.Builder
+	for _, segment := range h {
+		builder.WriteString(segment.Text)
+	}
+	return builder.String()
+}
@@ -0,0 +1,51 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighlightText(t *testing.T) {
+	t.Run("ok: highlight every word of the query", func(t *testing.T) {
+		highlight := core.HighlightText("Acme Corporation", "corp ACME")
+		assert.Equal(t, core.Highlight{
+			{Text: "Acme", Match: true},
+			{Text: " ", Match: false},
+			{Text: "Corp", Match: true},
+			{Text: "oration", Match: false},
+		}, highlight)
+		assert.True(t, highlight.Matches())
+		assert.Equal(t, "Acme Corporation", highlight.String())
+	})
+
+	t.Run("ok: overlapping and repeated matches", func(t *testing.T) {
+		highlight := core.HighlightText("anna@example.com", "ann nna a")
+		assert.Equal(t, "anna", highlight[0].Text)
+		assert.True(t, highlight[0].Match)
+		assert.Equal(t, "anna@example.com", highlight.String())
+	})
+
+	t.Run("ok: non-ascii text", func(t *testing.T) {
+		highlight := core.HighlightText("Zoë Ünal", "ünal")
+		assert.Equal(t, core.Highlight{
+			{Text: "Zoë ", Match: false},
+			{Text: "Ünal", Match: true},
+		}, highlight)
+	})
+
+	t.Run("ok: no match", func(t *testing.T) {
+		highlight := core.HighlightText("Acme", "globex")
+		assert.False(t, highlight.Matches())
+		assert.Equal(t, core.Highlight{{Text: "Acme", Match: false}}, highlight)
+		assert.False(t, core.HighlightText("Acme", " ").Matches())
+	})
+}
+
+func TestSearchQuery(t *testing.T) {
+	assert.True(t, core.SearchQuery{Text: "  "}.IsEmpty())
+	assert.False(t, core.SearchQuery{Text: "acme"}.IsEmpty())
+	assert.Equal(t, core.DefaultSearchLimit, core.SearchQuery{}.PageSize())
+	assert.Equal(t, core.MaxListLimit, core.SearchQuery{Limit: core.MaxListLimit + 1}.PageSize())
+}
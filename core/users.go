@@ -35,12 +35,29 @@ type UserService interface {
 	CreateUser(ctx context.Context, name string, email EmailAddress, lang string) (*User, error)
 	// Retrieve the user with the specified id or ErrUserDoesNotExist if no such user exists.
 	GetUser(ctx context.Context, id UserID) (*User, error)
-	// Retrieve all existing users.
-	ListUsers(ctx context.Context) ([]User, error)
+	// Retrieve the user with the specified e-mail address or ErrNotFound if no such user exists.
+	GetUserByEmail(ctx context.Context, email EmailAddress) (*User, error)
+	// Search users by name or e-mail address, matching words, fragments and small typos.
+	// Results are sorted by relevance and highlight the "name" and "email" fields.
+	SearchUsers(ctx context.Context, query SearchQuery) ([]SearchResult[User], error)
+	// Retrieve a page of users.
+	// Sort fields: "name" (default), "email", "joined" and "id".
+	// Filters: "search" (name or e-mail address contains), "admin" (true or false), "lang" and "deleted" (true to
+	// only list soft deleted users, defaults to false).
+	ListUsers(ctx context.Context, query ListQuery) (*Page[User], error)
 	// Retrieve the amount of existing users.
 	GetAmountOfUsers(ctx context.Context) (uint64, error)
-	// Delete the user with the specified id or ErrUserDoesNotExist if no such user exists.
+	// Soft delete the user with the specified id, hiding it from every other method until it is restored.
+	// Soft deleted users are purged permanently by PurgeDeletedUsers once the retention period has passed.
 	DeleteUser(ctx context.Context, id UserID) error
+	// Restore a soft deleted user or return ErrNotFound if no such deleted user exists.
+	// This returns ErrConflict if another user has claimed the e-mail address in the meantime.
+	RestoreUser(ctx context.Context, id UserID) error
+	// Permanently delete the user with the specified id, regardless of whether it was soft deleted.
+	PurgeUser(ctx context.Context, id UserID) error
+	// Permanently delete all users that were soft deleted before the specified moment and return their amount.
+	// Users that were anonymised with ErasureAnonymise are kept, so references to them stay intact.
+	PurgeDeletedUsers(ctx context.Context, before time.Time) (uint64, error)
 	// Update the user's admin state to the specified state.
 	UpdateUserAdmin(ctx context.Context, id UserID, admin bool) error
 	// Update the user with the specified data.
@@ -0,0 +1,313 @@
+package core
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrInvalidWebhookURL = errors.New("invalid webhook URL")
+	// The webhook URL is valid, but refers to a local or private address
+	ErrForbiddenWebhookAddress = fmt.Errorf("%w: local or private address", ErrInvalidWebhookURL)
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+)
+
+// Headers of every webhook request.
+const (
+	// Signature of the request, see SignWebhook
+	WebhookSignatureHeader = "Apollo-Signature"
+	// Type of the event, e.g. "organisation.user_added"
+	WebhookEventHeader = "Apollo-Event"
+	// Id of the event, which stays the same when the webhook is retried or replayed
+	WebhookEventIDHeader = "Apollo-Event-Id"
+)
+
+// DefaultWebhookTolerance is how old a webhook's signature timestamp can be before VerifyWebhookSignature rejects it.
+const DefaultWebhookTolerance = 5 * time.Minute
+
+/**
+ * DOMAIN
+ */
+
+// OrganisationEvent is an event that can belong to an organisation, which makes it available to the organisation's
+// webhooks.
+type OrganisationEvent interface {
+	Event
+	// The organisation that the event belongs to, or nil if it does not belong to any organisation
+	EventOrganisation() *OrganisationID
+}
+
+type WebhookEndpointID = ID
+
+// WebhookEndpoint is a URL of an organisation that receives its events.
+type WebhookEndpoint struct {
+	ID             WebhookEndpointID
+	OrganisationID OrganisationID
+	URL            string
+	// Key that every request is signed with, only the organisation and Apollo should know it
+	Secret string
+	// The event types that are delivered, or all events of the organisation if this is empty
+	Events []EventType
+	// Disabled endpoints receive no new events and their queued deliveries wait until they are enabled again
+	Enabled   bool
+	CreatedAt time.Time
+}
+
+// Subscribes returns true if the endpoint receives events of the specified type.
+func (e *WebhookEndpoint) Subscribes(eventType EventType) bool {
+	return len(e.Events) == 0 || slices.Contains(e.Events, eventType)
+}
+
+// WebhookPayload is the body of every webhook request.
+type WebhookPayload struct {
+	ID             string          `json:"id"`
+	Type           EventType       `json:"type"`
+	OccurredAt     time.Time       `json:"occurred_at"`
+	OrganisationID OrganisationID  `json:"organisation_id"`
+	Data           json.RawMessage `json:"data"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	// The webhook has not been delivered yet, it will be (re)tried at the delivery's next attempt
+	WebhookPending WebhookDeliveryStatus = "pending"
+	// A sender is delivering the webhook, if it does not finish in time the delivery is attempted again
+	WebhookSending WebhookDeliveryStatus = "sending"
+	// The endpoint responded with a 2xx status code
+	WebhookSucceeded WebhookDeliveryStatus = "succeeded"
+	// Every attempt failed, the webhook will only be delivered again if it is replayed
+	WebhookFailed WebhookDeliveryStatus = "failed"
+)
+
+type WebhookDeliveryID = ID
+
+// WebhookDelivery is a single event that is delivered to an endpoint.
+type WebhookDelivery struct {
+	ID         WebhookDeliveryID
+	EndpointID WebhookEndpointID
+	EventID    string
+	EventType  EventType
+	Payload    json.RawMessage
+	Status     WebhookDeliveryStatus
+	// The amount of attempts since the delivery was created or last replayed
+	Attempts      int
+	NextAttemptAt *time.Time
+	// Status code of the last response, nil if the endpoint could not be reached
+	ResponseCode *int
+	LastError    *string
+	CreatedAt    time.Time
+	DeliveredAt  *time.Time
+}
+
+// WebhookAttempt records a single request of a delivery.
+type WebhookAttempt struct {
+	ID          ID
+	DeliveryID  WebhookDeliveryID
+	AttemptedAt time.Time
+	// Status code of the response, nil if the endpoint could not be reached
+	ResponseCode *int
+	Error        *string
+	Duration     time.Duration
+}
+
+// ValidateWebhookURL returns ErrInvalidWebhookURL if the URL is not an absolute https URL, or
+// ErrForbiddenWebhookAddress if its host is localhost or an IP address that is not public, see ValidateWebhookIP.
+// Host names can still resolve to any address, so webhooks should be sent with NewWebhookClient.
+func ValidateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.Join(ErrInvalidWebhookURL, err)
+	}
+	host := parsed.Hostname()
+	if parsed.Scheme != "https" || len(host) == 0 {
+		return fmt.Errorf("%w: %q is not an absolute https URL", ErrInvalidWebhookURL, rawURL)
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %q", ErrForbiddenWebhookAddress, host)
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return ValidateWebhookIP(ip)
+	}
+	return nil
+}
+
+// ValidateWebhookIP returns ErrForbiddenWebhookAddress if webhooks should not be sent to the IP address, i.e. if it is
+// not a public unicast address. This includes loopback, private and link-local addresses such as 169.254.169.254.
+func ValidateWebhookIP(ip netip.Addr) error {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return fmt.Errorf("%w: %v", ErrForbiddenWebhookAddress, ip)
+	}
+	return nil
+}
+
+// NewWebhookClient creates an HTTP client to send webhooks with, which times out after the specified duration.
+// The client only connects to addresses that pass ValidateWebhookIP, which is checked for every connection so it
+// also covers host names that resolve to a private address. It does not follow redirects or use a proxy.
+func NewWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_ string, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrForbiddenWebhookAddress, err)
+			}
+			return ValidateWebhookIP(addrPort.Addr())
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// NewWebhookSecret generates a random secret to sign webhooks with.
+func NewWebhookSecret() (string, error) {
+	secret := make([]byte, 32) //nolint:mnd // 256 bits, the size of the SHA-256 block
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("could not generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+// SignWebhook returns the signature header of a webhook with the specified payload that is sent at the specified
+// time: "t={unix timestamp},v1={signature}", where the signature is the hex encoded HMAC-SHA256 of
+// "{unix timestamp}.{payload}" with the endpoint's secret as key. Including the timestamp prevents replay attacks.
+func SignWebhook(secret string, timestamp time.Time, payload []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", unix, webhookSignature(secret, unix, payload))
+}
+
+func webhookSignature(secret string, unix string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature returns ErrInvalidWebhookSignature unless the signature header was created by SignWebhook
+// for the payload with the secret, less than tolerance ago.
+func VerifyWebhookSignature(
+	secret string,
+	header string,
+	payload []byte,
+	tolerance time.Duration,
+) error {
+	var unix string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			unix = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	timestamp, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing timestamp", ErrInvalidWebhookSignature)
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp is outside of the tolerance", ErrInvalidWebhookSignature)
+	}
+	expected := webhookSignature(secret, unix, payload)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no matching signature", ErrInvalidWebhookSignature)
+}
+
+/**
+ * APPLICATION
+ */
+
+type WebhookEndpointCreate struct {
+	URL string
+	// The event types that are delivered, leave empty to deliver all events of the organisation
+	Events []EventType
+	// Key to sign the requests with, a random secret is generated if this is empty
+	Secret string
+}
+
+type WebhookEndpointUpdate struct {
+	URL     *string
+	Events  *[]EventType
+	Enabled *bool
+}
+
+type WebhookService interface {
+	// Register a new, enabled endpoint for an organisation.
+	// This returns ErrInvalidWebhookURL if the URL is not an absolute https URL, or ErrForbiddenWebhookAddress if it
+	// points to localhost or a private address.
+	CreateWebhookEndpoint(
+		ctx context.Context,
+		OrgID OrganisationID,
+		create WebhookEndpointCreate,
+	) (*WebhookEndpoint, error)
+	// Retrieve an endpoint of an organisation or ErrNotFound if the organisation has no such endpoint.
+	GetWebhookEndpoint(
+		ctx context.Context,
+		OrgID OrganisationID,
+		id WebhookEndpointID,
+	) (*WebhookEndpoint, error)
+	// List all endpoints of an organisation.
+	ListWebhookEndpoints(ctx context.Context, OrgID OrganisationID) ([]WebhookEndpoint, error)
+	// Update an endpoint of an organisation and return the result. A new URL is validated like in
+	// CreateWebhookEndpoint.
+	UpdateWebhookEndpoint(
+		ctx context.Context,
+		OrgID OrganisationID,
+		id WebhookEndpointID,
+		update WebhookEndpointUpdate,
+	) (*WebhookEndpoint, error)
+	// Delete an endpoint of an organisation together with its deliveries.
+	DeleteWebhookEndpoint(ctx context.Context, OrgID OrganisationID, id WebhookEndpointID) error
+	// Retrieve a page of the deliveries of an endpoint.
+	// Sort fields: "time" (default) and "id". Filters: "status" and "event_type".
+	ListWebhookDeliveries(
+		ctx context.Context,
+		OrgID OrganisationID,
+		id WebhookEndpointID,
+		query ListQuery,
+	) (*Page[WebhookDelivery], error)
+	// List every attempt of a delivery, oldest first.
+	ListWebhookAttempts(
+		ctx context.Context,
+		OrgID OrganisationID,
+		id WebhookDeliveryID,
+	) ([]WebhookAttempt, error)
+	// Deliver a webhook again as soon as possible with the same payload, regardless of its status.
+	// Its attempts start over, but the attempts that were made before are kept.
+	ReplayWebhookDelivery(
+		ctx context.Context,
+		OrgID OrganisationID,
+		id WebhookDeliveryID,
+	) (*WebhookDelivery, error)
+}
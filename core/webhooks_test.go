@@ -0,0 +1,119 @@
+package core_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	payload := []byte(`{"id":"1","type":"organisation.user_added"}`)
+	tolerance := core.DefaultWebhookTolerance
+
+	t.Run("ok: verify signature", func(t *testing.T) {
+		header := core.SignWebhook("secret", time.Now(), payload)
+		assert.Regexp(t, `^t=\d+,v1=[0-9a-f]{64}$`, header)
+		assert.Nil(t, core.VerifyWebhookSignature("secret", header, payload, tolerance))
+	})
+
+	t.Run("ok: any of multiple signatures", func(t *testing.T) {
+		header := core.SignWebhook("secret", time.Now(), payload) + ",v1=0123"
+		assert.Nil(t, core.VerifyWebhookSignature("secret", header, payload, tolerance))
+	})
+
+	t.Run("err: wrong secret", func(t *testing.T) {
+		header := core.SignWebhook("secret", time.Now(), payload)
+		err := core.VerifyWebhookSignature("other", header, payload, tolerance)
+		assert.ErrorIs(t, err, core.ErrInvalidWebhookSignature)
+	})
+
+	t.Run("err: modified payload", func(t *testing.T) {
+		header := core.SignWebhook("secret", time.Now(), payload)
+		err := core.VerifyWebhookSignature("secret", header, []byte(`{}`), tolerance)
+		assert.ErrorIs(t, err, core.ErrInvalidWebhookSignature)
+	})
+
+	t.Run("err: expired timestamp", func(t *testing.T) {
+		header := core.SignWebhook("secret", time.Now().Add(-2*tolerance), payload)
+		err := core.VerifyWebhookSignature("secret", header, payload, tolerance)
+		assert.ErrorIs(t, err, core.ErrInvalidWebhookSignature)
+	})
+
+	t.Run("err: missing timestamp", func(t *testing.T) {
+		err := core.VerifyWebhookSignature("secret", "v1=0123", payload, tolerance)
+		assert.ErrorIs(t, err, core.ErrInvalidWebhookSignature)
+	})
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	assert.Nil(t, core.ValidateWebhookURL("https://example.com/webhooks"))
+	assert.Nil(t, core.ValidateWebhookURL("https://93.184.215.14:8443"))
+	invalid := []string{
+		"", "/webhooks", "ftp://example.com", "https://", "://example.com", "http://example.com",
+	}
+	for _, url := range invalid {
+		assert.ErrorIs(t, core.ValidateWebhookURL(url), core.ErrInvalidWebhookURL, url)
+	}
+	forbidden := []string{
+		"https://localhost:8080",
+		"https://api.localhost",
+		"https://127.0.0.1",
+		"https://10.0.0.1/webhooks",
+		"https://192.168.1.1",
+		"https://169.254.169.254/latest/meta-data",
+		"https://[::1]:8443",
+		"https://[fd00::1]",
+		"https://[::ffff:127.0.0.1]",
+		"https://0.0.0.0",
+	}
+	for _, url := range forbidden {
+		assert.ErrorIs(t, core.ValidateWebhookURL(url), core.ErrForbiddenWebhookAddress, url)
+	}
+}
+
+func TestWebhookClient(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Redirect(w, r, "/other", http.StatusFound)
+	}))
+	defer server.Close()
+
+	t.Run("err: refuse local addresses", func(t *testing.T) {
+		client := core.NewWebhookClient(time.Second)
+		// Host names are checked after they are resolved
+		resolved := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+		for _, url := range []string{server.URL, resolved} {
+			_, err := client.Post(url, "application/json", strings.NewReader("{}"))
+			assert.ErrorIs(t, err, core.ErrForbiddenWebhookAddress, url)
+		}
+		assert.Zero(t, requests.Load())
+	})
+
+	t.Run("ok: do not follow redirects", func(t *testing.T) {
+		client := core.NewWebhookClient(time.Second)
+		// Allow the local test server, while keeping the client's redirect policy
+		client.Transport = http.DefaultTransport
+		response, err := client.Post(server.URL, "application/json", strings.NewReader("{}"))
+		if assert.Nil(t, err) {
+			response.Body.Close()
+			assert.Equal(t, http.StatusFound, response.StatusCode)
+		}
+		assert.Equal(t, int32(1), requests.Load())
+	})
+}
+
+func TestNewWebhookSecret(t *testing.T) {
+	first, err := core.NewWebhookSecret()
+	assert.Nil(t, err)
+	second, err := core.NewWebhookSecret()
+	assert.Nil(t, err)
+	assert.Regexp(t, `^whsec_[0-9a-f]{64}$`, first)
+	assert.NotEqual(t, first, second)
+}
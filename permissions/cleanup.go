@@ -0,0 +1,28 @@
+package permissions
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/prior-it/apollo/core"
+)
+
+// CleanupExpiredMembershipsTask returns a task that deletes all expired permission group memberships.
+// Expired memberships are already ignored by all permission checks, so this only keeps the tables small.
+//
+// # Example
+//
+//	db.Schedule("permissions.cleanup", "@hourly", permissions.CleanupExpiredMembershipsTask(service))
+func CleanupExpiredMembershipsTask(service Service) core.CronTask {
+	return func(ctx context.Context) error {
+		deleted, err := service.DeleteExpiredMemberships(ctx)
+		if err != nil {
+			return fmt.Errorf("could not delete expired permission group memberships: %w", err)
+		}
+		if deleted > 0 {
+			slog.Debug("Deleted expired permission group memberships", "amount", deleted)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,60 @@
+package permissions
+
+import "github.com/prior-it/apollo/core"
+
+// ExplanationSource describes what kind of rule an ExplanationStep consulted.
+type ExplanationSource string
+
+const (
+	// The user's admin flag, which grants every permission
+	SourceAdmin ExplanationSource = "admin"
+	// A global permission group the user belongs to
+	SourceGlobalGroup ExplanationSource = "global_group"
+	// A permission group the user belongs to within an organisation
+	SourceOrganisationGroup ExplanationSource = "organisation_group"
+)
+
+// ExplanationStep is a single rule that was consulted while checking a permission.
+type ExplanationStep struct {
+	Source ExplanationSource `json:"source"`
+	// The permission group that was consulted, nil for SourceAdmin
+	Group *PermissionGroup `json:"group,omitempty"`
+	// The organisation in which the user belongs to Group. This is either the requested organisation or one of its
+	// ancestors, nil for global groups and the admin flag.
+	OrganisationID *core.OrganisationID `json:"organisation_id,omitempty"`
+	// The number of levels between OrganisationID and the requested organisation: 0 for the organisation itself,
+	// 1 for its parent, and so on.
+	Depth int `json:"depth"`
+	// Whether or not this rule grants the permission
+	Granted bool `json:"granted"`
+}
+
+// Explanation lists every rule that was consulted while checking whether a user has a permission, in the order in
+// which they were consulted.
+type Explanation struct {
+	UserID     core.UserID `json:"user_id"`
+	Permission Permission  `json:"permission"`
+	// The organisation the permission was checked in, nil if only global permissions were checked
+	OrganisationID *core.OrganisationID `json:"organisation_id,omitempty"`
+	// Whether or not the user has the permission
+	Granted bool              `json:"granted"`
+	Steps   []ExplanationStep `json:"steps"`
+}
+
+// GrantedBy returns all steps that grant the permission.
+// If this is empty, the permission is denied because none of the steps enable it.
+func (e *Explanation) GrantedBy() []ExplanationStep {
+	var granted []ExplanationStep
+	for _, step := range e.Steps {
+		if step.Granted {
+			granted = append(granted, step)
+		}
+	}
+	return granted
+}
+
+// AddStep appends a step to the explanation and updates whether or not the permission is granted.
+func (e *Explanation) AddStep(step ExplanationStep) {
+	e.Steps = append(e.Steps, step)
+	e.Granted = e.Granted || step.Granted
+}
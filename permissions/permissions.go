@@ -1,8 +1,10 @@
 package permissions
 
 import (
+	"errors"
 	"log/slog"
 	"maps"
+	"time"
 
 	"github.com/prior-it/apollo/core"
 )
@@ -17,10 +19,71 @@ func (p Permission) String() string {
 
 type PermissionGroupID = core.ID
 
+// ResourceType identifies the kind of application object a resource-level permission applies to, e.g. "project".
+type ResourceType string
+
+func (r ResourceType) String() string {
+	return string(r)
+}
+
+// Resource is a typed reference to a single application object, e.g. project 42.
+type Resource struct {
+	Type ResourceType
+	ID   core.ID
+}
+
 type PermissionGroup struct {
 	ID          PermissionGroupID
 	Name        string
 	Permissions map[Permission]bool
+	// The organisation that owns this group. Groups without an owner are global and can be used by every
+	// organisation, owned groups can only be used within their owning organisation.
+	OrganisationID *core.OrganisationID
+}
+
+// PermissionGroupMember is a user that belongs to a permission group, either globally or within an organisation.
+type PermissionGroupMember struct {
+	User core.User
+	// The organisation in which the user belongs to the group, or nil if this is a global membership
+	OrganisationID *core.OrganisationID
+	// The window in which the membership is active
+	Validity Validity
+}
+
+var ErrInvalidValidity = errors.New("a membership cannot end before it starts")
+
+// Validity is the time window in which a permission group membership is active.
+// A nil bound means the window is unbounded on that side, so the zero value is always active.
+type Validity struct {
+	From  *time.Time
+	Until *time.Time
+}
+
+// IsActive returns true if the window contains the specified time.
+// From is inclusive, Until is exclusive.
+func (v Validity) IsActive(at time.Time) bool {
+	if v.From != nil && at.Before(*v.From) {
+		return false
+	}
+	return v.Until == nil || at.Before(*v.Until)
+}
+
+// Validate returns ErrInvalidValidity if the window ends before it starts.
+func (v Validity) Validate() error {
+	if v.From != nil && v.Until != nil && !v.From.Before(*v.Until) {
+		return ErrInvalidValidity
+	}
+	return nil
+}
+
+// IsGlobal returns true if this group is not owned by any organisation.
+func (pg *PermissionGroup) IsGlobal() bool {
+	return pg.OrganisationID == nil
+}
+
+// IsOwnedBy returns true if this group is owned by the specified organisation.
+func (pg *PermissionGroup) IsOwnedBy(orgID core.OrganisationID) bool {
+	return pg.OrganisationID != nil && *pg.OrganisationID == orgID
 }
 
 func (pg *PermissionGroup) Get(permission Permission) bool {
@@ -33,8 +96,9 @@ func (pg *PermissionGroup) Get(permission Permission) bool {
 
 func (pg *PermissionGroup) Clone() *PermissionGroup {
 	return &PermissionGroup{
-		ID:          0,
-		Name:        pg.Name + " (clone)",
-		Permissions: maps.Clone(pg.Permissions),
+		ID:             0,
+		Name:           pg.Name + " (clone)",
+		Permissions:    maps.Clone(pg.Permissions),
+		OrganisationID: pg.OrganisationID,
 	}
 }
@@ -0,0 +1,40 @@
+package permissions_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prior-it/apollo/permissions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidity(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	t.Run("ok: active windows", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, permissions.Validity{}.IsActive(now), "Unbounded windows are always active")
+		assert.True(t, permissions.Validity{From: &past}.IsActive(now))
+		assert.True(t, permissions.Validity{Until: &future}.IsActive(now))
+		assert.True(t, permissions.Validity{From: &now, Until: &future}.IsActive(now))
+	})
+
+	t.Run("ok: inactive windows", func(t *testing.T) {
+		t.Parallel()
+		assert.False(t, permissions.Validity{From: &future}.IsActive(now))
+		assert.False(t, permissions.Validity{Until: &past}.IsActive(now))
+		assert.False(t, permissions.Validity{Until: &now}.IsActive(now), "Until is exclusive")
+	})
+
+	t.Run("err: window ends before it starts", func(t *testing.T) {
+		t.Parallel()
+		assert.Nil(t, permissions.Validity{From: &past, Until: &future}.Validate())
+		assert.ErrorIs(
+			t,
+			permissions.Validity{From: &future, Until: &past}.Validate(),
+			permissions.ErrInvalidValidity,
+		)
+	})
+}
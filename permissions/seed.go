@@ -0,0 +1,335 @@
+package permissions
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/prior-it/apollo/core"
+	"gopkg.in/yaml.v3"
+)
+
+// DefinitionFormat is the file format of a set of permission group definitions.
+type DefinitionFormat string
+
+const (
+	FormatTOML DefinitionFormat = "toml"
+	FormatYAML DefinitionFormat = "yaml"
+)
+
+// GroupDefinition declares a global permission group with a fixed id.
+// Only the listed permissions are enabled, all other permissions are disabled.
+//
+// # Example
+//
+//	[[groups]]
+//	id = 1
+//	name = "Admin"
+//	permissions = ["view_all_users", "edit_all_users"]
+type GroupDefinition struct {
+	ID          PermissionGroupID `toml:"id"          yaml:"id"`
+	Name        string            `toml:"name"        yaml:"name"`
+	Permissions []Permission      `toml:"permissions" yaml:"permissions"`
+}
+
+type groupDefinitionFile struct {
+	Groups []GroupDefinition `toml:"groups" yaml:"groups"`
+}
+
+// GroupDrift describes how a permission group in the database differs from its definition.
+type GroupDrift struct {
+	ID PermissionGroupID
+	// The name in the database, if it differs from the definition
+	Name *string
+	// Permissions that are enabled in the definition but not in the database
+	Missing []Permission
+	// Permissions that are enabled in the database but not in the definition
+	Extra []Permission
+}
+
+// SeedReport lists all differences that were found between the definitions and the database.
+type SeedReport struct {
+	// Groups that did not exist yet
+	Created []PermissionGroupID
+	// Groups that existed but did not match their definition
+	Drifted []GroupDrift
+	// Global groups that exist in the database but have no definition. These are never changed.
+	Unmanaged []PermissionGroupID
+}
+
+// HasDrift returns true if the database did not match the definitions.
+func (r *SeedReport) HasDrift() bool {
+	return len(r.Created) > 0 || len(r.Drifted) > 0
+}
+
+// DetectDefinitionFormat returns the definition format that matches the extension of the specified file.
+func DetectDefinitionFormat(file string) (DefinitionFormat, error) {
+	switch path.Ext(file) {
+	case ".toml":
+		return FormatTOML, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	}
+	return "", fmt.Errorf("unknown permission group definition format for %q", file)
+}
+
+// LoadGroupDefinitions reads permission group definitions from the specified file in the filesystem, e.g. the
+// application's config FS. The format is based on the file's extension.
+func LoadGroupDefinitions(fsys fs.FS, file string) ([]GroupDefinition, error) {
+	format, err := DetectDefinitionFormat(file)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read permission group definitions: %w", err)
+	}
+	return ParseGroupDefinitions(data, format)
+}
+
+// ParseGroupDefinitions parses permission group definitions in the specified format.
+// Every definition needs a unique, positive id.
+func ParseGroupDefinitions(data []byte, format DefinitionFormat) ([]GroupDefinition, error) {
+	var file groupDefinitionFile
+	var err error
+	switch format {
+	case FormatTOML:
+		err = toml.Unmarshal(data, &file)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &file)
+	default:
+		return nil, fmt.Errorf("unknown permission group definition format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse permission group definitions: %w", err)
+	}
+
+	ids := make(map[PermissionGroupID]bool)
+	for _, def := range file.Groups {
+		if def.ID <= 0 {
+			return nil, fmt.Errorf("permission group %q needs a positive id", def.Name)
+		}
+		if ids[def.ID] {
+			return nil, fmt.Errorf("permission group id %v is defined more than once", def.ID)
+		}
+		ids[def.ID] = true
+	}
+	return file.Groups, nil
+}
+
+// MarshalGroupDefinitions encodes permission group definitions in the specified format, so they can be loaded
+// with [ParseGroupDefinitions].
+func MarshalGroupDefinitions(defs []GroupDefinition, format DefinitionFormat) ([]byte, error) {
+	file := groupDefinitionFile{Groups: defs}
+	switch format {
+	case FormatTOML:
+		return toml.Marshal(file)
+	case FormatYAML:
+		return yaml.Marshal(file)
+	}
+	return nil, fmt.Errorf("unknown permission group definition format %q", format)
+}
+
+// ExportGroupDefinitions returns the definitions of all global permission groups that currently exist, sorted by id.
+// Groups that are owned by an organisation are not exported.
+func ExportGroupDefinitions(ctx context.Context, service Service) ([]GroupDefinition, error) {
+	groups, err := service.ListPermissionGroups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list permission groups: %w", err)
+	}
+	defs := make([]GroupDefinition, 0, len(groups))
+	for _, group := range groups {
+		if !group.IsGlobal() {
+			continue
+		}
+		defs = append(defs, GroupDefinition{
+			ID:          group.ID,
+			Name:        group.Name,
+			Permissions: enabledPermissions(group.Permissions),
+		})
+	}
+	slices.SortFunc(defs, func(a, b GroupDefinition) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return defs, nil
+}
+
+// CheckGroupDefinitions compares the definitions with the permission groups in the database without changing
+// anything.
+func CheckGroupDefinitions(
+	ctx context.Context,
+	service Service,
+	defs []GroupDefinition,
+) (*SeedReport, error) {
+	return seedGroupDefinitions(ctx, service, defs, false)
+}
+
+// SeedGroupDefinitions creates or updates all defined permission groups so they match their definition.
+// This is idempotent, running it multiple times with the same definitions will only change the database once.
+// Permissions that are used in the definitions are registered first.
+// Groups that are not defined are left untouched and listed as unmanaged in the report.
+func SeedGroupDefinitions(
+	ctx context.Context,
+	service Service,
+	defs []GroupDefinition,
+) (*SeedReport, error) {
+	return seedGroupDefinitions(ctx, service, defs, true)
+}
+
+func seedGroupDefinitions(
+	ctx context.Context,
+	service Service,
+	defs []GroupDefinition,
+	apply bool,
+) (*SeedReport, error) {
+	report := &SeedReport{}
+	if apply {
+		for _, def := range defs {
+			for _, permission := range def.Permissions {
+				if err := service.RegisterPermission(ctx, permission); err != nil {
+					return nil, fmt.Errorf("could not register permission %q: %w", permission, err)
+				}
+			}
+		}
+	}
+
+	defined := make(map[PermissionGroupID]bool)
+	for _, def := range defs {
+		defined[def.ID] = true
+		group, err := service.GetPermissionGroup(ctx, def.ID)
+		if errors.Is(err, core.ErrNotFound) {
+			report.Created = append(report.Created, def.ID)
+			if apply {
+				if err := createDefinedGroup(ctx, service, def); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("could not retrieve permission group %v: %w", def.ID, err)
+		}
+
+		if !group.IsGlobal() {
+			return nil, fmt.Errorf(
+				"permission group %v is owned by organisation %v and cannot be seeded: %w",
+				def.ID,
+				*group.OrganisationID,
+				core.ErrConflict,
+			)
+		}
+		drift := compareDefinedGroup(def, group)
+		if drift == nil {
+			continue
+		}
+		report.Drifted = append(report.Drifted, *drift)
+		if apply {
+			if err := updateDefinedGroup(ctx, service, def, group, drift); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	groups, err := service.ListPermissionGroups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list permission groups: %w", err)
+	}
+	for _, group := range groups {
+		if group.IsGlobal() && !defined[group.ID] {
+			report.Unmanaged = append(report.Unmanaged, group.ID)
+		}
+	}
+	slices.Sort(report.Unmanaged)
+	return report, nil
+}
+
+func createDefinedGroup(ctx context.Context, service Service, def GroupDefinition) error {
+	perms := make(map[Permission]bool, len(def.Permissions))
+	for _, permission := range def.Permissions {
+		perms[permission] = true
+	}
+	_, err := service.CreatePermissionGroup(ctx, &PermissionGroup{
+		ID:          def.ID,
+		Name:        def.Name,
+		Permissions: perms,
+	})
+	if err != nil {
+		return fmt.Errorf("could not create permission group %v: %w", def.ID, err)
+	}
+	return nil
+}
+
+func updateDefinedGroup(
+	ctx context.Context,
+	service Service,
+	def GroupDefinition,
+	group *PermissionGroup,
+	drift *GroupDrift,
+) error {
+	if drift.Name != nil {
+		if err := service.RenamePermissionGroup(ctx, def.ID, def.Name); err != nil {
+			return fmt.Errorf("could not rename permission group %v: %w", def.ID, err)
+		}
+	}
+	if len(drift.Missing) == 0 && len(drift.Extra) == 0 {
+		return nil
+	}
+	perms := make(map[Permission]bool, len(drift.Missing)+len(drift.Extra))
+	for _, permission := range drift.Missing {
+		perms[permission] = true
+	}
+	for _, permission := range drift.Extra {
+		perms[permission] = false
+	}
+	err := service.UpdatePermissionGroup(ctx, &PermissionGroup{
+		ID:             group.ID,
+		Name:           def.Name,
+		Permissions:    perms,
+		OrganisationID: group.OrganisationID,
+	})
+	if err != nil {
+		return fmt.Errorf("could not update permission group %v: %w", def.ID, err)
+	}
+	return nil
+}
+
+// compareDefinedGroup returns nil if the group matches its definition.
+func compareDefinedGroup(def GroupDefinition, group *PermissionGroup) *GroupDrift {
+	drift := GroupDrift{ID: def.ID}
+	if group.Name != def.Name {
+		name := group.Name
+		drift.Name = &name
+	}
+	wanted := make(map[Permission]bool, len(def.Permissions))
+	for _, permission := range def.Permissions {
+		wanted[permission] = true
+		if !group.Permissions[permission] {
+			drift.Missing = append(drift.Missing, permission)
+		}
+	}
+	for _, permission := range enabledPermissions(group.Permissions) {
+		if !wanted[permission] {
+			drift.Extra = append(drift.Extra, permission)
+		}
+	}
+	if drift.Name == nil && len(drift.Missing) == 0 && len(drift.Extra) == 0 {
+		return nil
+	}
+	return &drift
+}
+
+// enabledPermissions returns all enabled permissions in sorted order.
+func enabledPermissions(perms map[Permission]bool) []Permission {
+	enabled := make([]Permission, 0, len(perms))
+	for _, permission := range slices.Sorted(maps.Keys(perms)) {
+		if perms[permission] {
+			enabled = append(enabled, permission)
+		}
+	}
+	return enabled
+}
@@ -0,0 +1,67 @@
+package permissions_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/prior-it/apollo/permissions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupDefinitions(t *testing.T) {
+	expected := []permissions.GroupDefinition{
+		{
+			ID:          1,
+			Name:        "Admin",
+			Permissions: []permissions.Permission{"view_all_users", "edit_all_users"},
+		},
+		{
+			ID:          2,
+			Name:        "Viewer",
+			Permissions: []permissions.Permission{"view_own_user"},
+		},
+	}
+
+	t.Run("ok: load toml and yaml", func(t *testing.T) {
+		t.Parallel()
+		for _, file := range []string{"groups.toml", "groups.yaml"} {
+			defs, err := permissions.LoadGroupDefinitions(os.DirFS("testdata"), file)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, defs, file)
+		}
+	})
+
+	t.Run("ok: marshalled definitions can be parsed again", func(t *testing.T) {
+		t.Parallel()
+		for _, format := range []permissions.DefinitionFormat{
+			permissions.FormatTOML,
+			permissions.FormatYAML,
+		} {
+			data, err := permissions.MarshalGroupDefinitions(expected, format)
+			assert.Nil(t, err)
+			defs, err := permissions.ParseGroupDefinitions(data, format)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, defs, format)
+		}
+	})
+
+	t.Run("err: unknown format", func(t *testing.T) {
+		t.Parallel()
+		_, err := permissions.LoadGroupDefinitions(os.DirFS("testdata"), "groups.json")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("err: invalid ids", func(t *testing.T) {
+		t.Parallel()
+		_, err := permissions.ParseGroupDefinitions(
+			[]byte("[[groups]]\nname = \"No id\""),
+			permissions.FormatTOML,
+		)
+		assert.NotNil(t, err, "Definitions need an id")
+		_, err = permissions.ParseGroupDefinitions(
+			[]byte("[[groups]]\nid = 1\n[[groups]]\nid = 1"),
+			permissions.FormatTOML,
+		)
+		assert.NotNil(t, err, "Ids need to be unique")
+	})
+}
@@ -14,16 +14,50 @@ type Service interface {
 	// Return a permission group by its ID.
 	// If the group does not exist, this returns core.ErrNotFound
 	GetPermissionGroup(ctx context.Context, id PermissionGroupID) (*PermissionGroup, error)
-	// Update a permission group
+	// Update a permission group. This does not change the group's owner.
 	UpdatePermissionGroup(ctx context.Context, group *PermissionGroup) error
+	// Update a permission group that is owned by the specified organisation.
+	// If the group does not exist or is owned by someone else, this returns core.ErrNotFound
+	UpdatePermissionGroupForOrganisation(
+		ctx context.Context,
+		orgID core.OrganisationID,
+		group *PermissionGroup,
+	) error
 	// Delete a permission group
 	DeletePermissionGroup(ctx context.Context, id PermissionGroupID) error
+	// Delete a permission group that is owned by the specified organisation.
+	// If the group does not exist or is owned by someone else, this returns core.ErrNotFound
+	DeletePermissionGroupForOrganisation(
+		ctx context.Context,
+		orgID core.OrganisationID,
+		id PermissionGroupID,
+	) error
 	// Create a new permission group. If no ID was provided, the returned permissiongroup will contain the generated id
 	// If an ID was provided as input, the permission group will have that ID. If another group with the same
 	// id already exists, this will return core.ErrConflict.
+	// If the group has an OrganisationID, it will be owned by that organisation, otherwise it will be global.
 	CreatePermissionGroup(ctx context.Context, group *PermissionGroup) (*PermissionGroup, error)
 	// Rename the specified permission group
 	RenamePermissionGroup(ctx context.Context, id PermissionGroupID, name string) error
+	// Rename a permission group that is owned by the specified organisation.
+	// If the group does not exist or is owned by someone else, this returns core.ErrNotFound
+	RenamePermissionGroupForOrganisation(
+		ctx context.Context,
+		orgID core.OrganisationID,
+		id PermissionGroupID,
+		name string,
+	) error
+	// Explain why the specified user does or does not have the specified permission.
+	// This lists the user's admin flag and every global permission group of the user. If an organisation is
+	// specified, it also lists the user's permission groups in that organisation and all of its ancestors, which
+	// mirrors the checks done by HasAny and HasAnyForOrgTree.
+	// If the user does not exist, this returns core.ErrNotFound
+	Explain(
+		ctx context.Context,
+		userID core.UserID,
+		permission Permission,
+		orgID *core.OrganisationID,
+	) (*Explanation, error)
 	// Returns whether or not the specified user has the specified permission in any of its permission groups.
 	HasAny(ctx context.Context, userID core.UserID, permission Permission) (bool, error)
 	// Returns whether or not the specified user has the specified permission in any of its permission groups for the
@@ -42,16 +76,52 @@ type Service interface {
 		orgID core.OrganisationID,
 		permission Permission,
 	) (bool, error)
-	// Lists all permission groups in the system
+	// Lists all permission groups in the system, regardless of their owner
 	ListPermissionGroups(ctx context.Context) ([]PermissionGroup, error)
+	// Lists all permission groups the specified organisation can use: the groups it owns and all global groups
+	ListPermissionGroupsForOrganisation(
+		ctx context.Context,
+		orgID core.OrganisationID,
+	) ([]PermissionGroup, error)
 	// Lists all permission groups for the specified user
 	ListPermissionGroupsForUser(ctx context.Context, userID core.UserID) ([]PermissionGroup, error)
-	// Add an existing user to an existing permission group
+	// Add an existing user to an existing, global permission group.
+	// If the group is owned by an organisation, this returns core.ErrForbidden
 	AddUserToPermissionGroup(
 		ctx context.Context,
 		userID core.UserID,
 		groupID PermissionGroupID,
 	) error
+	// Add an existing user to an existing, global permission group for a limited time. Outside of the validity
+	// window the membership is ignored by all permission checks. If the user already belongs to the group, the
+	// window of the existing membership is replaced.
+	// If the group is owned by an organisation, this returns core.ErrForbidden
+	AddUserToPermissionGroupWithValidity(
+		ctx context.Context,
+		userID core.UserID,
+		groupID PermissionGroupID,
+		validity Validity,
+	) error
+	// Remove a user from a global permission group. Removing a user that is not a member does nothing.
+	RemoveUserFromPermissionGroup(
+		ctx context.Context,
+		userID core.UserID,
+		groupID PermissionGroupID,
+	) error
+	// Replace all global permission groups of the specified user with the specified groups in a single transaction.
+	// If any of the groups is owned by an organisation, this returns core.ErrForbidden and nothing is changed.
+	SetUserPermissionGroups(
+		ctx context.Context,
+		userID core.UserID,
+		groupIDs []PermissionGroupID,
+	) error
+	// Lists all members of the specified permission group, both global members and members within an organisation.
+	// Users that belong to the group in multiple organisations will be listed once for each organisation.
+	// Memberships outside of their validity window are listed as well.
+	ListUsersInPermissionGroup(
+		ctx context.Context,
+		groupID PermissionGroupID,
+	) ([]PermissionGroupMember, error)
 	// Return the combined permissions for the specified user.
 	// If a user has multiple permission groups, the combined permission group will contain all permissions that are
 	// enabled in at least one of their permission groups.
@@ -65,13 +135,45 @@ type Service interface {
 		userID core.UserID,
 		orgID core.OrganisationID,
 	) ([]PermissionGroup, error)
-	// Add an existing user to an existing permission group in the specified organisation
+	// Add an existing user to an existing permission group in the specified organisation.
+	// If the group is owned by a different organisation, this returns core.ErrForbidden
 	AddUserToPermissionGroupForOrganisation(
 		ctx context.Context,
 		userID core.UserID,
 		orgID core.OrganisationID,
 		groupID PermissionGroupID,
 	) error
+	// Add an existing user to an existing permission group in the specified organisation for a limited time.
+	// Outside of the validity window the membership is ignored by all permission checks. If the user already
+	// belongs to the group in that organisation, the window of the existing membership is replaced.
+	// If the group is owned by a different organisation, this returns core.ErrForbidden
+	AddUserToPermissionGroupForOrganisationWithValidity(
+		ctx context.Context,
+		userID core.UserID,
+		orgID core.OrganisationID,
+		groupID PermissionGroupID,
+		validity Validity,
+	) error
+	// Delete all permission group memberships, both global and within organisations, whose validity window has
+	// ended. This returns the amount of deleted memberships.
+	DeleteExpiredMemberships(ctx context.Context) (int64, error)
+	// Remove a user from a permission group in the specified organisation. Removing a user that is not a member does
+	// nothing.
+	RemoveUserFromPermissionGroupForOrganisation(
+		ctx context.Context,
+		userID core.UserID,
+		orgID core.OrganisationID,
+		groupID PermissionGroupID,
+	) error
+	// Replace all permission groups of the specified user in the specified organisation with the specified groups in
+	// a single transaction.
+	// If any of the groups is owned by a different organisation, this returns core.ErrForbidden and nothing is changed.
+	SetUserPermissionGroupsForOrganisation(
+		ctx context.Context,
+		userID core.UserID,
+		orgID core.OrganisationID,
+		groupIDs []PermissionGroupID,
+	) error
 	// Return the combined permissions for the specified user in the specified organisation.
 	// If a user has multiple permission groups, the combined permission group will contain all permissions that are
 	// enabled in at least one of their permission groups.
@@ -80,4 +182,64 @@ type Service interface {
 		userID core.UserID,
 		orgID core.OrganisationID,
 	) (map[Permission]bool, error)
+	// Grant the specified permission on a single resource to the specified user.
+	// Granting a permission that the user already has on that resource does nothing.
+	GrantUserPermissionOn(
+		ctx context.Context,
+		userID core.UserID,
+		permission Permission,
+		resource Resource,
+	) error
+	// Revoke the specified permission on a single resource from the specified user.
+	// This only revokes direct grants, grants through permission groups are left untouched.
+	RevokeUserPermissionOn(
+		ctx context.Context,
+		userID core.UserID,
+		permission Permission,
+		resource Resource,
+	) error
+	// Grant the specified permission on a single resource to all members of the specified permission group.
+	// Granting a permission that the group already has on that resource does nothing.
+	GrantGroupPermissionOn(
+		ctx context.Context,
+		groupID PermissionGroupID,
+		permission Permission,
+		resource Resource,
+	) error
+	// Revoke the specified permission on a single resource from the specified permission group.
+	RevokeGroupPermissionOn(
+		ctx context.Context,
+		groupID PermissionGroupID,
+		permission Permission,
+		resource Resource,
+	) error
+	// Returns whether or not the specified user has the specified permission on the specified resource, either
+	// directly or through any of their permission groups.
+	HasAnyOn(
+		ctx context.Context,
+		userID core.UserID,
+		permission Permission,
+		resource Resource,
+	) (bool, error)
+	// Lists the ids of all resources of the specified type on which the specified user has the specified permission,
+	// either directly or through any of their permission groups.
+	ListResourceIDsForUser(
+		ctx context.Context,
+		userID core.UserID,
+		permission Permission,
+		resourceType ResourceType,
+	) ([]core.ID, error)
+	// Replace the default permission groups of the specified organisation. Users that are added to the organisation
+	// afterwards will automatically be added to these groups within the organisation.
+	// If any of the groups is owned by a different organisation, this returns core.ErrForbidden and nothing is changed.
+	SetDefaultPermissionGroupsForOrganisation(
+		ctx context.Context,
+		orgID core.OrganisationID,
+		groupIDs []PermissionGroupID,
+	) error
+	// Lists the default permission groups of the specified organisation
+	ListDefaultPermissionGroupsForOrganisation(
+		ctx context.Context,
+		orgID core.OrganisationID,
+	) ([]PermissionGroup, error)
 }
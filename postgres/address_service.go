@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/prior-it/apollo/core"
 	"github.com/prior-it/apollo/postgres/internal/sqlc"
 )
@@ -35,24 +36,51 @@ func (a *AddressService) CreateAddressTx(
 	dbtx sqlc.DBTX,
 	addressCreate core.Address,
 ) (*core.Address, error) {
-	q := sqlc.New(dbtx)
-	address, err := q.CreateAddress(ctx, sqlc.CreateAddressParams{
-		Street:     addressCreate.Street,
-		Number:     addressCreate.Number,
-		PostalCode: addressCreate.PostalCode,
-		City:       addressCreate.City,
-		Country:    addressCreate.Country,
-		ExtraLine:  addressCreate.ExtraLine,
+	var created *core.Address
+	err := runInTx(ctx, dbtx, func(tx pgx.Tx) error {
+		address, err := sqlc.New(tx).CreateAddress(ctx, sqlc.CreateAddressParams{
+			Street:     addressCreate.Street,
+			Number:     addressCreate.Number,
+			PostalCode: addressCreate.PostalCode,
+			City:       addressCreate.City,
+			Country:    addressCreate.Country,
+			ExtraLine:  addressCreate.ExtraLine,
+		})
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		created, err = convertAddress(address)
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, core.AuditRecord{
+			Action:     core.AuditCreate,
+			EntityType: core.AuditAddress,
+			EntityID:   created.ID.String(),
+			After:      created,
+		})
 	})
 	if err != nil {
-		return nil, ConvertPgError(err)
+		return nil, err
 	}
-	return convertAddress(address)
+	return created, nil
 }
 
 // DeleteAddress implements core.AddressService.DeleteAddress
 func (a *AddressService) DeleteAddress(ctx context.Context, id core.AddressID) error {
-	return a.q.DeleteAddress(ctx, int32(id))
+	return runInTx(ctx, a.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		before, err := queries.GetAddress(ctx, int32(id))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		} else if err != nil {
+			return ConvertPgError(err)
+		}
+		if err := queries.DeleteAddress(ctx, int32(id)); err != nil {
+			return ConvertPgError(err)
+		}
+		return recordAddressChange(ctx, tx, core.AuditDelete, &before, nil)
+	})
 }
 
 // GetAddress implements core.AddressService.GetAddress
@@ -70,14 +98,26 @@ func (a *AddressService) UpdateAddress(
 	id core.AddressID,
 	data core.AddressUpdateData,
 ) (*core.Address, error) {
-	dbAddress, err := a.q.UpdateAddress(ctx, sqlc.UpdateAddressParams{
-		ID:         int32(id),
-		Street:     data.Street,
-		Number:     data.Number,
-		PostalCode: data.PostalCode,
-		City:       data.City,
-		Country:    data.Country,
-		ExtraLine:  data.ExtraLine,
+	var dbAddress sqlc.Address
+	err := runInTx(ctx, a.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		before, err := queries.GetAddress(ctx, int32(id))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		dbAddress, err = queries.UpdateAddress(ctx, sqlc.UpdateAddressParams{
+			ID:         int32(id),
+			Street:     data.Street,
+			Number:     data.Number,
+			PostalCode: data.PostalCode,
+			City:       data.City,
+			Country:    data.Country,
+			ExtraLine:  data.ExtraLine,
+		})
+		if err != nil {
+			return err
+		}
+		return recordAddressChange(ctx, tx, core.AuditUpdate, &before, &dbAddress)
 	})
 	if err != nil {
 		return nil, err
@@ -85,13 +125,64 @@ func (a *AddressService) UpdateAddress(
 	return convertAddress(dbAddress)
 }
 
-// ListAddresses implements core.AddressService.ListAddresses
-func (a *AddressService) ListAddresses(ctx context.Context) ([]core.Address, error) {
-	addresses, err := a.q.ListAddresses(ctx)
-	if err != nil {
-		return nil, ConvertPgError(err)
+// recordAddressChange records the changed fields of an address, either before or after may be nil.
+func recordAddressChange(
+	ctx context.Context,
+	tx pgx.Tx,
+	action core.AuditAction,
+	before *sqlc.Address,
+	after *sqlc.Address,
+) error {
+	record := core.AuditRecord{Action: action, EntityType: core.AuditAddress}
+	if before != nil {
+		address, err := convertAddress(*before)
+		if err != nil {
+			return err
+		}
+		record.EntityID = address.ID.String()
+		record.Before = address
+	}
+	if after != nil {
+		address, err := convertAddress(*after)
+		if err != nil {
+			return err
+		}
+		record.EntityID = address.ID.String()
+		record.After = address
 	}
-	return convertAddressList(addresses)
+	return recordAudit(ctx, tx, record)
+}
+
+// ListAddresses implements core.AddressService.ListAddresses
+func (a *AddressService) ListAddresses(
+	ctx context.Context,
+	query core.ListQuery,
+) (*core.Page[core.Address], error) {
+	return addressList.list(ctx, a.db, query)
+}
+
+var addressList = &keysetList[core.Address, sqlc.Address]{
+	columns: "a.id, a.street, a.number, a.postal_code, a.city, a.country, a.extra_line",
+	from:    "address AS a",
+	id:      "a.id",
+	sorts: map[string]sortColumn[core.Address]{
+		"id":     {"a.id", "integer", func(a *core.Address) string { return a.ID.String() }},
+		"street": {"a.street", "text", func(a *core.Address) string { return a.Street }},
+		"postal_code": {"a.postal_code", "text", func(a *core.Address) string {
+			return a.PostalCode
+		}},
+		"city":    {"a.city", "text", func(a *core.Address) string { return a.City }},
+		"country": {"a.country", "text", func(a *core.Address) string { return a.Country }},
+	},
+	defaultSort: "id",
+	filters: map[string]listFilter{
+		"search":      containsFilter("a.street", "a.postal_code", "a.city"),
+		"postal_code": equalsFilter("a.postal_code", parseText),
+		"city":        equalsFilter("a.city", parseText),
+		"country":     equalsFilter("a.country", parseText),
+	},
+	convert: convertAddress,
+	itemID:  func(address *core.Address) core.ID { return address.ID },
 }
 
 func convertAddress(address sqlc.Address) (*core.Address, error) {
@@ -106,18 +197,3 @@ func convertAddress(address sqlc.Address) (*core.Address, error) {
 		ExtraLine:  address.ExtraLine,
 	}, nil
 }
-
-func convertAddressList(addresss []sqlc.Address) ([]core.Address, error) {
-	list := make([]core.Address, len(addresss))
-	for i, v := range addresss {
-		o, err := convertAddress(v)
-		if err != nil {
-			return nil, err
-		}
-		if o == nil {
-			return nil, errors.New("both address and error should never be nil")
-		}
-		list[i] = *o
-	}
-	return list, nil
-}
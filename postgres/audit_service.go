@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/postgres/internal/sqlc"
+)
+
+func NewAuditService(DB *DB) *AuditService {
+	return &AuditService{DB}
+}
+
+// Postgres implementation of the core AuditService interface.
+// All Apollo postgres services record their own mutations in the same transaction, so this is only needed to record
+// the mutations of the application or to query the log.
+type AuditService struct {
+	db *DB
+}
+
+// Force struct to implement the core interface
+var _ core.AuditService = &AuditService{}
+
+// Record implements core.AuditService.Record
+func (a *AuditService) Record(ctx context.Context, record core.AuditRecord) error {
+	return recordAudit(ctx, a.db, record)
+}
+
+// recordAudit records a mutation that was performed by the actor of the context, on the specified connection so it
+// can be part of the mutation's transaction.
+func recordAudit(ctx context.Context, dbtx sqlc.DBTX, record core.AuditRecord) error {
+	changes, err := record.Diff()
+	if err != nil {
+		return fmt.Errorf("could not determine audited changes: %w", err)
+	}
+	if record.Action == core.AuditUpdate && len(changes) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("could not encode audited changes: %w", err)
+	}
+	actor := core.ActorFromContext(ctx)
+	err = sqlc.New(dbtx).CreateAuditEntry(ctx, sqlc.CreateAuditEntryParams{
+		ActorID:        toOptionalID(actor.UserID),
+		ImpersonatorID: toOptionalID(actor.ImpersonatorID),
+		Action:         string(record.Action),
+		EntityType:     record.EntityType,
+		EntityID:       record.EntityID,
+		Changes:        data,
+	})
+	if err != nil {
+		return fmt.Errorf("could not record %s of %s %s: %w",
+			record.Action, record.EntityType, record.EntityID, ConvertPgError(err))
+	}
+	return nil
+}
+
+// ListAuditEntries implements core.AuditService.ListAuditEntries
+func (a *AuditService) ListAuditEntries(
+	ctx context.Context,
+	query core.ListQuery,
+) (*core.Page[core.AuditEntry], error) {
+	return auditList.list(ctx, a.db, query)
+}
+
+var auditList = &keysetList[core.AuditEntry, sqlc.AuditLog]{
+	columns: "l.id, l.occurred_at, l.actor_id, l.impersonator_id, " +
+		"l.action, l.entity_type, l.entity_id, l.changes",
+	from: "audit_log AS l",
+	id:   "l.id",
+	sorts: map[string]sortColumn[core.AuditEntry]{
+		"id": {"l.id", "integer", func(entry *core.AuditEntry) string { return entry.ID.String() }},
+		"time": {"l.occurred_at", "timestamptz", func(entry *core.AuditEntry) string {
+			return entry.OccurredAt.Format(time.RFC3339Nano)
+		}},
+	},
+	defaultSort: "time",
+	filters: map[string]listFilter{
+		"actor":       actorFilter,
+		"entity_type": equalsFilter("l.entity_type", parseText),
+		"entity_id":   equalsFilter("l.entity_id", parseText),
+		"action":      equalsFilter("l.action", parseText),
+		"from":        timeFilter("l.occurred_at", ">="),
+		"until":       timeFilter("l.occurred_at", "<"),
+	},
+	convert: convertAuditEntry,
+	itemID:  func(entry *core.AuditEntry) core.ID { return entry.ID },
+}
+
+// actorFilter matches the entries that were performed by a user, either directly or by impersonating someone.
+func actorFilter(placeholder string, value string) (string, any, error) {
+	id, err := parseID(value)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("(l.actor_id = %[1]s OR l.impersonator_id = %[1]s)", placeholder), id, nil
+}
+
+// timeFilter compares the expression to the filter's value, which should be an RFC 3339 time.
+func timeFilter(expr string, operator string) listFilter {
+	return func(placeholder string, value string) (string, any, error) {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("%s %s %s", expr, operator, placeholder), t, nil
+	}
+}
+
+func convertAuditEntry(entry sqlc.AuditLog) (*core.AuditEntry, error) {
+	var changes core.AuditChanges
+	if err := json.Unmarshal(entry.Changes, &changes); err != nil {
+		return nil, fmt.Errorf("invalid changes in audit entry %v: %w", entry.ID, err)
+	}
+	return &core.AuditEntry{
+		ID:         core.ID(entry.ID),
+		OccurredAt: entry.OccurredAt.Time,
+		Actor: core.Actor{
+			UserID:         fromOptionalID(entry.ActorID),
+			ImpersonatorID: fromOptionalID(entry.ImpersonatorID),
+		},
+		Action:     core.AuditAction(entry.Action),
+		EntityType: entry.EntityType,
+		EntityID:   entry.EntityID,
+		Changes:    changes,
+	}, nil
+}
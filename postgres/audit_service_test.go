@@ -0,0 +1,164 @@
+package postgres_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/login"
+	"github.com/prior-it/apollo/permissions"
+	"github.com/prior-it/apollo/postgres"
+	"github.com/prior-it/apollo/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditService(t *testing.T) {
+	db := tests.DB(t)
+	service := postgres.NewAuditService(db)
+	userService := postgres.NewUserService(db)
+	tests.DeleteAllUsers(userService)
+	defer tests.DeleteAllUsers(userService)
+
+	admin := tests.CreateRegularUser(userService)
+	impersonated := tests.CreateRegularUser(userService)
+	user := tests.CreateRegularUser(userService)
+	ctx := core.WithActor(context.Background(), core.Actor{
+		UserID:         &impersonated.ID,
+		ImpersonatorID: &admin.ID,
+	})
+	start := time.Now()
+
+	t.Run("ok: record update with actor", func(t *testing.T) {
+		tests.Check(userService.UpdateUserAdmin(ctx, user.ID, true))
+		page, err := service.ListAuditEntries(context.Background(), core.ListQuery{
+			Filters: map[string]string{
+				"entity_type": core.AuditUser,
+				"entity_id":   user.ID.String(),
+				"action":      string(core.AuditUpdate),
+			},
+		})
+		assert.Nil(t, err)
+		if assert.Len(t, page.Items, 1) {
+			entry := page.Items[0]
+			assert.Equal(t, impersonated.ID, *entry.Actor.UserID)
+			assert.Equal(t, admin.ID, *entry.Actor.ImpersonatorID)
+			assert.Equal(t, core.AuditChanges{
+				"Admin": {Before: json.RawMessage("false"), After: json.RawMessage("true")},
+			}, entry.Changes)
+		}
+	})
+
+	t.Run("ok: skip update without changes", func(t *testing.T) {
+		tests.Check(userService.UpdateUserAdmin(ctx, user.ID, true))
+		page, err := service.ListAuditEntries(context.Background(), core.ListQuery{
+			Filters: map[string]string{
+				"entity_type": core.AuditUser,
+				"entity_id":   user.ID.String(),
+				"action":      string(core.AuditUpdate),
+			},
+		})
+		assert.Nil(t, err)
+		assert.Len(t, page.Items, 1, "An update that changes nothing should not be recorded")
+	})
+
+	t.Run("ok: filter on actor and time", func(t *testing.T) {
+		for _, actor := range []core.UserID{admin.ID, impersonated.ID} {
+			page, err := service.ListAuditEntries(context.Background(), core.ListQuery{
+				Filters: map[string]string{
+					"actor": actor.String(),
+					"from":  start.Add(-time.Minute).Format(time.RFC3339Nano),
+				},
+			})
+			assert.Nil(t, err)
+			assert.NotEmpty(t, page.Items, "Both the actor and the impersonator should match")
+		}
+
+		page, err := service.ListAuditEntries(context.Background(), core.ListQuery{
+			Filters: map[string]string{
+				"actor": admin.ID.String(),
+				"until": start.Add(-time.Minute).Format(time.RFC3339Nano),
+			},
+		})
+		assert.Nil(t, err)
+		assert.Empty(t, page.Items)
+	})
+
+	t.Run("ok: record system mutations", func(t *testing.T) {
+		err := service.Record(context.Background(), core.AuditRecord{
+			Action:     core.AuditCreate,
+			EntityType: "invoice",
+			EntityID:   "42",
+			After:      map[string]any{"Amount": 100},
+		})
+		assert.Nil(t, err)
+		page, err := service.ListAuditEntries(context.Background(), core.ListQuery{
+			Filters: map[string]string{"entity_type": "invoice", "entity_id": "42"},
+		})
+		assert.Nil(t, err)
+		if assert.NotEmpty(t, page.Items) {
+			entry := page.Items[len(page.Items)-1]
+			assert.True(t, entry.Actor.IsSystem())
+			assert.Equal(t, json.RawMessage("100"), entry.Changes["Amount"].After)
+		}
+	})
+
+	t.Run("ok: record users that are created by logging in", func(t *testing.T) {
+		accounts := postgres.NewOauthAccountService(db)
+		created, err := accounts.CreateUserAccount(ctx, &login.UserData{
+			Name:       tests.Faker.Name(),
+			Email:      tests.Faker.Email(),
+			Lang:       "nl",
+			Provider:   "test",
+			ProviderID: tests.Faker.UUID(),
+		})
+		tests.Check(err)
+		page, err := service.ListAuditEntries(context.Background(), core.ListQuery{
+			Filters: map[string]string{
+				"entity_type": core.AuditUser,
+				"entity_id":   created.ID.String(),
+				"action":      string(core.AuditCreate),
+			},
+		})
+		assert.Nil(t, err)
+		assert.Len(t, page.Items, 1)
+	})
+
+	t.Run("ok: record organisation permission group renames", func(t *testing.T) {
+		orgService := postgres.NewOrganisationService(db)
+		permissionService := postgres.NewPermissionService(db)
+		defer tests.DeleteAllPermissions(permissionService)
+		defer tests.DeleteAllOrganisations(orgService)
+		org, err := orgService.CreateOrganisation(ctx, tests.Faker.Company(), nil)
+		tests.Check(err)
+		group, err := permissionService.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+			Name:           "before",
+			OrganisationID: &org.ID,
+		})
+		tests.Check(err)
+
+		err = permissionService.RenamePermissionGroupForOrganisation(ctx, org.ID, group.ID, "after")
+		assert.Nil(t, err)
+		page, err := service.ListAuditEntries(context.Background(), core.ListQuery{
+			Filters: map[string]string{
+				"entity_type": core.AuditPermissionGroup,
+				"entity_id":   group.ID.String(),
+				"action":      string(core.AuditUpdate),
+			},
+		})
+		assert.Nil(t, err)
+		if assert.Len(t, page.Items, 1) {
+			assert.Equal(t, core.AuditChanges{
+				"Name": {Before: json.RawMessage(`"before"`), After: json.RawMessage(`"after"`)},
+			}, page.Items[0].Changes)
+		}
+	})
+
+	t.Run("err: invalid time filter", func(t *testing.T) {
+		_, err := service.ListAuditEntries(context.Background(), core.ListQuery{
+			Filters: map[string]string{"from": "yesterday"},
+		})
+		assert.ErrorIs(t, err, core.ErrInvalidListQuery)
+	})
+}
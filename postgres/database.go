@@ -3,47 +3,91 @@ package postgres
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log/slog"
+	"regexp"
+	"strings"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/database"
 )
 
 //go:embed migrations/*.sql
 var embedMigrations embed.FS
 
+// The maximum length of a postgres identifier, longer names are truncated by postgres.
+const maxIdentifierLength = 63
+
+var (
+	ErrInvalidSchemaName = errors.New("invalid schema name")
+	schemaNameRegex      = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+)
+
 type DB struct {
 	*pgxpool.Pool
+	userProvisioners   []UserProvisioner
+	memberProvisioners []MemberProvisioner
+	userDataExporters  []userDataExporter
+	userDataErasers    []UserDataEraser
+	cronTasks          []cronTask
+	events             bool
 }
 
 // Initialise a new database connection. connString should be a valid postgres connection string (such as a postgres-url).
 // The database will use the specified schema, if it exists. If the schema doesn't exist yet, it will be created.
+// Every connection in the pool uses the schema as its default search path.
 func NewDB(ctx context.Context, connString, schema string) (*DB, error) {
 	slog.Info("Connecting to postgres database", "schema", schema)
-	pool, err := pgxpool.New(ctx, connString)
+	if err := ValidateSchemaName(schema); err != nil {
+		return nil, err
+	}
+	cfg, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse postgres connection string: %w", err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = QuoteIdentifier(schema)
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("cannot connect to postgres database: %w", err)
 	}
-	db := &DB{pool}
+	db := &DB{Pool: pool}
 	err = db.SwitchSchema(ctx, schema)
 	return db, err
 }
 
+// ValidateSchemaName returns ErrInvalidSchemaName if the specified name cannot safely be used as a schema, i.e. it
+// should only contain lowercase letters, digits and underscores, should not start with a digit or "pg_" and can be at
+// most 63 characters long.
+func ValidateSchemaName(schema string) error {
+	if len(schema) > maxIdentifierLength || !schemaNameRegex.MatchString(schema) ||
+		strings.HasPrefix(schema, "pg_") || schema == "information_schema" {
+		return fmt.Errorf("%w: %q", ErrInvalidSchemaName, schema)
+	}
+	return nil
+}
+
+// QuoteIdentifier quotes the specified identifier so it can be used in an SQL statement.
+func QuoteIdentifier(identifier string) string {
+	return pgx.Identifier{identifier}.Sanitize()
+}
+
 // Switch the database schema. If the specified schema does not exist already, this will create it.
-// Beware that the schema here is not sanitised, as such this could be used to do SQL injection and should never
-// pass on unsanitised user input!
+// Note that this only changes the search path of a single connection in the pool, use NewDB or a Tenancy to change
+// the schema of every connection.
 func (db *DB) SwitchSchema(ctx context.Context, schema string) error {
 	slog.Info("Switching postgres schema", "schema", schema)
+	if err := ValidateSchemaName(schema); err != nil {
+		return err
+	}
+	quoted := QuoteIdentifier(schema)
 	_, err := db.Exec(
 		ctx,
-		fmt.Sprintf(
-			"CREATE SCHEMA IF NOT EXISTS %s; SET search_path TO %s;",
-			schema,
-			schema,
-		),
+		fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s; SET search_path TO %s;", quoted, quoted),
 	)
 	if err != nil {
 		return fmt.Errorf("cannot create and switch to schema %q: %w", schema, err)
@@ -51,14 +95,21 @@ func (db *DB) SwitchSchema(ctx context.Context, schema string) error {
 	return nil
 }
 
-// Set the search path to the specified value. If the search path contains non-existent schemas, this will error.
-// Beware that the schema here is not sanitised, as such this could be used to do SQL injection and should never
-// pass on unsanitised user input!
+// Set the search path to the specified comma-separated list of schemas. If the search path contains non-existent
+// schemas, this will error. Every schema is validated with ValidateSchemaName.
 func (db *DB) SetSearchPath(ctx context.Context, path string) error {
 	slog.Info("Changing postgres search path", "search_path", path)
+	schemas := strings.Split(path, ",")
+	for i, schema := range schemas {
+		schema = strings.TrimSpace(schema)
+		if err := ValidateSchemaName(schema); err != nil {
+			return err
+		}
+		schemas[i] = QuoteIdentifier(schema)
+	}
 	_, err := db.Exec(
 		ctx,
-		"SET search_path TO "+path,
+		"SET search_path TO "+strings.Join(schemas, ", "),
 	)
 	if err != nil {
 		return fmt.Errorf("cannot set search path to %q: %w", path, err)
@@ -67,11 +118,13 @@ func (db *DB) SetSearchPath(ctx context.Context, path string) error {
 }
 
 // Delete the specified database schema, beware that this will delete all tables and data in the schema.
-// The schema string here is not sanitised, as such this could be used to do SQL injection and should never
-// pass on unsanitised user input!
 func (db *DB) DeleteSchema(ctx context.Context, schema string) error {
 	slog.Info("Deleting postgres schema", "schema", schema)
-	if _, err := db.Exec(ctx, fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE;", schema)); err != nil {
+	if err := ValidateSchemaName(schema); err != nil {
+		return err
+	}
+	query := fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE;", QuoteIdentifier(schema))
+	if _, err := db.Exec(ctx, query); err != nil {
 		return fmt.Errorf("cannot delete schema '%v': %w", schema, err)
 	}
 	return nil
@@ -79,7 +132,8 @@ func (db *DB) DeleteSchema(ctx context.Context, schema string) error {
 
 // createGooseProvider will create a new goose provider that combines the migrations from the passed embed.FS with
 // the embedded apollo migrations.
-func (db *DB) createGooseProvider(
+func createGooseProvider(
+	pool *pgxpool.Pool,
 	migrations *embed.FS,
 	folder string,
 	isDebug bool,
@@ -103,7 +157,7 @@ func (db *DB) createGooseProvider(
 		migrateFS = combinedFS
 	}
 
-	database := stdlib.OpenDBFromPool(db.Pool)
+	database := stdlib.OpenDBFromPool(pool)
 
 	return goose.NewProvider(
 		goose.DialectPostgres,
@@ -114,11 +168,42 @@ func (db *DB) createGooseProvider(
 	)
 }
 
+// createTenantGooseProvider creates a goose provider for the app's tenant migrations, without the apollo migrations.
+// The version table is qualified with the tenant's schema, since the search path also contains the default schema
+// and its version table.
+func createTenantGooseProvider(
+	pool *pgxpool.Pool,
+	schema string,
+	migrations *embed.FS,
+	folder string,
+	isDebug bool,
+) (*goose.Provider, error) {
+	app, err := fs.Sub(migrations, folder)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot get app embedFS migrations folder: %w", err)
+	}
+	store, err := database.NewStore(
+		database.DialectPostgres,
+		QuoteIdentifier(schema)+"."+goose.DefaultTablename,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot create goose store: %w", err)
+	}
+	return goose.NewProvider(
+		"", // The dialect is part of the store
+		stdlib.OpenDBFromPool(pool),
+		app,
+		goose.WithStore(store),
+		goose.WithVerbose(true),
+		goose.WithAllowOutofOrder(isDebug),
+	)
+}
+
 // Migrate the database using the specified embedded migration folder.
 // "folder" specifies the location of the folder containing sql files within the embed.FS
 // To only run the Apollo migrations, set migrations to nil
 func (db *DB) Migrate(migrations *embed.FS, folder string, isDebug bool) error {
-	provider, err := db.createGooseProvider(migrations, folder, isDebug)
+	provider, err := createGooseProvider(db.Pool, migrations, folder, isDebug)
 	if err != nil {
 		return fmt.Errorf("Cannot create goose provider: %w", err)
 	}
@@ -139,7 +224,7 @@ func (db *DB) Migrate(migrations *embed.FS, folder string, isDebug bool) error {
 // "folder" specifies the location of the folder containing sql files within the embed.FS
 // To only run the Apollo migrations, set migrations to nil
 func (db *DB) MigrateDown(migrations *embed.FS, folder string, isDebug bool) error {
-	provider, err := db.createGooseProvider(migrations, folder, isDebug)
+	provider, err := createGooseProvider(db.Pool, migrations, folder, isDebug)
 	if err != nil {
 		return fmt.Errorf("Cannot create goose provider: %w", err)
 	}
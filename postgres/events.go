@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/postgres/internal/sqlc"
+)
+
+const (
+	// DefaultEventBatchSize is the maximum amount of events that a dispatcher delivers in a single transaction.
+	DefaultEventBatchSize = 100
+	// The delay before an event is delivered again after its first failed attempt, doubled for every next attempt
+	eventRetryDelay = 5 * time.Second
+	// The maximum delay between two attempts to deliver an event
+	eventMaxRetryDelay = time.Hour
+)
+
+// EnableEvents makes every Apollo service that uses this database write the events it emits to the outbox table,
+// in the same transaction as the mutation that caused them. An EventDispatcher delivers them from there.
+// Enable this in every process that uses the database, even if another process dispatches the events.
+func (db *DB) EnableEvents() {
+	db.events = true
+}
+
+// Emit writes an application event to the outbox on the specified connection, which should be the transaction of
+// the mutation that caused it. This does nothing if events are not enabled.
+func (db *DB) Emit(ctx context.Context, dbtx sqlc.DBTX, event core.Event) error {
+	if !db.events {
+		return nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("could not encode %q event: %w", event.EventType(), err)
+	}
+	actor := core.ActorFromContext(ctx)
+	err = sqlc.New(dbtx).CreateOutboxEvent(ctx, sqlc.CreateOutboxEventParams{
+		EventType:      string(event.EventType()),
+		Payload:        payload,
+		ActorID:        toOptionalID(actor.UserID),
+		ImpersonatorID: toOptionalID(actor.ImpersonatorID),
+	})
+	if err != nil {
+		return fmt.Errorf("could not emit %q event: %w", event.EventType(), ConvertPgError(err))
+	}
+	return nil
+}
+
+func NewEventDispatcher(DB *DB, bus *core.EventBus) *EventDispatcher {
+	DB.EnableEvents()
+	return &EventDispatcher{db: DB, bus: bus, batchSize: DefaultEventBatchSize}
+}
+
+// EventDispatcher delivers the events in the outbox to the subscribers of an event bus, at least once.
+// Only synchronous subscribers can make the delivery fail: an event is delivered again, with an exponential back-off,
+// until none of them return an error, which means every synchronous subscriber can see the same event more than
+// once. Asynchronous subscribers see every event once it was delivered successfully, or more often if its delivery
+// is retried.
+type EventDispatcher struct {
+	db        *DB
+	bus       *core.EventBus
+	batchSize int
+}
+
+// SetBatchSize changes the maximum amount of events that are delivered in a single transaction.
+// The default is DefaultEventBatchSize.
+func (d *EventDispatcher) SetBatchSize(size int) {
+	d.batchSize = size
+}
+
+// Run delivers the pending events every interval until the context is cancelled.
+//
+// # Example
+//
+//	go postgres.NewEventDispatcher(db, bus).Run(ctx, time.Second)
+func (d *EventDispatcher) Run(ctx context.Context, interval time.Duration) {
+	pollLoop(ctx, interval, func(ctx context.Context) bool {
+		delivered, err := d.Dispatch(ctx)
+		if err != nil {
+			slog.Error("Could not dispatch events", "error", err)
+			return false
+		}
+		return delivered >= d.batchSize
+	})
+}
+
+// Dispatch delivers a single batch of pending events and returns the amount of events it attempted to deliver.
+// Events whose delivery fails are scheduled to be delivered again later.
+func (d *EventDispatcher) Dispatch(ctx context.Context) (int, error) {
+	var amount int
+	err := runInTx(ctx, d.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		events, err := queries.ClaimOutboxEvents(ctx, int32(d.batchSize))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		amount = len(events)
+		for _, event := range events {
+			if err := d.deliver(ctx, queries, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return amount, nil
+}
+
+// deliver publishes a single event and removes it from the outbox, or schedules another attempt if it fails.
+func (d *EventDispatcher) deliver(
+	ctx context.Context,
+	queries *sqlc.Queries,
+	event sqlc.EventOutbox,
+) error {
+	eventCtx := core.WithActor(ctx, core.Actor{
+		UserID:         fromOptionalID(event.ActorID),
+		ImpersonatorID: fromOptionalID(event.ImpersonatorID),
+	})
+	eventCtx = core.WithEventInfo(eventCtx, core.EventInfo{
+		ID:         strconv.FormatInt(event.ID, 10),
+		OccurredAt: event.CreatedAt.Time,
+		Attempts:   int(event.Attempts),
+	})
+	err := d.bus.PublishJSON(eventCtx, core.EventType(event.EventType), event.Payload)
+	if err == nil {
+		if err := queries.DeleteOutboxEvent(ctx, event.ID); err != nil {
+			return fmt.Errorf(
+				"could not remove delivered event %v: %w",
+				event.ID,
+				ConvertPgError(err),
+			)
+		}
+		return nil
+	}
+
+	delay := backoff(event.Attempts+1, eventRetryDelay, eventMaxRetryDelay)
+	slog.Warn(
+		"Could not deliver event",
+		"event_id", event.ID,
+		"event", event.EventType,
+		"attempts", event.Attempts+1,
+		"retry_in", delay,
+		"error", err,
+	)
+	message := err.Error()
+	availableAt := time.Now().Add(delay)
+	err = queries.RetryOutboxEvent(ctx, sqlc.RetryOutboxEventParams{
+		ID:          event.ID,
+		LastError:   &message,
+		AvailableAt: toTimestamptz(&availableAt),
+	})
+	if err != nil {
+		return fmt.Errorf("could not reschedule event %v: %w", event.ID, ConvertPgError(err))
+	}
+	return nil
+}
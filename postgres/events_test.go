@@ -0,0 +1,95 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/postgres"
+	"github.com/prior-it/apollo/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventDispatcher(t *testing.T) {
+	db := tests.DB(t)
+	bus := core.NewEventBus()
+	dispatcher := postgres.NewEventDispatcher(db, bus)
+	userService := postgres.NewUserService(db)
+	orgService := postgres.NewOrganisationService(db)
+	tests.DeleteAllUsers(userService)
+	defer tests.DeleteAllUsers(userService)
+	tests.DeleteAllOrganisations(orgService)
+	defer tests.DeleteAllOrganisations(orgService)
+	ctx := context.Background()
+
+	// Start with an empty outbox
+	_, err := dispatcher.Dispatch(ctx)
+	tests.Check(err)
+
+	var created []core.UserCreated
+	var infos []core.EventInfo
+	core.Subscribe(bus, func(ctx context.Context, event core.UserCreated) error {
+		created = append(created, event)
+		info, ok := core.EventInfoFromContext(ctx)
+		assert.True(t, ok, "Events from the outbox should be described in the context")
+		infos = append(infos, info)
+		return nil
+	})
+	var added []core.UserAddedToOrganisation
+	core.Subscribe(bus, func(_ context.Context, event core.UserAddedToOrganisation) error {
+		added = append(added, event)
+		return nil
+	})
+
+	t.Run("ok: deliver after commit", func(t *testing.T) {
+		created, infos, added = nil, nil, nil
+		user := tests.CreateRegularUser(userService)
+		org, err := orgService.CreateOrganisation(ctx, tests.Faker.Company(), nil)
+		tests.Check(err)
+		tests.Check(orgService.AddUser(ctx, user.ID, org.ID))
+		assert.Empty(t, created, "Events should only be delivered by the dispatcher")
+
+		delivered, err := dispatcher.Dispatch(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, delivered)
+		if assert.Len(t, created, 1) {
+			assert.Equal(t, user.ID, created[0].User.ID)
+			assert.Equal(t, 0, infos[0].Attempts)
+		}
+		assert.Equal(t, []core.UserAddedToOrganisation{{
+			UserID:         user.ID,
+			OrganisationID: org.ID,
+			Status:         core.MembershipActive,
+		}}, added)
+
+		delivered, err = dispatcher.Dispatch(ctx)
+		assert.Nil(t, err)
+		assert.Zero(t, delivered, "Delivered events should be removed from the outbox")
+	})
+
+	t.Run("ok: no events for rolled back mutations", func(t *testing.T) {
+		created = nil
+		user := tests.CreateRegularUser(userService)
+		_, err := userService.CreateUser(ctx, "Duplicate", user.Email, "en")
+		assert.ErrorIs(t, err, core.ErrConflict)
+		_, err = dispatcher.Dispatch(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, created, 1, "Only the committed user should be delivered")
+	})
+
+	t.Run("ok: retry failed deliveries", func(t *testing.T) {
+		failing := core.NewEventBus()
+		core.Subscribe(failing, func(_ context.Context, _ core.UserCreated) error {
+			return errors.New("failure")
+		})
+		tests.CreateRegularUser(userService)
+		delivered, err := postgres.NewEventDispatcher(db, failing).Dispatch(ctx)
+		assert.Nil(t, err, "A failing subscriber should not fail the dispatcher")
+		assert.Equal(t, 1, delivered)
+
+		delivered, err = dispatcher.Dispatch(ctx)
+		assert.Nil(t, err)
+		assert.Zero(t, delivered, "Failed events should only be delivered again after a delay")
+	})
+}
@@ -39,15 +39,29 @@ func (q *Queries) DeleteAccount(ctx context.Context, provider string, providerID
 	return err
 }
 
+const deleteAccountOfDeletedUser = `-- name: DeleteAccountOfDeletedUser :exec
+DELETE FROM accounts USING users
+WHERE accounts.user_id = users.id
+    AND accounts.provider = $1
+    AND accounts.provider_id = $2
+    AND users.deleted_at IS NOT NULL
+`
+
+func (q *Queries) DeleteAccountOfDeletedUser(ctx context.Context, provider string, providerID string) error {
+	_, err := q.db.Exec(ctx, deleteAccountOfDeletedUser, provider, providerID)
+	return err
+}
+
 const getUserForProvider = `-- name: GetUserForProvider :one
 SELECT
-    users.id, users.name, users.email, users.joined, users.admin, users.lang
+    users.id, users.name, users.email, users.joined, users.admin, users.lang, users.search, users.deleted_at, users.anonymised_at
 FROM
     users
     INNER JOIN accounts ON users.id = accounts.user_id
 WHERE
     accounts.provider = $1
     AND accounts.provider_id = $2
+    AND users.deleted_at IS NULL
 LIMIT 1
 `
 
@@ -61,6 +75,9 @@ func (q *Queries) GetUserForProvider(ctx context.Context, provider string, provi
 		&i.Joined,
 		&i.Admin,
 		&i.Lang,
+		&i.Search,
+		&i.DeletedAt,
+		&i.AnonymisedAt,
 	)
 	return i, err
 }
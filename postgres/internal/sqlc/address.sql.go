@@ -82,41 +82,6 @@ func (q *Queries) GetAddress(ctx context.Context, id int32) (Address, error) {
 	return i, err
 }
 
-const listAddresses = `-- name: ListAddresses :many
-SELECT
-	id, street, number, postal_code, city, country, extra_line
-FROM
-	address
-`
-
-func (q *Queries) ListAddresses(ctx context.Context) ([]Address, error) {
-	rows, err := q.db.Query(ctx, listAddresses)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []Address
-	for rows.Next() {
-		var i Address
-		if err := rows.Scan(
-			&i.ID,
-			&i.Street,
-			&i.Number,
-			&i.PostalCode,
-			&i.City,
-			&i.Country,
-			&i.ExtraLine,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
-}
-
 const updateAddress = `-- name: UpdateAddress :one
 UPDATE
 	address
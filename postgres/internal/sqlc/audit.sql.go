@@ -0,0 +1,52 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: audit.sql
+
+package sqlc
+
+import (
+	"context"
+)
+
+const createAuditEntry = `-- name: CreateAuditEntry :exec
+INSERT INTO audit_log(actor_id, impersonator_id, action, entity_type, entity_id, changes)
+    VALUES ($1, $2, $3, $4, $5, $6)
+`
+
+type CreateAuditEntryParams struct {
+	ActorID        *int32
+	ImpersonatorID *int32
+	Action         string
+	EntityType     string
+	EntityID       string
+	Changes        []byte
+}
+
+func (q *Queries) CreateAuditEntry(ctx context.Context, arg CreateAuditEntryParams) error {
+	_, err := q.db.Exec(ctx, createAuditEntry,
+		arg.ActorID,
+		arg.ImpersonatorID,
+		arg.Action,
+		arg.EntityType,
+		arg.EntityID,
+		arg.Changes,
+	)
+	return err
+}
+
+const eraseAuditChanges = `-- name: EraseAuditChanges :exec
+UPDATE
+    audit_log
+SET
+    changes = '{}'
+WHERE
+    entity_type = $1
+    AND entity_id = $2
+`
+
+// Keeps the history of an entity but removes the values that were recorded for it
+func (q *Queries) EraseAuditChanges(ctx context.Context, entityType string, entityID string) error {
+	_, err := q.db.Exec(ctx, eraseAuditChanges, entityType, entityID)
+	return err
+}
@@ -0,0 +1,86 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: cron.sql
+
+package sqlc
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const cronUnlock = `-- name: CronUnlock :one
+SELECT
+    pg_advisory_unlock(hashtextextended(current_schema() || '.cron.' || $1::text, 0))
+`
+
+func (q *Queries) CronUnlock(ctx context.Context, task string) (bool, error) {
+	row := q.db.QueryRow(ctx, cronUnlock, task)
+	var pg_advisory_unlock bool
+	err := row.Scan(&pg_advisory_unlock)
+	return pg_advisory_unlock, err
+}
+
+const deleteOldCronRuns = `-- name: DeleteOldCronRuns :exec
+DELETE FROM cron_runs
+WHERE task = $1
+    AND started_at < $2
+`
+
+func (q *Queries) DeleteOldCronRuns(ctx context.Context, task string, startedAt pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteOldCronRuns, task, startedAt)
+	return err
+}
+
+const finishCronRun = `-- name: FinishCronRun :exec
+UPDATE
+    cron_runs
+SET
+    finished_at = NOW(),
+    error = $2
+WHERE
+    id = $1
+`
+
+func (q *Queries) FinishCronRun(ctx context.Context, iD int32, error *string) error {
+	_, err := q.db.Exec(ctx, finishCronRun, iD, error)
+	return err
+}
+
+const startCronRun = `-- name: StartCronRun :one
+INSERT INTO cron_runs(task, scheduled_at, instance)
+    VALUES ($1, $2, $3)
+ON CONFLICT (task, scheduled_at)
+    DO NOTHING
+RETURNING
+    id
+`
+
+type StartCronRunParams struct {
+	Task        string
+	ScheduledAt pgtype.Timestamptz
+	Instance    string
+}
+
+// Returns no rows if the scheduled time of the task already ran
+func (q *Queries) StartCronRun(ctx context.Context, arg StartCronRunParams) (int32, error) {
+	row := q.db.QueryRow(ctx, startCronRun, arg.Task, arg.ScheduledAt, arg.Instance)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
+}
+
+const tryCronLock = `-- name: TryCronLock :one
+SELECT
+    pg_try_advisory_lock(hashtextextended(current_schema() || '.cron.' || $1::text, 0))
+`
+
+// Advisory locks are shared by every schema in the database, so the key includes the schema
+func (q *Queries) TryCronLock(ctx context.Context, task string) (bool, error) {
+	row := q.db.QueryRow(ctx, tryCronLock, task)
+	var pg_try_advisory_lock bool
+	err := row.Scan(&pg_try_advisory_lock)
+	return pg_try_advisory_lock, err
+}
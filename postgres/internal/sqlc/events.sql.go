@@ -0,0 +1,111 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: events.sql
+
+package sqlc
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimOutboxEvents = `-- name: ClaimOutboxEvents :many
+SELECT
+    id, event_type, payload, actor_id, impersonator_id, created_at, available_at, attempts, last_error
+FROM
+    event_outbox
+WHERE
+    available_at <= NOW()
+ORDER BY
+    id
+LIMIT $1
+FOR UPDATE
+    SKIP LOCKED
+`
+
+// Locks the events that are due, skipping the ones that another dispatcher is delivering
+func (q *Queries) ClaimOutboxEvents(ctx context.Context, limit int32) ([]EventOutbox, error) {
+	rows, err := q.db.Query(ctx, claimOutboxEvents, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []EventOutbox
+	for rows.Next() {
+		var i EventOutbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventType,
+			&i.Payload,
+			&i.ActorID,
+			&i.ImpersonatorID,
+			&i.CreatedAt,
+			&i.AvailableAt,
+			&i.Attempts,
+			&i.LastError,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createOutboxEvent = `-- name: CreateOutboxEvent :exec
+INSERT INTO event_outbox(event_type, payload, actor_id, impersonator_id)
+    VALUES ($1, $2, $3, $4)
+`
+
+type CreateOutboxEventParams struct {
+	EventType      string
+	Payload        []byte
+	ActorID        *int32
+	ImpersonatorID *int32
+}
+
+func (q *Queries) CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventParams) error {
+	_, err := q.db.Exec(ctx, createOutboxEvent,
+		arg.EventType,
+		arg.Payload,
+		arg.ActorID,
+		arg.ImpersonatorID,
+	)
+	return err
+}
+
+const deleteOutboxEvent = `-- name: DeleteOutboxEvent :exec
+DELETE FROM event_outbox
+WHERE id = $1
+`
+
+func (q *Queries) DeleteOutboxEvent(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, deleteOutboxEvent, id)
+	return err
+}
+
+const retryOutboxEvent = `-- name: RetryOutboxEvent :exec
+UPDATE
+    event_outbox
+SET
+    attempts = attempts + 1,
+    last_error = $2,
+    available_at = $3
+WHERE
+    id = $1
+`
+
+type RetryOutboxEventParams struct {
+	ID          int64
+	LastError   *string
+	AvailableAt pgtype.Timestamptz
+}
+
+func (q *Queries) RetryOutboxEvent(ctx context.Context, arg RetryOutboxEventParams) error {
+	_, err := q.db.Exec(ctx, retryOutboxEvent, arg.ID, arg.LastError, arg.AvailableAt)
+	return err
+}
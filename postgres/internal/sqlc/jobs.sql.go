@@ -0,0 +1,277 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: jobs.sql
+
+package sqlc
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimJobs = `-- name: ClaimJobs :many
+UPDATE
+    jobs
+SET
+    status = 'running',
+    attempts = attempts + 1,
+    locked_until = $1
+WHERE
+    id IN (
+        SELECT
+            id
+        FROM
+            jobs
+        WHERE
+            kind = ANY ($2::text[])
+            AND status <> 'dead'
+            AND ((status = 'pending'
+                    AND run_at <= NOW())
+                OR (status = 'running'
+                    AND locked_until < NOW()
+                    AND attempts < max_attempts))
+        ORDER BY
+            run_at,
+            id
+        LIMIT $3
+        FOR UPDATE
+            SKIP LOCKED)
+RETURNING
+    id, kind, payload, status, unique_key, run_at, attempts, max_attempts, last_error, locked_until, actor_id, impersonator_id, created_at, died_at
+`
+
+type ClaimJobsParams struct {
+	LockedUntil pgtype.Timestamptz
+	Kinds       []string
+	MaxJobs     int32
+}
+
+// Locks the jobs that are due, skipping the ones that another worker is claiming.
+// Running jobs whose lock expired are claimed again.
+func (q *Queries) ClaimJobs(ctx context.Context, arg ClaimJobsParams) ([]Job, error) {
+	rows, err := q.db.Query(ctx, claimJobs, arg.LockedUntil, arg.Kinds, arg.MaxJobs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Job
+	for rows.Next() {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Payload,
+			&i.Status,
+			&i.UniqueKey,
+			&i.RunAt,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.LastError,
+			&i.LockedUntil,
+			&i.ActorID,
+			&i.ImpersonatorID,
+			&i.CreatedAt,
+			&i.DiedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createJob = `-- name: CreateJob :one
+INSERT INTO jobs(kind, payload, unique_key, run_at, max_attempts, actor_id, impersonator_id)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (kind, unique_key)
+    WHERE status <> 'dead'
+        DO NOTHING
+    RETURNING
+        id
+`
+
+type CreateJobParams struct {
+	Kind           string
+	Payload        []byte
+	UniqueKey      *string
+	RunAt          pgtype.Timestamptz
+	MaxAttempts    int32
+	ActorID        *int32
+	ImpersonatorID *int32
+}
+
+// Returns no rows if another pending or running job of the same kind has the same unique key
+func (q *Queries) CreateJob(ctx context.Context, arg CreateJobParams) (int32, error) {
+	row := q.db.QueryRow(ctx, createJob,
+		arg.Kind,
+		arg.Payload,
+		arg.UniqueKey,
+		arg.RunAt,
+		arg.MaxAttempts,
+		arg.ActorID,
+		arg.ImpersonatorID,
+	)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
+}
+
+const deleteDeadJob = `-- name: DeleteDeadJob :execrows
+DELETE FROM jobs
+WHERE id = $1
+    AND status = 'dead'
+`
+
+func (q *Queries) DeleteDeadJob(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteDeadJob, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteJob = `-- name: DeleteJob :execrows
+DELETE FROM jobs
+WHERE id = $1
+    AND status = 'running'
+    AND attempts = $2
+`
+
+// Only removes the job if the worker that ran this attempt still holds its lease
+func (q *Queries) DeleteJob(ctx context.Context, iD int32, attempts int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteJob, iD, attempts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getUniqueJobID = `-- name: GetUniqueJobID :one
+SELECT
+    id
+FROM
+    jobs
+WHERE
+    kind = $1
+    AND unique_key = $2
+    AND status <> 'dead'
+`
+
+func (q *Queries) GetUniqueJobID(ctx context.Context, kind string, uniqueKey *string) (int32, error) {
+	row := q.db.QueryRow(ctx, getUniqueJobID, kind, uniqueKey)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
+}
+
+const killExpiredJobs = `-- name: KillExpiredJobs :execrows
+UPDATE
+    jobs
+SET
+    status = 'dead',
+    last_error = 'lease expired',
+    locked_until = NULL,
+    died_at = NOW()
+WHERE
+    kind = ANY ($1::text[])
+    AND status = 'running'
+    AND locked_until < NOW()
+    AND attempts >= max_attempts
+`
+
+// Kills the running jobs whose lock expired on their last attempt, e.g. because they keep crashing their worker.
+func (q *Queries) KillExpiredJobs(ctx context.Context, kinds []string) (int64, error) {
+	result, err := q.db.Exec(ctx, killExpiredJobs, kinds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const killJob = `-- name: KillJob :execrows
+UPDATE
+    jobs
+SET
+    status = 'dead',
+    last_error = $1,
+    locked_until = NULL,
+    died_at = NOW()
+WHERE
+    id = $2
+    AND status = 'running'
+    AND attempts = $3
+`
+
+type KillJobParams struct {
+	LastError *string
+	ID        int32
+	Attempts  int32
+}
+
+func (q *Queries) KillJob(ctx context.Context, arg KillJobParams) (int64, error) {
+	result, err := q.db.Exec(ctx, killJob, arg.LastError, arg.ID, arg.Attempts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const retryDeadJob = `-- name: RetryDeadJob :execrows
+UPDATE
+    jobs
+SET
+    status = 'pending',
+    run_at = NOW(),
+    attempts = 0,
+    died_at = NULL
+WHERE
+    id = $1
+    AND status = 'dead'
+`
+
+func (q *Queries) RetryDeadJob(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, retryDeadJob, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const retryJob = `-- name: RetryJob :execrows
+UPDATE
+    jobs
+SET
+    status = 'pending',
+    run_at = $1,
+    last_error = $2,
+    locked_until = NULL
+WHERE
+    id = $3
+    AND status = 'running'
+    AND attempts = $4
+`
+
+type RetryJobParams struct {
+	RunAt     pgtype.Timestamptz
+	LastError *string
+	ID        int32
+	Attempts  int32
+}
+
+func (q *Queries) RetryJob(ctx context.Context, arg RetryJobParams) (int64, error) {
+	result, err := q.db.Exec(ctx, retryJob,
+		arg.RunAt,
+		arg.LastError,
+		arg.ID,
+		arg.Attempts,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
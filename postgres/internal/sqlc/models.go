@@ -33,21 +33,92 @@ type Address struct {
 	ExtraLine  *string
 }
 
+type AuditLog struct {
+	ID             int32
+	OccurredAt     pgtype.Timestamptz
+	ActorID        *int32
+	ImpersonatorID *int32
+	Action         string
+	EntityType     string
+	EntityID       string
+	Changes        []byte
+}
+
+type CronRun struct {
+	ID          int32
+	Task        string
+	ScheduledAt pgtype.Timestamptz
+	StartedAt   pgtype.Timestamptz
+	FinishedAt  pgtype.Timestamptz
+	Error       *string
+	Instance    string
+}
+
+type EventOutbox struct {
+	ID             int64
+	EventType      string
+	Payload        []byte
+	ActorID        *int32
+	ImpersonatorID *int32
+	CreatedAt      pgtype.Timestamptz
+	AvailableAt    pgtype.Timestamptz
+	Attempts       int32
+	LastError      *string
+}
+
+type Job struct {
+	ID             int32
+	Kind           string
+	Payload        []byte
+	Status         string
+	UniqueKey      *string
+	RunAt          pgtype.Timestamptz
+	Attempts       int32
+	MaxAttempts    int32
+	LastError      *string
+	LockedUntil    pgtype.Timestamptz
+	ActorID        *int32
+	ImpersonatorID *int32
+	CreatedAt      pgtype.Timestamptz
+	DiedAt         pgtype.Timestamptz
+}
+
 type Organisation struct {
-	ID       int32
-	Name     string
-	ParentID *int32
+	ID        int32
+	Name      string
+	ParentID  *int32
+	LegalName *string
+	VatNumber *string
+	Search    string
+	DeletedAt pgtype.Timestamptz
+}
+
+type OrganisationAddress struct {
+	OrganisationID int32
+	AddressID      int32
+	Type           string
+}
+
+type OrganisationDefaultPermissiongroup struct {
+	OrganisationID    int32
+	PermissionGroupID int32
 }
 
 type OrganisationUser struct {
 	ID             int32
 	UserID         int32
 	OrganisationID int32
+	JoinedAt       pgtype.Timestamptz
+	InvitedBy      *int32
+	Role           *string
+	Status         string
 }
 
 type OrganisationUsersPermissiongroup struct {
 	OrganisationUsersID int32
 	PermissionGroupID   int32
+	ValidFrom           pgtype.Timestamptz
+	ValidUntil          pgtype.Timestamptz
 }
 
 type Permission struct {
@@ -55,8 +126,16 @@ type Permission struct {
 }
 
 type Permissiongroup struct {
-	ID   int32
-	Name *string
+	ID             int32
+	Name           *string
+	OrganisationID *int32
+}
+
+type PermissiongroupObjectPermission struct {
+	GroupID      int32
+	Permission   string
+	ResourceType string
+	ResourceID   int32
 }
 
 type PermissiongroupPermission struct {
@@ -66,15 +145,71 @@ type PermissiongroupPermission struct {
 }
 
 type User struct {
-	ID     int32
-	Name   string
-	Email  string
-	Joined pgtype.Timestamptz
-	Admin  bool
-	Lang   string
+	ID           int32
+	Name         string
+	Email        string
+	Joined       pgtype.Timestamptz
+	Admin        bool
+	Lang         string
+	Search       string
+	DeletedAt    pgtype.Timestamptz
+	AnonymisedAt pgtype.Timestamptz
+}
+
+type UserErasure struct {
+	ID          int32
+	UserID      int32
+	Mode        string
+	RequestedBy *int32
+	Reason      *string
+	ErasedAt    pgtype.Timestamptz
+}
+
+type UserObjectPermission struct {
+	UserID       int32
+	Permission   string
+	ResourceType string
+	ResourceID   int32
 }
 
 type UserPermissiongroupMembership struct {
-	GroupID int32
-	UserID  int32
+	GroupID    int32
+	UserID     int32
+	ValidFrom  pgtype.Timestamptz
+	ValidUntil pgtype.Timestamptz
+}
+
+type WebhookAttempt struct {
+	ID           int32
+	DeliveryID   int32
+	AttemptedAt  pgtype.Timestamptz
+	ResponseCode *int32
+	Error        *string
+	DurationMs   int32
+}
+
+type WebhookDelivery struct {
+	ID            int32
+	EndpointID    int32
+	EventID       string
+	EventType     string
+	Payload       []byte
+	Status        string
+	Attempts      int32
+	NextAttemptAt pgtype.Timestamptz
+	LockedUntil   pgtype.Timestamptz
+	ResponseCode  *int32
+	LastError     *string
+	CreatedAt     pgtype.Timestamptz
+	DeliveredAt   pgtype.Timestamptz
+}
+
+type WebhookEndpoint struct {
+	ID             int32
+	OrganisationID int32
+	Url            string
+	Secret         string
+	Events         []string
+	Enabled        bool
+	CreatedAt      pgtype.Timestamptz
 }
@@ -0,0 +1,229 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: object_permissions.sql
+
+package sqlc
+
+import (
+	"context"
+)
+
+const grantPermissionGroupObjectPermission = `-- name: GrantPermissionGroupObjectPermission :exec
+INSERT INTO permissiongroup_object_permissions (group_id, permission, resource_type, resource_id)
+    VALUES ($1, $2, $3, $4)
+ON CONFLICT
+    DO NOTHING
+`
+
+type GrantPermissionGroupObjectPermissionParams struct {
+	GroupID      int32
+	Permission   string
+	ResourceType string
+	ResourceID   int32
+}
+
+func (q *Queries) GrantPermissionGroupObjectPermission(ctx context.Context, arg GrantPermissionGroupObjectPermissionParams) error {
+	_, err := q.db.Exec(ctx, grantPermissionGroupObjectPermission,
+		arg.GroupID,
+		arg.Permission,
+		arg.ResourceType,
+		arg.ResourceID,
+	)
+	return err
+}
+
+const grantUserObjectPermission = `-- name: GrantUserObjectPermission :exec
+INSERT INTO user_object_permissions (user_id, permission, resource_type, resource_id)
+    VALUES ($1, $2, $3, $4)
+ON CONFLICT
+    DO NOTHING
+`
+
+type GrantUserObjectPermissionParams struct {
+	UserID       int32
+	Permission   string
+	ResourceType string
+	ResourceID   int32
+}
+
+func (q *Queries) GrantUserObjectPermission(ctx context.Context, arg GrantUserObjectPermissionParams) error {
+	_, err := q.db.Exec(ctx, grantUserObjectPermission,
+		arg.UserID,
+		arg.Permission,
+		arg.ResourceType,
+		arg.ResourceID,
+	)
+	return err
+}
+
+const hasObjectPermission = `-- name: HasObjectPermission :one
+SELECT
+    EXISTS (
+        SELECT
+            1
+        FROM
+            user_object_permissions uop
+        WHERE
+            uop.user_id = $1
+            AND uop.permission = $2
+            AND uop.resource_type = $3
+            AND uop.resource_id = $4
+        UNION ALL
+        SELECT
+            1
+        FROM
+            permissiongroup_object_permissions gop
+            INNER JOIN user_permissiongroup_membership usr ON usr.group_id = gop.group_id
+        WHERE
+            usr.user_id = $1
+            AND (usr.valid_from IS NULL
+                OR usr.valid_from <= NOW())
+            AND (usr.valid_until IS NULL
+                OR usr.valid_until > NOW())
+            AND gop.permission = $2
+            AND gop.resource_type = $3
+            AND gop.resource_id = $4
+            AND NOT EXISTS (
+                SELECT
+                    1
+                FROM
+                    permissiongroups pg
+                    INNER JOIN organisations o ON o.id = pg.organisation_id
+                WHERE
+                    pg.id = gop.group_id
+                    AND o.deleted_at IS NOT NULL))
+`
+
+type HasObjectPermissionParams struct {
+	UserID       int32
+	Permission   string
+	ResourceType string
+	ResourceID   int32
+}
+
+// Groups of soft deleted organisations no longer grant anything
+func (q *Queries) HasObjectPermission(ctx context.Context, arg HasObjectPermissionParams) (bool, error) {
+	row := q.db.QueryRow(ctx, hasObjectPermission,
+		arg.UserID,
+		arg.Permission,
+		arg.ResourceType,
+		arg.ResourceID,
+	)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const listObjectResourceIDsForUser = `-- name: ListObjectResourceIDsForUser :many
+SELECT
+    uop.resource_id
+FROM
+    user_object_permissions uop
+WHERE
+    uop.user_id = $1
+    AND uop.permission = $2
+    AND uop.resource_type = $3
+UNION
+SELECT
+    gop.resource_id
+FROM
+    permissiongroup_object_permissions gop
+    INNER JOIN user_permissiongroup_membership usr ON usr.group_id = gop.group_id
+WHERE
+    usr.user_id = $1
+    AND (usr.valid_from IS NULL
+        OR usr.valid_from <= NOW())
+    AND (usr.valid_until IS NULL
+        OR usr.valid_until > NOW())
+    AND gop.permission = $2
+    AND gop.resource_type = $3
+    AND NOT EXISTS (
+        SELECT
+            1
+        FROM
+            permissiongroups pg
+            INNER JOIN organisations o ON o.id = pg.organisation_id
+        WHERE
+            pg.id = gop.group_id
+            AND o.deleted_at IS NOT NULL)
+ORDER BY
+    resource_id
+`
+
+type ListObjectResourceIDsForUserParams struct {
+	UserID       int32
+	Permission   string
+	ResourceType string
+}
+
+// Groups of soft deleted organisations no longer grant anything
+func (q *Queries) ListObjectResourceIDsForUser(ctx context.Context, arg ListObjectResourceIDsForUserParams) ([]int32, error) {
+	rows, err := q.db.Query(ctx, listObjectResourceIDsForUser, arg.UserID, arg.Permission, arg.ResourceType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var resource_id int32
+		if err := rows.Scan(&resource_id); err != nil {
+			return nil, err
+		}
+		items = append(items, resource_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokePermissionGroupObjectPermission = `-- name: RevokePermissionGroupObjectPermission :exec
+DELETE FROM permissiongroup_object_permissions
+WHERE group_id = $1
+    AND permission = $2
+    AND resource_type = $3
+    AND resource_id = $4
+`
+
+type RevokePermissionGroupObjectPermissionParams struct {
+	GroupID      int32
+	Permission   string
+	ResourceType string
+	ResourceID   int32
+}
+
+func (q *Queries) RevokePermissionGroupObjectPermission(ctx context.Context, arg RevokePermissionGroupObjectPermissionParams) error {
+	_, err := q.db.Exec(ctx, revokePermissionGroupObjectPermission,
+		arg.GroupID,
+		arg.Permission,
+		arg.ResourceType,
+		arg.ResourceID,
+	)
+	return err
+}
+
+const revokeUserObjectPermission = `-- name: RevokeUserObjectPermission :exec
+DELETE FROM user_object_permissions
+WHERE user_id = $1
+    AND permission = $2
+    AND resource_type = $3
+    AND resource_id = $4
+`
+
+type RevokeUserObjectPermissionParams struct {
+	UserID       int32
+	Permission   string
+	ResourceType string
+	ResourceID   int32
+}
+
+func (q *Queries) RevokeUserObjectPermission(ctx context.Context, arg RevokeUserObjectPermissionParams) error {
+	_, err := q.db.Exec(ctx, revokeUserObjectPermission,
+		arg.UserID,
+		arg.Permission,
+		arg.ResourceType,
+		arg.ResourceID,
+	)
+	return err
+}
@@ -7,8 +7,50 @@ package sqlc
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const addMembership = `-- name: AddMembership :exec
+INSERT INTO organisation_users(user_id, organisation_id, status, role, invited_by)
+    VALUES ($1, $2, $3, $4, $5)
+`
+
+type AddMembershipParams struct {
+	UserID         int32
+	OrganisationID int32
+	Status         string
+	Role           *string
+	InvitedBy      *int32
+}
+
+func (q *Queries) AddMembership(ctx context.Context, arg AddMembershipParams) error {
+	_, err := q.db.Exec(ctx, addMembership,
+		arg.UserID,
+		arg.OrganisationID,
+		arg.Status,
+		arg.Role,
+		arg.InvitedBy,
+	)
+	return err
+}
+
+const addOrganisationAddress = `-- name: AddOrganisationAddress :exec
+INSERT INTO organisation_addresses(organisation_id, address_id, type)
+    VALUES ($1, $2, $3)
+`
+
+type AddOrganisationAddressParams struct {
+	OrganisationID int32
+	AddressID      int32
+	Type           string
+}
+
+func (q *Queries) AddOrganisationAddress(ctx context.Context, arg AddOrganisationAddressParams) error {
+	_, err := q.db.Exec(ctx, addOrganisationAddress, arg.OrganisationID, arg.AddressID, arg.Type)
+	return err
+}
+
 const addUserToOrganisation = `-- name: AddUserToOrganisation :exec
 INSERT INTO organisation_users(user_id, organisation_id)
     VALUES ($1, $2)
@@ -19,27 +61,129 @@ func (q *Queries) AddUserToOrganisation(ctx context.Context, userID int32, organ
 	return err
 }
 
+const countOrganisationDescendants = `-- name: CountOrganisationDescendants :one
+WITH RECURSIVE descendants AS (
+    SELECT
+        o.id
+    FROM
+        organisations AS o
+    WHERE
+        o.parent_id = $1
+        AND o.deleted_at IS NULL
+    UNION ALL
+    SELECT
+        child.id
+    FROM
+        organisations AS child
+        INNER JOIN descendants AS d ON child.parent_id = d.id
+    WHERE
+        child.deleted_at IS NULL
+)
+SELECT
+    COUNT(*)
+FROM
+    descendants
+`
+
+func (q *Queries) CountOrganisationDescendants(ctx context.Context, parentID *int32) (int64, error) {
+	row := q.db.QueryRow(ctx, countOrganisationDescendants, parentID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createOrganisation = `-- name: CreateOrganisation :one
 INSERT INTO organisations(name, parent_id)
     VALUES ($1, $2)
 RETURNING
-    id, name, parent_id
+    id, name, parent_id, legal_name, vat_number, search, deleted_at
 `
 
 func (q *Queries) CreateOrganisation(ctx context.Context, name string, parentID *int32) (Organisation, error) {
 	row := q.db.QueryRow(ctx, createOrganisation, name, parentID)
 	var i Organisation
-	err := row.Scan(&i.ID, &i.Name, &i.ParentID)
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.ParentID,
+		&i.LegalName,
+		&i.VatNumber,
+		&i.Search,
+		&i.DeletedAt,
+	)
 	return i, err
 }
 
-const deleteOrganisation = `-- name: DeleteOrganisation :exec
-DELETE FROM organisations
-WHERE id = $1
+const deleteOrganisation = `-- name: DeleteOrganisation :execrows
+WITH RECURSIVE tree AS (
+    SELECT
+        o.id
+    FROM
+        organisations AS o
+    WHERE
+        o.id = $1
+        AND o.deleted_at IS NULL
+    UNION ALL
+    SELECT
+        child.id
+    FROM
+        organisations AS child
+        INNER JOIN tree AS t ON child.parent_id = t.id
+    WHERE
+        child.deleted_at IS NULL
+)
+UPDATE
+    organisations
+SET
+    deleted_at = NOW()
+WHERE
+    id IN (
+        SELECT
+            id
+        FROM
+            tree)
+`
+
+// Soft deletes the organisation and its descendants at once, so they can be restored together
+func (q *Queries) DeleteOrganisation(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOrganisation, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteOrganisationTreeAddresses = `-- name: DeleteOrganisationTreeAddresses :exec
+WITH RECURSIVE tree AS (
+    SELECT
+        o.id
+    FROM
+        organisations AS o
+    WHERE
+        o.id = $1
+    UNION ALL
+    SELECT
+        child.id
+    FROM
+        organisations AS child
+        INNER JOIN tree AS t ON child.parent_id = t.id
+)
+DELETE FROM address
+WHERE id IN (
+        SELECT
+            oa.address_id
+        FROM
+            organisation_addresses AS oa
+        WHERE
+            oa.organisation_id IN (
+                SELECT
+                    id
+                FROM
+                    tree))
 `
 
-func (q *Queries) DeleteOrganisation(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, deleteOrganisation, id)
+func (q *Queries) DeleteOrganisationTreeAddresses(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, deleteOrganisationTreeAddresses, id)
 	return err
 }
 
@@ -48,6 +192,8 @@ SELECT
     COUNT(id)
 FROM
     organisations
+WHERE
+    deleted_at IS NULL
 `
 
 func (q *Queries) GetAmountOfOrganisations(ctx context.Context) (int64, error) {
@@ -59,13 +205,15 @@ func (q *Queries) GetAmountOfOrganisations(ctx context.Context) (int64, error) {
 
 const getMember = `-- name: GetMember :one
 SELECT
-    users.id, users.name, users.email, users.joined, users.admin, users.lang
+    users.id, users.name, users.email, users.joined, users.admin, users.lang, users.search, users.deleted_at, users.anonymised_at
 FROM
     users
     INNER JOIN organisation_users ON organisation_users.user_id = users.id
 WHERE
     organisation_users.organisation_id = $2
     AND users.id = $1
+    AND organisation_users.status = 'active'
+    AND users.deleted_at IS NULL
 `
 
 func (q *Queries) GetMember(ctx context.Context, iD int32, organisationID int32) (User, error) {
@@ -78,19 +226,24 @@ func (q *Queries) GetMember(ctx context.Context, iD int32, organisationID int32)
 		&i.Joined,
 		&i.Admin,
 		&i.Lang,
+		&i.Search,
+		&i.DeletedAt,
+		&i.AnonymisedAt,
 	)
 	return i, err
 }
 
 const getMemberByEmail = `-- name: GetMemberByEmail :one
 SELECT
-    users.id, users.name, users.email, users.joined, users.admin, users.lang
+    users.id, users.name, users.email, users.joined, users.admin, users.lang, users.search, users.deleted_at, users.anonymised_at
 FROM
     users
     INNER JOIN organisation_users ON organisation_users.user_id = users.id
 WHERE
     organisation_users.organisation_id = $1
     AND users.email = $2
+    AND organisation_users.status = 'active'
+    AND users.deleted_at IS NULL
 `
 
 func (q *Queries) GetMemberByEmail(ctx context.Context, organisationID int32, email string) (User, error) {
@@ -103,23 +256,106 @@ func (q *Queries) GetMemberByEmail(ctx context.Context, organisationID int32, em
 		&i.Joined,
 		&i.Admin,
 		&i.Lang,
+		&i.Search,
+		&i.DeletedAt,
+		&i.AnonymisedAt,
+	)
+	return i, err
+}
+
+const getMembership = `-- name: GetMembership :one
+SELECT
+    u.id, u.name, u.email, u.joined, u.admin, u.lang, u.search, u.deleted_at, u.anonymised_at,
+    ou.organisation_id,
+    ou.joined_at,
+    ou.invited_by,
+    ou.role,
+    ou.status
+FROM
+    users AS u
+    INNER JOIN organisation_users AS ou ON u.id = ou.user_id
+WHERE
+    ou.user_id = $1
+    AND ou.organisation_id = $2
+    AND u.deleted_at IS NULL
+`
+
+type GetMembershipRow struct {
+	User           User
+	OrganisationID int32
+	JoinedAt       pgtype.Timestamptz
+	InvitedBy      *int32
+	Role           *string
+	Status         string
+}
+
+func (q *Queries) GetMembership(ctx context.Context, userID int32, organisationID int32) (GetMembershipRow, error) {
+	row := q.db.QueryRow(ctx, getMembership, userID, organisationID)
+	var i GetMembershipRow
+	err := row.Scan(
+		&i.User.ID,
+		&i.User.Name,
+		&i.User.Email,
+		&i.User.Joined,
+		&i.User.Admin,
+		&i.User.Lang,
+		&i.User.Search,
+		&i.User.DeletedAt,
+		&i.User.AnonymisedAt,
+		&i.OrganisationID,
+		&i.JoinedAt,
+		&i.InvitedBy,
+		&i.Role,
+		&i.Status,
 	)
 	return i, err
 }
 
 const getOrganisation = `-- name: GetOrganisation :one
 SELECT
-    id, name, parent_id
+    id, name, parent_id, legal_name, vat_number, search, deleted_at
 FROM
     organisations
 WHERE
     id = $1
+    AND deleted_at IS NULL
 `
 
 func (q *Queries) GetOrganisation(ctx context.Context, id int32) (Organisation, error) {
 	row := q.db.QueryRow(ctx, getOrganisation, id)
 	var i Organisation
-	err := row.Scan(&i.ID, &i.Name, &i.ParentID)
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.ParentID,
+		&i.LegalName,
+		&i.VatNumber,
+		&i.Search,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getOrganisationBillingDetails = `-- name: GetOrganisationBillingDetails :one
+SELECT
+    legal_name,
+    vat_number
+FROM
+    organisations
+WHERE
+    id = $1
+    AND deleted_at IS NULL
+`
+
+type GetOrganisationBillingDetailsRow struct {
+	LegalName *string
+	VatNumber *string
+}
+
+func (q *Queries) GetOrganisationBillingDetails(ctx context.Context, id int32) (GetOrganisationBillingDetailsRow, error) {
+	row := q.db.QueryRow(ctx, getOrganisationBillingDetails, id)
+	var i GetOrganisationBillingDetailsRow
+	err := row.Scan(&i.LegalName, &i.VatNumber)
 	return i, err
 }
 
@@ -139,13 +375,272 @@ func (q *Queries) GetParentOrganisation(ctx context.Context, id int32) (*int32,
 	return parent_id, err
 }
 
+const isOrganisationDeleted = `-- name: IsOrganisationDeleted :one
+SELECT
+    (deleted_at IS NOT NULL)::boolean AS deleted
+FROM
+    organisations
+WHERE
+    id = $1
+`
+
+func (q *Queries) IsOrganisationDeleted(ctx context.Context, id int32) (bool, error) {
+	row := q.db.QueryRow(ctx, isOrganisationDeleted, id)
+	var deleted bool
+	err := row.Scan(&deleted)
+	return deleted, err
+}
+
+const listMemberships = `-- name: ListMemberships :many
+SELECT
+    u.id, u.name, u.email, u.joined, u.admin, u.lang, u.search, u.deleted_at, u.anonymised_at,
+    ou.organisation_id,
+    ou.joined_at,
+    ou.invited_by,
+    ou.role,
+    ou.status
+FROM
+    users AS u
+    INNER JOIN organisation_users AS ou ON u.id = ou.user_id
+WHERE
+    ou.organisation_id = $1
+    AND u.deleted_at IS NULL
+ORDER BY
+    ou.joined_at,
+    u.id
+`
+
+type ListMembershipsRow struct {
+	User           User
+	OrganisationID int32
+	JoinedAt       pgtype.Timestamptz
+	InvitedBy      *int32
+	Role           *string
+	Status         string
+}
+
+func (q *Queries) ListMemberships(ctx context.Context, organisationID int32) ([]ListMembershipsRow, error) {
+	rows, err := q.db.Query(ctx, listMemberships, organisationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListMembershipsRow
+	for rows.Next() {
+		var i ListMembershipsRow
+		if err := rows.Scan(
+			&i.User.ID,
+			&i.User.Name,
+			&i.User.Email,
+			&i.User.Joined,
+			&i.User.Admin,
+			&i.User.Lang,
+			&i.User.Search,
+			&i.User.DeletedAt,
+			&i.User.AnonymisedAt,
+			&i.OrganisationID,
+			&i.JoinedAt,
+			&i.InvitedBy,
+			&i.Role,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMembershipsForUser = `-- name: ListMembershipsForUser :many
+SELECT
+    u.id, u.name, u.email, u.joined, u.admin, u.lang, u.search, u.deleted_at, u.anonymised_at,
+    ou.organisation_id,
+    ou.joined_at,
+    ou.invited_by,
+    ou.role,
+    ou.status
+FROM
+    users AS u
+    INNER JOIN organisation_users AS ou ON u.id = ou.user_id
+    INNER JOIN organisations AS o ON o.id = ou.organisation_id
+WHERE
+    ou.user_id = $1
+    AND u.deleted_at IS NULL
+    AND o.deleted_at IS NULL
+ORDER BY
+    ou.organisation_id
+`
+
+type ListMembershipsForUserRow struct {
+	User           User
+	OrganisationID int32
+	JoinedAt       pgtype.Timestamptz
+	InvitedBy      *int32
+	Role           *string
+	Status         string
+}
+
+func (q *Queries) ListMembershipsForUser(ctx context.Context, userID int32) ([]ListMembershipsForUserRow, error) {
+	rows, err := q.db.Query(ctx, listMembershipsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListMembershipsForUserRow
+	for rows.Next() {
+		var i ListMembershipsForUserRow
+		if err := rows.Scan(
+			&i.User.ID,
+			&i.User.Name,
+			&i.User.Email,
+			&i.User.Joined,
+			&i.User.Admin,
+			&i.User.Lang,
+			&i.User.Search,
+			&i.User.DeletedAt,
+			&i.User.AnonymisedAt,
+			&i.OrganisationID,
+			&i.JoinedAt,
+			&i.InvitedBy,
+			&i.Role,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOrganisationAddresses = `-- name: ListOrganisationAddresses :many
+SELECT
+    a.id, a.street, a.number, a.postal_code, a.city, a.country, a.extra_line,
+    oa.type
+FROM
+    address AS a
+    INNER JOIN organisation_addresses AS oa ON a.id = oa.address_id
+WHERE
+    oa.organisation_id = $1
+ORDER BY
+    oa.type
+`
+
+type ListOrganisationAddressesRow struct {
+	Address Address
+	Type    string
+}
+
+func (q *Queries) ListOrganisationAddresses(ctx context.Context, organisationID int32) ([]ListOrganisationAddressesRow, error) {
+	rows, err := q.db.Query(ctx, listOrganisationAddresses, organisationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListOrganisationAddressesRow
+	for rows.Next() {
+		var i ListOrganisationAddressesRow
+		if err := rows.Scan(
+			&i.Address.ID,
+			&i.Address.Street,
+			&i.Address.Number,
+			&i.Address.PostalCode,
+			&i.Address.City,
+			&i.Address.Country,
+			&i.Address.ExtraLine,
+			&i.Type,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOrganisationAncestors = `-- name: ListOrganisationAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT
+        o.id,
+        o.name,
+        o.parent_id,
+        0 AS depth
+    FROM
+        organisations AS o
+    WHERE
+        o.id = $1
+    UNION ALL
+    SELECT
+        parent.id,
+        parent.name,
+        parent.parent_id,
+        a.depth + 1
+    FROM
+        organisations AS parent
+        INNER JOIN ancestors AS a ON parent.id = a.parent_id
+    WHERE
+        parent.deleted_at IS NULL
+)
+SELECT
+    id,
+    name,
+    parent_id,
+    depth::integer AS depth
+FROM
+    ancestors
+WHERE
+    depth > 0
+ORDER BY
+    depth
+`
+
+type ListOrganisationAncestorsRow struct {
+	ID       int32
+	Name     string
+	ParentID *int32
+	Depth    int32
+}
+
+func (q *Queries) ListOrganisationAncestors(ctx context.Context, id int32) ([]ListOrganisationAncestorsRow, error) {
+	rows, err := q.db.Query(ctx, listOrganisationAncestors, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListOrganisationAncestorsRow
+	for rows.Next() {
+		var i ListOrganisationAncestorsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.ParentID,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listOrganisationChildren = `-- name: ListOrganisationChildren :many
 SELECT
-    id, name, parent_id
+    id, name, parent_id, legal_name, vat_number, search, deleted_at
 FROM
     organisations
 WHERE
     parent_id = $1
+    AND deleted_at IS NULL
 `
 
 func (q *Queries) ListOrganisationChildren(ctx context.Context, parentID *int32) ([]Organisation, error) {
@@ -157,7 +652,15 @@ func (q *Queries) ListOrganisationChildren(ctx context.Context, parentID *int32)
 	var items []Organisation
 	for rows.Next() {
 		var i Organisation
-		if err := rows.Scan(&i.ID, &i.Name, &i.ParentID); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.ParentID,
+			&i.LegalName,
+			&i.VatNumber,
+			&i.Search,
+			&i.DeletedAt,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -168,23 +671,65 @@ func (q *Queries) ListOrganisationChildren(ctx context.Context, parentID *int32)
 	return items, nil
 }
 
-const listOrganisations = `-- name: ListOrganisations :many
+const listOrganisationDescendants = `-- name: ListOrganisationDescendants :many
+WITH RECURSIVE descendants AS (
+    SELECT
+        o.id,
+        o.name,
+        o.parent_id,
+        1 AS depth
+    FROM
+        organisations AS o
+    WHERE
+        o.parent_id = $1
+        AND o.deleted_at IS NULL
+    UNION ALL
+    SELECT
+        child.id,
+        child.name,
+        child.parent_id,
+        d.depth + 1
+    FROM
+        organisations AS child
+        INNER JOIN descendants AS d ON child.parent_id = d.id
+    WHERE
+        child.deleted_at IS NULL
+)
 SELECT
-    id, name, parent_id
+    id,
+    name,
+    parent_id,
+    depth::integer AS depth
 FROM
-    organisations
+    descendants
+ORDER BY
+    depth,
+    name,
+    id
 `
 
-func (q *Queries) ListOrganisations(ctx context.Context) ([]Organisation, error) {
-	rows, err := q.db.Query(ctx, listOrganisations)
+type ListOrganisationDescendantsRow struct {
+	ID       int32
+	Name     string
+	ParentID *int32
+	Depth    int32
+}
+
+func (q *Queries) ListOrganisationDescendants(ctx context.Context, parentID *int32) ([]ListOrganisationDescendantsRow, error) {
+	rows, err := q.db.Query(ctx, listOrganisationDescendants, parentID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Organisation
+	var items []ListOrganisationDescendantsRow
 	for rows.Next() {
-		var i Organisation
-		if err := rows.Scan(&i.ID, &i.Name, &i.ParentID); err != nil {
+		var i ListOrganisationDescendantsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.ParentID,
+			&i.Depth,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -197,12 +742,14 @@ func (q *Queries) ListOrganisations(ctx context.Context) ([]Organisation, error)
 
 const listOrganisationsForUser = `-- name: ListOrganisationsForUser :many
 SELECT
-    o.id, o.name, o.parent_id
+    o.id, o.name, o.parent_id, o.legal_name, o.vat_number, o.search, o.deleted_at
 FROM
     organisations AS o
     INNER JOIN organisation_users AS ou ON o.id = ou.organisation_id
 WHERE
     ou.user_id = $1
+    AND ou.status = 'active'
+    AND o.deleted_at IS NULL
 `
 
 func (q *Queries) ListOrganisationsForUser(ctx context.Context, userID int32) ([]Organisation, error) {
@@ -214,7 +761,15 @@ func (q *Queries) ListOrganisationsForUser(ctx context.Context, userID int32) ([
 	var items []Organisation
 	for rows.Next() {
 		var i Organisation
-		if err := rows.Scan(&i.ID, &i.Name, &i.ParentID); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.ParentID,
+			&i.LegalName,
+			&i.VatNumber,
+			&i.Search,
+			&i.DeletedAt,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -225,36 +780,35 @@ func (q *Queries) ListOrganisationsForUser(ctx context.Context, userID int32) ([
 	return items, nil
 }
 
-const listUsersInOrganisation = `-- name: ListUsersInOrganisation :many
+const listPurgeableOrganisations = `-- name: ListPurgeableOrganisations :many
 SELECT
-    u.id, u.name, u.email, u.joined, u.admin, u.lang
+    o.id
 FROM
-    users AS u
-    INNER JOIN organisation_users AS ou ON u.id = ou.user_id
+    organisations AS o
+    LEFT JOIN organisations AS parent ON parent.id = o.parent_id
 WHERE
-    ou.organisation_id = $1
+    o.deleted_at < $1
+    AND (parent.id IS NULL
+        OR parent.deleted_at IS NULL
+        OR parent.deleted_at >= $1)
+ORDER BY
+    o.id
 `
 
-func (q *Queries) ListUsersInOrganisation(ctx context.Context, organisationID int32) ([]User, error) {
-	rows, err := q.db.Query(ctx, listUsersInOrganisation, organisationID)
+// Only returns the roots of deleted subtrees, since purging those removes their descendants as well
+func (q *Queries) ListPurgeableOrganisations(ctx context.Context, deletedAt pgtype.Timestamptz) ([]int32, error) {
+	rows, err := q.db.Query(ctx, listPurgeableOrganisations, deletedAt)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []User
+	var items []int32
 	for rows.Next() {
-		var i User
-		if err := rows.Scan(
-			&i.ID,
-			&i.Name,
-			&i.Email,
-			&i.Joined,
-			&i.Admin,
-			&i.Lang,
-		); err != nil {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
 			return nil, err
 		}
-		items = append(items, i)
+		items = append(items, id)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
@@ -262,6 +816,85 @@ func (q *Queries) ListUsersInOrganisation(ctx context.Context, organisationID in
 	return items, nil
 }
 
+const lockOrganisation = `-- name: LockOrganisation :one
+SELECT
+    id
+FROM
+    organisations
+WHERE
+    id = $1
+    AND deleted_at IS NULL
+FOR UPDATE
+`
+
+func (q *Queries) LockOrganisation(ctx context.Context, id int32) (int32, error) {
+	row := q.db.QueryRow(ctx, lockOrganisation, id)
+	err := row.Scan(&id)
+	return id, err
+}
+
+const lockOrganisationHierarchy = `-- name: LockOrganisationHierarchy :exec
+SELECT
+    pg_advisory_xact_lock(hashtext('apollo_organisation_hierarchy'))
+`
+
+func (q *Queries) LockOrganisationHierarchy(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, lockOrganisationHierarchy)
+	return err
+}
+
+const moveOrganisation = `-- name: MoveOrganisation :one
+UPDATE
+    organisations
+SET
+    parent_id = $2
+WHERE
+    id = $1
+    AND deleted_at IS NULL
+RETURNING
+    id, name, parent_id, legal_name, vat_number, search, deleted_at
+`
+
+func (q *Queries) MoveOrganisation(ctx context.Context, iD int32, parentID *int32) (Organisation, error) {
+	row := q.db.QueryRow(ctx, moveOrganisation, iD, parentID)
+	var i Organisation
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.ParentID,
+		&i.LegalName,
+		&i.VatNumber,
+		&i.Search,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const purgeOrganisation = `-- name: PurgeOrganisation :exec
+DELETE FROM organisations
+WHERE id = $1
+`
+
+func (q *Queries) PurgeOrganisation(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, purgeOrganisation, id)
+	return err
+}
+
+const removeOrganisationAddress = `-- name: RemoveOrganisationAddress :one
+DELETE FROM organisation_addresses
+WHERE organisation_id = $1
+    AND type = $2
+RETURNING
+    address_id
+`
+
+func (q *Queries) RemoveOrganisationAddress(ctx context.Context, organisationID int32, type_ string) (int32, error) {
+	row := q.db.QueryRow(ctx, removeOrganisationAddress, organisationID, type_)
+	var address_id int32
+	err := row.Scan(&address_id)
+	return address_id, err
+}
+
 const removeUserFromOrganisation = `-- name: RemoveUserFromOrganisation :exec
 DELETE FROM organisation_users
 WHERE user_id = $1
@@ -273,6 +906,175 @@ func (q *Queries) RemoveUserFromOrganisation(ctx context.Context, userID int32,
 	return err
 }
 
+const restoreOrganisation = `-- name: RestoreOrganisation :execrows
+WITH RECURSIVE tree AS (
+    SELECT
+        o.id,
+        o.deleted_at
+    FROM
+        organisations AS o
+    WHERE
+        o.id = $1
+        AND o.deleted_at IS NOT NULL
+    UNION ALL
+    SELECT
+        child.id,
+        t.deleted_at
+    FROM
+        organisations AS child
+        INNER JOIN tree AS t ON child.parent_id = t.id
+    WHERE
+        child.deleted_at = t.deleted_at
+)
+UPDATE
+    organisations
+SET
+    deleted_at = NULL
+WHERE
+    id IN (
+        SELECT
+            id
+        FROM
+            tree)
+`
+
+// Restores the organisation and the descendants that were deleted together with it
+func (q *Queries) RestoreOrganisation(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, restoreOrganisation, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const searchOrganisations = `-- name: SearchOrganisations :many
+SELECT
+    o.id, o.name, o.parent_id, o.legal_name, o.vat_number, o.search, o.deleted_at,
+    (ts_rank(o.search, websearch_to_tsquery('simple', $1::text)) + public.word_similarity($1::text, o.name))::real AS rank
+FROM
+    organisations AS o
+WHERE (o.search @@ websearch_to_tsquery('simple', $1::text)
+    OR o.name ILIKE $2::text
+    OR $1::text OPERATOR(public.<%) o.name)
+AND o.deleted_at IS NULL
+AND ($3::integer IS NULL
+    OR EXISTS (
+        SELECT
+            1
+        FROM
+            organisation_users AS ou
+        WHERE
+            ou.organisation_id = o.id
+            AND ou.user_id = $3::integer
+            AND ou.status = 'active'))
+ORDER BY
+    rank DESC,
+    o.name,
+    o.id
+LIMIT $4::integer
+`
+
+type SearchOrganisationsParams struct {
+	Query      string
+	Pattern    string
+	UserID     *int32
+	MaxResults int32
+}
+
+type SearchOrganisationsRow struct {
+	Organisation Organisation
+	Rank         float32
+}
+
+func (q *Queries) SearchOrganisations(ctx context.Context, arg SearchOrganisationsParams) ([]SearchOrganisationsRow, error) {
+	rows, err := q.db.Query(ctx, searchOrganisations,
+		arg.Query,
+		arg.Pattern,
+		arg.UserID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchOrganisationsRow
+	for rows.Next() {
+		var i SearchOrganisationsRow
+		if err := rows.Scan(
+			&i.Organisation.ID,
+			&i.Organisation.Name,
+			&i.Organisation.ParentID,
+			&i.Organisation.LegalName,
+			&i.Organisation.VatNumber,
+			&i.Organisation.Search,
+			&i.Organisation.DeletedAt,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateMembershipRole = `-- name: UpdateMembershipRole :execrows
+UPDATE
+    organisation_users
+SET
+    role = $3
+WHERE
+    user_id = $1
+    AND organisation_id = $2
+`
+
+type UpdateMembershipRoleParams struct {
+	UserID         int32
+	OrganisationID int32
+	Role           *string
+}
+
+func (q *Queries) UpdateMembershipRole(ctx context.Context, arg UpdateMembershipRoleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateMembershipRole, arg.UserID, arg.OrganisationID, arg.Role)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateMembershipStatus = `-- name: UpdateMembershipStatus :execrows
+UPDATE
+    organisation_users
+SET
+    status = $1::text,
+    -- Accepting an invitation is the moment the user actually joins
+    joined_at = CASE WHEN status = 'pending'
+        AND $1::text = 'active' THEN
+        NOW()
+    ELSE
+        joined_at
+    END
+WHERE
+    user_id = $2
+    AND organisation_id = $3
+`
+
+type UpdateMembershipStatusParams struct {
+	Status         string
+	UserID         int32
+	OrganisationID int32
+}
+
+func (q *Queries) UpdateMembershipStatus(ctx context.Context, arg UpdateMembershipStatusParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateMembershipStatus, arg.Status, arg.UserID, arg.OrganisationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateOrganisation = `-- name: UpdateOrganisation :one
 UPDATE
     organisations
@@ -280,13 +1082,43 @@ SET
     name = $2
 WHERE
     id = $1
+    AND deleted_at IS NULL
 RETURNING
-    id, name, parent_id
+    id, name, parent_id, legal_name, vat_number, search, deleted_at
 `
 
 func (q *Queries) UpdateOrganisation(ctx context.Context, iD int32, name string) (Organisation, error) {
 	row := q.db.QueryRow(ctx, updateOrganisation, iD, name)
 	var i Organisation
-	err := row.Scan(&i.ID, &i.Name, &i.ParentID)
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.ParentID,
+		&i.LegalName,
+		&i.VatNumber,
+		&i.Search,
+		&i.DeletedAt,
+	)
 	return i, err
 }
+
+const updateOrganisationBillingDetails = `-- name: UpdateOrganisationBillingDetails :exec
+UPDATE
+    organisations
+SET
+    legal_name = $1,
+    vat_number = $2
+WHERE
+    id = $3
+`
+
+type UpdateOrganisationBillingDetailsParams struct {
+	LegalName *string
+	VatNumber *string
+	ID        int32
+}
+
+func (q *Queries) UpdateOrganisationBillingDetails(ctx context.Context, arg UpdateOrganisationBillingDetailsParams) error {
+	_, err := q.db.Exec(ctx, updateOrganisationBillingDetails, arg.LegalName, arg.VatNumber, arg.ID)
+	return err
+}
@@ -7,8 +7,40 @@ package sqlc
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const addDefaultPermissionGroupForOrganisation = `-- name: AddDefaultPermissionGroupForOrganisation :exec
+INSERT INTO organisation_default_permissiongroups (organisation_id, permission_group_id)
+    VALUES ($1, $2)
+`
+
+func (q *Queries) AddDefaultPermissionGroupForOrganisation(ctx context.Context, organisationID int32, permissionGroupID int32) error {
+	_, err := q.db.Exec(ctx, addDefaultPermissionGroupForOrganisation, organisationID, permissionGroupID)
+	return err
+}
+
+const addUserToDefaultPermissionGroupsForOrganisation = `-- name: AddUserToDefaultPermissionGroupsForOrganisation :exec
+INSERT INTO organisation_users_permissiongroups (permission_group_id, organisation_users_id)
+SELECT
+    def.permission_group_id,
+    ou.id
+FROM
+    organisation_default_permissiongroups def
+    INNER JOIN organisation_users ou ON ou.organisation_id = def.organisation_id
+WHERE
+    ou.user_id = $1
+    AND ou.organisation_id = $2
+ON CONFLICT
+    DO NOTHING
+`
+
+func (q *Queries) AddUserToDefaultPermissionGroupsForOrganisation(ctx context.Context, userID int32, organisationID int32) error {
+	_, err := q.db.Exec(ctx, addUserToDefaultPermissionGroupsForOrganisation, userID, organisationID)
+	return err
+}
+
 const addUserToPermissionGroup = `-- name: AddUserToPermissionGroup :exec
 INSERT INTO user_permissiongroup_membership (group_id, user_id)
     VALUES ($1, $2)
@@ -42,6 +74,67 @@ func (q *Queries) AddUserToPermissionGroupForOrganisation(ctx context.Context, a
 	return err
 }
 
+const addUserToPermissionGroupForOrganisationWithValidity = `-- name: AddUserToPermissionGroupForOrganisationWithValidity :exec
+INSERT INTO organisation_users_permissiongroups (permission_group_id, organisation_users_id, valid_from, valid_until)
+    VALUES ($1, (
+            SELECT
+                id
+            FROM
+                organisation_users
+            WHERE
+                user_id = $2
+                AND organisation_id = $3), $4, $5)
+ON CONFLICT (organisation_users_id, permission_group_id)
+    DO UPDATE SET
+        valid_from = EXCLUDED.valid_from,
+        valid_until = EXCLUDED.valid_until
+`
+
+type AddUserToPermissionGroupForOrganisationWithValidityParams struct {
+	PermissionGroupID int32
+	UserID            int32
+	OrganisationID    int32
+	ValidFrom         pgtype.Timestamptz
+	ValidUntil        pgtype.Timestamptz
+}
+
+func (q *Queries) AddUserToPermissionGroupForOrganisationWithValidity(ctx context.Context, arg AddUserToPermissionGroupForOrganisationWithValidityParams) error {
+	_, err := q.db.Exec(ctx, addUserToPermissionGroupForOrganisationWithValidity,
+		arg.PermissionGroupID,
+		arg.UserID,
+		arg.OrganisationID,
+		arg.ValidFrom,
+		arg.ValidUntil,
+	)
+	return err
+}
+
+const addUserToPermissionGroupWithValidity = `-- name: AddUserToPermissionGroupWithValidity :exec
+INSERT INTO user_permissiongroup_membership (group_id, user_id, valid_from, valid_until)
+    VALUES ($1, $2, $3, $4)
+ON CONFLICT (group_id, user_id)
+    DO UPDATE SET
+        valid_from = EXCLUDED.valid_from,
+        valid_until = EXCLUDED.valid_until
+`
+
+type AddUserToPermissionGroupWithValidityParams struct {
+	GroupID    int32
+	UserID     int32
+	ValidFrom  pgtype.Timestamptz
+	ValidUntil pgtype.Timestamptz
+}
+
+func (q *Queries) AddUserToPermissionGroupWithValidity(ctx context.Context, arg AddUserToPermissionGroupWithValidityParams) error {
+	_, err := q.db.Exec(ctx, addUserToPermissionGroupWithValidity,
+		arg.GroupID,
+		arg.UserID,
+		arg.ValidFrom,
+		arg.ValidUntil,
+	)
+	return err
+}
+
 const createPermission = `-- name: CreatePermission :exec
 INSERT INTO permissions (name)
     VALUES ($1)
@@ -55,16 +148,16 @@ func (q *Queries) CreatePermission(ctx context.Context, name string) error {
 }
 
 const createPermissionGroup = `-- name: CreatePermissionGroup :one
-INSERT INTO permissiongroups (name)
-    VALUES ($1)
+INSERT INTO permissiongroups (name, organisation_id)
+    VALUES ($1, $2)
 RETURNING
-    id, name
+    id, name, organisation_id
 `
 
-func (q *Queries) CreatePermissionGroup(ctx context.Context, name *string) (Permissiongroup, error) {
-	row := q.db.QueryRow(ctx, createPermissionGroup, name)
+func (q *Queries) CreatePermissionGroup(ctx context.Context, name *string, organisationID *int32) (Permissiongroup, error) {
+	row := q.db.QueryRow(ctx, createPermissionGroup, name, organisationID)
 	var i Permissiongroup
-	err := row.Scan(&i.ID, &i.Name)
+	err := row.Scan(&i.ID, &i.Name, &i.OrganisationID)
 	return i, err
 }
 
@@ -85,19 +178,51 @@ func (q *Queries) CreatePermissionGroupPermission(ctx context.Context, arg Creat
 }
 
 const createPermissionGroupWithID = `-- name: CreatePermissionGroupWithID :one
-INSERT INTO permissiongroups (id, name)
-    VALUES ($1, $2)
+INSERT INTO permissiongroups (id, name, organisation_id)
+    VALUES ($1, $2, $3)
 RETURNING
-    id, name
+    id, name, organisation_id
 `
 
-func (q *Queries) CreatePermissionGroupWithID(ctx context.Context, iD int32, name *string) (Permissiongroup, error) {
-	row := q.db.QueryRow(ctx, createPermissionGroupWithID, iD, name)
+type CreatePermissionGroupWithIDParams struct {
+	ID             int32
+	Name           *string
+	OrganisationID *int32
+}
+
+func (q *Queries) CreatePermissionGroupWithID(ctx context.Context, arg CreatePermissionGroupWithIDParams) (Permissiongroup, error) {
+	row := q.db.QueryRow(ctx, createPermissionGroupWithID, arg.ID, arg.Name, arg.OrganisationID)
 	var i Permissiongroup
-	err := row.Scan(&i.ID, &i.Name)
+	err := row.Scan(&i.ID, &i.Name, &i.OrganisationID)
 	return i, err
 }
 
+const deleteExpiredOrganisationPermissionGroupMemberships = `-- name: DeleteExpiredOrganisationPermissionGroupMemberships :execrows
+DELETE FROM organisation_users_permissiongroups
+WHERE valid_until <= NOW()
+`
+
+func (q *Queries) DeleteExpiredOrganisationPermissionGroupMemberships(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredOrganisationPermissionGroupMemberships)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteExpiredPermissionGroupMemberships = `-- name: DeleteExpiredPermissionGroupMemberships :execrows
+DELETE FROM user_permissiongroup_membership
+WHERE valid_until <= NOW()
+`
+
+func (q *Queries) DeleteExpiredPermissionGroupMemberships(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredPermissionGroupMemberships)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const deletePermissionGroup = `-- name: DeletePermissionGroup :exec
 DELETE FROM permissiongroups
 WHERE permissiongroups.id = $1
@@ -108,9 +233,23 @@ func (q *Queries) DeletePermissionGroup(ctx context.Context, id int32) error {
 	return err
 }
 
+const deletePermissionGroupForOrganisation = `-- name: DeletePermissionGroupForOrganisation :execrows
+DELETE FROM permissiongroups
+WHERE permissiongroups.id = $1
+    AND permissiongroups.organisation_id = $2
+`
+
+func (q *Queries) DeletePermissionGroupForOrganisation(ctx context.Context, iD int32, organisationID *int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deletePermissionGroupForOrganisation, iD, organisationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getPermissionGroup = `-- name: GetPermissionGroup :one
 SELECT
-    pg.id, pg.name
+    pg.id, pg.name, pg.organisation_id
 FROM
     permissiongroups pg
 WHERE
@@ -120,7 +259,7 @@ WHERE
 func (q *Queries) GetPermissionGroup(ctx context.Context, id int32) (Permissiongroup, error) {
 	row := q.db.QueryRow(ctx, getPermissionGroup, id)
 	var i Permissiongroup
-	err := row.Scan(&i.ID, &i.Name)
+	err := row.Scan(&i.ID, &i.Name, &i.OrganisationID)
 	return i, err
 }
 
@@ -159,9 +298,97 @@ func (q *Queries) GetPermissionsForGroup(ctx context.Context, groupID int32) ([]
 	return items, nil
 }
 
+const listDefaultPermissionGroupsForOrganisation = `-- name: ListDefaultPermissionGroupsForOrganisation :many
+SELECT
+    pg.id, pg.name, pg.organisation_id
+FROM
+    permissiongroups pg
+    INNER JOIN organisation_default_permissiongroups def ON def.permission_group_id = pg.id
+WHERE
+    def.organisation_id = $1
+`
+
+func (q *Queries) ListDefaultPermissionGroupsForOrganisation(ctx context.Context, organisationID int32) ([]Permissiongroup, error) {
+	rows, err := q.db.Query(ctx, listDefaultPermissionGroupsForOrganisation, organisationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Permissiongroup
+	for rows.Next() {
+		var i Permissiongroup
+		if err := rows.Scan(&i.ID, &i.Name, &i.OrganisationID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOrganisationUsersInPermissionGroup = `-- name: ListOrganisationUsersInPermissionGroup :many
+SELECT
+    u.id, u.name, u.email, u.joined, u.admin, u.lang, u.search, u.deleted_at, u.anonymised_at,
+    ou.organisation_id,
+    org_usr.valid_from,
+    org_usr.valid_until
+FROM
+    users u
+    INNER JOIN organisation_users ou ON ou.user_id = u.id
+    INNER JOIN organisation_users_permissiongroups org_usr ON org_usr.organisation_users_id = ou.id
+WHERE
+    org_usr.permission_group_id = $1
+    AND u.deleted_at IS NULL
+ORDER BY
+    ou.organisation_id,
+    u.id
+`
+
+type ListOrganisationUsersInPermissionGroupRow struct {
+	User           User
+	OrganisationID int32
+	ValidFrom      pgtype.Timestamptz
+	ValidUntil     pgtype.Timestamptz
+}
+
+func (q *Queries) ListOrganisationUsersInPermissionGroup(ctx context.Context, permissionGroupID int32) ([]ListOrganisationUsersInPermissionGroupRow, error) {
+	rows, err := q.db.Query(ctx, listOrganisationUsersInPermissionGroup, permissionGroupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListOrganisationUsersInPermissionGroupRow
+	for rows.Next() {
+		var i ListOrganisationUsersInPermissionGroupRow
+		if err := rows.Scan(
+			&i.User.ID,
+			&i.User.Name,
+			&i.User.Email,
+			&i.User.Joined,
+			&i.User.Admin,
+			&i.User.Lang,
+			&i.User.Search,
+			&i.User.DeletedAt,
+			&i.User.AnonymisedAt,
+			&i.OrganisationID,
+			&i.ValidFrom,
+			&i.ValidUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listPermissionGroups = `-- name: ListPermissionGroups :many
 SELECT
-    permissiongroups.id, permissiongroups.name
+    permissiongroups.id, permissiongroups.name, permissiongroups.organisation_id
 FROM
     permissiongroups
 `
@@ -175,7 +402,39 @@ func (q *Queries) ListPermissionGroups(ctx context.Context) ([]Permissiongroup,
 	var items []Permissiongroup
 	for rows.Next() {
 		var i Permissiongroup
-		if err := rows.Scan(&i.ID, &i.Name); err != nil {
+		if err := rows.Scan(&i.ID, &i.Name, &i.OrganisationID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPermissionGroupsForOrganisation = `-- name: ListPermissionGroupsForOrganisation :many
+SELECT
+    permissiongroups.id, permissiongroups.name, permissiongroups.organisation_id
+FROM
+    permissiongroups
+WHERE
+    organisation_id IS NULL
+    OR organisation_id = $1
+ORDER BY
+    id
+`
+
+func (q *Queries) ListPermissionGroupsForOrganisation(ctx context.Context, organisationID *int32) ([]Permissiongroup, error) {
+	rows, err := q.db.Query(ctx, listPermissionGroupsForOrganisation, organisationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Permissiongroup
+	for rows.Next() {
+		var i Permissiongroup
+		if err := rows.Scan(&i.ID, &i.Name, &i.OrganisationID); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -188,12 +447,16 @@ func (q *Queries) ListPermissionGroups(ctx context.Context) ([]Permissiongroup,
 
 const listPermissionGroupsForUser = `-- name: ListPermissionGroupsForUser :many
 SELECT
-    pg.id, pg.name
+    pg.id, pg.name, pg.organisation_id
 FROM
     permissiongroups pg
     INNER JOIN user_permissiongroup_membership usr ON usr.group_id = pg.id
 WHERE
     usr.user_id = $1
+    AND (usr.valid_from IS NULL
+        OR usr.valid_from <= NOW())
+    AND (usr.valid_until IS NULL
+        OR usr.valid_until > NOW())
 `
 
 func (q *Queries) ListPermissionGroupsForUser(ctx context.Context, userID int32) ([]Permissiongroup, error) {
@@ -205,7 +468,7 @@ func (q *Queries) ListPermissionGroupsForUser(ctx context.Context, userID int32)
 	var items []Permissiongroup
 	for rows.Next() {
 		var i Permissiongroup
-		if err := rows.Scan(&i.ID, &i.Name); err != nil {
+		if err := rows.Scan(&i.ID, &i.Name, &i.OrganisationID); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -218,7 +481,7 @@ func (q *Queries) ListPermissionGroupsForUser(ctx context.Context, userID int32)
 
 const listPermissionGroupsForUserForOrganisation = `-- name: ListPermissionGroupsForUserForOrganisation :many
 SELECT
-    pg.id, pg.name
+    pg.id, pg.name, pg.organisation_id
 FROM
     permissiongroups pg
     INNER JOIN organisation_users_permissiongroups org_usr ON org_usr.permission_group_id = pg.id
@@ -227,10 +490,17 @@ WHERE
         SELECT
             id
         FROM
-            organisation_users
+            organisation_users ou
+            INNER JOIN organisations o ON o.id = ou.organisation_id
+                AND o.deleted_at IS NULL
         WHERE
-            user_id = $1
-            AND organisation_id = $2)
+            ou.user_id = $1
+            AND ou.organisation_id = $2
+            AND ou.status = 'active')
+    AND (org_usr.valid_from IS NULL
+        OR org_usr.valid_from <= NOW())
+    AND (org_usr.valid_until IS NULL
+        OR org_usr.valid_until > NOW())
 `
 
 func (q *Queries) ListPermissionGroupsForUserForOrganisation(ctx context.Context, userID int32, organisationID int32) ([]Permissiongroup, error) {
@@ -242,7 +512,7 @@ func (q *Queries) ListPermissionGroupsForUserForOrganisation(ctx context.Context
 	var items []Permissiongroup
 	for rows.Next() {
 		var i Permissiongroup
-		if err := rows.Scan(&i.ID, &i.Name); err != nil {
+		if err := rows.Scan(&i.ID, &i.Name, &i.OrganisationID); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -280,6 +550,149 @@ func (q *Queries) ListPermissions(ctx context.Context) ([]string, error) {
 	return items, nil
 }
 
+const listUsersInPermissionGroup = `-- name: ListUsersInPermissionGroup :many
+SELECT
+    u.id, u.name, u.email, u.joined, u.admin, u.lang, u.search, u.deleted_at, u.anonymised_at,
+    usr.valid_from,
+    usr.valid_until
+FROM
+    users u
+    INNER JOIN user_permissiongroup_membership usr ON usr.user_id = u.id
+WHERE
+    usr.group_id = $1
+    AND u.deleted_at IS NULL
+ORDER BY
+    u.id
+`
+
+type ListUsersInPermissionGroupRow struct {
+	User       User
+	ValidFrom  pgtype.Timestamptz
+	ValidUntil pgtype.Timestamptz
+}
+
+func (q *Queries) ListUsersInPermissionGroup(ctx context.Context, groupID int32) ([]ListUsersInPermissionGroupRow, error) {
+	rows, err := q.db.Query(ctx, listUsersInPermissionGroup, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUsersInPermissionGroupRow
+	for rows.Next() {
+		var i ListUsersInPermissionGroupRow
+		if err := rows.Scan(
+			&i.User.ID,
+			&i.User.Name,
+			&i.User.Email,
+			&i.User.Joined,
+			&i.User.Admin,
+			&i.User.Lang,
+			&i.User.Search,
+			&i.User.DeletedAt,
+			&i.User.AnonymisedAt,
+			&i.ValidFrom,
+			&i.ValidUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockPermissionGroup = `-- name: LockPermissionGroup :one
+SELECT
+    pg.organisation_id
+FROM
+    permissiongroups pg
+WHERE
+    pg.id = $1
+FOR SHARE
+`
+
+// Keeps the owner of the group from changing until the transaction ends
+func (q *Queries) LockPermissionGroup(ctx context.Context, id int32) (*int32, error) {
+	row := q.db.QueryRow(ctx, lockPermissionGroup, id)
+	var organisation_id *int32
+	err := row.Scan(&organisation_id)
+	return organisation_id, err
+}
+
+const removeAllDefaultPermissionGroupsForOrganisation = `-- name: RemoveAllDefaultPermissionGroupsForOrganisation :exec
+DELETE FROM organisation_default_permissiongroups
+WHERE organisation_id = $1
+`
+
+func (q *Queries) RemoveAllDefaultPermissionGroupsForOrganisation(ctx context.Context, organisationID int32) error {
+	_, err := q.db.Exec(ctx, removeAllDefaultPermissionGroupsForOrganisation, organisationID)
+	return err
+}
+
+const removeUserFromAllPermissionGroups = `-- name: RemoveUserFromAllPermissionGroups :exec
+DELETE FROM user_permissiongroup_membership
+WHERE user_id = $1
+`
+
+func (q *Queries) RemoveUserFromAllPermissionGroups(ctx context.Context, userID int32) error {
+	_, err := q.db.Exec(ctx, removeUserFromAllPermissionGroups, userID)
+	return err
+}
+
+const removeUserFromAllPermissionGroupsForOrganisation = `-- name: RemoveUserFromAllPermissionGroupsForOrganisation :exec
+DELETE FROM organisation_users_permissiongroups
+WHERE organisation_users_id = (
+        SELECT
+            id
+        FROM
+            organisation_users
+        WHERE
+            user_id = $1
+            AND organisation_id = $2)
+`
+
+func (q *Queries) RemoveUserFromAllPermissionGroupsForOrganisation(ctx context.Context, userID int32, organisationID int32) error {
+	_, err := q.db.Exec(ctx, removeUserFromAllPermissionGroupsForOrganisation, userID, organisationID)
+	return err
+}
+
+const removeUserFromPermissionGroup = `-- name: RemoveUserFromPermissionGroup :exec
+DELETE FROM user_permissiongroup_membership
+WHERE group_id = $1
+    AND user_id = $2
+`
+
+func (q *Queries) RemoveUserFromPermissionGroup(ctx context.Context, groupID int32, userID int32) error {
+	_, err := q.db.Exec(ctx, removeUserFromPermissionGroup, groupID, userID)
+	return err
+}
+
+const removeUserFromPermissionGroupForOrganisation = `-- name: RemoveUserFromPermissionGroupForOrganisation :exec
+DELETE FROM organisation_users_permissiongroups
+WHERE permission_group_id = $1
+    AND organisation_users_id = (
+        SELECT
+            id
+        FROM
+            organisation_users
+        WHERE
+            user_id = $2
+            AND organisation_id = $3)
+`
+
+type RemoveUserFromPermissionGroupForOrganisationParams struct {
+	PermissionGroupID int32
+	UserID            int32
+	OrganisationID    int32
+}
+
+func (q *Queries) RemoveUserFromPermissionGroupForOrganisation(ctx context.Context, arg RemoveUserFromPermissionGroupForOrganisationParams) error {
+	_, err := q.db.Exec(ctx, removeUserFromPermissionGroupForOrganisation, arg.PermissionGroupID, arg.UserID, arg.OrganisationID)
+	return err
+}
+
 const renamePermissionGroup = `-- name: RenamePermissionGroup :exec
 UPDATE
     permissiongroups
@@ -294,6 +707,30 @@ func (q *Queries) RenamePermissionGroup(ctx context.Context, iD int32, name *str
 	return err
 }
 
+const renamePermissionGroupForOrganisation = `-- name: RenamePermissionGroupForOrganisation :execrows
+UPDATE
+    permissiongroups
+SET
+    name = $3
+WHERE
+    id = $1
+    AND organisation_id = $2
+`
+
+type RenamePermissionGroupForOrganisationParams struct {
+	ID             int32
+	OrganisationID *int32
+	Name           *string
+}
+
+func (q *Queries) RenamePermissionGroupForOrganisation(ctx context.Context, arg RenamePermissionGroupForOrganisationParams) (int64, error) {
+	result, err := q.db.Exec(ctx, renamePermissionGroupForOrganisation, arg.ID, arg.OrganisationID, arg.Name)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updatePermissionGroupIndex = `-- name: UpdatePermissionGroupIndex :exec
 SELECT
     SETVAL('permissiongroups_id_seq', (
@@ -308,13 +745,11 @@ func (q *Queries) UpdatePermissionGroupIndex(ctx context.Context) error {
 }
 
 const updatePermissionGroupPermission = `-- name: UpdatePermissionGroupPermission :exec
-UPDATE
-    permissiongroup_permissions
-SET
-    enabled = $3
-WHERE
-    group_id = $1
-    AND permission = $2
+INSERT INTO permissiongroup_permissions (group_id, permission, enabled)
+    VALUES ($1, $2, $3)
+ON CONFLICT (group_id, permission)
+    DO UPDATE SET
+        enabled = EXCLUDED.enabled
 `
 
 type UpdatePermissionGroupPermissionParams struct {
@@ -0,0 +1,461 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: privacy.sql
+
+package sqlc
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const anonymiseUser = `-- name: AnonymiseUser :exec
+UPDATE
+    users
+SET
+    name = $1::text,
+    email = $2::text,
+    admin = FALSE,
+    deleted_at = COALESCE(deleted_at, NOW()),
+    anonymised_at = NOW()
+WHERE
+    id = $3
+`
+
+type AnonymiseUserParams struct {
+	Name  string
+	Email string
+	ID    int32
+}
+
+func (q *Queries) AnonymiseUser(ctx context.Context, arg AnonymiseUserParams) error {
+	_, err := q.db.Exec(ctx, anonymiseUser, arg.Name, arg.Email, arg.ID)
+	return err
+}
+
+const createUserErasure = `-- name: CreateUserErasure :one
+INSERT INTO user_erasures(user_id, mode, requested_by, reason)
+    VALUES ($1, $2, $3, $4)
+RETURNING
+    id, user_id, mode, requested_by, reason, erased_at
+`
+
+type CreateUserErasureParams struct {
+	UserID      int32
+	Mode        string
+	RequestedBy *int32
+	Reason      *string
+}
+
+func (q *Queries) CreateUserErasure(ctx context.Context, arg CreateUserErasureParams) (UserErasure, error) {
+	row := q.db.QueryRow(ctx, createUserErasure,
+		arg.UserID,
+		arg.Mode,
+		arg.RequestedBy,
+		arg.Reason,
+	)
+	var i UserErasure
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Mode,
+		&i.RequestedBy,
+		&i.Reason,
+		&i.ErasedAt,
+	)
+	return i, err
+}
+
+const deleteAccountsForUser = `-- name: DeleteAccountsForUser :exec
+DELETE FROM accounts
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteAccountsForUser(ctx context.Context, userID int32) error {
+	_, err := q.db.Exec(ctx, deleteAccountsForUser, userID)
+	return err
+}
+
+const deleteUserDataAccountCache = `-- name: DeleteUserDataAccountCache :execrows
+DELETE FROM account_cache AS ac
+WHERE ac.email = $1::text
+    OR EXISTS (
+        SELECT
+            1
+        FROM
+            accounts AS a
+        WHERE
+            a.user_id = $2
+            AND a.provider = ac.provider
+            AND a.provider_id = ac.provider_id)
+`
+
+func (q *Queries) DeleteUserDataAccountCache(ctx context.Context, email string, userID int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteUserDataAccountCache, email, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getUserIncludingDeleted = `-- name: GetUserIncludingDeleted :one
+SELECT
+    id, name, email, joined, admin, lang, search, deleted_at, anonymised_at
+FROM
+    users
+WHERE
+    id = $1
+`
+
+func (q *Queries) GetUserIncludingDeleted(ctx context.Context, id int32) (User, error) {
+	row := q.db.QueryRow(ctx, getUserIncludingDeleted, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Joined,
+		&i.Admin,
+		&i.Lang,
+		&i.Search,
+		&i.DeletedAt,
+		&i.AnonymisedAt,
+	)
+	return i, err
+}
+
+const leaveAllOrganisations = `-- name: LeaveAllOrganisations :exec
+UPDATE
+    organisation_users
+SET
+    status = 'left',
+    role = NULL
+WHERE
+    user_id = $1
+`
+
+func (q *Queries) LeaveAllOrganisations(ctx context.Context, userID int32) error {
+	_, err := q.db.Exec(ctx, leaveAllOrganisations, userID)
+	return err
+}
+
+const listAccountsForUser = `-- name: ListAccountsForUser :many
+SELECT
+    provider,
+    provider_id
+FROM
+    accounts
+WHERE
+    user_id = $1
+ORDER BY
+    provider
+`
+
+type ListAccountsForUserRow struct {
+	Provider   string
+	ProviderID string
+}
+
+func (q *Queries) ListAccountsForUser(ctx context.Context, userID int32) ([]ListAccountsForUserRow, error) {
+	rows, err := q.db.Query(ctx, listAccountsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAccountsForUserRow
+	for rows.Next() {
+		var i ListAccountsForUserRow
+		if err := rows.Scan(&i.Provider, &i.ProviderID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserDataAccountCache = `-- name: ListUserDataAccountCache :many
+SELECT
+    ac.id, ac.name, ac.email, ac.provider, ac.provider_id, ac.created
+FROM
+    account_cache AS ac
+WHERE
+    ac.email = $1::text
+    OR EXISTS (
+        SELECT
+            1
+        FROM
+            accounts AS a
+        WHERE
+            a.user_id = $2
+            AND a.provider = ac.provider
+            AND a.provider_id = ac.provider_id)
+ORDER BY
+    ac.created
+`
+
+func (q *Queries) ListUserDataAccountCache(ctx context.Context, email string, userID int32) ([]AccountCache, error) {
+	rows, err := q.db.Query(ctx, listUserDataAccountCache, email, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AccountCache
+	for rows.Next() {
+		var i AccountCache
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.Provider,
+			&i.ProviderID,
+			&i.Created,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserDataAddresses = `-- name: ListUserDataAddresses :many
+SELECT
+    a.id, a.street, a.number, a.postal_code, a.city, a.country, a.extra_line,
+    oa.organisation_id,
+    oa.type
+FROM
+    address AS a
+    INNER JOIN organisation_addresses AS oa ON a.id = oa.address_id
+    INNER JOIN organisation_users AS ou ON ou.organisation_id = oa.organisation_id
+WHERE
+    ou.user_id = $1
+    AND ou.status = 'active'
+ORDER BY
+    oa.organisation_id,
+    oa.type
+`
+
+type ListUserDataAddressesRow struct {
+	Address        Address
+	OrganisationID int32
+	Type           string
+}
+
+func (q *Queries) ListUserDataAddresses(ctx context.Context, userID int32) ([]ListUserDataAddressesRow, error) {
+	rows, err := q.db.Query(ctx, listUserDataAddresses, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserDataAddressesRow
+	for rows.Next() {
+		var i ListUserDataAddressesRow
+		if err := rows.Scan(
+			&i.Address.ID,
+			&i.Address.Street,
+			&i.Address.Number,
+			&i.Address.PostalCode,
+			&i.Address.City,
+			&i.Address.Country,
+			&i.Address.ExtraLine,
+			&i.OrganisationID,
+			&i.Type,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserDataMemberships = `-- name: ListUserDataMemberships :many
+SELECT
+    ou.organisation_id,
+    o.name AS organisation_name,
+    ou.status,
+    ou.role,
+    ou.joined_at,
+    ou.invited_by
+FROM
+    organisation_users AS ou
+    INNER JOIN organisations AS o ON o.id = ou.organisation_id
+WHERE
+    ou.user_id = $1
+ORDER BY
+    ou.organisation_id
+`
+
+type ListUserDataMembershipsRow struct {
+	OrganisationID   int32
+	OrganisationName string
+	Status           string
+	Role             *string
+	JoinedAt         pgtype.Timestamptz
+	InvitedBy        *int32
+}
+
+func (q *Queries) ListUserDataMemberships(ctx context.Context, userID int32) ([]ListUserDataMembershipsRow, error) {
+	rows, err := q.db.Query(ctx, listUserDataMemberships, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserDataMembershipsRow
+	for rows.Next() {
+		var i ListUserDataMembershipsRow
+		if err := rows.Scan(
+			&i.OrganisationID,
+			&i.OrganisationName,
+			&i.Status,
+			&i.Role,
+			&i.JoinedAt,
+			&i.InvitedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserDataPermissionGroups = `-- name: ListUserDataPermissionGroups :many
+SELECT
+    pg.id,
+    pg.name,
+    NULL::integer AS organisation_id,
+    usr.valid_from,
+    usr.valid_until
+FROM
+    permissiongroups AS pg
+    INNER JOIN user_permissiongroup_membership AS usr ON usr.group_id = pg.id
+WHERE
+    usr.user_id = $1
+UNION ALL
+SELECT
+    pg.id,
+    pg.name,
+    ou.organisation_id,
+    org_usr.valid_from,
+    org_usr.valid_until
+FROM
+    permissiongroups AS pg
+    INNER JOIN organisation_users_permissiongroups AS org_usr ON org_usr.permission_group_id = pg.id
+    INNER JOIN organisation_users AS ou ON ou.id = org_usr.organisation_users_id
+WHERE
+    ou.user_id = $1
+ORDER BY
+    organisation_id NULLS FIRST,
+    id
+`
+
+type ListUserDataPermissionGroupsRow struct {
+	ID             int32
+	Name           *string
+	OrganisationID *int32
+	ValidFrom      pgtype.Timestamptz
+	ValidUntil     pgtype.Timestamptz
+}
+
+func (q *Queries) ListUserDataPermissionGroups(ctx context.Context, userID int32) ([]ListUserDataPermissionGroupsRow, error) {
+	rows, err := q.db.Query(ctx, listUserDataPermissionGroups, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserDataPermissionGroupsRow
+	for rows.Next() {
+		var i ListUserDataPermissionGroupsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.OrganisationID,
+			&i.ValidFrom,
+			&i.ValidUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserErasures = `-- name: ListUserErasures :many
+SELECT
+    id, user_id, mode, requested_by, reason, erased_at
+FROM
+    user_erasures
+WHERE
+    user_id = $1
+ORDER BY
+    erased_at,
+    id
+`
+
+func (q *Queries) ListUserErasures(ctx context.Context, userID int32) ([]UserErasure, error) {
+	rows, err := q.db.Query(ctx, listUserErasures, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserErasure
+	for rows.Next() {
+		var i UserErasure
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Mode,
+			&i.RequestedBy,
+			&i.Reason,
+			&i.ErasedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const removeUserFromAllOrganisationPermissionGroups = `-- name: RemoveUserFromAllOrganisationPermissionGroups :exec
+DELETE FROM organisation_users_permissiongroups
+WHERE organisation_users_id IN (
+        SELECT
+            id
+        FROM
+            organisation_users
+        WHERE
+            user_id = $1)
+`
+
+func (q *Queries) RemoveUserFromAllOrganisationPermissionGroups(ctx context.Context, userID int32) error {
+	_, err := q.db.Exec(ctx, removeUserFromAllOrganisationPermissionGroups, userID)
+	return err
+}
+
+const revokeAllUserObjectPermissions = `-- name: RevokeAllUserObjectPermissions :exec
+DELETE FROM user_object_permissions
+WHERE user_id = $1
+`
+
+func (q *Queries) RevokeAllUserObjectPermissions(ctx context.Context, userID int32) error {
+	_, err := q.db.Exec(ctx, revokeAllUserObjectPermissions, userID)
+	return err
+}
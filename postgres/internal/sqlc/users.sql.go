@@ -7,13 +7,15 @@ package sqlc
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (name, email, lang)
     VALUES ($1, $2, $3)
 RETURNING
-    id, name, email, joined, admin, lang
+    id, name, email, joined, admin, lang, search, deleted_at, anonymised_at
 `
 
 type CreateUserParams struct {
@@ -32,18 +34,29 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Joined,
 		&i.Admin,
 		&i.Lang,
+		&i.Search,
+		&i.DeletedAt,
+		&i.AnonymisedAt,
 	)
 	return i, err
 }
 
-const deleteUser = `-- name: DeleteUser :exec
-DELETE FROM users
-WHERE id = $1
+const deleteUser = `-- name: DeleteUser :execrows
+UPDATE
+    users
+SET
+    deleted_at = NOW()
+WHERE
+    id = $1
+    AND deleted_at IS NULL
 `
 
-func (q *Queries) DeleteUser(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, deleteUser, id)
-	return err
+func (q *Queries) DeleteUser(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
 const getAmountOfUsers = `-- name: GetAmountOfUsers :one
@@ -51,6 +64,8 @@ SELECT
     COUNT(*)
 FROM
     users
+WHERE
+    deleted_at IS NULL
 `
 
 func (q *Queries) GetAmountOfUsers(ctx context.Context) (int64, error) {
@@ -62,11 +77,12 @@ func (q *Queries) GetAmountOfUsers(ctx context.Context) (int64, error) {
 
 const getUser = `-- name: GetUser :one
 SELECT
-    id, name, email, joined, admin, lang
+    id, name, email, joined, admin, lang, search, deleted_at, anonymised_at
 FROM
     users
 WHERE
     id = $1
+    AND deleted_at IS NULL
 LIMIT 1
 `
 
@@ -80,35 +96,165 @@ func (q *Queries) GetUser(ctx context.Context, id int32) (User, error) {
 		&i.Joined,
 		&i.Admin,
 		&i.Lang,
+		&i.Search,
+		&i.DeletedAt,
+		&i.AnonymisedAt,
 	)
 	return i, err
 }
 
-const listUsers = `-- name: ListUsers :many
+const getUserByEmail = `-- name: GetUserByEmail :one
 SELECT
-    id, name, email, joined, admin, lang
+    id, name, email, joined, admin, lang, search, deleted_at, anonymised_at
 FROM
     users
+WHERE
+    email = $1
+    AND deleted_at IS NULL
+LIMIT 1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Joined,
+		&i.Admin,
+		&i.Lang,
+		&i.Search,
+		&i.DeletedAt,
+		&i.AnonymisedAt,
+	)
+	return i, err
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :many
+DELETE FROM users
+WHERE deleted_at < $1
+    AND anonymised_at IS NULL
+RETURNING
+    id
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, deletedAt pgtype.Timestamptz) ([]int32, error) {
+	rows, err := q.db.Query(ctx, purgeDeletedUsers, deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeUser = `-- name: PurgeUser :execrows
+DELETE FROM users
+WHERE id = $1
+`
+
+func (q *Queries) PurgeUser(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const restoreUser = `-- name: RestoreUser :execrows
+UPDATE
+    users
+SET
+    deleted_at = NULL
+WHERE
+    id = $1
+    AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) RestoreUser(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, restoreUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT
+    u.id, u.name, u.email, u.joined, u.admin, u.lang, u.search, u.deleted_at, u.anonymised_at,
+    (ts_rank(u.search, websearch_to_tsquery('simple', $1::text)) + GREATEST(public.word_similarity($1::text, u.name), public.word_similarity($1::text, u.email)))::real AS rank
+FROM
+    users AS u
+WHERE (u.search @@ websearch_to_tsquery('simple', $1::text)
+    OR u.name ILIKE $2::text
+    OR u.email ILIKE $2::text
+    OR $1::text OPERATOR(public.<%) u.name)
+AND u.deleted_at IS NULL
+AND ($3::integer IS NULL
+    OR EXISTS (
+        SELECT
+            1
+        FROM
+            organisation_users AS ou
+        WHERE
+            ou.user_id = u.id
+            AND ou.organisation_id = $3::integer
+            AND ou.status = 'active'))
 ORDER BY
-    RANDOM()
+    rank DESC,
+    u.name,
+    u.id
+LIMIT $4::integer
 `
 
-func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
-	rows, err := q.db.Query(ctx, listUsers)
+type SearchUsersParams struct {
+	Query          string
+	Pattern        string
+	OrganisationID *int32
+	MaxResults     int32
+}
+
+type SearchUsersRow struct {
+	User User
+	Rank float32
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]SearchUsersRow, error) {
+	rows, err := q.db.Query(ctx, searchUsers,
+		arg.Query,
+		arg.Pattern,
+		arg.OrganisationID,
+		arg.MaxResults,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []User
+	var items []SearchUsersRow
 	for rows.Next() {
-		var i User
+		var i SearchUsersRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.Name,
-			&i.Email,
-			&i.Joined,
-			&i.Admin,
-			&i.Lang,
+			&i.User.ID,
+			&i.User.Name,
+			&i.User.Email,
+			&i.User.Joined,
+			&i.User.Admin,
+			&i.User.Lang,
+			&i.User.Search,
+			&i.User.DeletedAt,
+			&i.User.AnonymisedAt,
+			&i.Rank,
 		); err != nil {
 			return nil, err
 		}
@@ -129,8 +275,9 @@ SET
     lang = COALESCE($4, lang)
 WHERE
     id = $1
+    AND deleted_at IS NULL
 RETURNING
-    id, name, email, joined, admin, lang
+    id, name, email, joined, admin, lang, search, deleted_at, anonymised_at
 `
 
 type UpdateUserParams struct {
@@ -155,6 +302,9 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Joined,
 		&i.Admin,
 		&i.Lang,
+		&i.Search,
+		&i.DeletedAt,
+		&i.AnonymisedAt,
 	)
 	return i, err
 }
@@ -166,6 +316,7 @@ SET
     admin = $2
 WHERE
     id = $1
+    AND deleted_at IS NULL
 `
 
 func (q *Queries) UpdateUserAdmin(ctx context.Context, iD int32, admin bool) error {
@@ -0,0 +1,487 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: webhooks.sql
+
+package sqlc
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+UPDATE
+    webhook_deliveries
+SET
+    status = 'sending',
+    locked_until = $1
+FROM
+    webhook_endpoints
+WHERE
+    webhook_deliveries.endpoint_id = webhook_endpoints.id
+    AND webhook_deliveries.id IN (
+        SELECT
+            d.id
+        FROM
+            webhook_deliveries d
+            INNER JOIN webhook_endpoints e ON d.endpoint_id = e.id
+            INNER JOIN organisations o ON e.organisation_id = o.id
+        WHERE
+            e.enabled
+            AND o.deleted_at IS NULL
+            AND ((d.status = 'pending'
+                    AND d.next_attempt_at <= NOW())
+                OR (d.status = 'sending'
+                    AND d.locked_until < NOW()))
+        ORDER BY
+            d.next_attempt_at,
+            d.id
+        LIMIT $2
+        FOR UPDATE
+            OF d SKIP LOCKED)
+RETURNING
+    webhook_deliveries.id,
+    webhook_deliveries.event_id,
+    webhook_deliveries.event_type,
+    webhook_deliveries.payload,
+    webhook_deliveries.attempts,
+    webhook_endpoints.url,
+    webhook_endpoints.secret
+`
+
+type ClaimWebhookDeliveriesRow struct {
+	ID        int32
+	EventID   string
+	EventType string
+	Payload   []byte
+	Attempts  int32
+	Url       string
+	Secret    string
+}
+
+// Leases the deliveries that are due, skipping the ones that another sender is claiming.
+// Deliveries whose lease expired are claimed again. Deliveries to disabled endpoints or of deleted organisations wait
+// until the endpoint is enabled or the organisation is restored.
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, lockedUntil pgtype.Timestamptz, maxDeliveries int32) ([]ClaimWebhookDeliveriesRow, error) {
+	rows, err := q.db.Query(ctx, claimWebhookDeliveries, lockedUntil, maxDeliveries)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimWebhookDeliveriesRow
+	for rows.Next() {
+		var i ClaimWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Attempts,
+			&i.Url,
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createWebhookAttempt = `-- name: CreateWebhookAttempt :exec
+INSERT INTO webhook_attempts(delivery_id, response_code, error, duration_ms)
+    VALUES ($1, $2, $3, $4)
+`
+
+type CreateWebhookAttemptParams struct {
+	DeliveryID   int32
+	ResponseCode *int32
+	Error        *string
+	DurationMs   int32
+}
+
+func (q *Queries) CreateWebhookAttempt(ctx context.Context, arg CreateWebhookAttemptParams) error {
+	_, err := q.db.Exec(ctx, createWebhookAttempt,
+		arg.DeliveryID,
+		arg.ResponseCode,
+		arg.Error,
+		arg.DurationMs,
+	)
+	return err
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries(endpoint_id, event_id, event_type, payload)
+    VALUES ($1, $2, $3, $4)
+ON CONFLICT (endpoint_id, event_id)
+    DO NOTHING
+`
+
+type CreateWebhookDeliveryParams struct {
+	EndpointID int32
+	EventID    string
+	EventType  string
+	Payload    []byte
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) error {
+	_, err := q.db.Exec(ctx, createWebhookDelivery,
+		arg.EndpointID,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+	)
+	return err
+}
+
+const createWebhookEndpoint = `-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints(organisation_id, url, secret, events)
+    VALUES ($1, $2, $3, $4)
+RETURNING
+    id, organisation_id, url, secret, events, enabled, created_at
+`
+
+type CreateWebhookEndpointParams struct {
+	OrganisationID int32
+	Url            string
+	Secret         string
+	Events         []string
+}
+
+func (q *Queries) CreateWebhookEndpoint(ctx context.Context, arg CreateWebhookEndpointParams) (WebhookEndpoint, error) {
+	row := q.db.QueryRow(ctx, createWebhookEndpoint,
+		arg.OrganisationID,
+		arg.Url,
+		arg.Secret,
+		arg.Events,
+	)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.OrganisationID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.Enabled,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteWebhookEndpoint = `-- name: DeleteWebhookEndpoint :exec
+DELETE FROM webhook_endpoints
+WHERE id = $1
+    AND organisation_id = $2
+`
+
+func (q *Queries) DeleteWebhookEndpoint(ctx context.Context, iD int32, organisationID int32) error {
+	_, err := q.db.Exec(ctx, deleteWebhookEndpoint, iD, organisationID)
+	return err
+}
+
+const getWebhookDelivery = `-- name: GetWebhookDelivery :one
+SELECT
+    webhook_deliveries.id, webhook_deliveries.endpoint_id, webhook_deliveries.event_id, webhook_deliveries.event_type, webhook_deliveries.payload, webhook_deliveries.status, webhook_deliveries.attempts, webhook_deliveries.next_attempt_at, webhook_deliveries.locked_until, webhook_deliveries.response_code, webhook_deliveries.last_error, webhook_deliveries.created_at, webhook_deliveries.delivered_at
+FROM
+    webhook_deliveries
+    INNER JOIN webhook_endpoints ON webhook_deliveries.endpoint_id = webhook_endpoints.id
+WHERE
+    webhook_deliveries.id = $1
+    AND webhook_endpoints.organisation_id = $2
+`
+
+func (q *Queries) GetWebhookDelivery(ctx context.Context, iD int32, organisationID int32) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, getWebhookDelivery, iD, organisationID)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.EndpointID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LockedUntil,
+		&i.ResponseCode,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.DeliveredAt,
+	)
+	return i, err
+}
+
+const getWebhookEndpoint = `-- name: GetWebhookEndpoint :one
+SELECT
+    id, organisation_id, url, secret, events, enabled, created_at
+FROM
+    webhook_endpoints
+WHERE
+    id = $1
+    AND organisation_id = $2
+`
+
+func (q *Queries) GetWebhookEndpoint(ctx context.Context, iD int32, organisationID int32) (WebhookEndpoint, error) {
+	row := q.db.QueryRow(ctx, getWebhookEndpoint, iD, organisationID)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.OrganisationID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.Enabled,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listWebhookAttempts = `-- name: ListWebhookAttempts :many
+SELECT
+    id, delivery_id, attempted_at, response_code, error, duration_ms
+FROM
+    webhook_attempts
+WHERE
+    delivery_id = $1
+ORDER BY
+    attempted_at,
+    id
+`
+
+func (q *Queries) ListWebhookAttempts(ctx context.Context, deliveryID int32) ([]WebhookAttempt, error) {
+	rows, err := q.db.Query(ctx, listWebhookAttempts, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookAttempt
+	for rows.Next() {
+		var i WebhookAttempt
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeliveryID,
+			&i.AttemptedAt,
+			&i.ResponseCode,
+			&i.Error,
+			&i.DurationMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookEndpoints = `-- name: ListWebhookEndpoints :many
+SELECT
+    id, organisation_id, url, secret, events, enabled, created_at
+FROM
+    webhook_endpoints
+WHERE
+    organisation_id = $1
+ORDER BY
+    id
+`
+
+func (q *Queries) ListWebhookEndpoints(ctx context.Context, organisationID int32) ([]WebhookEndpoint, error) {
+	rows, err := q.db.Query(ctx, listWebhookEndpoints, organisationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEndpoint
+	for rows.Next() {
+		var i WebhookEndpoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.OrganisationID,
+			&i.Url,
+			&i.Secret,
+			&i.Events,
+			&i.Enabled,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookEndpointsForEvent = `-- name: ListWebhookEndpointsForEvent :many
+SELECT
+    webhook_endpoints.id, webhook_endpoints.organisation_id, webhook_endpoints.url, webhook_endpoints.secret, webhook_endpoints.events, webhook_endpoints.enabled, webhook_endpoints.created_at
+FROM
+    webhook_endpoints
+    INNER JOIN organisations ON webhook_endpoints.organisation_id = organisations.id
+WHERE
+    webhook_endpoints.organisation_id = $1
+    AND webhook_endpoints.enabled
+    AND organisations.deleted_at IS NULL
+    AND (cardinality(webhook_endpoints.events) = 0
+        OR $2::text = ANY (webhook_endpoints.events))
+ORDER BY
+    webhook_endpoints.id
+`
+
+// Only the enabled endpoints of organisations that were not deleted receive events
+func (q *Queries) ListWebhookEndpointsForEvent(ctx context.Context, organisationID int32, eventType string) ([]WebhookEndpoint, error) {
+	rows, err := q.db.Query(ctx, listWebhookEndpointsForEvent, organisationID, eventType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEndpoint
+	for rows.Next() {
+		var i WebhookEndpoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.OrganisationID,
+			&i.Url,
+			&i.Secret,
+			&i.Events,
+			&i.Enabled,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const replayWebhookDelivery = `-- name: ReplayWebhookDelivery :one
+UPDATE
+    webhook_deliveries
+SET
+    status = 'pending',
+    attempts = 0,
+    next_attempt_at = NOW(),
+    locked_until = NULL,
+    delivered_at = NULL
+WHERE
+    id = $1
+RETURNING
+    id, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, locked_until, response_code, last_error, created_at, delivered_at
+`
+
+func (q *Queries) ReplayWebhookDelivery(ctx context.Context, id int32) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, replayWebhookDelivery, id)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.EndpointID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LockedUntil,
+		&i.ResponseCode,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.DeliveredAt,
+	)
+	return i, err
+}
+
+const updateWebhookDeliveryAttempt = `-- name: UpdateWebhookDeliveryAttempt :execrows
+UPDATE
+    webhook_deliveries
+SET
+    attempts = attempts + 1,
+    status = $1,
+    locked_until = NULL,
+    response_code = $2,
+    last_error = $3,
+    next_attempt_at = $4,
+    delivered_at = $5
+WHERE
+    id = $6
+    AND status = 'sending'
+    AND locked_until = $7
+`
+
+type UpdateWebhookDeliveryAttemptParams struct {
+	Status        string
+	ResponseCode  *int32
+	LastError     *string
+	NextAttemptAt pgtype.Timestamptz
+	DeliveredAt   pgtype.Timestamptz
+	ID            int32
+	LockedUntil   pgtype.Timestamptz
+}
+
+// Only updates the delivery while the sender still holds its lease
+func (q *Queries) UpdateWebhookDeliveryAttempt(ctx context.Context, arg UpdateWebhookDeliveryAttemptParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateWebhookDeliveryAttempt,
+		arg.Status,
+		arg.ResponseCode,
+		arg.LastError,
+		arg.NextAttemptAt,
+		arg.DeliveredAt,
+		arg.ID,
+		arg.LockedUntil,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateWebhookEndpoint = `-- name: UpdateWebhookEndpoint :one
+UPDATE
+    webhook_endpoints
+SET
+    url = $3,
+    events = $4,
+    enabled = $5
+WHERE
+    id = $1
+    AND organisation_id = $2
+RETURNING
+    id, organisation_id, url, secret, events, enabled, created_at
+`
+
+type UpdateWebhookEndpointParams struct {
+	ID             int32
+	OrganisationID int32
+	Url            string
+	Events         []string
+	Enabled        bool
+}
+
+func (q *Queries) UpdateWebhookEndpoint(ctx context.Context, arg UpdateWebhookEndpointParams) (WebhookEndpoint, error) {
+	row := q.db.QueryRow(ctx, updateWebhookEndpoint,
+		arg.ID,
+		arg.OrganisationID,
+		arg.Url,
+		arg.Events,
+		arg.Enabled,
+	)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.OrganisationID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.Enabled,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,445 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/postgres/internal/sqlc"
+)
+
+const (
+	// DefaultJobConcurrency is the maximum amount of jobs that a queue runs at the same time.
+	DefaultJobConcurrency = 10
+	// DefaultJobTimeout is how long a job can run before its context is cancelled.
+	DefaultJobTimeout = 10 * time.Minute
+	// The delay before a job runs again after its first failed attempt, doubled for every next attempt
+	jobRetryDelay = 10 * time.Second
+	// The maximum delay between two attempts of a job
+	jobMaxRetryDelay = time.Hour
+	// How long a running job stays locked after its timeout, before another worker assumes its worker stopped
+	jobLockMargin = time.Minute
+)
+
+// jobHandler runs a JSON encoded job.
+type jobHandler func(ctx context.Context, payload []byte) error
+
+func NewJobQueue(DB *DB) *JobQueue {
+	return &JobQueue{
+		db:          DB,
+		handlers:    make(map[core.JobKind]jobHandler),
+		concurrency: DefaultJobConcurrency,
+		timeout:     DefaultJobTimeout,
+	}
+}
+
+// JobQueue runs background jobs that are stored in the database. Register a handler for every kind of job with
+// HandleJob, then Start the queue in every process that should run jobs.
+// Jobs run at least once: a job whose handler returns an error runs again with an exponential back-off, until it runs
+// out of attempts and is dead. Jobs of a worker that stopped without finishing them run again once their lock expires.
+type JobQueue struct {
+	db          *DB
+	lock        sync.RWMutex
+	handlers    map[core.JobKind]jobHandler
+	concurrency int
+	timeout     time.Duration
+	// Stops claiming new jobs
+	stop context.CancelFunc
+	// Cancels the jobs that are running
+	cancelJobs context.CancelFunc
+	running    sync.WaitGroup
+}
+
+// Force struct to implement the core interface
+var _ core.JobQueue = &JobQueue{}
+
+// HandleJob registers the handler that runs every job of type J. A queue only claims the kinds of jobs that it has
+// handlers for, so processes with different handlers can share the same database.
+func HandleJob[J core.Job](queue *JobQueue, handler core.JobHandler[J]) {
+	var zero J
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	queue.handlers[zero.JobKind()] = func(ctx context.Context, payload []byte) error {
+		var job J
+		if err := json.Unmarshal(payload, &job); err != nil {
+			return fmt.Errorf("could not decode %q job: %w", zero.JobKind(), err)
+		}
+		return handler(ctx, job)
+	}
+}
+
+// SetConcurrency changes the maximum amount of jobs that run at the same time.
+// The default is DefaultJobConcurrency.
+func (q *JobQueue) SetConcurrency(concurrency int) {
+	q.concurrency = concurrency
+}
+
+// SetTimeout changes how long a job can run before its context is cancelled.
+// The default is DefaultJobTimeout.
+func (q *JobQueue) SetTimeout(timeout time.Duration) {
+	q.timeout = timeout
+}
+
+// Enqueue implements core.JobQueue.Enqueue
+func (q *JobQueue) Enqueue(
+	ctx context.Context,
+	job core.Job,
+	options core.JobOptions,
+) (core.JobID, error) {
+	return q.EnqueueTx(ctx, q.db, job, options)
+}
+
+// EnqueueTx enqueues a job on the specified connection, so it only runs if the transaction commits.
+func (q *JobQueue) EnqueueTx(
+	ctx context.Context,
+	dbtx sqlc.DBTX,
+	job core.Job,
+	options core.JobOptions,
+) (core.JobID, error) {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return 0, fmt.Errorf("could not encode %q job: %w", job.JobKind(), err)
+	}
+	runAt := options.RunAt
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+	maxAttempts := options.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = core.DefaultJobMaxAttempts
+	}
+	var uniqueKey *string
+	if len(options.UniqueKey) > 0 {
+		uniqueKey = &options.UniqueKey
+	}
+	kind := job.JobKind()
+	actor := core.ActorFromContext(ctx)
+	queries := sqlc.New(dbtx)
+	for {
+		id, err := queries.CreateJob(ctx, sqlc.CreateJobParams{
+			Kind:           string(kind),
+			Payload:        payload,
+			UniqueKey:      uniqueKey,
+			RunAt:          toTimestamptz(&runAt),
+			MaxAttempts:    int32(maxAttempts),
+			ActorID:        toOptionalID(actor.UserID),
+			ImpersonatorID: toOptionalID(actor.ImpersonatorID),
+		})
+		if err == nil {
+			return core.JobID(id), nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("could not enqueue %q job: %w", kind, ConvertPgError(err))
+		}
+		// Another job has the same unique key
+		id, err = queries.GetUniqueJobID(ctx, string(kind), uniqueKey)
+		if err == nil {
+			return core.JobID(id), nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("could not find %q job: %w", kind, ConvertPgError(err))
+		}
+		// The other job finished in the meantime, so try again
+	}
+}
+
+// Start runs jobs in the background, checking for new jobs every interval, until the context is cancelled or the
+// queue is drained. Call Drain to wait for the jobs that are running when the application stops.
+//
+// # Example
+//
+//	queue := postgres.NewJobQueue(db)
+//	postgres.HandleJob(queue, core.SendEmailHandler(emailService))
+//	queue.Start(ctx, time.Second)
+//	server.OnShutdown(queue.Drain)
+func (q *JobQueue) Start(ctx context.Context, interval time.Duration) {
+	pollCtx, stop := context.WithCancel(ctx)
+	// Running jobs are only cancelled when draining takes too long, not when the queue stops claiming new jobs
+	jobsCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	q.lock.Lock()
+	q.stop, q.cancelJobs = stop, cancelJobs
+	q.lock.Unlock()
+
+	slots := make(chan struct{}, q.concurrency)
+	q.running.Add(1)
+	go func() {
+		defer q.running.Done()
+		pollLoop(pollCtx, interval, func(ctx context.Context) bool {
+			free := cap(slots) - len(slots)
+			if free == 0 {
+				return false
+			}
+			jobs, err := q.claim(ctx, free)
+			if err != nil {
+				slog.Error("Could not claim jobs", "error", err)
+				return false
+			}
+			for _, job := range jobs {
+				slots <- struct{}{}
+				q.running.Add(1)
+				go func() {
+					defer q.running.Done()
+					q.run(jobsCtx, job)
+					<-slots
+				}()
+			}
+			// Keep claiming while every free slot is filled
+			return len(jobs) >= free
+		})
+	}()
+}
+
+// Drain stops claiming new jobs and waits for the running jobs to finish. If the context ends first, the running jobs
+// are cancelled and run again later, either by another queue or after a restart.
+func (q *JobQueue) Drain(ctx context.Context) {
+	q.lock.RLock()
+	stop, cancelJobs := q.stop, q.cancelJobs
+	q.lock.RUnlock()
+	if stop == nil {
+		return
+	}
+	stop()
+	done := make(chan struct{})
+	go func() {
+		q.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("Cancelling running jobs", "error", ctx.Err())
+		cancelJobs()
+	}
+}
+
+// Work runs a single batch of due jobs, waits for them to finish and returns the amount of jobs it ran.
+// This is mostly useful in tests, use Start to keep running jobs in the background.
+func (q *JobQueue) Work(ctx context.Context) (int, error) {
+	jobs, err := q.claim(ctx, q.concurrency)
+	if err != nil {
+		return 0, err
+	}
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.run(ctx, job)
+		}()
+	}
+	wg.Wait()
+	return len(jobs), nil
+}
+
+// claim locks at most the specified amount of due jobs for this queue. Jobs whose lock expired on their last attempt
+// are killed instead, since their worker stopped without recording a result every time.
+func (q *JobQueue) claim(ctx context.Context, amount int) ([]sqlc.Job, error) {
+	q.lock.RLock()
+	kinds := slices.Sorted(maps.Keys(q.handlers))
+	q.lock.RUnlock()
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+	queries := sqlc.New(q.db)
+	killed, err := queries.KillExpiredJobs(ctx, fromJobKinds(kinds))
+	if err != nil {
+		return nil, fmt.Errorf("could not kill expired jobs: %w", ConvertPgError(err))
+	}
+	if killed > 0 {
+		slog.Error("Jobs expired on their last attempt", "jobs", killed)
+	}
+	lockedUntil := time.Now().Add(q.timeout + jobLockMargin)
+	jobs, err := queries.ClaimJobs(ctx, sqlc.ClaimJobsParams{
+		LockedUntil: toTimestamptz(&lockedUntil),
+		Kinds:       fromJobKinds(kinds),
+		MaxJobs:     int32(amount),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not claim jobs: %w", ConvertPgError(err))
+	}
+	return jobs, nil
+}
+
+// run runs a single claimed job and removes it, or schedules another attempt if it fails.
+func (q *JobQueue) run(ctx context.Context, job sqlc.Job) {
+	err := q.handle(ctx, job)
+	// Record the result even if the job was cancelled
+	ctx = context.WithoutCancel(ctx)
+	queries := sqlc.New(q.db)
+	if err == nil {
+		deleted, err := queries.DeleteJob(ctx, job.ID, job.Attempts)
+		if err != nil {
+			err = ConvertPgError(err)
+			slog.Error("Could not remove finished job", "job_id", job.ID, "error", err)
+		} else if deleted == 0 {
+			logLostJobLease(job)
+		}
+		return
+	}
+
+	message := err.Error()
+	if job.Attempts >= job.MaxAttempts {
+		slog.Error(
+			"Job failed its last attempt",
+			"job_id", job.ID,
+			"job", job.Kind,
+			"attempts", job.Attempts,
+			"error", err,
+		)
+		killed, err := queries.KillJob(ctx, sqlc.KillJobParams{
+			LastError: &message,
+			ID:        job.ID,
+			Attempts:  job.Attempts,
+		})
+		if err != nil {
+			slog.Error("Could not mark job as dead", "job_id", job.ID, "error", ConvertPgError(err))
+		} else if killed == 0 {
+			logLostJobLease(job)
+		}
+		return
+	}
+	delay := backoff(job.Attempts, jobRetryDelay, jobMaxRetryDelay)
+	slog.Warn(
+		"Job failed",
+		"job_id", job.ID,
+		"job", job.Kind,
+		"attempts", job.Attempts,
+		"retry_in", delay,
+		"error", err,
+	)
+	runAt := time.Now().Add(delay)
+	retried, err := queries.RetryJob(ctx, sqlc.RetryJobParams{
+		RunAt:     toTimestamptz(&runAt),
+		LastError: &message,
+		ID:        job.ID,
+		Attempts:  job.Attempts,
+	})
+	if err != nil {
+		slog.Error("Could not reschedule job", "job_id", job.ID, "error", ConvertPgError(err))
+	} else if retried == 0 {
+		logLostJobLease(job)
+	}
+}
+
+// logLostJobLease warns that the result of a job was not recorded because its lease expired and another worker claimed
+// it again or an administrator changed it in the meantime.
+func logLostJobLease(job sqlc.Job) {
+	slog.Warn(
+		"Job lease was lost, its result is not recorded",
+		"job_id", job.ID,
+		"job", job.Kind,
+		"attempts", job.Attempts,
+	)
+}
+
+// handle calls the handler of a job in the context of the actor that enqueued it.
+func (q *JobQueue) handle(ctx context.Context, job sqlc.Job) (err error) {
+	q.lock.RLock()
+	handler, ok := q.handlers[core.JobKind(job.Kind)]
+	q.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("no handler for %q jobs", job.Kind)
+	}
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+	ctx = core.WithActor(ctx, core.Actor{
+		UserID:         fromOptionalID(job.ActorID),
+		ImpersonatorID: fromOptionalID(job.ImpersonatorID),
+	})
+	ctx = core.WithJobInfo(ctx, core.JobInfo{
+		ID:          core.JobID(job.ID),
+		Kind:        core.JobKind(job.Kind),
+		Attempt:     int(job.Attempts),
+		MaxAttempts: int(job.MaxAttempts),
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job.Payload)
+}
+
+// ListDeadJobs implements core.JobQueue.ListDeadJobs
+func (q *JobQueue) ListDeadJobs(
+	ctx context.Context,
+	query core.ListQuery,
+) (*core.Page[core.DeadJob], error) {
+	return deadJobList.list(ctx, q.db, query)
+}
+
+var deadJobList = &keysetList[core.DeadJob, sqlc.Job]{
+	columns: "j.id, j.kind, j.payload, j.status, j.unique_key, j.run_at, j.attempts, " +
+		"j.max_attempts, j.last_error, j.locked_until, j.actor_id, j.impersonator_id, " +
+		"j.created_at, j.died_at",
+	from:  "jobs AS j",
+	where: []string{"j.status = 'dead'"},
+	id:    "j.id",
+	sorts: map[string]sortColumn[core.DeadJob]{
+		"id": {"j.id", "integer", func(job *core.DeadJob) string { return job.ID.String() }},
+		"time": {"j.died_at", "timestamptz", func(job *core.DeadJob) string {
+			return job.DiedAt.Format(time.RFC3339Nano)
+		}},
+	},
+	defaultSort: "time",
+	filters: map[string]listFilter{
+		"kind": equalsFilter("j.kind", parseText),
+	},
+	convert: func(job sqlc.Job) (*core.DeadJob, error) {
+		var lastError string
+		if job.LastError != nil {
+			lastError = *job.LastError
+		}
+		return &core.DeadJob{
+			ID:        core.JobID(job.ID),
+			Kind:      core.JobKind(job.Kind),
+			Payload:   job.Payload,
+			UniqueKey: job.UniqueKey,
+			Attempts:  int(job.Attempts),
+			LastError: lastError,
+			CreatedAt: job.CreatedAt.Time,
+			DiedAt:    job.DiedAt.Time,
+		}, nil
+	},
+	itemID: func(job *core.DeadJob) core.ID { return job.ID },
+}
+
+// RetryDeadJob implements core.JobQueue.RetryDeadJob
+func (q *JobQueue) RetryDeadJob(ctx context.Context, id core.JobID) error {
+	rows, err := sqlc.New(q.db).RetryDeadJob(ctx, int32(id))
+	if err != nil {
+		return ConvertPgError(err)
+	}
+	if rows == 0 {
+		return core.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteDeadJob implements core.JobQueue.DeleteDeadJob
+func (q *JobQueue) DeleteDeadJob(ctx context.Context, id core.JobID) error {
+	rows, err := sqlc.New(q.db).DeleteDeadJob(ctx, int32(id))
+	if err != nil {
+		return ConvertPgError(err)
+	}
+	if rows == 0 {
+		return core.ErrNotFound
+	}
+	return nil
+}
+
+func fromJobKinds(kinds []core.JobKind) []string {
+	result := make([]string, len(kinds))
+	for i, kind := range kinds {
+		result[i] = string(kind)
+	}
+	return result
+}
@@ -0,0 +1,245 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/postgres"
+	"github.com/prior-it/apollo/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+type testJob struct {
+	Name string `json:"name"`
+	Fail bool   `json:"fail"`
+	// Simulates another worker claiming the job again while it runs
+	Steal bool `json:"steal"`
+	// Simulates the lock of the job expiring while it runs, after which another worker claims it again and crashes
+	Crash bool `json:"crash"`
+}
+
+func (testJob) JobKind() core.JobKind { return "test.job" }
+
+type otherJob struct{}
+
+func (otherJob) JobKind() core.JobKind { return "test.other" }
+
+func TestJobQueue(t *testing.T) {
+	db := tests.DB(t)
+	queue := postgres.NewJobQueue(db)
+	ctx := context.Background()
+
+	var ran []testJob
+	var infos []core.JobInfo
+	var actors []core.Actor
+	postgres.HandleJob(queue, func(ctx context.Context, job testJob) error {
+		ran = append(ran, job)
+		info, ok := core.JobInfoFromContext(ctx)
+		assert.True(t, ok, "Jobs should be described in the context")
+		infos = append(infos, info)
+		actors = append(actors, core.ActorFromContext(ctx))
+		if job.Steal {
+			_, err := db.Exec(ctx, "UPDATE jobs SET attempts = attempts + 1 WHERE id = $1", info.ID)
+			tests.Check(err)
+		}
+		if job.Crash {
+			query := `UPDATE jobs
+				SET attempts = attempts + 1, locked_until = NOW() - interval '1 second'
+				WHERE id = $1`
+			_, err := db.Exec(ctx, query, info.ID)
+			tests.Check(err)
+		}
+		if job.Fail {
+			return errors.New("failure")
+		}
+		return nil
+	})
+	reset := func() {
+		ran, infos, actors = nil, nil, nil
+	}
+
+	t.Run("ok: run job as the actor that enqueued it", func(t *testing.T) {
+		reset()
+		userID := core.UserID(42)
+		actorCtx := core.WithActor(ctx, core.Actor{UserID: &userID})
+		id, err := queue.Enqueue(actorCtx, testJob{Name: "first"}, core.JobOptions{})
+		assert.Nil(t, err)
+
+		amount, err := queue.Work(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, amount)
+		assert.Equal(t, []testJob{{Name: "first"}}, ran)
+		if assert.Len(t, infos, 1) {
+			assert.Equal(t, id, infos[0].ID)
+			assert.Equal(t, 1, infos[0].Attempt)
+			assert.Equal(t, core.DefaultJobMaxAttempts, infos[0].MaxAttempts)
+			assert.Equal(t, &userID, actors[0].UserID)
+		}
+
+		amount, err = queue.Work(ctx)
+		assert.Nil(t, err)
+		assert.Zero(t, amount, "Finished jobs should not run again")
+	})
+
+	t.Run("ok: scheduled job", func(t *testing.T) {
+		reset()
+		_, err := queue.Enqueue(ctx, testJob{}, core.JobOptions{RunAt: time.Now().Add(time.Hour)})
+		tests.Check(err)
+		amount, err := queue.Work(ctx)
+		assert.Nil(t, err)
+		assert.Zero(t, amount, "Jobs should not run before their time")
+	})
+
+	t.Run("ok: unique key", func(t *testing.T) {
+		reset()
+		options := core.JobOptions{UniqueKey: "unique", RunAt: time.Now().Add(time.Hour)}
+		first, err := queue.Enqueue(ctx, testJob{Name: "first"}, options)
+		tests.Check(err)
+		second, err := queue.Enqueue(ctx, testJob{Name: "second"}, options)
+		assert.Nil(t, err)
+		assert.Equal(t, first, second, "A duplicate unique key should return the existing job")
+		other, err := queue.Enqueue(ctx, otherJob{}, options)
+		assert.Nil(t, err)
+		assert.NotEqual(t, first, other, "Unique keys should only apply to jobs of the same kind")
+	})
+
+	t.Run("ok: only claim jobs with a handler", func(t *testing.T) {
+		reset()
+		_, err := queue.Enqueue(ctx, otherJob{}, core.JobOptions{})
+		tests.Check(err)
+		amount, err := queue.Work(ctx)
+		assert.Nil(t, err)
+		assert.Zero(t, amount)
+	})
+
+	t.Run("ok: retry failed job later", func(t *testing.T) {
+		reset()
+		_, err := queue.Enqueue(ctx, testJob{Fail: true}, core.JobOptions{})
+		tests.Check(err)
+		amount, err := queue.Work(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, amount)
+		amount, err = queue.Work(ctx)
+		assert.Nil(t, err)
+		assert.Zero(t, amount, "Failed jobs should only run again after a delay")
+		page, err := queue.ListDeadJobs(ctx, core.ListQuery{})
+		assert.Nil(t, err)
+		assert.Empty(t, page.Items, "Jobs with attempts left should not be dead")
+	})
+
+	t.Run("ok: leave a job that another worker claimed again", func(t *testing.T) {
+		reset()
+		options := core.JobOptions{UniqueKey: "stolen"}
+		id, err := queue.Enqueue(ctx, testJob{Steal: true}, options)
+		tests.Check(err)
+		amount, err := queue.Work(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, amount)
+		again, err := queue.Enqueue(ctx, testJob{}, options)
+		assert.Nil(t, err)
+		assert.Equal(t, id, again, "A job claimed by another worker should not be removed")
+	})
+
+	t.Run("ok: dead jobs", func(t *testing.T) {
+		reset()
+		id, err := queue.Enqueue(ctx, testJob{Name: "dead", Fail: true}, core.JobOptions{
+			UniqueKey:   "dead",
+			MaxAttempts: 1,
+		})
+		tests.Check(err)
+		_, err = queue.Work(ctx)
+		tests.Check(err)
+
+		query := core.ListQuery{Filters: map[string]string{"kind": "test.job"}}
+		page, err := queue.ListDeadJobs(ctx, query)
+		assert.Nil(t, err)
+		if !assert.Len(t, page.Items, 1) {
+			return
+		}
+		dead := page.Items[0]
+		assert.Equal(t, id, dead.ID)
+		assert.Equal(t, 1, dead.Attempts)
+		assert.Equal(t, "failure", dead.LastError)
+
+		// Dead jobs do not block their unique key
+		other, err := queue.Enqueue(ctx, testJob{}, core.JobOptions{
+			UniqueKey: "dead",
+			RunAt:     time.Now().Add(time.Hour),
+		})
+		assert.Nil(t, err)
+		assert.NotEqual(t, id, other)
+		assert.ErrorIs(t, queue.RetryDeadJob(ctx, id), core.ErrConflict)
+
+		assert.Nil(t, queue.DeleteDeadJob(ctx, id))
+		assert.ErrorIs(t, queue.DeleteDeadJob(ctx, id), core.ErrNotFound)
+		assert.ErrorIs(t, queue.RetryDeadJob(ctx, id), core.ErrNotFound)
+	})
+
+	t.Run("ok: retry dead job", func(t *testing.T) {
+		reset()
+		id, err := queue.Enqueue(ctx, testJob{Fail: true}, core.JobOptions{MaxAttempts: 1})
+		tests.Check(err)
+		_, err = queue.Work(ctx)
+		tests.Check(err)
+		assert.Nil(t, queue.RetryDeadJob(ctx, id))
+		amount, err := queue.Work(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, amount)
+		if assert.Len(t, infos, 2) {
+			assert.Equal(t, 1, infos[1].Attempt, "A retried dead job should start over")
+		}
+	})
+
+	t.Run("ok: kill job whose lock expired on its last attempt", func(t *testing.T) {
+		reset()
+		job := testJob{Name: "crash", Crash: true}
+		id, err := queue.Enqueue(ctx, job, core.JobOptions{MaxAttempts: 2})
+		tests.Check(err)
+		amount, err := queue.Work(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, amount)
+
+		amount, err = queue.Work(ctx)
+		assert.Nil(t, err)
+		assert.Zero(t, amount, "A job should not run again once its last attempt expired")
+		assert.Len(t, ran, 1)
+		page, err := queue.ListDeadJobs(ctx, core.ListQuery{})
+		assert.Nil(t, err)
+		index := slices.IndexFunc(page.Items, func(job core.DeadJob) bool { return job.ID == id })
+		if assert.NotEqual(t, -1, index, "A job that expired on its last attempt should be dead") {
+			assert.Equal(t, 2, page.Items[index].Attempts)
+			assert.Equal(t, "lease expired", page.Items[index].LastError)
+		}
+	})
+}
+
+func TestJobQueueDrain(t *testing.T) {
+	db := tests.DB(t)
+	queue := postgres.NewJobQueue(db)
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	var finished atomic.Bool
+	postgres.HandleJob(queue, func(_ context.Context, _ testJob) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+		return nil
+	})
+	_, err := queue.Enqueue(ctx, testJob{}, core.JobOptions{})
+	tests.Check(err)
+
+	queue.Start(ctx, 10*time.Millisecond)
+	<-started
+	queue.Drain(ctx)
+	assert.True(t, finished.Load(), "Drain should wait for running jobs")
+
+	amount, err := queue.Work(ctx)
+	assert.Nil(t, err)
+	assert.Zero(t, amount, "The drained job should have finished")
+}
@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prior-it/apollo/core"
+)
+
+// sortColumn is a column that a list can be sorted on.
+type sortColumn[T any] struct {
+	// SQL expression to sort on, this should never be NULL
+	expr string
+	// Postgres type of the expression, used to cast the cursor value
+	typ string
+	// value returns the sort value of an item as it should be stored in the cursor
+	value func(item *T) string
+}
+
+// listFilter returns the SQL condition of a filter together with the argument that should be passed in the
+// specified placeholder. This returns core.ErrInvalidListQuery if the filter's value is invalid.
+type listFilter func(placeholder string, value string) (condition string, arg any, err error)
+
+// keysetList describes a list that is retrieved with keyset pagination.
+// Every page continues after the (sort value, id) of the last item of the previous page, so retrieving a page is
+// equally fast regardless of its position in the list.
+type keysetList[T any, R any] struct {
+	// Columns to select, in the order of the fields of R
+	columns string
+	// FROM clause, including any joins
+	from string
+	// Conditions that always apply, using placeholders $1 to $len(args)
+	where []string
+	args  []any
+	// Unique id column, used to break ties between equal sort values
+	id          string
+	sorts       map[string]sortColumn[T]
+	defaultSort string
+	filters     map[string]listFilter
+	// Values of the filters that apply when the query does not specify them
+	defaultFilters map[string]string
+	convert        func(row R) (*T, error)
+	itemID         func(item *T) core.ID
+}
+
+// list retrieves the page of the list that is specified by the query.
+func (l *keysetList[T, R]) list(
+	ctx context.Context,
+	db *DB,
+	query core.ListQuery,
+) (*core.Page[T], error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	sortName := query.Sort
+	if len(sortName) == 0 {
+		sortName = l.defaultSort
+	}
+	sort, ok := l.sorts[sortName]
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot sort on %q", core.ErrInvalidListQuery, query.Sort)
+	}
+
+	filters := maps.Clone(l.defaultFilters)
+	if filters == nil {
+		filters = make(map[string]string, len(query.Filters))
+	}
+	maps.Copy(filters, query.Filters)
+
+	args := slices.Clone(l.args)
+	where := slices.Clone(l.where)
+	for _, name := range slices.Sorted(maps.Keys(filters)) {
+		filter, ok := l.filters[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown filter %q", core.ErrInvalidListQuery, name)
+		}
+		condition, arg, err := filter(fmt.Sprintf("$%d", len(args)+1), filters[name])
+		if err != nil {
+			err = fmt.Errorf("invalid value for filter %q: %w", name, err)
+			return nil, errors.Join(core.ErrInvalidListQuery, err)
+		}
+		args = append(args, arg)
+		where = append(where, condition)
+	}
+
+	total, err := l.count(ctx, db, where, args)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := query.DecodeCursor()
+	if err != nil {
+		return nil, err
+	}
+	direction, operator := "ASC", ">"
+	if query.Descending() {
+		direction, operator = "DESC", "<"
+	}
+	if cursor != nil {
+		args = append(args, cursor.Value, int32(cursor.ID))
+		where = append(where, fmt.Sprintf(
+			"(%s, %s) %s ($%d::%s, $%d)",
+			sort.expr, l.id, operator, len(args)-1, sort.typ, len(args),
+		))
+	}
+	limit := query.PageSize()
+	args = append(args, limit+1)
+	sql := fmt.Sprintf(
+		"SELECT %s FROM %s%s ORDER BY %s %s, %s %s LIMIT $%d",
+		l.columns, l.from, whereClause(where), sort.expr, direction, l.id, direction, len(args),
+	)
+
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	dbItems, err := pgx.CollectRows(rows, pgx.RowToStructByPos[R])
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+
+	page := &core.Page[T]{Items: make([]T, 0, min(len(dbItems), limit)), Total: total}
+	for i, dbItem := range dbItems {
+		if i == limit {
+			last := &page.Items[limit-1]
+			page.NextCursor = core.Cursor{
+				Sort:      query.Sort,
+				Direction: query.Direction,
+				Value:     sort.value(last),
+				ID:        l.itemID(last),
+			}.Encode()
+			break
+		}
+		item, err := l.convert(dbItem)
+		if err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, *item)
+	}
+	return page, nil
+}
+
+// count returns the amount of items that match the conditions.
+func (l *keysetList[T, R]) count(
+	ctx context.Context,
+	db *DB,
+	where []string,
+	args []any,
+) (uint64, error) {
+	var total int64
+	err := db.QueryRow(ctx, "SELECT COUNT(*) FROM "+l.from+whereClause(where), args...).Scan(&total)
+	if err != nil {
+		return 0, ConvertPgError(err)
+	}
+	return uint64(total), nil
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// containsFilter matches items where one of the text expressions contains the filter's value, ignoring case.
+func containsFilter(exprs ...string) listFilter {
+	return func(placeholder string, value string) (string, any, error) {
+		conditions := make([]string, len(exprs))
+		for i, expr := range exprs {
+			conditions[i] = fmt.Sprintf("%s ILIKE %s", expr, placeholder)
+		}
+		return "(" + strings.Join(conditions, " OR ") + ")", containsPattern(value), nil
+	}
+}
+
+// containsPattern returns the LIKE pattern that matches texts that contain the value.
+func containsPattern(value string) string {
+	return "%" + likeEscaper.Replace(value) + "%"
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// equalsFilter matches items where the expression equals the filter's value after parsing it.
+func equalsFilter[V any](expr string, parse func(value string) (V, error)) listFilter {
+	return func(placeholder string, value string) (string, any, error) {
+		arg, err := parse(value)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("%s = %s", expr, placeholder), arg, nil
+	}
+}
+
+// deletedFilter matches soft deleted items if the filter's value is true and active items if it is false.
+func deletedFilter(column string) listFilter {
+	return equalsFilter(fmt.Sprintf("(%s IS NOT NULL)", column), strconv.ParseBool)
+}
+
+func parseText(value string) (string, error) {
+	return value, nil
+}
+
+func parseID(value string) (int32, error) {
+	id, err := core.ParseID(value)
+	return int32(id), err
+}
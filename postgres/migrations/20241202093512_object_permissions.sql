@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS user_object_permissions (
+    user_id integer NOT NULL,
+    permission text NOT NULL,
+    resource_type text NOT NULL,
+    resource_id integer NOT NULL,
+    PRIMARY KEY (user_id, permission, resource_type, resource_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (permission) REFERENCES permissions (name) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS permissiongroup_object_permissions (
+    group_id integer NOT NULL,
+    permission text NOT NULL,
+    resource_type text NOT NULL,
+    resource_id integer NOT NULL,
+    PRIMARY KEY (group_id, permission, resource_type, resource_id),
+    FOREIGN KEY (group_id) REFERENCES permissiongroups (id) ON DELETE CASCADE,
+    FOREIGN KEY (permission) REFERENCES permissions (name) ON DELETE CASCADE
+);
+
+CREATE INDEX user_object_permissions_resource_idx ON user_object_permissions (resource_type, resource_id);
+
+CREATE INDEX permissiongroup_object_permissions_resource_idx ON permissiongroup_object_permissions (resource_type, resource_id);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS permissiongroup_object_permissions_resource_idx;
+
+DROP INDEX IF EXISTS user_object_permissions_resource_idx;
+
+DROP TABLE IF EXISTS permissiongroup_object_permissions;
+
+DROP TABLE IF EXISTS user_object_permissions;
+
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE permissiongroups
+    ADD COLUMN organisation_id integer NULL,
+    ADD FOREIGN KEY (organisation_id) REFERENCES organisations (id) ON DELETE CASCADE;
+
+CREATE INDEX permissiongroups_organisation_id_idx ON permissiongroups (organisation_id);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS permissiongroups_organisation_id_idx;
+
+ALTER TABLE permissiongroups
+    DROP COLUMN organisation_id;
+
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS organisation_default_permissiongroups (
+    organisation_id integer NOT NULL,
+    permission_group_id integer NOT NULL,
+    PRIMARY KEY (organisation_id, permission_group_id),
+    FOREIGN KEY (organisation_id) REFERENCES organisations (id) ON DELETE CASCADE,
+    FOREIGN KEY (permission_group_id) REFERENCES permissiongroups (id) ON DELETE CASCADE
+);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS organisation_default_permissiongroups;
+
+-- +goose StatementEnd
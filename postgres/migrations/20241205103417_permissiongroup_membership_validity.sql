@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE user_permissiongroup_membership
+    ADD COLUMN valid_from timestamptz,
+    ADD COLUMN valid_until timestamptz,
+    ADD CONSTRAINT user_permissiongroup_membership_validity CHECK (valid_from < valid_until);
+
+ALTER TABLE organisation_users_permissiongroups
+    ADD COLUMN valid_from timestamptz,
+    ADD COLUMN valid_until timestamptz,
+    ADD CONSTRAINT organisation_users_permissiongroups_validity CHECK (valid_from < valid_until);
+
+CREATE INDEX user_permissiongroup_membership_valid_until ON user_permissiongroup_membership (valid_until)
+WHERE
+    valid_until IS NOT NULL;
+
+CREATE INDEX organisation_users_permissiongroups_valid_until ON organisation_users_permissiongroups (valid_until)
+WHERE
+    valid_until IS NOT NULL;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS organisation_users_permissiongroups_valid_until;
+
+DROP INDEX IF EXISTS user_permissiongroup_membership_valid_until;
+
+ALTER TABLE organisation_users_permissiongroups
+    DROP CONSTRAINT organisation_users_permissiongroups_validity,
+    DROP COLUMN valid_until,
+    DROP COLUMN valid_from;
+
+ALTER TABLE user_permissiongroup_membership
+    DROP CONSTRAINT user_permissiongroup_membership_validity,
+    DROP COLUMN valid_until,
+    DROP COLUMN valid_from;
+
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE organisation_users
+    ADD COLUMN joined_at timestamptz NOT NULL DEFAULT NOW(),
+    ADD COLUMN invited_by integer NULL,
+    ADD COLUMN role text NULL,
+    ADD COLUMN status text NOT NULL DEFAULT 'active',
+    ADD FOREIGN KEY (invited_by) REFERENCES users (id) ON DELETE SET NULL,
+    ADD CONSTRAINT organisation_users_status CHECK (status IN ('pending', 'active', 'left'));
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE organisation_users
+    DROP CONSTRAINT organisation_users_status,
+    DROP COLUMN status,
+    DROP COLUMN role,
+    DROP COLUMN invited_by,
+    DROP COLUMN joined_at;
+
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE organisations
+    ADD COLUMN legal_name text NULL,
+    ADD COLUMN vat_number text NULL;
+
+CREATE TABLE IF NOT EXISTS organisation_addresses (
+    organisation_id integer NOT NULL,
+    address_id integer NOT NULL,
+    type text NOT NULL,
+    PRIMARY KEY (organisation_id, type),
+    FOREIGN KEY (organisation_id) REFERENCES organisations (id) ON DELETE CASCADE,
+    FOREIGN KEY (address_id) REFERENCES address (id) ON DELETE CASCADE,
+    CONSTRAINT organisation_addresses_type CHECK (type IN ('billing', 'shipping', 'registered_office'))
+);
+
+CREATE UNIQUE INDEX organisation_addresses_address_id_idx ON organisation_addresses (address_id);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS organisation_addresses_address_id_idx;
+
+DROP TABLE IF EXISTS organisation_addresses;
+
+ALTER TABLE organisations
+    DROP COLUMN vat_number,
+    DROP COLUMN legal_name;
+
+-- +goose StatementEnd
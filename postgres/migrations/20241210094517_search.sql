@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+-- pg_trgm is installed once per database, so every tenant schema uses the same extension from the public schema
+CREATE EXTENSION IF NOT EXISTS pg_trgm WITH SCHEMA public;
+
+ALTER TABLE users
+    ADD COLUMN search tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', name), 'A') ||
+        setweight(to_tsvector('simple', regexp_replace(email, '[@._+-]+', ' ', 'g')), 'B')
+    ) STORED;
+
+CREATE INDEX users_search_idx ON users USING gin (search);
+
+CREATE INDEX users_name_trgm_idx ON users USING gin (name public.gin_trgm_ops);
+
+CREATE INDEX users_email_trgm_idx ON users USING gin (email public.gin_trgm_ops);
+
+ALTER TABLE organisations
+    ADD COLUMN search tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', name), 'A') ||
+        setweight(to_tsvector('simple', COALESCE(legal_name, '')), 'B')
+    ) STORED;
+
+CREATE INDEX organisations_search_idx ON organisations USING gin (search);
+
+CREATE INDEX organisations_name_trgm_idx ON organisations USING gin (name public.gin_trgm_ops);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS organisations_name_trgm_idx;
+
+DROP INDEX IF EXISTS organisations_search_idx;
+
+ALTER TABLE organisations
+    DROP COLUMN search;
+
+DROP INDEX IF EXISTS users_email_trgm_idx;
+
+DROP INDEX IF EXISTS users_name_trgm_idx;
+
+DROP INDEX IF EXISTS users_search_idx;
+
+ALTER TABLE users
+    DROP COLUMN search;
+
+-- +goose StatementEnd
@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN deleted_at timestamptz NULL;
+
+-- Deleted users should not prevent a new account with the same e-mail address
+ALTER TABLE users
+    DROP CONSTRAINT users_email_key;
+
+CREATE UNIQUE INDEX users_email_idx ON users (email)
+WHERE
+    deleted_at IS NULL;
+
+CREATE INDEX users_deleted_at_idx ON users (deleted_at)
+WHERE
+    deleted_at IS NOT NULL;
+
+ALTER TABLE organisations
+    ADD COLUMN deleted_at timestamptz NULL;
+
+CREATE INDEX organisations_deleted_at_idx ON organisations (deleted_at)
+WHERE
+    deleted_at IS NOT NULL;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM organisations
+WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS organisations_deleted_at_idx;
+
+ALTER TABLE organisations
+    DROP COLUMN deleted_at;
+
+DELETE FROM users
+WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS users_deleted_at_idx;
+
+DROP INDEX IF EXISTS users_email_idx;
+
+ALTER TABLE users
+    ADD CONSTRAINT users_email_key UNIQUE (email);
+
+ALTER TABLE users
+    DROP COLUMN deleted_at;
+
+-- +goose StatementEnd
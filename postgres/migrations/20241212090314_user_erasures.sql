@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The user ids deliberately have no foreign keys, so the record outlives the users that it refers to
+CREATE TABLE IF NOT EXISTS user_erasures (
+    id serial PRIMARY KEY,
+    user_id integer NOT NULL,
+    mode text NOT NULL,
+    requested_by integer NULL,
+    reason text NULL,
+    erased_at timestamptz NOT NULL DEFAULT NOW(),
+    CONSTRAINT user_erasures_mode CHECK (mode IN ('anonymise', 'delete'))
+);
+
+CREATE INDEX user_erasures_user_id_idx ON user_erasures (user_id);
+
+-- Anonymised users are kept for their references, so they are never purged
+ALTER TABLE users
+    ADD COLUMN anonymised_at timestamptz NULL;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP COLUMN anonymised_at;
+
+DROP TABLE IF EXISTS user_erasures;
+
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The user ids deliberately have no foreign keys, so the log outlives the users that it refers to
+CREATE TABLE IF NOT EXISTS audit_log (
+    id serial PRIMARY KEY,
+    occurred_at timestamptz NOT NULL DEFAULT NOW(),
+    actor_id integer NULL,
+    impersonator_id integer NULL,
+    action text NOT NULL,
+    entity_type text NOT NULL,
+    entity_id text NOT NULL,
+    changes jsonb NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX audit_log_occurred_at_idx ON audit_log (occurred_at);
+
+CREATE INDEX audit_log_actor_idx ON audit_log (actor_id, occurred_at);
+
+CREATE INDEX audit_log_impersonator_idx ON audit_log (impersonator_id, occurred_at)
+WHERE
+    impersonator_id IS NOT NULL;
+
+CREATE INDEX audit_log_entity_idx ON audit_log (entity_type, entity_id, occurred_at);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_log;
+
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Events are written in the same transaction as the mutation that caused them and removed once they are delivered
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id bigserial PRIMARY KEY,
+    event_type text NOT NULL,
+    payload jsonb NOT NULL,
+    actor_id integer NULL,
+    impersonator_id integer NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    available_at timestamptz NOT NULL DEFAULT NOW(),
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text NULL
+);
+
+CREATE INDEX event_outbox_available_idx ON event_outbox (available_at, id);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS event_outbox;
+
+-- +goose StatementEnd
@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id serial PRIMARY KEY,
+    organisation_id integer NOT NULL REFERENCES organisations (id) ON DELETE CASCADE,
+    url text NOT NULL,
+    secret text NOT NULL,
+    -- An empty array subscribes the endpoint to every event
+    events text[] NOT NULL DEFAULT '{}',
+    enabled boolean NOT NULL DEFAULT TRUE,
+    created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX webhook_endpoints_organisation_idx ON webhook_endpoints (organisation_id);
+
+-- The payload is stored as sent, so replaying a delivery sends exactly the same body
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id serial PRIMARY KEY,
+    endpoint_id integer NOT NULL REFERENCES webhook_endpoints (id) ON DELETE CASCADE,
+    event_id text NOT NULL,
+    event_type text NOT NULL,
+    payload jsonb NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NULL DEFAULT NOW(),
+    -- The end of the lease of the sender that is sending the delivery
+    locked_until timestamptz NULL,
+    response_code integer NULL,
+    last_error text NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    delivered_at timestamptz NULL,
+    -- Events are delivered from the outbox at least once, this makes sure each endpoint only sees them once
+    UNIQUE (endpoint_id, event_id)
+);
+
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at, id)
+WHERE
+    status = 'pending';
+
+CREATE INDEX webhook_deliveries_sending_idx ON webhook_deliveries (locked_until)
+WHERE
+    status = 'sending';
+
+CREATE INDEX webhook_deliveries_endpoint_idx ON webhook_deliveries (endpoint_id, created_at);
+
+CREATE TABLE IF NOT EXISTS webhook_attempts (
+    id serial PRIMARY KEY,
+    delivery_id integer NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+    attempted_at timestamptz NOT NULL DEFAULT NOW(),
+    response_code integer NULL,
+    error text NULL,
+    duration_ms integer NOT NULL
+);
+
+CREATE INDEX webhook_attempts_delivery_idx ON webhook_attempts (delivery_id, attempted_at);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS webhook_attempts;
+
+DROP TABLE IF EXISTS webhook_deliveries;
+
+DROP TABLE IF EXISTS webhook_endpoints;
+
+-- +goose StatementEnd
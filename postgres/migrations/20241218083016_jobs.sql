@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Jobs are removed once they succeed, jobs that fail every attempt are kept with the 'dead' status
+CREATE TABLE IF NOT EXISTS jobs (
+    id serial PRIMARY KEY,
+    kind text NOT NULL,
+    payload jsonb NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    unique_key text NULL,
+    run_at timestamptz NOT NULL DEFAULT NOW(),
+    attempts integer NOT NULL DEFAULT 0,
+    max_attempts integer NOT NULL,
+    last_error text NULL,
+    -- A running job whose lock expired belongs to a worker that stopped, so another worker takes over
+    locked_until timestamptz NULL,
+    actor_id integer NULL,
+    impersonator_id integer NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    died_at timestamptz NULL
+);
+
+CREATE INDEX jobs_pending_idx ON jobs (run_at, id)
+WHERE
+    status <> 'dead';
+
+CREATE INDEX jobs_dead_idx ON jobs (died_at, id)
+WHERE
+    status = 'dead';
+
+CREATE UNIQUE INDEX jobs_unique_idx ON jobs (kind, unique_key)
+WHERE
+    status <> 'dead';
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS jobs;
+
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Every scheduled time of a task runs once, no matter how many instances of the application are running
+CREATE TABLE IF NOT EXISTS cron_runs (
+    id serial PRIMARY KEY,
+    task text NOT NULL,
+    scheduled_at timestamptz NOT NULL,
+    started_at timestamptz NOT NULL DEFAULT NOW(),
+    finished_at timestamptz NULL,
+    error text NULL,
+    instance text NOT NULL,
+    UNIQUE (task, scheduled_at)
+);
+
+CREATE INDEX cron_runs_started_at_idx ON cron_runs (started_at);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS cron_runs;
+
+-- +goose StatementEnd
@@ -55,6 +55,12 @@ func (s *PgOauthAccountService) CreateUserAccount(
 		return nil, fmt.Errorf("cannot create user: %w", err)
 	}
 
+	// A soft deleted user cannot log in anymore, so the new user takes over the login of its account
+	err = qtx.DeleteAccountOfDeletedUser(ctx, data.Provider, data.ProviderID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot delete account of deleted user: %w", err)
+	}
+
 	_, err = qtx.CreateAccount(ctx, sqlc.CreateAccountParams{
 		UserID:     user.ID,
 		Provider:   data.Provider,
@@ -64,11 +70,20 @@ func (s *PgOauthAccountService) CreateUserAccount(
 		return nil, fmt.Errorf("cannot create account: %w", err)
 	}
 
+	newUser, err := convertUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.db.provisionUser(ctx, tx, newUser); err != nil {
+		return nil, err
+	}
+
 	if err = tx.Commit(ctx); err != nil {
 		return nil, err
 	}
 
-	return convertUser(user)
+	return newUser, nil
 }
 
 func (s *PgOauthAccountService) FindUser(
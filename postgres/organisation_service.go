@@ -3,20 +3,37 @@ package postgres
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/prior-it/apollo/core"
 	"github.com/prior-it/apollo/postgres/internal/sqlc"
 )
 
 func NewOrganisationService(DB *DB) *OrganisationService {
 	q := sqlc.New(DB)
-	return &OrganisationService{DB, q}
+	return &OrganisationService{
+		db:           DB,
+		q:            q,
+		addresses:    NewAddressService(DB),
+		cascadeLimit: core.DefaultDeleteCascadeLimit,
+	}
 }
 
 // Postgres implementation of the core OrganisationService interface.
 type OrganisationService struct {
-	db *DB
-	q  *sqlc.Queries
+	db           *DB
+	q            *sqlc.Queries
+	addresses    *AddressService
+	cascadeLimit int
+}
+
+// SetDeleteCascadeLimit changes the maximum amount of descendants that DeleteOrganisation will remove together with
+// an organisation. The default is core.DefaultDeleteCascadeLimit.
+func (o *OrganisationService) SetDeleteCascadeLimit(limit int) {
+	o.cascadeLimit = limit
 }
 
 // Force struct to implement the core interface
@@ -32,23 +49,39 @@ func (o *OrganisationService) CreateOrganisation(
 }
 
 // Calls CreateOrganisation query using as a regular query or as a transaction
+// DB.InTx makes every service method part of a transaction, so prefer it over the specified connection.
 func (o *OrganisationService) CreateOrganisationTx(
 	ctx context.Context,
 	dbtx sqlc.DBTX,
 	name string,
 	parentID *core.OrganisationID,
 ) (*core.Organisation, error) {
-	queries := sqlc.New(dbtx)
 	var castParentID *int32
 	if parentID != nil {
 		intID := int32(*parentID)
 		castParentID = &intID
 	}
-	organisation, err := queries.CreateOrganisation(ctx, name, castParentID)
+	var org *core.Organisation
+	err := runInTx(ctx, dbtx, func(tx pgx.Tx) error {
+		organisation, err := sqlc.New(tx).CreateOrganisation(ctx, name, castParentID)
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		org, err = core.ParseOrganisation(organisation.ID, organisation.Name, organisation.ParentID)
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, core.AuditRecord{
+			Action:     core.AuditCreate,
+			EntityType: core.AuditOrganisation,
+			EntityID:   org.ID.String(),
+			After:      org,
+		})
+	})
 	if err != nil {
-		return nil, ConvertPgError(err)
+		return nil, err
 	}
-	return core.ParseOrganisation(organisation.ID, organisation.Name, organisation.ParentID)
+	return org, nil
 }
 
 // Calls UpdateOrganisation query
@@ -57,20 +90,303 @@ func (o *OrganisationService) UpdateOrganisation(
 	organisationID core.OrganisationID,
 	name string,
 ) (*core.Organisation, error) {
-	intID := int32(organisationID)
-	org, err := o.q.UpdateOrganisation(ctx, intID, name)
+	var org sqlc.Organisation
+	err := runInTx(ctx, o.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		before, err := queries.GetOrganisation(ctx, int32(organisationID))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		org, err = queries.UpdateOrganisation(ctx, int32(organisationID), name)
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		return recordOrganisationUpdate(ctx, tx, before, org)
+	})
 	if err != nil {
-		return nil, ConvertPgError(err)
+		return nil, err
 	}
 	return core.ParseOrganisation(org.ID, org.Name, org.ParentID)
 }
 
+// recordOrganisationUpdate records the changed fields of an organisation.
+func recordOrganisationUpdate(
+	ctx context.Context,
+	tx pgx.Tx,
+	before sqlc.Organisation,
+	after sqlc.Organisation,
+) error {
+	beforeOrg, err := core.ParseOrganisation(before.ID, before.Name, before.ParentID)
+	if err != nil {
+		return err
+	}
+	afterOrg, err := core.ParseOrganisation(after.ID, after.Name, after.ParentID)
+	if err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, core.AuditRecord{
+		Action:     core.AuditUpdate,
+		EntityType: core.AuditOrganisation,
+		EntityID:   afterOrg.ID.String(),
+		Before:     beforeOrg,
+		After:      afterOrg,
+	})
+}
+
+// organisationAudit describes a mutation of an organisation that does not change any of its fields.
+func organisationAudit(action core.AuditAction, id core.OrganisationID) core.AuditRecord {
+	return core.AuditRecord{
+		Action:     action,
+		EntityType: core.AuditOrganisation,
+		EntityID:   id.String(),
+	}
+}
+
 // DeleteOrganisation implements core.OrganisationService.DeleteOrganisation
 func (o *OrganisationService) DeleteOrganisation(
 	ctx context.Context,
 	id core.OrganisationID,
 ) error {
-	return o.q.DeleteOrganisation(ctx, int32(id))
+	return runInTx(ctx, o.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		if err := queries.LockOrganisationHierarchy(ctx); err != nil {
+			return fmt.Errorf("could not lock organisation hierarchy: %w", err)
+		}
+		parentID := int32(id)
+		amount, err := queries.CountOrganisationDescendants(ctx, &parentID)
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		if amount > int64(o.cascadeLimit) {
+			return fmt.Errorf(
+				"cannot delete organisation %v with %d descendants: %w",
+				id,
+				amount,
+				errors.Join(core.ErrConflict, core.ErrOrganisationHasDescendants),
+			)
+		}
+		return deleteOrganisationTree(ctx, tx, id)
+	})
+}
+
+// DeleteOrganisationTree implements core.OrganisationService.DeleteOrganisationTree
+func (o *OrganisationService) DeleteOrganisationTree(
+	ctx context.Context,
+	id core.OrganisationID,
+) error {
+	return runInTx(ctx, o.db, func(tx pgx.Tx) error {
+		return deleteOrganisationTree(ctx, tx, id)
+	})
+}
+
+// deleteOrganisationTree soft deletes an organisation together with all of its descendants.
+// Only the organisation itself is recorded in the audit log, since its descendants are restored together with it.
+func deleteOrganisationTree(ctx context.Context, tx pgx.Tx, id core.OrganisationID) error {
+	amount, err := sqlc.New(tx).DeleteOrganisation(ctx, int32(id))
+	if err != nil || amount == 0 {
+		return ConvertPgError(err)
+	}
+	return recordAudit(ctx, tx, organisationAudit(core.AuditDelete, id))
+}
+
+// RestoreOrganisation implements core.OrganisationService.RestoreOrganisation
+func (o *OrganisationService) RestoreOrganisation(
+	ctx context.Context,
+	id core.OrganisationID,
+) error {
+	return runInTx(ctx, o.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		if err := queries.LockOrganisationHierarchy(ctx); err != nil {
+			return fmt.Errorf("could not lock organisation hierarchy: %w", err)
+		}
+		parentID, err := queries.GetParentOrganisation(ctx, int32(id))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		if parentID != nil {
+			deleted, err := queries.IsOrganisationDeleted(ctx, *parentID)
+			if err != nil {
+				return ConvertPgError(err)
+			}
+			if deleted {
+				return fmt.Errorf(
+					"cannot restore organisation %v while its parent is deleted: %w",
+					id,
+					core.ErrConflict,
+				)
+			}
+		}
+		amount, err := queries.RestoreOrganisation(ctx, int32(id))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		if amount == 0 {
+			return core.ErrNotFound
+		}
+		return recordAudit(ctx, tx, organisationAudit(core.AuditRestore, id))
+	})
+}
+
+// PurgeOrganisation implements core.OrganisationService.PurgeOrganisation
+func (o *OrganisationService) PurgeOrganisation(
+	ctx context.Context,
+	id core.OrganisationID,
+) error {
+	return runInTx(ctx, o.db, func(tx pgx.Tx) error {
+		return purgeOrganisationTree(ctx, tx, id)
+	})
+}
+
+// PurgeDeletedOrganisations implements core.OrganisationService.PurgeDeletedOrganisations
+func (o *OrganisationService) PurgeDeletedOrganisations(
+	ctx context.Context,
+	before time.Time,
+) (uint64, error) {
+	var amount uint64
+	err := runInTx(ctx, o.db, func(tx pgx.Tx) error {
+		ids, err := sqlc.New(tx).ListPurgeableOrganisations(ctx, toTimestamptz(&before))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		for _, id := range ids {
+			if err := purgeOrganisationTree(ctx, tx, core.OrganisationID(id)); err != nil {
+				return err
+			}
+		}
+		amount = uint64(len(ids))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return amount, nil
+}
+
+// purgeOrganisationTree permanently deletes an organisation, all of its descendants and the addresses they own.
+func purgeOrganisationTree(
+	ctx context.Context,
+	tx pgx.Tx,
+	id core.OrganisationID,
+) error {
+	queries := sqlc.New(tx)
+	// Addresses are not removed by the cascade since they are only referenced by the organisations
+	if err := queries.DeleteOrganisationTreeAddresses(ctx, int32(id)); err != nil {
+		return fmt.Errorf("could not delete organisation addresses: %w", ConvertPgError(err))
+	}
+	if err := queries.PurgeOrganisation(ctx, int32(id)); err != nil {
+		return ConvertPgError(err)
+	}
+	return recordAudit(ctx, tx, organisationAudit(core.AuditPurge, id))
+}
+
+// MoveOrganisation implements core.OrganisationService.MoveOrganisation
+func (o *OrganisationService) MoveOrganisation(
+	ctx context.Context,
+	id core.OrganisationID,
+	parentID *core.OrganisationID,
+) (*core.Organisation, error) {
+	var org sqlc.Organisation
+	err := runInTx(ctx, o.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		// Concurrent moves could otherwise create a cycle together
+		if err := queries.LockOrganisationHierarchy(ctx); err != nil {
+			return fmt.Errorf("could not lock organisation hierarchy: %w", err)
+		}
+		var castParentID *int32
+		if parentID != nil {
+			if *parentID == id {
+				return errors.Join(core.ErrConflict, core.ErrOrganisationCycle)
+			}
+			if _, err := queries.GetOrganisation(ctx, int32(*parentID)); err != nil {
+				return ConvertPgError(err)
+			}
+			ancestors, err := queries.ListOrganisationAncestors(ctx, int32(*parentID))
+			if err != nil {
+				return ConvertPgError(err)
+			}
+			for _, ancestor := range ancestors {
+				if core.OrganisationID(ancestor.ID) == id {
+					return errors.Join(core.ErrConflict, core.ErrOrganisationCycle)
+				}
+			}
+			intID := int32(*parentID)
+			castParentID = &intID
+		}
+		before, err := queries.GetOrganisation(ctx, int32(id))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		org, err = queries.MoveOrganisation(ctx, int32(id), castParentID)
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		return recordOrganisationUpdate(ctx, tx, before, org)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return core.ParseOrganisation(org.ID, org.Name, org.ParentID)
+}
+
+// ListAncestors implements core.OrganisationService.ListAncestors
+func (o *OrganisationService) ListAncestors(
+	ctx context.Context,
+	id core.OrganisationID,
+) ([]core.OrganisationLevel, error) {
+	rows, err := o.q.ListOrganisationAncestors(ctx, int32(id))
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	list := make([]core.OrganisationLevel, len(rows))
+	for i, row := range rows {
+		list[i], err = convertOrganisationLevel(row.ID, row.Name, row.ParentID, row.Depth)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// ListDescendants implements core.OrganisationService.ListDescendants
+func (o *OrganisationService) ListDescendants(
+	ctx context.Context,
+	id core.OrganisationID,
+) ([]core.OrganisationLevel, error) {
+	parentID := int32(id)
+	rows, err := o.q.ListOrganisationDescendants(ctx, &parentID)
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	list := make([]core.OrganisationLevel, len(rows))
+	for i, row := range rows {
+		list[i], err = convertOrganisationLevel(row.ID, row.Name, row.ParentID, row.Depth)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// GetOrganisationTree implements core.OrganisationService.GetOrganisationTree
+func (o *OrganisationService) GetOrganisationTree(
+	ctx context.Context,
+	id core.OrganisationID,
+) (*core.OrganisationNode, error) {
+	root, err := o.GetOrganisation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	descendants, err := o.ListDescendants(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	organisations := make([]core.Organisation, 0, len(descendants)+1)
+	organisations = append(organisations, *root)
+	for _, descendant := range descendants {
+		organisations = append(organisations, descendant.Organisation)
+	}
+	// The root's parent is not part of the list, so it is the only root in the result
+	return core.BuildOrganisationTree(organisations)[0], nil
 }
 
 // GetAmountOfOrganisations implements core.OrganisationService.GetAmountOfOrganisations
@@ -95,12 +411,75 @@ func (o *OrganisationService) GetOrganisation(
 }
 
 // ListOrganisations implements core.OrganisationService.ListOrganisations
-func (o *OrganisationService) ListOrganisations(ctx context.Context) ([]core.Organisation, error) {
-	organisations, err := o.q.ListOrganisations(ctx)
+func (o *OrganisationService) ListOrganisations(
+	ctx context.Context,
+	query core.ListQuery,
+) (*core.Page[core.Organisation], error) {
+	return organisationList.list(ctx, o.db, query)
+}
+
+// SearchOrganisations implements core.OrganisationService.SearchOrganisations
+func (o *OrganisationService) SearchOrganisations(
+	ctx context.Context,
+	query core.SearchQuery,
+) ([]core.SearchResult[core.Organisation], error) {
+	if query.IsEmpty() {
+		return []core.SearchResult[core.Organisation]{}, nil
+	}
+	var userID *int32
+	if query.UserID != nil {
+		id := int32(*query.UserID)
+		userID = &id
+	}
+	rows, err := o.q.SearchOrganisations(ctx, sqlc.SearchOrganisationsParams{
+		Query:      query.Text,
+		Pattern:    containsPattern(query.Text),
+		UserID:     userID,
+		MaxResults: int32(query.PageSize()),
+	})
 	if err != nil {
 		return nil, ConvertPgError(err)
 	}
-	return convertOrganisationList(organisations)
+	results := make([]core.SearchResult[core.Organisation], len(rows))
+	for i, row := range rows {
+		org, err := core.ParseOrganisation(
+			row.Organisation.ID,
+			row.Organisation.Name,
+			row.Organisation.ParentID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = core.SearchResult[core.Organisation]{
+			Item: *org,
+			Rank: row.Rank,
+			Highlights: map[string]core.Highlight{
+				"name": core.HighlightText(org.Name, query.Text),
+			},
+		}
+	}
+	return results, nil
+}
+
+var organisationList = &keysetList[core.Organisation, sqlc.Organisation]{
+	columns: "o.id, o.name, o.parent_id, o.legal_name, o.vat_number, o.search, o.deleted_at",
+	from:    "organisations AS o",
+	id:      "o.id",
+	sorts: map[string]sortColumn[core.Organisation]{
+		"id":   {"o.id", "integer", func(org *core.Organisation) string { return org.ID.String() }},
+		"name": {"o.name", "text", func(org *core.Organisation) string { return org.Name }},
+	},
+	defaultSort: "name",
+	filters: map[string]listFilter{
+		"search":  containsFilter("o.name"),
+		"parent":  equalsFilter("o.parent_id", parseID),
+		"deleted": deletedFilter("o.deleted_at"),
+	},
+	defaultFilters: map[string]string{"deleted": "false"},
+	convert: func(org sqlc.Organisation) (*core.Organisation, error) {
+		return core.ParseOrganisation(org.ID, org.Name, org.ParentID)
+	},
+	itemID: func(org *core.Organisation) core.ID { return org.ID },
 }
 
 // ListOrganisationChildren implements core.OrganisationService.ListOrganisationChildren
@@ -120,12 +499,15 @@ func (o *OrganisationService) ListOrganisationChildren(
 func (o *OrganisationService) ListUsersInOrganisation(
 	ctx context.Context,
 	id core.OrganisationID,
-) ([]core.User, error) {
-	users, err := o.q.ListUsersInOrganisation(ctx, int32(id))
-	if err != nil {
-		return nil, ConvertPgError(err)
-	}
-	return convertUserList(users)
+	query core.ListQuery,
+) (*core.Page[core.User], error) {
+	list := userList(
+		"users AS u INNER JOIN organisation_users AS ou ON u.id = ou.user_id",
+		"ou.organisation_id = $1",
+		"ou.status = 'active'",
+	)
+	list.args = []any{int32(id)}
+	return list.list(ctx, o.db, query)
 }
 
 // ListOrganisationsForUser implements core.OrganisationService.ListOrganisationsForUser
@@ -149,14 +531,457 @@ func (o *OrganisationService) AddUser(
 	return o.AddUserTx(ctx, o.db, UserID, OrgID)
 }
 
+// AddUserTx adds a user to an organisation using the specified connection, which may already be a transaction.
+// The organisation's default permission groups and all registered MemberProvisioners are applied in the same
+// transaction.
 func (o *OrganisationService) AddUserTx(
 	ctx context.Context,
 	dbtx sqlc.DBTX,
 	UserID core.UserID,
 	OrgID core.OrganisationID,
 ) error {
-	queries := sqlc.New(dbtx)
-	return queries.AddUserToOrganisation(ctx, int32(UserID), int32(OrgID))
+	return runInTx(ctx, dbtx, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		if err := queries.AddUserToOrganisation(ctx, int32(UserID), int32(OrgID)); err != nil {
+			return ConvertPgError(err)
+		}
+		membership, err := getMembership(ctx, queries, UserID, OrgID)
+		if err != nil {
+			return err
+		}
+		if err := o.membershipCreated(ctx, tx, membership); err != nil {
+			return err
+		}
+		return o.db.provisionMember(ctx, tx, UserID, OrgID)
+	})
+}
+
+// AddMembership implements core.OrganisationService.AddMembership
+func (o *OrganisationService) AddMembership(
+	ctx context.Context,
+	UserID core.UserID,
+	OrgID core.OrganisationID,
+	options core.MembershipOptions,
+) (*core.Membership, error) {
+	status := options.Status
+	if len(status) == 0 {
+		status = core.MembershipActive
+	}
+	if _, err := core.ParseMembershipStatus(string(status)); err != nil {
+		return nil, err
+	}
+	var invitedBy *int32
+	if options.InvitedBy != nil {
+		id := int32(*options.InvitedBy)
+		invitedBy = &id
+	}
+	var membership *core.Membership
+	err := runInTx(ctx, o.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		err := queries.AddMembership(ctx, sqlc.AddMembershipParams{
+			UserID:         int32(UserID),
+			OrganisationID: int32(OrgID),
+			Status:         string(status),
+			Role:           options.Role,
+			InvitedBy:      invitedBy,
+		})
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		membership, err = getMembership(ctx, queries, UserID, OrgID)
+		if err != nil {
+			return err
+		}
+		if err := o.membershipCreated(ctx, tx, membership); err != nil {
+			return err
+		}
+		return o.db.provisionMember(ctx, tx, UserID, OrgID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+// GetMembership implements core.OrganisationService.GetMembership
+func (o *OrganisationService) GetMembership(
+	ctx context.Context,
+	UserID core.UserID,
+	OrgID core.OrganisationID,
+) (*core.Membership, error) {
+	return getMembership(ctx, o.q, UserID, OrgID)
+}
+
+func getMembership(
+	ctx context.Context,
+	queries *sqlc.Queries,
+	UserID core.UserID,
+	OrgID core.OrganisationID,
+) (*core.Membership, error) {
+	row, err := queries.GetMembership(ctx, int32(UserID), int32(OrgID))
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	return convertMembership(
+		row.User,
+		row.OrganisationID,
+		row.JoinedAt,
+		row.InvitedBy,
+		row.Role,
+		row.Status,
+	)
+}
+
+// ListMemberships implements core.OrganisationService.ListMemberships
+func (o *OrganisationService) ListMemberships(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+) ([]core.Membership, error) {
+	rows, err := o.q.ListMemberships(ctx, int32(OrgID))
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	list := make([]core.Membership, len(rows))
+	for i, row := range rows {
+		membership, err := convertMembership(
+			row.User,
+			row.OrganisationID,
+			row.JoinedAt,
+			row.InvitedBy,
+			row.Role,
+			row.Status,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = *membership
+	}
+	return list, nil
+}
+
+// ListMembershipsForUser implements core.OrganisationService.ListMembershipsForUser
+func (o *OrganisationService) ListMembershipsForUser(
+	ctx context.Context,
+	UserID core.UserID,
+) ([]core.Membership, error) {
+	rows, err := o.q.ListMembershipsForUser(ctx, int32(UserID))
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	list := make([]core.Membership, len(rows))
+	for i, row := range rows {
+		membership, err := convertMembership(
+			row.User,
+			row.OrganisationID,
+			row.JoinedAt,
+			row.InvitedBy,
+			row.Role,
+			row.Status,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = *membership
+	}
+	return list, nil
+}
+
+// UpdateMembershipStatus implements core.OrganisationService.UpdateMembershipStatus
+func (o *OrganisationService) UpdateMembershipStatus(
+	ctx context.Context,
+	UserID core.UserID,
+	OrgID core.OrganisationID,
+	status core.MembershipStatus,
+) (*core.Membership, error) {
+	if _, err := core.ParseMembershipStatus(string(status)); err != nil {
+		return nil, err
+	}
+	return o.updateMembership(ctx, UserID, OrgID, func(queries *sqlc.Queries) (int64, error) {
+		return queries.UpdateMembershipStatus(ctx, sqlc.UpdateMembershipStatusParams{
+			Status:         string(status),
+			UserID:         int32(UserID),
+			OrganisationID: int32(OrgID),
+		})
+	})
+}
+
+// UpdateMembershipRole implements core.OrganisationService.UpdateMembershipRole
+func (o *OrganisationService) UpdateMembershipRole(
+	ctx context.Context,
+	UserID core.UserID,
+	OrgID core.OrganisationID,
+	role *string,
+) (*core.Membership, error) {
+	return o.updateMembership(ctx, UserID, OrgID, func(queries *sqlc.Queries) (int64, error) {
+		return queries.UpdateMembershipRole(ctx, sqlc.UpdateMembershipRoleParams{
+			UserID:         int32(UserID),
+			OrganisationID: int32(OrgID),
+			Role:           role,
+		})
+	})
+}
+
+// updateMembership runs an update query that returns the amount of affected rows, records the changes of the
+// membership and returns the result.
+func (o *OrganisationService) updateMembership(
+	ctx context.Context,
+	UserID core.UserID,
+	OrgID core.OrganisationID,
+	update func(queries *sqlc.Queries) (int64, error),
+) (*core.Membership, error) {
+	var membership *core.Membership
+	err := runInTx(ctx, o.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		before, err := getMembership(ctx, queries, UserID, OrgID)
+		if err != nil {
+			return err
+		}
+		amount, err := update(queries)
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		if amount == 0 {
+			return core.ErrNotFound
+		}
+		membership, err = getMembership(ctx, queries, UserID, OrgID)
+		if err != nil {
+			return err
+		}
+		return recordMembershipChange(ctx, tx, core.AuditUpdate, before, membership)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+// membershipCreated records a new membership and emits core.UserAddedToOrganisation.
+func (o *OrganisationService) membershipCreated(
+	ctx context.Context,
+	tx pgx.Tx,
+	membership *core.Membership,
+) error {
+	if err := recordMembershipChange(ctx, tx, core.AuditCreate, nil, membership); err != nil {
+		return err
+	}
+	return o.db.Emit(ctx, tx, core.UserAddedToOrganisation{
+		UserID:         membership.User.ID,
+		OrganisationID: membership.OrganisationID,
+		Status:         membership.Status,
+	})
+}
+
+// recordMembershipChange records the changed fields of a membership, either before or after may be nil.
+func recordMembershipChange(
+	ctx context.Context,
+	tx pgx.Tx,
+	action core.AuditAction,
+	before *core.Membership,
+	after *core.Membership,
+) error {
+	membership := after
+	if membership == nil {
+		membership = before
+	}
+	return recordAudit(ctx, tx, core.AuditRecord{
+		Action:     action,
+		EntityType: core.AuditMembership,
+		EntityID:   core.MembershipAuditID(membership.User.ID, membership.OrganisationID),
+		Before:     membershipAuditState(before),
+		After:      membershipAuditState(after),
+	})
+}
+
+// membershipAuditState returns the recorded fields of a membership, which leaves out the user's own data.
+func membershipAuditState(membership *core.Membership) any {
+	if membership == nil {
+		return nil
+	}
+	return map[string]any{
+		"UserID":         membership.User.ID,
+		"OrganisationID": membership.OrganisationID,
+		"Status":         membership.Status,
+		"JoinedAt":       membership.JoinedAt,
+		"InvitedBy":      membership.InvitedBy,
+		"Role":           membership.Role,
+	}
+}
+
+// GetBillingDetails implements core.OrganisationService.GetBillingDetails
+func (o *OrganisationService) GetBillingDetails(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+) (*core.BillingDetails, error) {
+	return getBillingDetails(ctx, o.q, OrgID)
+}
+
+func getBillingDetails(
+	ctx context.Context,
+	queries *sqlc.Queries,
+	OrgID core.OrganisationID,
+) (*core.BillingDetails, error) {
+	row, err := queries.GetOrganisationBillingDetails(ctx, int32(OrgID))
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	details := &core.BillingDetails{
+		OrganisationID: OrgID,
+		LegalName:      row.LegalName,
+		Addresses:      make(map[core.AddressType]core.Address),
+	}
+	if row.VatNumber != nil {
+		details.VatNumber, err = core.NewVatNumber(*row.VatNumber)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"could not parse stored VAT number of organisation %v: %w",
+				OrgID,
+				err,
+			)
+		}
+	}
+	addresses, err := queries.ListOrganisationAddresses(ctx, int32(OrgID))
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	for _, row := range addresses {
+		addressType, err := core.ParseAddressType(row.Type)
+		if err != nil {
+			return nil, err
+		}
+		address, err := convertAddress(row.Address)
+		if err != nil {
+			return nil, err
+		}
+		details.Addresses[addressType] = *address
+	}
+	return details, nil
+}
+
+// UpdateBillingDetails implements core.OrganisationService.UpdateBillingDetails
+func (o *OrganisationService) UpdateBillingDetails(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+	update core.BillingDetailsUpdate,
+) (*core.BillingDetails, error) {
+	// Validate everything up front so an invalid update never starts a transaction
+	var vatNumber *string
+	if update.VatNumber != nil && len(*update.VatNumber) > 0 {
+		vat, err := core.NewVatNumber(*update.VatNumber)
+		if err != nil {
+			return nil, errors.Join(core.ErrInvalidVatNumber, err)
+		}
+		canonical := vat.String()
+		vatNumber = &canonical
+	}
+	for addressType := range update.Addresses {
+		if _, err := core.ParseAddressType(string(addressType)); err != nil {
+			return nil, err
+		}
+	}
+
+	var details *core.BillingDetails
+	err := runInTx(ctx, o.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		if _, err := queries.LockOrganisation(ctx, int32(OrgID)); err != nil {
+			return ConvertPgError(err)
+		}
+		before, err := getBillingDetails(ctx, queries, OrgID)
+		if err != nil {
+			return err
+		}
+		current, err := queries.GetOrganisationBillingDetails(ctx, int32(OrgID))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		params := sqlc.UpdateOrganisationBillingDetailsParams{
+			ID:        int32(OrgID),
+			LegalName: current.LegalName,
+			VatNumber: current.VatNumber,
+		}
+		if update.LegalName != nil {
+			params.LegalName = nil
+			if len(*update.LegalName) > 0 {
+				params.LegalName = update.LegalName
+			}
+		}
+		if update.VatNumber != nil {
+			params.VatNumber = vatNumber
+		}
+		if err := queries.UpdateOrganisationBillingDetails(ctx, params); err != nil {
+			return ConvertPgError(err)
+		}
+
+		for _, addressType := range update.RemoveAddresses {
+			if err := removeOrganisationAddress(ctx, queries, OrgID, addressType); err != nil {
+				return err
+			}
+		}
+		for addressType, address := range update.Addresses {
+			if err := removeOrganisationAddress(ctx, queries, OrgID, addressType); err != nil {
+				return err
+			}
+			created, err := o.addresses.CreateAddressTx(ctx, tx, address)
+			if err != nil {
+				return fmt.Errorf("could not create %v address: %w", addressType, err)
+			}
+			err = queries.AddOrganisationAddress(ctx, sqlc.AddOrganisationAddressParams{
+				OrganisationID: int32(OrgID),
+				AddressID:      int32(created.ID),
+				Type:           string(addressType),
+			})
+			if err != nil {
+				return fmt.Errorf("could not link %v address: %w", addressType, ConvertPgError(err))
+			}
+		}
+		details, err = getBillingDetails(ctx, queries, OrgID)
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, core.AuditRecord{
+			Action:     core.AuditUpdate,
+			EntityType: core.AuditOrganisation,
+			EntityID:   OrgID.String(),
+			Before:     billingAuditState(before),
+			After:      billingAuditState(details),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return details, nil
+}
+
+// billingAuditState returns the recorded fields of billing details, VAT numbers are recorded in their canonical form.
+func billingAuditState(details *core.BillingDetails) map[string]any {
+	var vatNumber *string
+	if details.VatNumber != nil {
+		canonical := details.VatNumber.String()
+		vatNumber = &canonical
+	}
+	return map[string]any{
+		"LegalName": details.LegalName,
+		"VatNumber": vatNumber,
+		"Addresses": details.Addresses,
+	}
+}
+
+// removeOrganisationAddress unlinks and deletes the organisation's address of the specified type, if it has one.
+func removeOrganisationAddress(
+	ctx context.Context,
+	queries *sqlc.Queries,
+	OrgID core.OrganisationID,
+	addressType core.AddressType,
+) error {
+	addressID, err := queries.RemoveOrganisationAddress(ctx, int32(OrgID), string(addressType))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("could not unlink %v address: %w", addressType, ConvertPgError(err))
+	}
+	if err := queries.DeleteAddress(ctx, addressID); err != nil {
+		return fmt.Errorf("could not delete %v address: %w", addressType, ConvertPgError(err))
+	}
+	return nil
 }
 
 func (o *OrganisationService) GetMemberByEmail(
@@ -189,7 +1014,63 @@ func (o *OrganisationService) RemoveUser(
 	UserID core.UserID,
 	OrgID core.OrganisationID,
 ) error {
-	return o.q.RemoveUserFromOrganisation(ctx, int32(UserID), int32(OrgID))
+	return runInTx(ctx, o.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		before, err := getMembership(ctx, queries, UserID, OrgID)
+		if err != nil && !errors.Is(err, core.ErrNotFound) {
+			return err
+		}
+		if err := queries.RemoveUserFromOrganisation(ctx, int32(UserID), int32(OrgID)); err != nil {
+			return ConvertPgError(err)
+		}
+		if before == nil {
+			return nil
+		}
+		return recordMembershipChange(ctx, tx, core.AuditDelete, before, nil)
+	})
+}
+
+func convertMembership(
+	user sqlc.User,
+	orgID int32,
+	joinedAt pgtype.Timestamptz,
+	invitedBy *int32,
+	role *string,
+	status string,
+) (*core.Membership, error) {
+	member, err := convertUser(user)
+	if err != nil {
+		return nil, err
+	}
+	membershipStatus, err := core.ParseMembershipStatus(status)
+	if err != nil {
+		return nil, err
+	}
+	membership := &core.Membership{
+		User:           *member,
+		OrganisationID: core.OrganisationID(orgID),
+		Status:         membershipStatus,
+		JoinedAt:       joinedAt.Time,
+		Role:           role,
+	}
+	if invitedBy != nil {
+		id := core.UserID(*invitedBy)
+		membership.InvitedBy = &id
+	}
+	return membership, nil
+}
+
+func convertOrganisationLevel(
+	id int32,
+	name string,
+	parentID *int32,
+	depth int32,
+) (core.OrganisationLevel, error) {
+	org, err := core.ParseOrganisation(id, name, parentID)
+	if err != nil {
+		return core.OrganisationLevel{}, err
+	}
+	return core.OrganisationLevel{Organisation: *org, Depth: int(depth)}, nil
 }
 
 func convertOrganisationList(organisations []sqlc.Organisation) ([]core.Organisation, error) {
@@ -3,6 +3,7 @@ package postgres_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/prior-it/apollo/core"
 	"github.com/prior-it/apollo/postgres"
@@ -14,6 +15,7 @@ func TestOrganisationService(t *testing.T) {
 	db := tests.DB(t)
 	service := postgres.NewOrganisationService(db)
 	UserService := postgres.NewUserService(db)
+	addressService := postgres.NewAddressService(db)
 	defer tests.DeleteAllOrganisations(service)
 	ctx := context.Background()
 
@@ -137,14 +139,154 @@ func TestOrganisationService(t *testing.T) {
 		assert.Contains(t, childIDs, org1B.ID)
 	})
 
+	t.Run("ok: list ancestors and descendants", func(t *testing.T) {
+		root, err := service.CreateOrganisation(ctx, "root", nil)
+		assert.Nil(t, err)
+		child, err := service.CreateOrganisation(ctx, "child", &root.ID)
+		assert.Nil(t, err)
+		grandchild, err := service.CreateOrganisation(ctx, "grandchild", &child.ID)
+		assert.Nil(t, err)
+
+		ancestors, err := service.ListAncestors(ctx, grandchild.ID)
+		assert.Nil(t, err)
+		assert.Len(t, ancestors, 2)
+		assert.Equal(t, child.ID, ancestors[0].ID)
+		assert.Equal(t, 1, ancestors[0].Depth)
+		assert.Equal(t, root.ID, ancestors[1].ID)
+		assert.Equal(t, 2, ancestors[1].Depth)
+
+		descendants, err := service.ListDescendants(ctx, root.ID)
+		assert.Nil(t, err)
+		assert.Len(t, descendants, 2)
+		assert.Equal(t, child.ID, descendants[0].ID)
+		assert.Equal(t, grandchild.ID, descendants[1].ID)
+		assert.Equal(t, 2, descendants[1].Depth)
+
+		tree, err := service.GetOrganisationTree(ctx, root.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, tree.Size())
+		assert.Equal(t, grandchild.ID, tree.Children[0].Children[0].Organisation.ID)
+	})
+
+	t.Run("ok: move organisation", func(t *testing.T) {
+		org1, err := service.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		org2, err := service.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		child, err := service.CreateOrganisation(ctx, tests.Faker.BS(), &org1.ID)
+		assert.Nil(t, err)
+
+		moved, err := service.MoveOrganisation(ctx, child.ID, &org2.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, org2.ID, *moved.ParentID)
+
+		moved, err = service.MoveOrganisation(ctx, child.ID, nil)
+		assert.Nil(t, err)
+		assert.Nil(t, moved.ParentID, "Moving to nil should create a root organisation")
+	})
+
+	t.Run("err: move organisation below itself", func(t *testing.T) {
+		parent, err := service.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		child, err := service.CreateOrganisation(ctx, tests.Faker.BS(), &parent.ID)
+		assert.Nil(t, err)
+		grandchild, err := service.CreateOrganisation(ctx, tests.Faker.BS(), &child.ID)
+		assert.Nil(t, err)
+
+		_, err = service.MoveOrganisation(ctx, parent.ID, &parent.ID)
+		assert.ErrorIs(t, err, core.ErrOrganisationCycle)
+		_, err = service.MoveOrganisation(ctx, parent.ID, &grandchild.ID)
+		assert.ErrorIs(t, err, core.ErrOrganisationCycle)
+		assert.ErrorIs(t, err, core.ErrConflict)
+
+		missing := core.OrganisationID(999999)
+		_, err = service.MoveOrganisation(ctx, parent.ID, &missing)
+		assert.ErrorIs(t, err, core.ErrNotFound)
+	})
+
+	t.Run("err: delete organisation with large subtree", func(t *testing.T) {
+		service := postgres.NewOrganisationService(db)
+		service.SetDeleteCascadeLimit(1)
+		parent, err := service.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		for range 2 {
+			_, err = service.CreateOrganisation(ctx, tests.Faker.BS(), &parent.ID)
+			assert.Nil(t, err)
+		}
+
+		err = service.DeleteOrganisation(ctx, parent.ID)
+		assert.ErrorIs(t, err, core.ErrOrganisationHasDescendants)
+		descendants, err := service.ListDescendants(ctx, parent.ID)
+		assert.Nil(t, err)
+		assert.Len(t, descendants, 2, "A refused delete should not remove any descendants")
+
+		assert.Nil(t, service.DeleteOrganisationTree(ctx, parent.ID))
+		_, err = service.GetOrganisation(ctx, parent.ID)
+		assert.ErrorIs(t, err, core.ErrNotFound)
+	})
+
+	t.Run("ok: restore deleted organisation", func(t *testing.T) {
+		parent, err := service.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		tests.Check(err)
+		child, err := service.CreateOrganisation(ctx, tests.Faker.BS(), &parent.ID)
+		tests.Check(err)
+		deletedEarlier, err := service.CreateOrganisation(ctx, tests.Faker.BS(), &parent.ID)
+		tests.Check(err)
+		tests.Check(service.DeleteOrganisation(ctx, deletedEarlier.ID))
+		tests.Check(service.DeleteOrganisation(ctx, parent.ID))
+
+		_, err = service.GetOrganisation(ctx, child.ID)
+		assert.ErrorIs(t, err, core.ErrNotFound, "Descendants should be deleted with the parent")
+		deleted, err := service.ListOrganisations(ctx, core.ListQuery{
+			Filters: map[string]string{"deleted": "true", "parent": parent.ID.String()},
+		})
+		assert.Nil(t, err)
+		assert.Len(t, deleted.Items, 2, "The deleted filter should list deleted organisations")
+
+		err = service.RestoreOrganisation(ctx, child.ID)
+		assert.ErrorIs(t, err, core.ErrConflict, "Children of a deleted parent cannot be restored")
+
+		assert.Nil(t, service.RestoreOrganisation(ctx, parent.ID))
+		children, err := service.ListOrganisationChildren(ctx, parent.ID)
+		assert.Nil(t, err)
+		if assert.Len(t, children, 1, "Only descendants deleted with the parent are restored") {
+			assert.Equal(t, child.ID, children[0].ID)
+		}
+
+		err = service.RestoreOrganisation(ctx, parent.ID)
+		assert.ErrorIs(t, err, core.ErrNotFound, "Active organisations cannot be restored")
+	})
+
+	t.Run("ok: purge deleted organisations", func(t *testing.T) {
+		parent, err := service.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		tests.Check(err)
+		_, err = service.CreateOrganisation(ctx, tests.Faker.BS(), &parent.ID)
+		tests.Check(err)
+		tests.Check(service.DeleteOrganisation(ctx, parent.ID))
+
+		purged, err := service.PurgeDeletedOrganisations(ctx, time.Now().Add(-time.Hour))
+		assert.Nil(t, err)
+		assert.Zero(t, purged, "Organisations within the retention period should be kept")
+
+		purged, err = service.PurgeDeletedOrganisations(ctx, time.Now().Add(time.Hour))
+		assert.Nil(t, err)
+		assert.GreaterOrEqual(t, purged, uint64(1))
+		assert.ErrorIs(t, service.RestoreOrganisation(ctx, parent.ID), core.ErrNotFound)
+		deleted, err := service.ListOrganisations(ctx, core.ListQuery{
+			Filters: map[string]string{"deleted": "true", "parent": parent.ID.String()},
+		})
+		assert.Nil(t, err)
+		assert.Empty(t, deleted.Items, "Descendants should be purged together with their parent")
+	})
+
 	t.Run("ok: add user to organisation and list", func(t *testing.T) {
 		organisation, err := service.CreateOrganisation(ctx, tests.Faker.BS(), nil)
 		assert.Nil(t, err)
 
 		// List without users
-		users, err := service.ListUsersInOrganisation(ctx, organisation.ID)
+		users, err := service.ListUsersInOrganisation(ctx, organisation.ID, core.ListQuery{})
 		assert.Nil(t, err, "Getting users in organisation should not error")
-		assert.Len(t, users, 0, "Users list should be empty")
+		assert.Len(t, users.Items, 0, "Users list should be empty")
 
 		// Add user to organisation
 		email, err := core.ParseEmailAddress("getuserok@example.com")
@@ -155,16 +297,175 @@ func TestOrganisationService(t *testing.T) {
 		assert.Nil(t, service.AddUser(ctx, user.ID, organisation.ID))
 
 		// List with user in organisation
-		users, err = service.ListUsersInOrganisation(ctx, organisation.ID)
+		users, err = service.ListUsersInOrganisation(ctx, organisation.ID, core.ListQuery{})
 		assert.Nil(t, err, "Getting users in organisation should not error")
-		assert.NotEmpty(t, users, "Users list should not be empty")
-		assert.Equal(t, users[0], *user)
+		assert.NotEmpty(t, users.Items, "Users list should not be empty")
+		assert.Equal(t, users.Items[0], *user)
 
 		// Remove user from organisation
 		assert.Nil(t, service.RemoveUser(ctx, user.ID, organisation.ID))
-		users, err = service.ListUsersInOrganisation(ctx, organisation.ID)
+		users, err = service.ListUsersInOrganisation(ctx, organisation.ID, core.ListQuery{})
 		assert.Nil(t, err, "Getting users in organisation should not error")
-		assert.Len(t, users, 0, "Users list should be empty")
+		assert.Len(t, users.Items, 0, "Users list should be empty")
+	})
+
+	t.Run("ok: membership metadata", func(t *testing.T) {
+		organisation, err := service.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		inviter := tests.CreateRegularUser(UserService)
+		user := tests.CreateRegularUser(UserService)
+		defer func() {
+			tests.Check(UserService.DeleteUser(ctx, inviter.ID))
+			tests.Check(UserService.DeleteUser(ctx, user.ID))
+		}()
+
+		role := "Treasurer"
+		options := core.MembershipOptions{
+			Status:    core.MembershipPending,
+			InvitedBy: &inviter.ID,
+			Role:      &role,
+		}
+		membership, err := service.AddMembership(ctx, user.ID, organisation.ID, options)
+		assert.Nil(t, err)
+		assert.Equal(t, core.MembershipPending, membership.Status)
+		assert.Equal(t, inviter.ID, *membership.InvitedBy)
+		assert.Equal(t, role, *membership.Role)
+		assert.Equal(t, user.ID, membership.User.ID)
+		assert.False(t, membership.JoinedAt.IsZero())
+
+		users, err := service.ListUsersInOrganisation(ctx, organisation.ID, core.ListQuery{})
+		assert.Nil(t, err)
+		assert.Empty(t, users.Items, "Pending members should not be listed as users")
+		_, err = service.GetMember(ctx, user.ID, organisation.ID)
+		assert.ErrorIs(t, err, core.ErrNotFound, "Pending members should not be members yet")
+
+		membership, err = service.UpdateMembershipStatus(
+			ctx,
+			user.ID,
+			organisation.ID,
+			core.MembershipActive,
+		)
+		assert.Nil(t, err)
+		assert.True(t, membership.IsActive())
+		users, err = service.ListUsersInOrganisation(ctx, organisation.ID, core.ListQuery{})
+		assert.Nil(t, err)
+		assert.Len(t, users.Items, 1)
+
+		membership, err = service.UpdateMembershipRole(ctx, user.ID, organisation.ID, nil)
+		assert.Nil(t, err)
+		assert.Nil(t, membership.Role)
+
+		_, err = service.UpdateMembershipStatus(ctx, user.ID, organisation.ID, core.MembershipLeft)
+		assert.Nil(t, err)
+		memberships, err := service.ListMemberships(ctx, organisation.ID)
+		assert.Nil(t, err)
+		assert.Len(t, memberships, 1, "Members that left should still have a membership")
+		assert.Equal(t, core.MembershipLeft, memberships[0].Status)
+		orgs, err := service.ListOrganisationsForUser(ctx, user.ID)
+		assert.Nil(t, err)
+		assert.Empty(t, orgs)
+		memberships, err = service.ListMembershipsForUser(ctx, user.ID)
+		assert.Nil(t, err)
+		assert.Len(t, memberships, 1)
+	})
+
+	t.Run("err: invalid membership", func(t *testing.T) {
+		organisation, err := service.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		user := tests.CreateRegularUser(UserService)
+		defer func() { tests.Check(UserService.DeleteUser(ctx, user.ID)) }()
+
+		_, err = service.AddMembership(ctx, user.ID, organisation.ID, core.MembershipOptions{
+			Status: "banned",
+		})
+		assert.ErrorIs(t, err, core.ErrInvalidMembershipStatus)
+		_, err = service.UpdateMembershipStatus(ctx, user.ID, organisation.ID, core.MembershipLeft)
+		assert.ErrorIs(t, err, core.ErrNotFound)
+	})
+
+	t.Run("ok: billing details", func(t *testing.T) {
+		organisation, err := service.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+
+		details, err := service.GetBillingDetails(ctx, organisation.ID)
+		assert.Nil(t, err)
+		assert.Nil(t, details.LegalName)
+		assert.Nil(t, details.VatNumber)
+		assert.Empty(t, details.Addresses)
+
+		legalName := tests.Faker.Company()
+		vat := "be 0403.170.701"
+		billing := core.Address{
+			Street:     tests.Faker.Street(),
+			Number:     "1",
+			PostalCode: "9000",
+			City:       "Gent",
+			Country:    "BE",
+		}
+		details, err = service.UpdateBillingDetails(ctx, organisation.ID, core.BillingDetailsUpdate{
+			LegalName: &legalName,
+			VatNumber: &vat,
+			Addresses: map[core.AddressType]core.Address{core.AddressBilling: billing},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, legalName, *details.LegalName)
+		assert.Equal(t, "BE0403170701", details.VatNumber.String())
+		assert.Equal(t, billing.Street, details.Addresses[core.AddressBilling].Street)
+		oldAddressID := details.Addresses[core.AddressBilling].ID
+
+		// Replacing an address should delete the old one, other fields should not change
+		billing.City = "Brugge"
+		details, err = service.UpdateBillingDetails(ctx, organisation.ID, core.BillingDetailsUpdate{
+			Addresses: map[core.AddressType]core.Address{
+				core.AddressBilling:  billing,
+				core.AddressShipping: billing,
+			},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, legalName, *details.LegalName)
+		assert.Equal(t, "Brugge", details.Addresses[core.AddressBilling].City)
+		assert.Len(t, details.Addresses, 2)
+		_, err = addressService.GetAddress(ctx, oldAddressID)
+		assert.ErrorIs(t, err, core.ErrNotFound, "Replaced addresses should be deleted")
+
+		empty := ""
+		details, err = service.UpdateBillingDetails(ctx, organisation.ID, core.BillingDetailsUpdate{
+			VatNumber:       &empty,
+			RemoveAddresses: []core.AddressType{core.AddressShipping},
+		})
+		assert.Nil(t, err)
+		assert.Nil(t, details.VatNumber)
+		assert.Len(t, details.Addresses, 1)
+
+		billingAddressID := details.Addresses[core.AddressBilling].ID
+		assert.Nil(t, service.DeleteOrganisation(ctx, organisation.ID))
+		_, err = addressService.GetAddress(ctx, billingAddressID)
+		assert.ErrorIs(
+			t,
+			err,
+			core.ErrNotFound,
+			"Deleting an organisation should delete its addresses",
+		)
+	})
+
+	t.Run("err: invalid billing details", func(t *testing.T) {
+		organisation, err := service.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		legalName := tests.Faker.Company()
+		vat := "BE0403170702"
+		_, err = service.UpdateBillingDetails(ctx, organisation.ID, core.BillingDetailsUpdate{
+			LegalName: &legalName,
+			VatNumber: &vat,
+		})
+		assert.ErrorIs(t, err, core.ErrInvalidVatNumber)
+		details, err := service.GetBillingDetails(ctx, organisation.ID)
+		assert.Nil(t, err)
+		assert.Nil(t, details.LegalName, "A failed update should not change anything")
+
+		_, err = service.UpdateBillingDetails(ctx, organisation.ID, core.BillingDetailsUpdate{
+			Addresses: map[core.AddressType]core.Address{"home": {}},
+		})
+		assert.ErrorIs(t, err, core.ErrInvalidAddressType)
 	})
 
 	t.Run("ok: deleting user removes user from organisation", func(t *testing.T) {
@@ -181,9 +482,9 @@ func TestOrganisationService(t *testing.T) {
 
 		// Delete user
 		assert.Nil(t, UserService.DeleteUser(ctx, user.ID))
-		users, err := service.ListUsersInOrganisation(ctx, organisation.ID)
+		users, err := service.ListUsersInOrganisation(ctx, organisation.ID, core.ListQuery{})
 		assert.Nil(t, err, "Getting users in organisation should not error")
-		assert.Len(t, users, 0, "Users list should be empty")
+		assert.Len(t, users.Items, 0, "Users list should be empty")
 	})
 
 	t.Run("ok: add user to organisation and check email", func(t *testing.T) {
@@ -208,4 +509,40 @@ func TestOrganisationService(t *testing.T) {
 		assert.ErrorIs(t, err, core.ErrNotFound)
 		assert.Nil(t, user2)
 	})
+
+	t.Run("ok: search organisations", func(t *testing.T) {
+		word := tests.Faker.LetterN(10)
+		acme, err := service.CreateOrganisation(ctx, word+" Holdings", nil)
+		assert.Nil(t, err)
+		_, err = service.CreateOrganisation(ctx, tests.Faker.LetterN(10)+" "+word, nil)
+		assert.Nil(t, err)
+
+		results, err := service.SearchOrganisations(ctx, core.SearchQuery{Text: word})
+		assert.Nil(t, err)
+		assert.Len(t, results, 2)
+
+		results, err = service.SearchOrganisations(ctx, core.SearchQuery{Text: word[2:8]})
+		assert.Nil(t, err)
+		assert.Len(t, results, 2, "Fragments of a name should match")
+
+		results, err = service.SearchOrganisations(ctx, core.SearchQuery{Text: word + " holdings"})
+		assert.Nil(t, err)
+		if assert.NotEmpty(t, results) {
+			assert.Equal(t, acme.ID, results[0].Item.ID, "The best match should be ranked first")
+			assert.True(t, results[0].Highlights["name"].Matches())
+		}
+
+		user := tests.CreateRegularUser(UserService)
+		assert.Nil(t, service.AddUser(ctx, user.ID, acme.ID))
+		query := core.SearchQuery{Text: word, UserID: &user.ID}
+		results, err = service.SearchOrganisations(ctx, query)
+		assert.Nil(t, err)
+		if assert.Len(t, results, 1, "Only organisations of the user should be searched") {
+			assert.Equal(t, acme.ID, results[0].Item.ID)
+		}
+
+		results, err = service.SearchOrganisations(ctx, core.SearchQuery{Text: " "})
+		assert.Nil(t, err)
+		assert.Empty(t, results)
+	})
 }
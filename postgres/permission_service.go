@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/prior-it/apollo/core"
 	"github.com/prior-it/apollo/permissions"
 	"github.com/prior-it/apollo/postgres/internal/sqlc"
@@ -58,9 +61,19 @@ func (p *PermissionService) CreatePermissionGroup(
 	defer tx.Rollback(ctx) //nolint:errcheck // See tx.Rollback() documentation
 	q := sqlc.New(tx)
 
+	var orgID *int32
+	if Group.OrganisationID != nil {
+		intID := int32(*Group.OrganisationID)
+		orgID = &intID
+	}
+
 	var NewGroup sqlc.Permissiongroup
 	if Group.ID > 0 {
-		NewGroup, err = q.CreatePermissionGroupWithID(ctx, int32(Group.ID), &Group.Name)
+		NewGroup, err = q.CreatePermissionGroupWithID(ctx, sqlc.CreatePermissionGroupWithIDParams{
+			ID:             int32(Group.ID),
+			Name:           &Group.Name,
+			OrganisationID: orgID,
+		})
 		if err != nil {
 			return nil, fmt.Errorf(
 				"could not create a new permission group with id %v: %w",
@@ -78,7 +91,7 @@ func (p *PermissionService) CreatePermissionGroup(
 		}
 
 	} else {
-		NewGroup, err = q.CreatePermissionGroup(ctx, &Group.Name)
+		NewGroup, err = q.CreatePermissionGroup(ctx, &Group.Name, orgID)
 		if err != nil {
 			return nil, fmt.Errorf("could not create the new permission group: %w", ConvertPgError(err))
 		}
@@ -100,11 +113,14 @@ func (p *PermissionService) CreatePermissionGroup(
 		}
 	}
 
+	Group.ID = permissions.PermissionGroupID(NewGroup.ID)
+	if err := p.permissionGroupChanged(ctx, tx, core.AuditCreate, nil, Group); err != nil {
+		return nil, err
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("could not commit transaction: %w", err)
 	}
-
-	Group.ID = permissions.PermissionGroupID(NewGroup.ID)
 	return Group, nil
 }
 
@@ -128,6 +144,28 @@ func (p *PermissionService) ListPermissionGroups(
 	return list, nil
 }
 
+// ListPermissionGroupsForOrganisation implements permissions.Service.
+func (p *PermissionService) ListPermissionGroupsForOrganisation(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+) ([]permissions.PermissionGroup, error) {
+	intID := int32(OrgID)
+	groups, err := p.q.ListPermissionGroupsForOrganisation(ctx, &intID)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]permissions.PermissionGroup, 0)
+	for _, g := range groups {
+		perms, err := p.q.GetPermissionsForGroup(ctx, g.ID)
+		if err != nil {
+			return nil, err
+		}
+		group := combinePermissionGroup(g, perms)
+		list = append(list, group)
+	}
+	return list, nil
+}
+
 // ListPermissionGroupsForUser implements permissions.Service.
 func (p *PermissionService) ListPermissionGroupsForUser(
 	ctx context.Context,
@@ -266,13 +304,120 @@ func (p *PermissionService) HasAnyForOrgTree(
 	return false, nil
 }
 
+// Explain implements permissions.Service.
+func (p *PermissionService) Explain(
+	ctx context.Context,
+	UserID core.UserID,
+	permission permissions.Permission,
+	OrgID *core.OrganisationID,
+) (*permissions.Explanation, error) {
+	user, err := p.q.GetUser(ctx, int32(UserID))
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	explanation := &permissions.Explanation{
+		UserID:         UserID,
+		Permission:     permission,
+		OrganisationID: OrgID,
+	}
+	explanation.AddStep(permissions.ExplanationStep{
+		Source:  permissions.SourceAdmin,
+		Granted: user.Admin,
+	})
+
+	groups, err := p.ListPermissionGroupsForUser(ctx, UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		explanation.AddStep(permissions.ExplanationStep{
+			Source:  permissions.SourceGlobalGroup,
+			Group:   &group,
+			Granted: group.Get(permission),
+		})
+	}
+
+	// Walk up the organisation tree, the same way HasAnyForOrgTree does
+	for depth := 0; OrgID != nil; depth++ {
+		groups, err := p.ListPermissionGroupsForUserForOrganisation(ctx, UserID, *OrgID)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			explanation.AddStep(permissions.ExplanationStep{
+				Source:         permissions.SourceOrganisationGroup,
+				Group:          &group,
+				OrganisationID: OrgID,
+				Depth:          depth,
+				Granted:        group.Get(permission),
+			})
+		}
+		parentID, err := p.q.GetParentOrganisation(ctx, int32(*OrgID))
+		if err != nil {
+			return nil, fmt.Errorf("cannot get parent organisation id: %w", err)
+		}
+		OrgID = nil
+		if parentID != nil {
+			pID := core.OrganisationID(*parentID)
+			OrgID = &pID
+		}
+	}
+	return explanation, nil
+}
+
 // RenamePermissionGroup implements permissions.Service.
 func (p *PermissionService) RenamePermissionGroup(
 	ctx context.Context,
 	ID permissions.PermissionGroupID,
 	Name string,
 ) error {
-	return p.q.RenamePermissionGroup(ctx, int32(ID), &Name)
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		q := sqlc.New(tx)
+		before, err := getPermissionGroup(ctx, q, ID)
+		if err != nil {
+			return err
+		}
+		if err := q.RenamePermissionGroup(ctx, int32(ID), &Name); err != nil {
+			return ConvertPgError(err)
+		}
+		after := *before
+		after.Name = Name
+		return p.permissionGroupChanged(ctx, tx, core.AuditUpdate, before, &after)
+	})
+}
+
+// RenamePermissionGroupForOrganisation implements permissions.Service.
+func (p *PermissionService) RenamePermissionGroupForOrganisation(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+	ID permissions.PermissionGroupID,
+	Name string,
+) error {
+	intOrgID := int32(OrgID)
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		q := sqlc.New(tx)
+		before, err := getPermissionGroup(ctx, q, ID)
+		if err != nil {
+			return err
+		}
+		rows, err := q.RenamePermissionGroupForOrganisation(
+			ctx,
+			sqlc.RenamePermissionGroupForOrganisationParams{
+				ID:             int32(ID),
+				OrganisationID: &intOrgID,
+				Name:           &Name,
+			},
+		)
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		if rows == 0 {
+			return core.ErrNotFound
+		}
+		after := *before
+		after.Name = Name
+		return p.permissionGroupChanged(ctx, tx, core.AuditUpdate, before, &after)
+	})
 }
 
 // UpdatePermissionGroup implements permissions.Service.
@@ -285,7 +430,53 @@ func (p *PermissionService) UpdatePermissionGroup(
 		return fmt.Errorf("could not create transaction: %w", err)
 	}
 	defer tx.Rollback(ctx) //nolint:errcheck // See tx.Rollback() documentation
+	if err := p.updatePermissionGroupPermissions(ctx, tx, Group); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UpdatePermissionGroupForOrganisation implements permissions.Service.
+func (p *PermissionService) UpdatePermissionGroupForOrganisation(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+	Group *permissions.PermissionGroup,
+) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // See tx.Rollback() documentation
+	// Keep the group from being deleted or changing owner while it is updated
+	owner, err := sqlc.New(tx).LockPermissionGroup(ctx, int32(Group.ID))
+	if err != nil {
+		return ConvertPgError(err)
+	}
+	if owner == nil || *owner != int32(OrgID) {
+		return core.ErrNotFound
+	}
+	if err := p.updatePermissionGroupPermissions(ctx, tx, Group); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (p *PermissionService) updatePermissionGroupPermissions(
+	ctx context.Context,
+	tx pgx.Tx,
+	Group *permissions.PermissionGroup,
+) error {
 	q := sqlc.New(tx)
+	before, err := getPermissionGroup(ctx, q, Group.ID)
+	if err != nil {
+		return err
+	}
 	for permission, enabled := range Group.Permissions {
 		err := q.UpdatePermissionGroupPermission(ctx, sqlc.UpdatePermissionGroupPermissionParams{
 			GroupID:    int32(Group.ID),
@@ -301,10 +492,11 @@ func (p *PermissionService) UpdatePermissionGroup(
 			)
 		}
 	}
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("could not commit transaction: %w", err)
+	after, err := getPermissionGroup(ctx, q, Group.ID)
+	if err != nil {
+		return err
 	}
-	return nil
+	return p.permissionGroupChanged(ctx, tx, core.AuditUpdate, before, after)
 }
 
 // DeletePermissionGroup implements permissions.Service.
@@ -312,7 +504,43 @@ func (p *PermissionService) DeletePermissionGroup(
 	ctx context.Context,
 	GroupID permissions.PermissionGroupID,
 ) error {
-	return p.q.DeletePermissionGroup(ctx, int32(GroupID))
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		q := sqlc.New(tx)
+		before, err := getPermissionGroup(ctx, q, GroupID)
+		if errors.Is(err, core.ErrNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := q.DeletePermissionGroup(ctx, int32(GroupID)); err != nil {
+			return ConvertPgError(err)
+		}
+		return p.permissionGroupChanged(ctx, tx, core.AuditDelete, before, nil)
+	})
+}
+
+// DeletePermissionGroupForOrganisation implements permissions.Service.
+func (p *PermissionService) DeletePermissionGroupForOrganisation(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+	GroupID permissions.PermissionGroupID,
+) error {
+	intOrgID := int32(OrgID)
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		q := sqlc.New(tx)
+		before, err := getPermissionGroup(ctx, q, GroupID)
+		if err != nil {
+			return err
+		}
+		rows, err := q.DeletePermissionGroupForOrganisation(ctx, int32(GroupID), &intOrgID)
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		if rows == 0 {
+			return core.ErrNotFound
+		}
+		return p.permissionGroupChanged(ctx, tx, core.AuditDelete, before, nil)
+	})
 }
 
 // AddUserToPermissionGroup implements permissions.Service.
@@ -321,7 +549,16 @@ func (p *PermissionService) AddUserToPermissionGroup(
 	UserID core.UserID,
 	GroupID permissions.PermissionGroupID,
 ) error {
-	return p.q.AddUserToPermissionGroup(ctx, int32(GroupID), int32(UserID))
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		q := sqlc.New(tx)
+		if err := checkPermissionGroupOwner(ctx, q, GroupID, nil); err != nil {
+			return err
+		}
+		if err := q.AddUserToPermissionGroup(ctx, int32(GroupID), int32(UserID)); err != nil {
+			return ConvertPgError(err)
+		}
+		return recordGroupMemberChange(ctx, tx, core.AuditCreate, GroupID, UserID, nil, nil)
+	})
 }
 
 // AddUserToPermissionGroup implements permissions.Service.
@@ -331,12 +568,387 @@ func (p *PermissionService) AddUserToPermissionGroupForOrganisation(
 	OrgID core.OrganisationID,
 	GroupID permissions.PermissionGroupID,
 ) error {
-	params := sqlc.AddUserToPermissionGroupForOrganisationParams{
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		q := sqlc.New(tx)
+		if err := checkPermissionGroupOwner(ctx, q, GroupID, &OrgID); err != nil {
+			return err
+		}
+		params := sqlc.AddUserToPermissionGroupForOrganisationParams{
+			PermissionGroupID: int32(GroupID),
+			UserID:            int32(UserID),
+			OrganisationID:    int32(OrgID),
+		}
+		if err := q.AddUserToPermissionGroupForOrganisation(ctx, params); err != nil {
+			return ConvertPgError(err)
+		}
+		return recordGroupMemberChange(ctx, tx, core.AuditCreate, GroupID, UserID, &OrgID, nil)
+	})
+}
+
+// AddUserToPermissionGroupWithValidity implements permissions.Service.
+func (p *PermissionService) AddUserToPermissionGroupWithValidity(
+	ctx context.Context,
+	UserID core.UserID,
+	GroupID permissions.PermissionGroupID,
+	Validity permissions.Validity,
+) error {
+	if err := Validity.Validate(); err != nil {
+		return err
+	}
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		q := sqlc.New(tx)
+		if err := checkPermissionGroupOwner(ctx, q, GroupID, nil); err != nil {
+			return err
+		}
+		err := q.AddUserToPermissionGroupWithValidity(
+			ctx,
+			sqlc.AddUserToPermissionGroupWithValidityParams{
+				GroupID:    int32(GroupID),
+				UserID:     int32(UserID),
+				ValidFrom:  toTimestamptz(Validity.From),
+				ValidUntil: toTimestamptz(Validity.Until),
+			},
+		)
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		return recordGroupMemberChange(ctx, tx, core.AuditCreate, GroupID, UserID, nil, &Validity)
+	})
+}
+
+// AddUserToPermissionGroupForOrganisationWithValidity implements permissions.Service.
+func (p *PermissionService) AddUserToPermissionGroupForOrganisationWithValidity(
+	ctx context.Context,
+	UserID core.UserID,
+	OrgID core.OrganisationID,
+	GroupID permissions.PermissionGroupID,
+	Validity permissions.Validity,
+) error {
+	if err := Validity.Validate(); err != nil {
+		return err
+	}
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		q := sqlc.New(tx)
+		if err := checkPermissionGroupOwner(ctx, q, GroupID, &OrgID); err != nil {
+			return err
+		}
+		err := q.AddUserToPermissionGroupForOrganisationWithValidity(
+			ctx,
+			sqlc.AddUserToPermissionGroupForOrganisationWithValidityParams{
+				PermissionGroupID: int32(GroupID),
+				UserID:            int32(UserID),
+				OrganisationID:    int32(OrgID),
+				ValidFrom:         toTimestamptz(Validity.From),
+				ValidUntil:        toTimestamptz(Validity.Until),
+			},
+		)
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		return recordGroupMemberChange(
+			ctx, tx, core.AuditCreate, GroupID, UserID, &OrgID, &Validity,
+		)
+	})
+}
+
+// DeleteExpiredMemberships implements permissions.Service.
+// This is not recorded in the audit log: the validity of the memberships was recorded when they were added.
+func (p *PermissionService) DeleteExpiredMemberships(ctx context.Context) (int64, error) {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("could not create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // See tx.Rollback() documentation
+	q := sqlc.New(tx)
+
+	global, err := q.DeleteExpiredPermissionGroupMemberships(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("could not delete expired memberships: %w", err)
+	}
+	organisation, err := q.DeleteExpiredOrganisationPermissionGroupMemberships(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("could not delete expired organisation memberships: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return global + organisation, nil
+}
+
+// RemoveUserFromPermissionGroup implements permissions.Service.
+func (p *PermissionService) RemoveUserFromPermissionGroup(
+	ctx context.Context,
+	UserID core.UserID,
+	GroupID permissions.PermissionGroupID,
+) error {
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		err := sqlc.New(tx).RemoveUserFromPermissionGroup(ctx, int32(GroupID), int32(UserID))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		return recordGroupMemberChange(ctx, tx, core.AuditDelete, GroupID, UserID, nil, nil)
+	})
+}
+
+// RemoveUserFromPermissionGroupForOrganisation implements permissions.Service.
+func (p *PermissionService) RemoveUserFromPermissionGroupForOrganisation(
+	ctx context.Context,
+	UserID core.UserID,
+	OrgID core.OrganisationID,
+	GroupID permissions.PermissionGroupID,
+) error {
+	params := sqlc.RemoveUserFromPermissionGroupForOrganisationParams{
 		PermissionGroupID: int32(GroupID),
 		UserID:            int32(UserID),
 		OrganisationID:    int32(OrgID),
 	}
-	return p.q.AddUserToPermissionGroupForOrganisation(ctx, params)
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		err := sqlc.New(tx).RemoveUserFromPermissionGroupForOrganisation(ctx, params)
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		return recordGroupMemberChange(ctx, tx, core.AuditDelete, GroupID, UserID, &OrgID, nil)
+	})
+}
+
+// SetUserPermissionGroups implements permissions.Service.
+func (p *PermissionService) SetUserPermissionGroups(
+	ctx context.Context,
+	UserID core.UserID,
+	GroupIDs []permissions.PermissionGroupID,
+) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // See tx.Rollback() documentation
+	q := sqlc.New(tx)
+
+	current, err := q.ListPermissionGroupsForUser(ctx, int32(UserID))
+	if err != nil {
+		return fmt.Errorf("could not list the existing permission groups: %w", err)
+	}
+	if err := q.RemoveUserFromAllPermissionGroups(ctx, int32(UserID)); err != nil {
+		return fmt.Errorf("could not remove the existing permission groups: %w", err)
+	}
+	for _, GroupID := range GroupIDs {
+		if err := checkPermissionGroupOwner(ctx, q, GroupID, nil); err != nil {
+			return err
+		}
+		if err := q.AddUserToPermissionGroup(ctx, int32(GroupID), int32(UserID)); err != nil {
+			return fmt.Errorf(
+				"could not add user to permission group (id %v): %w",
+				GroupID,
+				ConvertPgError(err),
+			)
+		}
+	}
+	if err := recordGroupMemberships(ctx, tx, UserID, nil, current, GroupIDs); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+// SetUserPermissionGroupsForOrganisation implements permissions.Service.
+func (p *PermissionService) SetUserPermissionGroupsForOrganisation(
+	ctx context.Context,
+	UserID core.UserID,
+	OrgID core.OrganisationID,
+	GroupIDs []permissions.PermissionGroupID,
+) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // See tx.Rollback() documentation
+	q := sqlc.New(tx)
+
+	current, err := q.ListPermissionGroupsForUserForOrganisation(ctx, int32(UserID), int32(OrgID))
+	if err != nil {
+		return fmt.Errorf("could not list the existing permission groups: %w", err)
+	}
+	err = q.RemoveUserFromAllPermissionGroupsForOrganisation(ctx, int32(UserID), int32(OrgID))
+	if err != nil {
+		return fmt.Errorf("could not remove the existing permission groups: %w", err)
+	}
+	for _, GroupID := range GroupIDs {
+		if err := checkPermissionGroupOwner(ctx, q, GroupID, &OrgID); err != nil {
+			return err
+		}
+		err := q.AddUserToPermissionGroupForOrganisation(
+			ctx,
+			sqlc.AddUserToPermissionGroupForOrganisationParams{
+				PermissionGroupID: int32(GroupID),
+				UserID:            int32(UserID),
+				OrganisationID:    int32(OrgID),
+			},
+		)
+		if err != nil {
+			return fmt.Errorf(
+				"could not add user to permission group (id %v) in organisation %v: %w",
+				GroupID,
+				OrgID,
+				ConvertPgError(err),
+			)
+		}
+	}
+	if err := recordGroupMemberships(ctx, tx, UserID, &OrgID, current, GroupIDs); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListUsersInPermissionGroup implements permissions.Service.
+func (p *PermissionService) ListUsersInPermissionGroup(
+	ctx context.Context,
+	GroupID permissions.PermissionGroupID,
+) ([]permissions.PermissionGroupMember, error) {
+	users, err := p.q.ListUsersInPermissionGroup(ctx, int32(GroupID))
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	orgUsers, err := p.q.ListOrganisationUsersInPermissionGroup(ctx, int32(GroupID))
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+
+	members := make([]permissions.PermissionGroupMember, 0, len(users)+len(orgUsers))
+	for _, row := range users {
+		user, err := convertUser(row.User)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, permissions.PermissionGroupMember{
+			User:     *user,
+			Validity: convertValidity(row.ValidFrom, row.ValidUntil),
+		})
+	}
+	for _, row := range orgUsers {
+		user, err := convertUser(row.User)
+		if err != nil {
+			return nil, err
+		}
+		orgID := core.OrganisationID(row.OrganisationID)
+		members = append(members, permissions.PermissionGroupMember{
+			User:           *user,
+			OrganisationID: &orgID,
+			Validity:       convertValidity(row.ValidFrom, row.ValidUntil),
+		})
+	}
+	return members, nil
+}
+
+// SetDefaultPermissionGroupsForOrganisation implements permissions.Service.
+func (p *PermissionService) SetDefaultPermissionGroupsForOrganisation(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+	GroupIDs []permissions.PermissionGroupID,
+) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // See tx.Rollback() documentation
+	q := sqlc.New(tx)
+
+	current, err := q.ListDefaultPermissionGroupsForOrganisation(ctx, int32(OrgID))
+	if err != nil {
+		return fmt.Errorf("could not list the existing default permission groups: %w", err)
+	}
+	if err := q.RemoveAllDefaultPermissionGroupsForOrganisation(ctx, int32(OrgID)); err != nil {
+		return fmt.Errorf("could not remove the existing default permission groups: %w", err)
+	}
+	for _, GroupID := range GroupIDs {
+		if err := checkPermissionGroupOwner(ctx, q, GroupID, &OrgID); err != nil {
+			return err
+		}
+		err := q.AddDefaultPermissionGroupForOrganisation(ctx, int32(OrgID), int32(GroupID))
+		if err != nil {
+			return fmt.Errorf(
+				"could not add default permission group (id %v) to organisation %v: %w",
+				GroupID,
+				OrgID,
+				ConvertPgError(err),
+			)
+		}
+	}
+	currentIDs := make([]permissions.PermissionGroupID, len(current))
+	for i, group := range current {
+		currentIDs[i] = permissions.PermissionGroupID(group.ID)
+	}
+	err = recordAudit(ctx, tx, core.AuditRecord{
+		Action:     core.AuditUpdate,
+		EntityType: core.AuditOrganisation,
+		EntityID:   OrgID.String(),
+		Before:     map[string]any{"DefaultPermissionGroups": sortedGroupIDs(currentIDs)},
+		After:      map[string]any{"DefaultPermissionGroups": sortedGroupIDs(GroupIDs)},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListDefaultPermissionGroupsForOrganisation implements permissions.Service.
+func (p *PermissionService) ListDefaultPermissionGroupsForOrganisation(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+) ([]permissions.PermissionGroup, error) {
+	groups, err := p.q.ListDefaultPermissionGroupsForOrganisation(ctx, int32(OrgID))
+	if err != nil {
+		return nil, err
+	}
+	list := make([]permissions.PermissionGroup, 0)
+	for _, g := range groups {
+		perms, err := p.q.GetPermissionsForGroup(ctx, g.ID)
+		if err != nil {
+			return nil, err
+		}
+		group := combinePermissionGroup(g, perms)
+		list = append(list, group)
+	}
+	return list, nil
+}
+
+// checkPermissionGroupOwner returns core.ErrForbidden if the specified group cannot be assigned in the specified
+// organisation: only global groups and groups owned by that organisation can be assigned.
+// If orgID is nil, only global groups are allowed.
+// The group stays locked until the transaction of q ends, so its owner cannot change before the group is assigned.
+func checkPermissionGroupOwner(
+	ctx context.Context,
+	q *sqlc.Queries,
+	GroupID permissions.PermissionGroupID,
+	OrgID *core.OrganisationID,
+) error {
+	owner, err := q.LockPermissionGroup(ctx, int32(GroupID))
+	if err != nil {
+		return ConvertPgError(err)
+	}
+	if owner == nil {
+		return nil
+	}
+	if OrgID == nil || *owner != int32(*OrgID) {
+		return fmt.Errorf(
+			"permission group %v is owned by organisation %v: %w",
+			GroupID,
+			*owner,
+			core.ErrForbidden,
+		)
+	}
+	return nil
 }
 
 // GetUserPermissions implements permissions.Service.
@@ -378,6 +990,299 @@ func (p *PermissionService) GetUserPermissionsForOrganisation(
 	return combined, nil
 }
 
+// GrantUserPermissionOn implements permissions.Service.
+func (p *PermissionService) GrantUserPermissionOn(
+	ctx context.Context,
+	UserID core.UserID,
+	permission permissions.Permission,
+	resource permissions.Resource,
+) error {
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		err := sqlc.New(tx).GrantUserObjectPermission(ctx, sqlc.GrantUserObjectPermissionParams{
+			UserID:       int32(UserID),
+			Permission:   permission.String(),
+			ResourceType: resource.Type.String(),
+			ResourceID:   int32(resource.ID),
+		})
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		grant := map[string]any{"UserID": UserID, "Permission": permission}
+		return recordObjectPermissionChange(ctx, tx, core.AuditCreate, resource, grant)
+	})
+}
+
+// RevokeUserPermissionOn implements permissions.Service.
+func (p *PermissionService) RevokeUserPermissionOn(
+	ctx context.Context,
+	UserID core.UserID,
+	permission permissions.Permission,
+	resource permissions.Resource,
+) error {
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		err := sqlc.New(tx).RevokeUserObjectPermission(ctx, sqlc.RevokeUserObjectPermissionParams{
+			UserID:       int32(UserID),
+			Permission:   permission.String(),
+			ResourceType: resource.Type.String(),
+			ResourceID:   int32(resource.ID),
+		})
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		grant := map[string]any{"UserID": UserID, "Permission": permission}
+		return recordObjectPermissionChange(ctx, tx, core.AuditDelete, resource, grant)
+	})
+}
+
+// GrantGroupPermissionOn implements permissions.Service.
+func (p *PermissionService) GrantGroupPermissionOn(
+	ctx context.Context,
+	GroupID permissions.PermissionGroupID,
+	permission permissions.Permission,
+	resource permissions.Resource,
+) error {
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		err := sqlc.New(tx).GrantPermissionGroupObjectPermission(
+			ctx,
+			sqlc.GrantPermissionGroupObjectPermissionParams{
+				GroupID:      int32(GroupID),
+				Permission:   permission.String(),
+				ResourceType: resource.Type.String(),
+				ResourceID:   int32(resource.ID),
+			},
+		)
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		grant := map[string]any{"GroupID": GroupID, "Permission": permission}
+		return recordObjectPermissionChange(ctx, tx, core.AuditCreate, resource, grant)
+	})
+}
+
+// RevokeGroupPermissionOn implements permissions.Service.
+func (p *PermissionService) RevokeGroupPermissionOn(
+	ctx context.Context,
+	GroupID permissions.PermissionGroupID,
+	permission permissions.Permission,
+	resource permissions.Resource,
+) error {
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		err := sqlc.New(tx).RevokePermissionGroupObjectPermission(
+			ctx,
+			sqlc.RevokePermissionGroupObjectPermissionParams{
+				GroupID:      int32(GroupID),
+				Permission:   permission.String(),
+				ResourceType: resource.Type.String(),
+				ResourceID:   int32(resource.ID),
+			},
+		)
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		grant := map[string]any{"GroupID": GroupID, "Permission": permission}
+		return recordObjectPermissionChange(ctx, tx, core.AuditDelete, resource, grant)
+	})
+}
+
+// HasAnyOn implements permissions.Service.
+func (p *PermissionService) HasAnyOn(
+	ctx context.Context,
+	UserID core.UserID,
+	permission permissions.Permission,
+	resource permissions.Resource,
+) (bool, error) {
+	ok, err := p.q.HasObjectPermission(ctx, sqlc.HasObjectPermissionParams{
+		UserID:       int32(UserID),
+		Permission:   permission.String(),
+		ResourceType: resource.Type.String(),
+		ResourceID:   int32(resource.ID),
+	})
+	if err != nil {
+		return false, ConvertPgError(err)
+	}
+	return ok, nil
+}
+
+// ListResourceIDsForUser implements permissions.Service.
+func (p *PermissionService) ListResourceIDsForUser(
+	ctx context.Context,
+	UserID core.UserID,
+	permission permissions.Permission,
+	resourceType permissions.ResourceType,
+) ([]core.ID, error) {
+	dbIDs, err := p.q.ListObjectResourceIDsForUser(ctx, sqlc.ListObjectResourceIDsForUserParams{
+		UserID:       int32(UserID),
+		Permission:   permission.String(),
+		ResourceType: resourceType.String(),
+	})
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	ids := make([]core.ID, len(dbIDs))
+	for i, id := range dbIDs {
+		ids[i] = core.ID(id)
+	}
+	return ids, nil
+}
+
+// getPermissionGroup retrieves a permission group together with its permissions.
+func getPermissionGroup(
+	ctx context.Context,
+	q *sqlc.Queries,
+	ID permissions.PermissionGroupID,
+) (*permissions.PermissionGroup, error) {
+	group, err := q.GetPermissionGroup(ctx, int32(ID))
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	perms, err := q.GetPermissionsForGroup(ctx, group.ID)
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	combinedGroup := combinePermissionGroup(group, perms)
+	return &combinedGroup, nil
+}
+
+// permissionGroupChanged records the changed fields of a permission group, either before or after may be nil, and
+// emits core.PermissionGroupChanged. Every permission is recorded as a separate field, so the audit log shows exactly
+// which permissions changed.
+func (p *PermissionService) permissionGroupChanged(
+	ctx context.Context,
+	tx pgx.Tx,
+	action core.AuditAction,
+	before *permissions.PermissionGroup,
+	after *permissions.PermissionGroup,
+) error {
+	group := after
+	if group == nil {
+		group = before
+	}
+	err := recordAudit(ctx, tx, core.AuditRecord{
+		Action:     action,
+		EntityType: core.AuditPermissionGroup,
+		EntityID:   group.ID.String(),
+		Before:     permissionGroupAuditState(before),
+		After:      permissionGroupAuditState(after),
+	})
+	if err != nil {
+		return err
+	}
+	return p.db.Emit(ctx, tx, core.PermissionGroupChanged{
+		GroupID:        group.ID,
+		OrganisationID: group.OrganisationID,
+		Deleted:        after == nil,
+	})
+}
+
+func permissionGroupAuditState(group *permissions.PermissionGroup) any {
+	if group == nil {
+		return nil
+	}
+	state := map[string]any{
+		"Name":           group.Name,
+		"OrganisationID": group.OrganisationID,
+	}
+	for permission, enabled := range group.Permissions {
+		state["Permissions."+permission.String()] = enabled
+	}
+	return state
+}
+
+// recordGroupMemberChange records that a user was added to or removed from a permission group, optionally within an
+// organisation. The validity is only known when the user is added with one.
+func recordGroupMemberChange(
+	ctx context.Context,
+	tx pgx.Tx,
+	action core.AuditAction,
+	GroupID permissions.PermissionGroupID,
+	UserID core.UserID,
+	OrgID *core.OrganisationID,
+	validity *permissions.Validity,
+) error {
+	entityID := fmt.Sprintf("%v:%v", GroupID, UserID)
+	if OrgID != nil {
+		entityID = fmt.Sprintf("%s:%v", entityID, *OrgID)
+	}
+	state := map[string]any{
+		"GroupID":        GroupID,
+		"UserID":         UserID,
+		"OrganisationID": OrgID,
+	}
+	if validity != nil {
+		state["ValidFrom"] = validity.From
+		state["ValidUntil"] = validity.Until
+	}
+	record := core.AuditRecord{
+		Action:     action,
+		EntityType: core.AuditPermissionGroupMember,
+		EntityID:   entityID,
+		After:      state,
+	}
+	if action == core.AuditDelete {
+		record.Before, record.After = state, nil
+	}
+	return recordAudit(ctx, tx, record)
+}
+
+// recordGroupMemberships records the permission groups that a user was removed from and added to when replacing
+// all of its groups.
+func recordGroupMemberships(
+	ctx context.Context,
+	tx pgx.Tx,
+	UserID core.UserID,
+	OrgID *core.OrganisationID,
+	current []sqlc.Permissiongroup,
+	GroupIDs []permissions.PermissionGroupID,
+) error {
+	for _, group := range current {
+		GroupID := permissions.PermissionGroupID(group.ID)
+		if slices.Contains(GroupIDs, GroupID) {
+			continue
+		}
+		err := recordGroupMemberChange(ctx, tx, core.AuditDelete, GroupID, UserID, OrgID, nil)
+		if err != nil {
+			return err
+		}
+	}
+	for _, GroupID := range GroupIDs {
+		if slices.ContainsFunc(current, func(group sqlc.Permissiongroup) bool {
+			return permissions.PermissionGroupID(group.ID) == GroupID
+		}) {
+			continue
+		}
+		err := recordGroupMemberChange(ctx, tx, core.AuditCreate, GroupID, UserID, OrgID, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedGroupIDs(GroupIDs []permissions.PermissionGroupID) []permissions.PermissionGroupID {
+	return slices.Sorted(slices.Values(GroupIDs))
+}
+
+// recordObjectPermissionChange records that a permission on a resource was granted or revoked.
+// The resource is the audited entity, identified as "{resource type}:{resource id}".
+func recordObjectPermissionChange(
+	ctx context.Context,
+	tx pgx.Tx,
+	action core.AuditAction,
+	resource permissions.Resource,
+	grant map[string]any,
+) error {
+	record := core.AuditRecord{
+		Action:     action,
+		EntityType: core.AuditObjectPermission,
+		EntityID:   fmt.Sprintf("%s:%v", resource.Type, resource.ID),
+		After:      grant,
+	}
+	if action == core.AuditDelete {
+		record.Before, record.After = grant, nil
+	}
+	return recordAudit(ctx, tx, record)
+}
+
 func combinePermissionGroup(
 	group sqlc.Permissiongroup,
 	perms []sqlc.GetPermissionsForGroupRow,
@@ -386,10 +1291,16 @@ func combinePermissionGroup(
 	if group.Name != nil {
 		Name = *group.Name
 	}
+	var orgID *core.OrganisationID
+	if group.OrganisationID != nil {
+		id := core.OrganisationID(*group.OrganisationID)
+		orgID = &id
+	}
 	return permissions.PermissionGroup{
-		ID:          permissions.PermissionGroupID(group.ID),
-		Name:        Name,
-		Permissions: cvtPermissions(perms),
+		ID:             permissions.PermissionGroupID(group.ID),
+		Name:           Name,
+		Permissions:    cvtPermissions(perms),
+		OrganisationID: orgID,
 	}
 }
 
@@ -402,3 +1313,21 @@ func cvtPermissions(
 	}
 	return Map
 }
+
+func toTimestamptz(t *time.Time) pgtype.Timestamptz {
+	if t == nil {
+		return pgtype.Timestamptz{}
+	}
+	return pgtype.Timestamptz{Time: *t, Valid: true}
+}
+
+func fromTimestamptz(t pgtype.Timestamptz) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func convertValidity(from pgtype.Timestamptz, until pgtype.Timestamptz) permissions.Validity {
+	return permissions.Validity{From: fromTimestamptz(from), Until: fromTimestamptz(until)}
+}
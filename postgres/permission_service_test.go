@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"testing"
+	"time"
 
 	"github.com/prior-it/apollo/core"
 	"github.com/prior-it/apollo/permissions"
@@ -174,4 +175,537 @@ func TestPermissionService(t *testing.T) {
 		assert.Greater(t, fixedGroup.ID, autoGroup1.ID)
 		assert.Greater(t, autoGroup2.ID, fixedGroup.ID)
 	})
+
+	t.Run("ok: direct resource permission", func(t *testing.T) {
+		user := tests.CreateRegularUser(userService)
+		perm := permissions.PermEditOwnUser
+		project := permissions.Resource{Type: "project", ID: 42}
+		otherProject := permissions.Resource{Type: "project", ID: 43}
+
+		assert.Nil(t, service.GrantUserPermissionOn(ctx, user.ID, perm, project))
+		// Granting the same permission twice should be a no-op
+		assert.Nil(t, service.GrantUserPermissionOn(ctx, user.ID, perm, project))
+
+		ok, err := service.HasAnyOn(ctx, user.ID, perm, project)
+		assert.Nil(t, err)
+		assert.True(t, ok, "A directly granted resource permission should return true")
+
+		ok, err = service.HasAnyOn(ctx, user.ID, perm, otherProject)
+		assert.Nil(t, err)
+		assert.False(t, ok, "A resource permission should not leak to other resources")
+
+		ok, err = service.HasAnyOn(ctx, user.ID, permissions.PermViewOwnUser, project)
+		assert.Nil(t, err)
+		assert.False(t, ok, "A resource permission should not leak to other permissions")
+
+		ok, err = service.HasAny(ctx, user.ID, perm)
+		assert.Nil(t, err)
+		assert.False(t, ok, "A resource permission should not grant the global permission")
+
+		assert.Nil(t, service.RevokeUserPermissionOn(ctx, user.ID, perm, project))
+		ok, err = service.HasAnyOn(ctx, user.ID, perm, project)
+		assert.Nil(t, err)
+		assert.False(t, ok, "A revoked resource permission should return false")
+	})
+
+	t.Run("ok: resource permission through group", func(t *testing.T) {
+		user := tests.CreateRegularUser(userService)
+		perm := permissions.PermEditOwnUser
+		group, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{})
+		assert.Nil(t, err)
+		assert.Nil(t, service.AddUserToPermissionGroup(ctx, user.ID, group.ID))
+		project := permissions.Resource{Type: "project", ID: 7}
+
+		assert.Nil(t, service.GrantGroupPermissionOn(ctx, group.ID, perm, project))
+		ok, err := service.HasAnyOn(ctx, user.ID, perm, project)
+		assert.Nil(t, err)
+		assert.True(t, ok, "Group members should inherit the group's resource permissions")
+
+		assert.Nil(t, service.RevokeGroupPermissionOn(ctx, group.ID, perm, project))
+		ok, err = service.HasAnyOn(ctx, user.ID, perm, project)
+		assert.Nil(t, err)
+		assert.False(t, ok, "A revoked group resource permission should return false")
+	})
+
+	t.Run("ok: list resource ids for user", func(t *testing.T) {
+		user := tests.CreateRegularUser(userService)
+		group, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{})
+		assert.Nil(t, err)
+		assert.Nil(t, service.AddUserToPermissionGroup(ctx, user.ID, group.ID))
+
+		perm := permissions.PermViewOwnOrganisation
+		project1 := permissions.Resource{Type: "project", ID: 1}
+		project2 := permissions.Resource{Type: "project", ID: 2}
+		project3 := permissions.Resource{Type: "project", ID: 3}
+		invoice := permissions.Resource{Type: "invoice", ID: 4}
+		assert.Nil(t, service.GrantUserPermissionOn(ctx, user.ID, perm, project3))
+		assert.Nil(t, service.GrantUserPermissionOn(ctx, user.ID, perm, project1))
+		assert.Nil(t, service.GrantGroupPermissionOn(ctx, group.ID, perm, project1))
+		assert.Nil(t, service.GrantGroupPermissionOn(ctx, group.ID, perm, project2))
+		assert.Nil(t, service.GrantUserPermissionOn(ctx, user.ID, perm, invoice))
+
+		ids, err := service.ListResourceIDsForUser(ctx, user.ID, perm, "project")
+		assert.Nil(t, err)
+		assert.Equal(t, []core.ID{1, 2, 3}, ids)
+
+		ids, err = service.ListResourceIDsForUser(
+			ctx,
+			user.ID,
+			permissions.PermEditAllUsers,
+			"project",
+		)
+		assert.Nil(t, err)
+		assert.Empty(t, ids)
+	})
+
+	t.Run("ok: organisation-owned permission groups", func(t *testing.T) {
+		orgService := postgres.NewOrganisationService(db)
+		org, err := orgService.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		otherOrg, err := orgService.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		defer func() {
+			tests.Check(orgService.DeleteOrganisation(ctx, org.ID))
+			tests.Check(orgService.DeleteOrganisation(ctx, otherOrg.ID))
+		}()
+
+		global, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+			Name: "global",
+		})
+		assert.Nil(t, err)
+		owned, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+			Name:           "owned",
+			OrganisationID: &org.ID,
+		})
+		assert.Nil(t, err)
+		foreign, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+			Name:           "foreign",
+			OrganisationID: &otherOrg.ID,
+		})
+		assert.Nil(t, err)
+
+		group, err := service.GetPermissionGroup(ctx, owned.ID)
+		assert.Nil(t, err)
+		assert.True(t, group.IsOwnedBy(org.ID), "The owner should be stored")
+
+		groups, err := service.ListPermissionGroupsForOrganisation(ctx, org.ID)
+		assert.Nil(t, err)
+		ids := make([]permissions.PermissionGroupID, len(groups))
+		for i, g := range groups {
+			ids[i] = g.ID
+		}
+		assert.Contains(t, ids, global.ID, "Global groups should be listed for every organisation")
+		assert.Contains(t, ids, owned.ID, "Owned groups should be listed for their owner")
+		assert.NotContains(t, ids, foreign.ID, "Groups of other organisations should not be listed")
+		assert.IsIncreasing(t, ids, "Groups should be listed in a stable order")
+
+		assert.ErrorIs(
+			t,
+			service.RenamePermissionGroupForOrganisation(ctx, org.ID, foreign.ID, "hijacked"),
+			core.ErrNotFound,
+		)
+		assert.ErrorIs(
+			t,
+			service.RenamePermissionGroupForOrganisation(ctx, org.ID, global.ID, "hijacked"),
+			core.ErrNotFound,
+		)
+		assert.Nil(
+			t,
+			service.RenamePermissionGroupForOrganisation(ctx, org.ID, owned.ID, "renamed"),
+		)
+
+		owned.Permissions = map[permissions.Permission]bool{permissions.PermViewOwnUser: true}
+		assert.Nil(t, service.UpdatePermissionGroupForOrganisation(ctx, org.ID, owned))
+		foreign.Permissions = map[permissions.Permission]bool{permissions.PermViewOwnUser: true}
+		assert.ErrorIs(
+			t,
+			service.UpdatePermissionGroupForOrganisation(ctx, org.ID, foreign),
+			core.ErrNotFound,
+		)
+		global.Permissions = map[permissions.Permission]bool{permissions.PermViewOwnUser: true}
+		assert.ErrorIs(
+			t,
+			service.UpdatePermissionGroupForOrganisation(ctx, org.ID, global),
+			core.ErrNotFound,
+			"Organisations should not update global groups",
+		)
+
+		assert.ErrorIs(
+			t,
+			service.DeletePermissionGroupForOrganisation(ctx, org.ID, foreign.ID),
+			core.ErrNotFound,
+		)
+	})
+
+	t.Run("err: assign permission groups of another organisation", func(t *testing.T) {
+		orgService := postgres.NewOrganisationService(db)
+		org, err := orgService.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		otherOrg, err := orgService.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		defer func() {
+			tests.Check(orgService.DeleteOrganisation(ctx, org.ID))
+			tests.Check(orgService.DeleteOrganisation(ctx, otherOrg.ID))
+		}()
+		user := tests.CreateRegularUser(userService)
+		assert.Nil(t, orgService.AddUser(ctx, user.ID, org.ID))
+
+		global, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{})
+		assert.Nil(t, err)
+		owned, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+			OrganisationID: &org.ID,
+		})
+		assert.Nil(t, err)
+		foreign, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+			OrganisationID: &otherOrg.ID,
+		})
+		assert.Nil(t, err)
+
+		assert.Nil(
+			t,
+			service.AddUserToPermissionGroupForOrganisation(ctx, user.ID, org.ID, global.ID),
+		)
+		assert.Nil(
+			t,
+			service.AddUserToPermissionGroupForOrganisation(ctx, user.ID, org.ID, owned.ID),
+		)
+		assert.ErrorIs(
+			t,
+			service.AddUserToPermissionGroupForOrganisation(ctx, user.ID, org.ID, foreign.ID),
+			core.ErrForbidden,
+		)
+		assert.ErrorIs(
+			t,
+			service.AddUserToPermissionGroup(ctx, user.ID, owned.ID),
+			core.ErrForbidden,
+			"Owned groups cannot be assigned globally",
+		)
+	})
+
+	t.Run("ok: remove user from permission group", func(t *testing.T) {
+		user := CreateUserWithPermissions(t, db, map[permissions.Permission]bool{
+			permissions.PermViewOwnUser: true,
+		})
+		groups, err := service.ListPermissionGroupsForUser(ctx, user.ID)
+		assert.Nil(t, err)
+		assert.Len(t, groups, 1)
+
+		assert.Nil(t, service.RemoveUserFromPermissionGroup(ctx, user.ID, groups[0].ID))
+		ok, err := service.HasAny(ctx, user.ID, permissions.PermViewOwnUser)
+		assert.Nil(t, err)
+		assert.False(t, ok, "A removed user should lose the group's permissions")
+
+		assert.Nil(
+			t,
+			service.RemoveUserFromPermissionGroup(ctx, user.ID, groups[0].ID),
+			"Removing a user that is not a member should not fail",
+		)
+	})
+
+	t.Run("ok: remove user from permission group in organisation", func(t *testing.T) {
+		orgService := postgres.NewOrganisationService(db)
+		org, err := orgService.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		defer func() { tests.Check(orgService.DeleteOrganisation(ctx, org.ID)) }()
+		user := tests.CreateRegularUser(userService)
+		assert.Nil(t, orgService.AddUser(ctx, user.ID, org.ID))
+		group, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+			Permissions: map[permissions.Permission]bool{permissions.PermViewOwnUser: true},
+		})
+		assert.Nil(t, err)
+
+		err = service.AddUserToPermissionGroupForOrganisation(ctx, user.ID, org.ID, group.ID)
+		assert.Nil(t, err)
+		ok, err := service.HasAnyForOrg(ctx, user.ID, org.ID, permissions.PermViewOwnUser)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+
+		err = service.RemoveUserFromPermissionGroupForOrganisation(ctx, user.ID, org.ID, group.ID)
+		assert.Nil(t, err)
+		ok, err = service.HasAnyForOrg(ctx, user.ID, org.ID, permissions.PermViewOwnUser)
+		assert.Nil(t, err)
+		assert.False(t, ok, "A removed user should lose the group's permissions")
+	})
+
+	t.Run("ok: soft deleted organisation grants no permissions", func(t *testing.T) {
+		orgService := postgres.NewOrganisationService(db)
+		org, err := orgService.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		user := tests.CreateRegularUser(userService)
+		perm := permissions.PermViewOwnUser
+		assert.Nil(t, orgService.AddUser(ctx, user.ID, org.ID))
+		group, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+			OrganisationID: &org.ID,
+			Permissions:    map[permissions.Permission]bool{perm: true},
+		})
+		assert.Nil(t, err)
+		err = service.AddUserToPermissionGroupForOrganisation(ctx, user.ID, org.ID, group.ID)
+		assert.Nil(t, err)
+		ok, err := service.HasAnyForOrg(ctx, user.ID, org.ID, perm)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+
+		assert.Nil(t, orgService.DeleteOrganisation(ctx, org.ID))
+		ok, err = service.HasAnyForOrg(ctx, user.ID, org.ID, perm)
+		assert.Nil(t, err)
+		assert.False(t, ok, "Members of a deleted organisation should lose its permissions")
+		granted, err := service.GetUserPermissionsForOrganisation(ctx, user.ID, org.ID)
+		assert.Nil(t, err)
+		assert.False(t, granted[perm])
+	})
+
+	t.Run("ok: list users in permission group", func(t *testing.T) {
+		orgService := postgres.NewOrganisationService(db)
+		org, err := orgService.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		defer func() { tests.Check(orgService.DeleteOrganisation(ctx, org.ID)) }()
+		group, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{})
+		assert.Nil(t, err)
+
+		globalUser := tests.CreateRegularUser(userService)
+		orgUser := tests.CreateRegularUser(userService)
+		tests.CreateRegularUser(userService) // not a member
+		assert.Nil(t, service.AddUserToPermissionGroup(ctx, globalUser.ID, group.ID))
+		assert.Nil(t, orgService.AddUser(ctx, orgUser.ID, org.ID))
+		err = service.AddUserToPermissionGroupForOrganisation(ctx, orgUser.ID, org.ID, group.ID)
+		assert.Nil(t, err)
+
+		members, err := service.ListUsersInPermissionGroup(ctx, group.ID)
+		assert.Nil(t, err)
+		assert.Len(t, members, 2)
+		for _, member := range members {
+			switch member.User.ID {
+			case globalUser.ID:
+				assert.Nil(t, member.OrganisationID, "Global members have no organisation")
+			case orgUser.ID:
+				assert.NotNil(t, member.OrganisationID, "Organisation members need an organisation")
+				assert.Equal(t, org.ID, *member.OrganisationID)
+			default:
+				assert.Fail(t, "Unexpected member", "user id %v", member.User.ID)
+			}
+		}
+	})
+
+	t.Run("ok: set user permission groups", func(t *testing.T) {
+		user := tests.CreateRegularUser(userService)
+		group1, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{})
+		assert.Nil(t, err)
+		group2, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{})
+		assert.Nil(t, err)
+		group3, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{})
+		assert.Nil(t, err)
+		assert.Nil(t, service.AddUserToPermissionGroup(ctx, user.ID, group1.ID))
+
+		groupIDs := []permissions.PermissionGroupID{group2.ID, group3.ID}
+		assert.Nil(t, service.SetUserPermissionGroups(ctx, user.ID, groupIDs))
+		groups, err := service.ListPermissionGroupsForUser(ctx, user.ID)
+		assert.Nil(t, err)
+		ids := make([]permissions.PermissionGroupID, len(groups))
+		for i, g := range groups {
+			ids[i] = g.ID
+		}
+		assert.ElementsMatch(t, groupIDs, ids)
+
+		assert.Nil(t, service.SetUserPermissionGroups(ctx, user.ID, nil))
+		groups, err = service.ListPermissionGroupsForUser(ctx, user.ID)
+		assert.Nil(t, err)
+		assert.Empty(t, groups)
+	})
+
+	t.Run("err: set user permission groups is atomic", func(t *testing.T) {
+		orgService := postgres.NewOrganisationService(db)
+		org, err := orgService.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		defer func() { tests.Check(orgService.DeleteOrganisation(ctx, org.ID)) }()
+		user := tests.CreateRegularUser(userService)
+		assert.Nil(t, orgService.AddUser(ctx, user.ID, org.ID))
+		existing, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{})
+		assert.Nil(t, err)
+		valid, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{})
+		assert.Nil(t, err)
+		owned, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+			OrganisationID: &org.ID,
+		})
+		assert.Nil(t, err)
+		assert.Nil(t, service.AddUserToPermissionGroup(ctx, user.ID, existing.ID))
+
+		err = service.SetUserPermissionGroups(
+			ctx,
+			user.ID,
+			[]permissions.PermissionGroupID{valid.ID, owned.ID},
+		)
+		assert.ErrorIs(t, err, core.ErrForbidden)
+		groups, err := service.ListPermissionGroupsForUser(ctx, user.ID)
+		assert.Nil(t, err)
+		assert.Len(t, groups, 1, "A failed update should not change any groups")
+		assert.Equal(t, existing.ID, groups[0].ID)
+
+		err = service.SetUserPermissionGroupsForOrganisation(
+			ctx,
+			user.ID,
+			org.ID,
+			[]permissions.PermissionGroupID{valid.ID, owned.ID},
+		)
+		assert.Nil(t, err)
+		groups, err = service.ListPermissionGroupsForUserForOrganisation(ctx, user.ID, org.ID)
+		assert.Nil(t, err)
+		assert.Len(t, groups, 2)
+	})
+
+	t.Run("ok: explain permissions", func(t *testing.T) {
+		orgService := postgres.NewOrganisationService(db)
+		parent, err := orgService.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		child, err := orgService.CreateOrganisation(ctx, tests.Faker.BS(), &parent.ID)
+		assert.Nil(t, err)
+		defer func() {
+			tests.Check(orgService.DeleteOrganisation(ctx, parent.ID))
+		}()
+
+		perm := permissions.PermEditOwnOrganisation
+		global, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+			Permissions: map[permissions.Permission]bool{perm: false},
+		})
+		assert.Nil(t, err)
+		granting, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+			Permissions: map[permissions.Permission]bool{perm: true},
+		})
+		assert.Nil(t, err)
+
+		user := tests.CreateRegularUser(userService)
+		assert.Nil(t, service.AddUserToPermissionGroup(ctx, user.ID, global.ID))
+		assert.Nil(t, orgService.AddUser(ctx, user.ID, parent.ID))
+		assert.Nil(t, orgService.AddUser(ctx, user.ID, child.ID))
+		err = service.AddUserToPermissionGroupForOrganisation(ctx, user.ID, parent.ID, granting.ID)
+		assert.Nil(t, err)
+
+		explanation, err := service.Explain(ctx, user.ID, perm, nil)
+		assert.Nil(t, err)
+		assert.False(t, explanation.Granted, "Global groups do not grant the permission")
+		assert.Len(t, explanation.Steps, 2)
+		assert.Equal(t, permissions.SourceAdmin, explanation.Steps[0].Source)
+		assert.Equal(t, permissions.SourceGlobalGroup, explanation.Steps[1].Source)
+
+		explanation, err = service.Explain(ctx, user.ID, perm, &child.ID)
+		assert.Nil(t, err)
+		assert.True(t, explanation.Granted, "The parent organisation grants the permission")
+		granted := explanation.GrantedBy()
+		assert.Len(t, granted, 1)
+		assert.Equal(t, permissions.SourceOrganisationGroup, granted[0].Source)
+		assert.Equal(t, granting.ID, granted[0].Group.ID)
+		assert.Equal(t, parent.ID, *granted[0].OrganisationID)
+		assert.Equal(t, 1, granted[0].Depth)
+
+		_, err = service.Explain(ctx, 0, perm, nil)
+		assert.ErrorIs(t, err, core.ErrNotFound)
+	})
+
+	t.Run("ok: seed permission group definitions", func(t *testing.T) {
+		defs := []permissions.GroupDefinition{{
+			ID:          9001,
+			Name:        "Seeded",
+			Permissions: []permissions.Permission{permissions.PermViewOwnUser},
+		}}
+		defer func() {
+			tests.Check(service.DeletePermissionGroup(ctx, 9001))
+		}()
+
+		report, err := permissions.SeedGroupDefinitions(ctx, service, defs)
+		assert.Nil(t, err)
+		assert.Equal(t, []permissions.PermissionGroupID{9001}, report.Created)
+
+		report, err = permissions.SeedGroupDefinitions(ctx, service, defs)
+		assert.Nil(t, err)
+		assert.False(t, report.HasDrift(), "Seeding should be idempotent")
+
+		err = service.RenamePermissionGroup(ctx, 9001, "Renamed")
+		assert.Nil(t, err)
+		err = service.UpdatePermissionGroup(ctx, &permissions.PermissionGroup{
+			ID:          9001,
+			Permissions: map[permissions.Permission]bool{permissions.PermEditOwnUser: true},
+		})
+		assert.Nil(t, err)
+
+		report, err = permissions.CheckGroupDefinitions(ctx, service, defs)
+		assert.Nil(t, err)
+		assert.Len(t, report.Drifted, 1)
+		drift := report.Drifted[0]
+		assert.Equal(t, "Renamed", *drift.Name)
+		assert.Equal(t, []permissions.Permission{permissions.PermEditOwnUser}, drift.Extra)
+		assert.Empty(t, drift.Missing)
+
+		_, err = permissions.SeedGroupDefinitions(ctx, service, defs)
+		assert.Nil(t, err)
+		exported, err := permissions.ExportGroupDefinitions(ctx, service)
+		assert.Nil(t, err)
+		assert.Contains(t, exported, defs[0], "Seeding should fix all drift")
+	})
+
+	t.Run("ok: time-bound memberships", func(t *testing.T) {
+		orgService := postgres.NewOrganisationService(db)
+		org, err := orgService.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		defer func() {
+			tests.Check(orgService.DeleteOrganisation(ctx, org.ID))
+		}()
+		perm := permissions.PermViewOwnOrganisation
+		group, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+			Permissions: map[permissions.Permission]bool{perm: true},
+		})
+		assert.Nil(t, err)
+
+		past := time.Now().Add(-time.Hour)
+		future := time.Now().Add(time.Hour)
+		expired := permissions.Validity{Until: &past}
+		scheduled := permissions.Validity{From: &future}
+		current := permissions.Validity{From: &past, Until: &future}
+
+		user := tests.CreateRegularUser(userService)
+		assert.Nil(t, orgService.AddUser(ctx, user.ID, org.ID))
+		err = service.AddUserToPermissionGroupWithValidity(ctx, user.ID, group.ID, scheduled)
+		assert.Nil(t, err)
+		err = service.AddUserToPermissionGroupForOrganisationWithValidity(
+			ctx,
+			user.ID,
+			org.ID,
+			group.ID,
+			expired,
+		)
+		assert.Nil(t, err)
+
+		ok, err := service.HasAny(ctx, user.ID, perm)
+		assert.Nil(t, err)
+		assert.False(t, ok, "Memberships that have not started yet should be ignored")
+		ok, err = service.HasAnyForOrg(ctx, user.ID, org.ID, perm)
+		assert.Nil(t, err)
+		assert.False(t, ok, "Expired memberships should be ignored")
+		perms, err := service.GetUserPermissionsForOrganisation(ctx, user.ID, org.ID)
+		assert.Nil(t, err)
+		assert.False(t, perms[perm], "Expired memberships should be ignored")
+
+		members, err := service.ListUsersInPermissionGroup(ctx, group.ID)
+		assert.Nil(t, err)
+		assert.Len(t, members, 2, "Inactive memberships should still be listed")
+
+		err = service.AddUserToPermissionGroupWithValidity(ctx, user.ID, group.ID, current)
+		assert.Nil(t, err)
+		ok, err = service.HasAny(ctx, user.ID, perm)
+		assert.Nil(t, err)
+		assert.True(t, ok, "Adding the user again should replace the validity window")
+
+		deleted, err := service.DeleteExpiredMemberships(ctx)
+		assert.Nil(t, err)
+		assert.GreaterOrEqual(t, deleted, int64(1))
+		members, err = service.ListUsersInPermissionGroup(ctx, group.ID)
+		assert.Nil(t, err)
+		assert.Len(t, members, 1, "Only the expired membership should be deleted")
+
+		err = service.AddUserToPermissionGroupWithValidity(
+			ctx,
+			user.ID,
+			group.ID,
+			permissions.Validity{From: &future, Until: &past},
+		)
+		assert.ErrorIs(t, err, permissions.ErrInvalidValidity)
+	})
 }
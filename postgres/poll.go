@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	"time"
+)
+
+// pollLoop runs batch every interval until the context is cancelled. As long as batch returns true, e.g. because it
+// processed a full batch, it runs again right away so a backlog does not have to wait for the ticker.
+func pollLoop(ctx context.Context, interval time.Duration, batch func(ctx context.Context) bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for ctx.Err() == nil {
+				if !batch(ctx) {
+					break
+				}
+			}
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt after the specified amount of failed attempts: the base delay
+// after the first failure, doubled after every other failure, up to the maximum delay.
+func backoff(failures int32, base, maxDelay time.Duration) time.Duration {
+	delay := base
+	for i := int32(1); i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
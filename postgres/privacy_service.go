@@ -0,0 +1,322 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/postgres/internal/sqlc"
+)
+
+// UserDataExporter returns the data that the application stores about a user, to include in user data exports.
+// The result is encoded as JSON, so it should only contain exported fields or implement json.Marshaler.
+// It runs in the same transaction that gathers Apollo's own data.
+type UserDataExporter func(ctx context.Context, tx pgx.Tx, userID core.UserID) (any, error)
+
+// UserDataEraser erases the data that the application stores about a user.
+// It runs in the same transaction that erases Apollo's own data, before the user itself is anonymised or deleted.
+// Returning an error will roll back the transaction, which means nothing will be erased.
+type UserDataEraser func(
+	ctx context.Context,
+	tx pgx.Tx,
+	userID core.UserID,
+	mode core.ErasureMode,
+) error
+
+type userDataExporter struct {
+	name   string
+	export UserDataExporter
+}
+
+// OnUserDataExport registers an exporter that contributes the application's own data to every user data export,
+// under the specified name. You should register all exporters while bootstrapping, before the server starts handling
+// requests.
+func (db *DB) OnUserDataExport(name string, exporter UserDataExporter) {
+	db.userDataExporters = append(db.userDataExporters, userDataExporter{name, exporter})
+}
+
+// OnUserDataErasure registers erasers that will run whenever the data of a user is erased through
+// PrivacyService.EraseUserData. Erasers run in the order in which they were registered.
+// You should register all erasers while bootstrapping, before the server starts handling requests.
+func (db *DB) OnUserDataErasure(erasers ...UserDataEraser) {
+	db.userDataErasers = append(db.userDataErasers, erasers...)
+}
+
+func NewPrivacyService(DB *DB) *PrivacyService {
+	return &PrivacyService{DB, sqlc.New(DB)}
+}
+
+// Postgres implementation of the core PrivacyService interface.
+type PrivacyService struct {
+	db *DB
+	q  *sqlc.Queries
+}
+
+// Force struct to implement the core interface
+var _ core.PrivacyService = &PrivacyService{}
+
+// ExportUserData implements core.PrivacyService.ExportUserData
+func (p *PrivacyService) ExportUserData(
+	ctx context.Context,
+	id core.UserID,
+) (*core.UserData, error) {
+	var data *core.UserData
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		var err error
+		data, err = exportUserData(ctx, sqlc.New(tx), id)
+		if err != nil {
+			return err
+		}
+		for _, exporter := range p.db.userDataExporters {
+			appData, err := exporter.export(ctx, tx, id)
+			if err != nil {
+				return fmt.Errorf("could not export %q data of user %v: %w", exporter.name, id, err)
+			}
+			if data.App == nil {
+				data.App = make(map[string]any, len(p.db.userDataExporters))
+			}
+			data.App[exporter.name] = appData
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// exportUserData gathers Apollo's own data about a user.
+func exportUserData(
+	ctx context.Context,
+	queries *sqlc.Queries,
+	id core.UserID,
+) (*core.UserData, error) {
+	user, err := queries.GetUserIncludingDeleted(ctx, int32(id))
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	data := &core.UserData{
+		ExportedAt: time.Now(),
+		Profile: core.UserProfileData{
+			ID:        core.UserID(user.ID),
+			Name:      user.Name,
+			Email:     user.Email,
+			Admin:     user.Admin,
+			Lang:      user.Lang,
+			Joined:    user.Joined.Time,
+			DeletedAt: fromTimestamptz(user.DeletedAt),
+		},
+		Accounts:         []core.UserAccountData{},
+		Memberships:      []core.UserMembershipData{},
+		PermissionGroups: []core.UserPermissionGroupData{},
+		AccountCache:     []core.UserAccountCacheData{},
+		Addresses:        []core.UserAddressData{},
+	}
+
+	accounts, err := queries.ListAccountsForUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not export accounts: %w", ConvertPgError(err))
+	}
+	for _, account := range accounts {
+		data.Accounts = append(data.Accounts, core.UserAccountData{
+			Provider:   account.Provider,
+			ProviderID: account.ProviderID,
+		})
+	}
+
+	memberships, err := queries.ListUserDataMemberships(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not export memberships: %w", ConvertPgError(err))
+	}
+	for _, membership := range memberships {
+		data.Memberships = append(data.Memberships, core.UserMembershipData{
+			OrganisationID:   core.OrganisationID(membership.OrganisationID),
+			OrganisationName: membership.OrganisationName,
+			Status:           core.MembershipStatus(membership.Status),
+			Role:             membership.Role,
+			JoinedAt:         membership.JoinedAt.Time,
+			InvitedBy:        fromOptionalID(membership.InvitedBy),
+		})
+	}
+
+	groups, err := queries.ListUserDataPermissionGroups(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not export permission groups: %w", ConvertPgError(err))
+	}
+	for _, group := range groups {
+		data.PermissionGroups = append(data.PermissionGroups, core.UserPermissionGroupData{
+			ID:             core.ID(group.ID),
+			Name:           group.Name,
+			OrganisationID: fromOptionalID(group.OrganisationID),
+			ValidFrom:      fromTimestamptz(group.ValidFrom),
+			ValidUntil:     fromTimestamptz(group.ValidUntil),
+		})
+	}
+
+	cache, err := queries.ListUserDataAccountCache(ctx, user.Email, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not export account cache: %w", ConvertPgError(err))
+	}
+	for _, entry := range cache {
+		data.AccountCache = append(data.AccountCache, core.UserAccountCacheData{
+			Name:       entry.Name,
+			Email:      entry.Email,
+			Provider:   entry.Provider,
+			ProviderID: entry.ProviderID,
+			Created:    entry.Created.Time,
+		})
+	}
+
+	addresses, err := queries.ListUserDataAddresses(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not export addresses: %w", ConvertPgError(err))
+	}
+	for _, address := range addresses {
+		data.Addresses = append(data.Addresses, core.UserAddressData{
+			OrganisationID: core.OrganisationID(address.OrganisationID),
+			Type:           core.AddressType(address.Type),
+			Street:         address.Address.Street,
+			Number:         address.Address.Number,
+			PostalCode:     address.Address.PostalCode,
+			City:           address.Address.City,
+			Country:        address.Address.Country,
+			ExtraLine:      address.Address.ExtraLine,
+		})
+	}
+	return data, nil
+}
+
+// EraseUserData implements core.PrivacyService.EraseUserData
+func (p *PrivacyService) EraseUserData(
+	ctx context.Context,
+	id core.UserID,
+	request core.ErasureRequest,
+) (*core.UserErasure, error) {
+	if request.Mode != core.ErasureAnonymise && request.Mode != core.ErasureDelete {
+		return nil, fmt.Errorf("unknown erasure mode %q", request.Mode)
+	}
+	var erasure sqlc.UserErasure
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		user, err := queries.GetUserIncludingDeleted(ctx, int32(id))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		for _, erase := range p.db.userDataErasers {
+			if err := erase(ctx, tx, id, request.Mode); err != nil {
+				return fmt.Errorf("could not erase application data of user %v: %w", id, err)
+			}
+		}
+		// The cache is not linked to the user, so it has to be removed before the accounts that identify it
+		if _, err := queries.DeleteUserDataAccountCache(ctx, user.Email, user.ID); err != nil {
+			return fmt.Errorf("could not erase account cache: %w", ConvertPgError(err))
+		}
+		if request.Mode == core.ErasureDelete {
+			if _, err := queries.PurgeUser(ctx, user.ID); err != nil {
+				return fmt.Errorf("could not delete user: %w", ConvertPgError(err))
+			}
+		} else if err := anonymiseUser(ctx, queries, user.ID); err != nil {
+			return err
+		}
+		// The audit log keeps the history of the user, but not the personal data that was recorded in it
+		if err := queries.EraseAuditChanges(ctx, core.AuditUser, id.String()); err != nil {
+			return fmt.Errorf("could not erase audit log: %w", ConvertPgError(err))
+		}
+		err = recordAudit(ctx, tx, core.AuditRecord{
+			Action:     core.AuditErase,
+			EntityType: core.AuditUser,
+			EntityID:   id.String(),
+			After:      map[string]core.ErasureMode{"Mode": request.Mode},
+		})
+		if err != nil {
+			return err
+		}
+		erasure, err = queries.CreateUserErasure(ctx, sqlc.CreateUserErasureParams{
+			UserID:      user.ID,
+			Mode:        string(request.Mode),
+			RequestedBy: toOptionalID(request.RequestedBy),
+			Reason:      request.Reason,
+		})
+		if err != nil {
+			return fmt.Errorf("could not record erasure: %w", ConvertPgError(err))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return convertUserErasure(erasure), nil
+}
+
+// anonymiseUser removes everything that identifies a user or grants it access, but keeps the user itself.
+func anonymiseUser(ctx context.Context, queries *sqlc.Queries, id int32) error {
+	if err := queries.DeleteAccountsForUser(ctx, id); err != nil {
+		return fmt.Errorf("could not erase accounts: %w", ConvertPgError(err))
+	}
+	if err := queries.RemoveUserFromAllPermissionGroups(ctx, id); err != nil {
+		return fmt.Errorf("could not erase permission groups: %w", ConvertPgError(err))
+	}
+	if err := queries.RemoveUserFromAllOrganisationPermissionGroups(ctx, id); err != nil {
+		return fmt.Errorf("could not erase organisation permission groups: %w", ConvertPgError(err))
+	}
+	if err := queries.RevokeAllUserObjectPermissions(ctx, id); err != nil {
+		return fmt.Errorf("could not erase object permissions: %w", ConvertPgError(err))
+	}
+	if err := queries.LeaveAllOrganisations(ctx, id); err != nil {
+		return fmt.Errorf("could not erase memberships: %w", ConvertPgError(err))
+	}
+	err := queries.AnonymiseUser(ctx, sqlc.AnonymiseUserParams{
+		Name:  core.AnonymousUserName,
+		Email: core.AnonymousUserEmail(core.UserID(id)),
+		ID:    id,
+	})
+	if err != nil {
+		return fmt.Errorf("could not anonymise user: %w", ConvertPgError(err))
+	}
+	return nil
+}
+
+// ListUserErasures implements core.PrivacyService.ListUserErasures
+func (p *PrivacyService) ListUserErasures(
+	ctx context.Context,
+	id core.UserID,
+) ([]core.UserErasure, error) {
+	rows, err := p.q.ListUserErasures(ctx, int32(id))
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	erasures := make([]core.UserErasure, len(rows))
+	for i, row := range rows {
+		erasures[i] = *convertUserErasure(row)
+	}
+	return erasures, nil
+}
+
+func convertUserErasure(erasure sqlc.UserErasure) *core.UserErasure {
+	return &core.UserErasure{
+		ID:          core.ID(erasure.ID),
+		UserID:      core.UserID(erasure.UserID),
+		Mode:        core.ErasureMode(erasure.Mode),
+		RequestedBy: fromOptionalID(erasure.RequestedBy),
+		Reason:      erasure.Reason,
+		ErasedAt:    erasure.ErasedAt.Time,
+	}
+}
+
+func toOptionalID(id *core.ID) *int32 {
+	if id == nil {
+		return nil
+	}
+	i := int32(*id)
+	return &i
+}
+
+func fromOptionalID(id *int32) *core.ID {
+	if id == nil {
+		return nil
+	}
+	i := core.ID(*id)
+	return &i
+}
@@ -0,0 +1,172 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/login"
+	"github.com/prior-it/apollo/permissions"
+	"github.com/prior-it/apollo/postgres"
+	"github.com/prior-it/apollo/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivacyService(t *testing.T) {
+	db := tests.DB(t)
+	service := postgres.NewPrivacyService(db)
+	userService := postgres.NewUserService(db)
+	orgService := postgres.NewOrganisationService(db)
+	permissionService := postgres.NewPermissionService(db)
+	accountService := postgres.NewOauthAccountService(db)
+	defer tests.DeleteAllPermissions(permissionService)
+	defer tests.DeleteAllUsers(userService)
+	defer tests.DeleteAllOrganisations(orgService)
+	ctx := context.Background()
+
+	var erased []core.UserID
+	db.OnUserDataExport("notes", func(_ context.Context, _ pgx.Tx, id core.UserID) (any, error) {
+		return map[string]any{"user": id}, nil
+	})
+	db.OnUserDataErasure(
+		func(_ context.Context, _ pgx.Tx, id core.UserID, _ core.ErasureMode) error {
+			erased = append(erased, id)
+			return nil
+		},
+	)
+
+	// createUser creates a user with an account, a cached login, a membership and a permission group
+	createUser := func() (*core.User, *core.Organisation) {
+		data := &login.UserData{
+			Name:       tests.Faker.Name(),
+			Email:      tests.Faker.Email(),
+			Lang:       "nl",
+			Provider:   "test",
+			ProviderID: tests.Faker.UUID(),
+		}
+		user, err := accountService.CreateUserAccount(ctx, data)
+		tests.Check(err)
+		_, err = accountService.CacheUserData(ctx, data)
+		tests.Check(err)
+		org, err := orgService.CreateOrganisation(ctx, tests.Faker.Company(), nil)
+		tests.Check(err)
+		tests.Check(orgService.AddUser(ctx, user.ID, org.ID))
+		_, err = orgService.UpdateBillingDetails(ctx, org.ID, core.BillingDetailsUpdate{
+			Addresses: map[core.AddressType]core.Address{core.AddressBilling: {
+				Street:     tests.Faker.Street(),
+				Number:     "1",
+				PostalCode: "1000",
+				City:       tests.Faker.City(),
+				Country:    "BE",
+			}},
+		})
+		tests.Check(err)
+		group, err := permissionService.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+			Name:        tests.Faker.BS(),
+			Permissions: map[permissions.Permission]bool{},
+		})
+		tests.Check(err)
+		tests.Check(permissionService.AddUserToPermissionGroup(ctx, user.ID, group.ID))
+		return user, org
+	}
+
+	t.Run("ok: export user data", func(t *testing.T) {
+		user, org := createUser()
+
+		data, err := service.ExportUserData(ctx, user.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, user.Email.String(), data.Profile.Email)
+		assert.Len(t, data.Accounts, 1)
+		if assert.Len(t, data.Memberships, 1) {
+			assert.Equal(t, org.ID, data.Memberships[0].OrganisationID)
+		}
+		assert.Len(t, data.PermissionGroups, 1)
+		assert.Len(t, data.AccountCache, 1)
+		assert.Len(t, data.Addresses, 1)
+		assert.Equal(t, map[string]any{"user": user.ID}, data.App["notes"])
+	})
+
+	t.Run("ok: export deleted user", func(t *testing.T) {
+		user := tests.CreateRegularUser(userService)
+		tests.Check(userService.DeleteUser(ctx, user.ID))
+
+		data, err := service.ExportUserData(ctx, user.ID)
+		assert.Nil(t, err)
+		assert.NotNil(t, data.Profile.DeletedAt, "Soft deleted users should be exported as well")
+	})
+
+	t.Run("ok: anonymise user", func(t *testing.T) {
+		user, org := createUser()
+		requester := tests.CreateRegularUser(userService)
+		reason := "ticket 42"
+
+		erasure, err := service.EraseUserData(ctx, user.ID, core.ErasureRequest{
+			Mode:        core.ErasureAnonymise,
+			RequestedBy: &requester.ID,
+			Reason:      &reason,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, core.ErasureAnonymise, erasure.Mode)
+		assert.Contains(t, erased, user.ID, "Application erasers should run")
+
+		data, err := service.ExportUserData(ctx, user.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, core.AnonymousUserName, data.Profile.Name)
+		assert.Equal(t, core.AnonymousUserEmail(user.ID), data.Profile.Email)
+		assert.Empty(t, data.Accounts)
+		assert.Empty(t, data.PermissionGroups)
+		assert.Empty(t, data.AccountCache)
+		if assert.Len(t, data.Memberships, 1, "Memberships should be kept as history") {
+			assert.Equal(t, core.MembershipLeft, data.Memberships[0].Status)
+		}
+		_, err = orgService.GetMember(ctx, user.ID, org.ID)
+		assert.ErrorIs(t, err, core.ErrNotFound)
+
+		erasures, err := service.ListUserErasures(ctx, user.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, []core.UserErasure{*erasure}, erasures)
+
+		_, err = userService.PurgeDeletedUsers(ctx, time.Now().Add(time.Hour))
+		assert.Nil(t, err)
+		_, err = service.ExportUserData(ctx, user.ID)
+		assert.Nil(t, err, "Anonymised users should not be purged")
+	})
+
+	t.Run("ok: delete user", func(t *testing.T) {
+		user, _ := createUser()
+
+		_, err := service.EraseUserData(ctx, user.ID, core.ErasureRequest{
+			Mode:        core.ErasureDelete,
+			RequestedBy: &user.ID,
+		})
+		assert.Nil(t, err)
+		_, err = service.ExportUserData(ctx, user.ID)
+		assert.ErrorIs(t, err, core.ErrNotFound)
+
+		erasures, err := service.ListUserErasures(ctx, user.ID)
+		assert.Nil(t, err)
+		assert.Len(t, erasures, 1, "The erasure should be recorded after the user is deleted")
+	})
+
+	t.Run("err: erasure is rolled back", func(t *testing.T) {
+		_, err := service.EraseUserData(ctx, 0, core.ErasureRequest{Mode: core.ErasureDelete})
+		assert.ErrorIs(t, err, core.ErrNotFound)
+
+		user := tests.CreateRegularUser(userService)
+		db.OnUserDataErasure(func(context.Context, pgx.Tx, core.UserID, core.ErasureMode) error {
+			return errors.New("failed")
+		})
+		request := core.ErasureRequest{Mode: core.ErasureAnonymise}
+		_, err = service.EraseUserData(ctx, user.ID, request)
+		assert.NotNil(t, err)
+		user2, err := userService.GetUser(ctx, user.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, user, user2, "Nothing should be erased if an eraser fails")
+		erasures, err := service.ListUserErasures(ctx, user.ID)
+		assert.Nil(t, err)
+		assert.Empty(t, erasures)
+	})
+}
@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/permissions"
+	"github.com/prior-it/apollo/postgres/internal/sqlc"
+)
+
+// UserProvisioner is called within the same transaction that creates a new user.
+// Returning an error will roll back the transaction, which means the user will not be created.
+type UserProvisioner func(ctx context.Context, tx pgx.Tx, user *core.User) error
+
+// MemberProvisioner is called within the same transaction that adds an existing user to an organisation.
+// Returning an error will roll back the transaction, which means the user will not be added.
+type MemberProvisioner func(
+	ctx context.Context,
+	tx pgx.Tx,
+	userID core.UserID,
+	orgID core.OrganisationID,
+) error
+
+// OnUserCreated registers provisioners that will run whenever a new user is created through any of the Apollo
+// services that use this database, e.g. UserService.CreateUser or PgOauthAccountService.CreateUserAccount.
+// Provisioners run in the order in which they were registered.
+// You should register all provisioners while bootstrapping, before the server starts handling requests.
+func (db *DB) OnUserCreated(provisioners ...UserProvisioner) {
+	db.userProvisioners = append(db.userProvisioners, provisioners...)
+}
+
+// OnMemberAdded registers provisioners that will run whenever an existing user is added to an organisation through
+// OrganisationService.AddUser or OrganisationService.AddMembership. These run after the organisation's own default
+// permission groups have been assigned. Both also happen for pending memberships, e.g. invitations, so provisioners
+// should check the membership's status if they should only provision active members. The permission groups of a
+// pending member only take effect once the membership is active.
+// Provisioners run in the order in which they were registered.
+// You should register all provisioners while bootstrapping, before the server starts handling requests.
+func (db *DB) OnMemberAdded(provisioners ...MemberProvisioner) {
+	db.memberProvisioners = append(db.memberProvisioners, provisioners...)
+}
+
+// provisionUser records the creation of a new user, emits core.UserCreated and runs the registered provisioners.
+func (db *DB) provisionUser(ctx context.Context, tx pgx.Tx, user *core.User) error {
+	err := recordAudit(ctx, tx, core.AuditRecord{
+		Action:     core.AuditCreate,
+		EntityType: core.AuditUser,
+		EntityID:   user.ID.String(),
+		After:      user,
+	})
+	if err != nil {
+		return err
+	}
+	if err := db.Emit(ctx, tx, core.UserCreated{User: *user}); err != nil {
+		return err
+	}
+	for _, provision := range db.userProvisioners {
+		if err := provision(ctx, tx, user); err != nil {
+			return fmt.Errorf("cannot provision user %v: %w", user.ID, err)
+		}
+	}
+	return nil
+}
+
+func (db *DB) provisionMember(
+	ctx context.Context,
+	tx pgx.Tx,
+	userID core.UserID,
+	orgID core.OrganisationID,
+) error {
+	err := sqlc.New(tx).AddUserToDefaultPermissionGroupsForOrganisation(
+		ctx,
+		int32(userID),
+		int32(orgID),
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"cannot assign the default permission groups of organisation %v: %w",
+			orgID,
+			ConvertPgError(err),
+		)
+	}
+	for _, provision := range db.memberProvisioners {
+		if err := provision(ctx, tx, userID, orgID); err != nil {
+			return fmt.Errorf("cannot provision user %v in organisation %v: %w", userID, orgID, err)
+		}
+	}
+	return nil
+}
+
+// AssignPermissionGroups returns a UserProvisioner that adds every new user to the specified global permission
+// groups. This is typically used with the DEFAULTPERMGROUP configuration setting.
+func AssignPermissionGroups(groupIDs ...permissions.PermissionGroupID) UserProvisioner {
+	return func(ctx context.Context, tx pgx.Tx, user *core.User) error {
+		q := sqlc.New(tx)
+		for _, groupID := range groupIDs {
+			if err := checkPermissionGroupOwner(ctx, q, groupID, nil); err != nil {
+				return err
+			}
+			if err := q.AddUserToPermissionGroup(ctx, int32(groupID), int32(user.ID)); err != nil {
+				return fmt.Errorf(
+					"cannot add user to default permission group %v: %w",
+					groupID,
+					ConvertPgError(err),
+				)
+			}
+		}
+		return nil
+	}
+}
+
+type beginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// runInTx runs fn in a transaction on the specified connection. If dbtx is already a transaction, this will use a
+// savepoint instead so that fn can still be rolled back on its own.
+func runInTx(ctx context.Context, dbtx sqlc.DBTX, fn func(tx pgx.Tx) error) error {
+	conn, ok := dbtx.(beginner)
+	if !ok {
+		return errors.New("cannot start a transaction on the specified connection")
+	}
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // See tx.Rollback() documentation
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
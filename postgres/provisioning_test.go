@@ -0,0 +1,131 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/login"
+	"github.com/prior-it/apollo/permissions"
+	"github.com/prior-it/apollo/postgres"
+	"github.com/prior-it/apollo/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvisioning(t *testing.T) {
+	db := tests.DB(t)
+	service := postgres.NewPermissionService(db)
+	userService := postgres.NewUserService(db)
+	orgService := postgres.NewOrganisationService(db)
+	accountService := postgres.NewOauthAccountService(db)
+	defer tests.DeleteAllPermissions(service)
+	defer tests.DeleteAllUsers(userService)
+	defer tests.DeleteAllOrganisations(orgService)
+	tests.Check(permissions.RegisterApolloPermissions(service))
+	ctx := context.Background()
+
+	defaultGroup, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+		Name:        "default",
+		Permissions: map[permissions.Permission]bool{permissions.PermViewOwnUser: true},
+	})
+	tests.Check(err)
+	db.OnUserCreated(postgres.AssignPermissionGroups(defaultGroup.ID))
+
+	t.Run("ok: default group for created users", func(t *testing.T) {
+		user := tests.CreateRegularUser(userService)
+		ok, err := service.HasAny(ctx, user.ID, permissions.PermViewOwnUser)
+		assert.Nil(t, err)
+		assert.True(t, ok, "New users should be added to the default permission group")
+	})
+
+	t.Run("ok: default group for created accounts", func(t *testing.T) {
+		user, err := accountService.CreateUserAccount(ctx, &login.UserData{
+			Name:       tests.Faker.Name(),
+			Email:      tests.Faker.Email(),
+			Lang:       "nl",
+			Provider:   "test",
+			ProviderID: tests.Faker.UUID(),
+		})
+		assert.Nil(t, err)
+		ok, err := service.HasAny(ctx, user.ID, permissions.PermViewOwnUser)
+		assert.Nil(t, err)
+		assert.True(t, ok, "New accounts should be added to the default permission group")
+	})
+
+	t.Run("ok: organisation default groups", func(t *testing.T) {
+		org, err := orgService.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		assert.Nil(t, err)
+		group, err := service.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+			OrganisationID: &org.ID,
+			Permissions:    map[permissions.Permission]bool{permissions.PermEditOwnUser: true},
+		})
+		assert.Nil(t, err)
+		err = service.SetDefaultPermissionGroupsForOrganisation(
+			ctx,
+			org.ID,
+			[]permissions.PermissionGroupID{group.ID},
+		)
+		assert.Nil(t, err)
+
+		defaults, err := service.ListDefaultPermissionGroupsForOrganisation(ctx, org.ID)
+		assert.Nil(t, err)
+		assert.Len(t, defaults, 1)
+
+		user := tests.CreateRegularUser(userService)
+		assert.Nil(t, orgService.AddUser(ctx, user.ID, org.ID))
+		ok, err := service.HasAnyForOrg(ctx, user.ID, org.ID, permissions.PermEditOwnUser)
+		assert.Nil(t, err)
+		assert.True(t, ok, "New members should be added to the organisation's default groups")
+
+		invitee := tests.CreateRegularUser(userService)
+		options := core.MembershipOptions{Status: core.MembershipPending}
+		_, err = orgService.AddMembership(ctx, invitee.ID, org.ID, options)
+		assert.Nil(t, err)
+		ok, err = service.HasAnyForOrg(ctx, invitee.ID, org.ID, permissions.PermEditOwnUser)
+		assert.Nil(t, err)
+		assert.False(t, ok, "Default groups should only take effect once the membership is active")
+		_, err = orgService.UpdateMembershipStatus(ctx, invitee.ID, org.ID, core.MembershipActive)
+		assert.Nil(t, err)
+		ok, err = service.HasAnyForOrg(ctx, invitee.ID, org.ID, permissions.PermEditOwnUser)
+		assert.Nil(t, err)
+		assert.True(t, ok, "Pending members should be added to the default groups as well")
+	})
+
+	t.Run("err: failing provisioner rolls back", func(t *testing.T) {
+		db := tests.DB(t)
+		userService := postgres.NewUserService(db)
+		orgService := postgres.NewOrganisationService(db)
+		defer tests.DeleteAllUsers(userService)
+		defer tests.DeleteAllOrganisations(orgService)
+		member := tests.CreateRegularUser(userService)
+		org, err := orgService.CreateOrganisation(ctx, tests.Faker.BS(), nil)
+		tests.Check(err)
+
+		errProvision := errors.New("provisioning failed")
+		db.OnUserCreated(func(_ context.Context, _ pgx.Tx, _ *core.User) error {
+			return errProvision
+		})
+		db.OnMemberAdded(
+			func(_ context.Context, _ pgx.Tx, _ core.UserID, _ core.OrganisationID) error {
+				return errProvision
+			},
+		)
+
+		email, err := core.ParseEmailAddress(tests.Faker.Email())
+		tests.Check(err)
+		user, err := userService.CreateUser(ctx, tests.Faker.Name(), *email, "nl")
+		assert.ErrorIs(t, err, errProvision)
+		assert.Nil(t, user)
+		amount, err := userService.GetAmountOfUsers(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), amount, "The user should not be created if provisioning fails")
+
+		err = orgService.AddUser(ctx, member.ID, org.ID)
+		assert.ErrorIs(t, err, errProvision)
+		members, err := orgService.ListUsersInOrganisation(ctx, org.ID, core.ListQuery{})
+		assert.Nil(t, err)
+		assert.Empty(t, members.Items, "The user should not be added if provisioning fails")
+	})
+}
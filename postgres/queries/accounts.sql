@@ -17,4 +17,12 @@ FROM
 WHERE
     accounts.provider = $1
     AND accounts.provider_id = $2
+    AND users.deleted_at IS NULL
 LIMIT 1;
+
+-- name: DeleteAccountOfDeletedUser :exec
+DELETE FROM accounts USING users
+WHERE accounts.user_id = users.id
+    AND accounts.provider = $1
+    AND accounts.provider_id = $2
+    AND users.deleted_at IS NOT NULL;
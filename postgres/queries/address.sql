@@ -31,9 +31,3 @@ WHERE
 	id = $1
 RETURNING
 	*;
-
--- name: ListAddresses :many
-SELECT
-	*
-FROM
-	address;
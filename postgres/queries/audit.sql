@@ -0,0 +1,13 @@
+-- name: CreateAuditEntry :exec
+INSERT INTO audit_log(actor_id, impersonator_id, action, entity_type, entity_id, changes)
+    VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: EraseAuditChanges :exec
+-- Keeps the history of an entity but removes the values that were recorded for it
+UPDATE
+    audit_log
+SET
+    changes = '{}'
+WHERE
+    entity_type = $1
+    AND entity_id = $2;
@@ -0,0 +1,31 @@
+-- name: TryCronLock :one
+-- Advisory locks are shared by every schema in the database, so the key includes the schema
+SELECT
+    pg_try_advisory_lock(hashtextextended(current_schema() || '.cron.' || @task::text, 0));
+
+-- name: CronUnlock :one
+SELECT
+    pg_advisory_unlock(hashtextextended(current_schema() || '.cron.' || @task::text, 0));
+
+-- name: StartCronRun :one
+-- Returns no rows if the scheduled time of the task already ran
+INSERT INTO cron_runs(task, scheduled_at, instance)
+    VALUES ($1, $2, $3)
+ON CONFLICT (task, scheduled_at)
+    DO NOTHING
+RETURNING
+    id;
+
+-- name: FinishCronRun :exec
+UPDATE
+    cron_runs
+SET
+    finished_at = NOW(),
+    error = $2
+WHERE
+    id = $1;
+
+-- name: DeleteOldCronRuns :exec
+DELETE FROM cron_runs
+WHERE task = $1
+    AND started_at < $2;
@@ -0,0 +1,31 @@
+-- name: CreateOutboxEvent :exec
+INSERT INTO event_outbox(event_type, payload, actor_id, impersonator_id)
+    VALUES ($1, $2, $3, $4);
+
+-- name: ClaimOutboxEvents :many
+-- Locks the events that are due, skipping the ones that another dispatcher is delivering
+SELECT
+    *
+FROM
+    event_outbox
+WHERE
+    available_at <= NOW()
+ORDER BY
+    id
+LIMIT $1
+FOR UPDATE
+    SKIP LOCKED;
+
+-- name: DeleteOutboxEvent :exec
+DELETE FROM event_outbox
+WHERE id = $1;
+
+-- name: RetryOutboxEvent :exec
+UPDATE
+    event_outbox
+SET
+    attempts = attempts + 1,
+    last_error = $2,
+    available_at = $3
+WHERE
+    id = $1;
@@ -0,0 +1,116 @@
+-- name: CreateJob :one
+-- Returns no rows if another pending or running job of the same kind has the same unique key
+INSERT INTO jobs(kind, payload, unique_key, run_at, max_attempts, actor_id, impersonator_id)
+    VALUES (@kind, @payload, @unique_key, @run_at, @max_attempts, @actor_id, @impersonator_id)
+ON CONFLICT (kind, unique_key)
+    WHERE status <> 'dead'
+        DO NOTHING
+    RETURNING
+        id;
+
+-- name: GetUniqueJobID :one
+SELECT
+    id
+FROM
+    jobs
+WHERE
+    kind = $1
+    AND unique_key = $2
+    AND status <> 'dead';
+
+-- name: ClaimJobs :many
+-- Locks the jobs that are due, skipping the ones that another worker is claiming.
+-- Running jobs whose lock expired are claimed again.
+UPDATE
+    jobs
+SET
+    status = 'running',
+    attempts = attempts + 1,
+    locked_until = @locked_until
+WHERE
+    id IN (
+        SELECT
+            id
+        FROM
+            jobs
+        WHERE
+            kind = ANY (@kinds::text[])
+            AND status <> 'dead'
+            AND ((status = 'pending'
+                    AND run_at <= NOW())
+                OR (status = 'running'
+                    AND locked_until < NOW()
+                    AND attempts < max_attempts))
+        ORDER BY
+            run_at,
+            id
+        LIMIT @max_jobs
+        FOR UPDATE
+            SKIP LOCKED)
+RETURNING
+    *;
+
+-- name: KillExpiredJobs :execrows
+-- Kills the running jobs whose lock expired on their last attempt, e.g. because they keep crashing their worker.
+UPDATE
+    jobs
+SET
+    status = 'dead',
+    last_error = 'lease expired',
+    locked_until = NULL,
+    died_at = NOW()
+WHERE
+    kind = ANY (@kinds::text[])
+    AND status = 'running'
+    AND locked_until < NOW()
+    AND attempts >= max_attempts;
+
+-- name: DeleteJob :execrows
+-- Only removes the job if the worker that ran this attempt still holds its lease
+DELETE FROM jobs
+WHERE id = @id
+    AND status = 'running'
+    AND attempts = @attempts;
+
+-- name: RetryJob :execrows
+UPDATE
+    jobs
+SET
+    status = 'pending',
+    run_at = @run_at,
+    last_error = @last_error,
+    locked_until = NULL
+WHERE
+    id = @id
+    AND status = 'running'
+    AND attempts = @attempts;
+
+-- name: KillJob :execrows
+UPDATE
+    jobs
+SET
+    status = 'dead',
+    last_error = @last_error,
+    locked_until = NULL,
+    died_at = NOW()
+WHERE
+    id = @id
+    AND status = 'running'
+    AND attempts = @attempts;
+
+-- name: RetryDeadJob :execrows
+UPDATE
+    jobs
+SET
+    status = 'pending',
+    run_at = NOW(),
+    attempts = 0,
+    died_at = NULL
+WHERE
+    id = $1
+    AND status = 'dead';
+
+-- name: DeleteDeadJob :execrows
+DELETE FROM jobs
+WHERE id = $1
+    AND status = 'dead';
@@ -0,0 +1,99 @@
+-- name: GrantUserObjectPermission :exec
+INSERT INTO user_object_permissions (user_id, permission, resource_type, resource_id)
+    VALUES ($1, $2, $3, $4)
+ON CONFLICT
+    DO NOTHING;
+
+-- name: RevokeUserObjectPermission :exec
+DELETE FROM user_object_permissions
+WHERE user_id = $1
+    AND permission = $2
+    AND resource_type = $3
+    AND resource_id = $4;
+
+-- name: GrantPermissionGroupObjectPermission :exec
+INSERT INTO permissiongroup_object_permissions (group_id, permission, resource_type, resource_id)
+    VALUES ($1, $2, $3, $4)
+ON CONFLICT
+    DO NOTHING;
+
+-- name: RevokePermissionGroupObjectPermission :exec
+DELETE FROM permissiongroup_object_permissions
+WHERE group_id = $1
+    AND permission = $2
+    AND resource_type = $3
+    AND resource_id = $4;
+
+-- name: HasObjectPermission :one
+-- Groups of soft deleted organisations no longer grant anything
+SELECT
+    EXISTS (
+        SELECT
+            1
+        FROM
+            user_object_permissions uop
+        WHERE
+            uop.user_id = sqlc.arg(user_id)
+            AND uop.permission = sqlc.arg(permission)
+            AND uop.resource_type = sqlc.arg(resource_type)
+            AND uop.resource_id = sqlc.arg(resource_id)
+        UNION ALL
+        SELECT
+            1
+        FROM
+            permissiongroup_object_permissions gop
+            INNER JOIN user_permissiongroup_membership usr ON usr.group_id = gop.group_id
+        WHERE
+            usr.user_id = sqlc.arg(user_id)
+            AND (usr.valid_from IS NULL
+                OR usr.valid_from <= NOW())
+            AND (usr.valid_until IS NULL
+                OR usr.valid_until > NOW())
+            AND gop.permission = sqlc.arg(permission)
+            AND gop.resource_type = sqlc.arg(resource_type)
+            AND gop.resource_id = sqlc.arg(resource_id)
+            AND NOT EXISTS (
+                SELECT
+                    1
+                FROM
+                    permissiongroups pg
+                    INNER JOIN organisations o ON o.id = pg.organisation_id
+                WHERE
+                    pg.id = gop.group_id
+                    AND o.deleted_at IS NOT NULL));
+
+-- name: ListObjectResourceIDsForUser :many
+-- Groups of soft deleted organisations no longer grant anything
+SELECT
+    uop.resource_id
+FROM
+    user_object_permissions uop
+WHERE
+    uop.user_id = sqlc.arg(user_id)
+    AND uop.permission = sqlc.arg(permission)
+    AND uop.resource_type = sqlc.arg(resource_type)
+UNION
+SELECT
+    gop.resource_id
+FROM
+    permissiongroup_object_permissions gop
+    INNER JOIN user_permissiongroup_membership usr ON usr.group_id = gop.group_id
+WHERE
+    usr.user_id = sqlc.arg(user_id)
+    AND (usr.valid_from IS NULL
+        OR usr.valid_from <= NOW())
+    AND (usr.valid_until IS NULL
+        OR usr.valid_until > NOW())
+    AND gop.permission = sqlc.arg(permission)
+    AND gop.resource_type = sqlc.arg(resource_type)
+    AND NOT EXISTS (
+        SELECT
+            1
+        FROM
+            permissiongroups pg
+            INNER JOIN organisations o ON o.id = pg.organisation_id
+        WHERE
+            pg.id = gop.group_id
+            AND o.deleted_at IS NOT NULL)
+ORDER BY
+    resource_id;
@@ -4,19 +4,16 @@ SELECT
 FROM
     organisations
 WHERE
-    id = $1;
-
--- name: ListOrganisations :many
-SELECT
-    *
-FROM
-    organisations;
+    id = $1
+    AND deleted_at IS NULL;
 
 -- name: GetAmountOfOrganisations :one
 SELECT
     COUNT(id)
 FROM
-    organisations;
+    organisations
+WHERE
+    deleted_at IS NULL;
 
 -- name: CreateOrganisation :one
 INSERT INTO organisations(name, parent_id)
@@ -31,20 +28,107 @@ SET
     name = $2
 WHERE
     id = $1
+    AND deleted_at IS NULL
 RETURNING
     *;
 
--- name: DeleteOrganisation :exec
+-- name: DeleteOrganisation :execrows
+-- Soft deletes the organisation and its descendants at once, so they can be restored together
+WITH RECURSIVE tree AS (
+    SELECT
+        o.id
+    FROM
+        organisations AS o
+    WHERE
+        o.id = $1
+        AND o.deleted_at IS NULL
+    UNION ALL
+    SELECT
+        child.id
+    FROM
+        organisations AS child
+        INNER JOIN tree AS t ON child.parent_id = t.id
+    WHERE
+        child.deleted_at IS NULL
+)
+UPDATE
+    organisations
+SET
+    deleted_at = NOW()
+WHERE
+    id IN (
+        SELECT
+            id
+        FROM
+            tree);
+
+-- name: RestoreOrganisation :execrows
+-- Restores the organisation and the descendants that were deleted together with it
+WITH RECURSIVE tree AS (
+    SELECT
+        o.id,
+        o.deleted_at
+    FROM
+        organisations AS o
+    WHERE
+        o.id = $1
+        AND o.deleted_at IS NOT NULL
+    UNION ALL
+    SELECT
+        child.id,
+        t.deleted_at
+    FROM
+        organisations AS child
+        INNER JOIN tree AS t ON child.parent_id = t.id
+    WHERE
+        child.deleted_at = t.deleted_at
+)
+UPDATE
+    organisations
+SET
+    deleted_at = NULL
+WHERE
+    id IN (
+        SELECT
+            id
+        FROM
+            tree);
+
+-- name: IsOrganisationDeleted :one
+SELECT
+    (deleted_at IS NOT NULL)::boolean AS deleted
+FROM
+    organisations
+WHERE
+    id = $1;
+
+-- name: PurgeOrganisation :exec
 DELETE FROM organisations
 WHERE id = $1;
 
+-- name: ListPurgeableOrganisations :many
+-- Only returns the roots of deleted subtrees, since purging those removes their descendants as well
+SELECT
+    o.id
+FROM
+    organisations AS o
+    LEFT JOIN organisations AS parent ON parent.id = o.parent_id
+WHERE
+    o.deleted_at < $1
+    AND (parent.id IS NULL
+        OR parent.deleted_at IS NULL
+        OR parent.deleted_at >= $1)
+ORDER BY
+    o.id;
+
 -- name: ListOrganisationChildren :many
 SELECT
     *
 FROM
     organisations
 WHERE
-    parent_id = $1;
+    parent_id = $1
+    AND deleted_at IS NULL;
 
 -- name: ListOrganisationsForUser :many
 SELECT
@@ -53,20 +137,95 @@ FROM
     organisations AS o
     INNER JOIN organisation_users AS ou ON o.id = ou.organisation_id
 WHERE
-    ou.user_id = $1;
+    ou.user_id = $1
+    AND ou.status = 'active'
+    AND o.deleted_at IS NULL;
+
+-- name: AddUserToOrganisation :exec
+INSERT INTO organisation_users(user_id, organisation_id)
+    VALUES ($1, $2);
+
+-- name: AddMembership :exec
+INSERT INTO organisation_users(user_id, organisation_id, status, role, invited_by)
+    VALUES ($1, $2, $3, $4, $5);
 
--- name: ListUsersInOrganisation :many
+-- name: GetMembership :one
 SELECT
-    u.*
+    sqlc.embed(u),
+    ou.organisation_id,
+    ou.joined_at,
+    ou.invited_by,
+    ou.role,
+    ou.status
 FROM
     users AS u
     INNER JOIN organisation_users AS ou ON u.id = ou.user_id
 WHERE
-    ou.organisation_id = $1;
+    ou.user_id = $1
+    AND ou.organisation_id = $2
+    AND u.deleted_at IS NULL;
 
--- name: AddUserToOrganisation :exec
-INSERT INTO organisation_users(user_id, organisation_id)
-    VALUES ($1, $2);
+-- name: ListMemberships :many
+SELECT
+    sqlc.embed(u),
+    ou.organisation_id,
+    ou.joined_at,
+    ou.invited_by,
+    ou.role,
+    ou.status
+FROM
+    users AS u
+    INNER JOIN organisation_users AS ou ON u.id = ou.user_id
+WHERE
+    ou.organisation_id = $1
+    AND u.deleted_at IS NULL
+ORDER BY
+    ou.joined_at,
+    u.id;
+
+-- name: ListMembershipsForUser :many
+SELECT
+    sqlc.embed(u),
+    ou.organisation_id,
+    ou.joined_at,
+    ou.invited_by,
+    ou.role,
+    ou.status
+FROM
+    users AS u
+    INNER JOIN organisation_users AS ou ON u.id = ou.user_id
+    INNER JOIN organisations AS o ON o.id = ou.organisation_id
+WHERE
+    ou.user_id = $1
+    AND u.deleted_at IS NULL
+    AND o.deleted_at IS NULL
+ORDER BY
+    ou.organisation_id;
+
+-- name: UpdateMembershipStatus :execrows
+UPDATE
+    organisation_users
+SET
+    status = sqlc.arg(status)::text,
+    -- Accepting an invitation is the moment the user actually joins
+    joined_at = CASE WHEN status = 'pending'
+        AND sqlc.arg(status)::text = 'active' THEN
+        NOW()
+    ELSE
+        joined_at
+    END
+WHERE
+    user_id = sqlc.arg(user_id)
+    AND organisation_id = sqlc.arg(organisation_id);
+
+-- name: UpdateMembershipRole :execrows
+UPDATE
+    organisation_users
+SET
+    role = $3
+WHERE
+    user_id = $1
+    AND organisation_id = $2;
 
 -- name: RemoveUserFromOrganisation :exec
 DELETE FROM organisation_users
@@ -89,7 +248,9 @@ FROM
     INNER JOIN organisation_users ON organisation_users.user_id = users.id
 WHERE
     organisation_users.organisation_id = $2
-    AND users.id = $1;
+    AND users.id = $1
+    AND organisation_users.status = 'active'
+    AND users.deleted_at IS NULL;
 
 -- name: GetMemberByEmail :one
 SELECT
@@ -99,4 +260,221 @@ FROM
     INNER JOIN organisation_users ON organisation_users.user_id = users.id
 WHERE
     organisation_users.organisation_id = $1
-    AND users.email = $2;
+    AND users.email = $2
+    AND organisation_users.status = 'active'
+    AND users.deleted_at IS NULL;
+
+-- name: MoveOrganisation :one
+UPDATE
+    organisations
+SET
+    parent_id = $2
+WHERE
+    id = $1
+    AND deleted_at IS NULL
+RETURNING
+    *;
+
+-- name: LockOrganisationHierarchy :exec
+SELECT
+    pg_advisory_xact_lock(hashtext('apollo_organisation_hierarchy'));
+
+-- name: ListOrganisationAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT
+        o.id,
+        o.name,
+        o.parent_id,
+        0 AS depth
+    FROM
+        organisations AS o
+    WHERE
+        o.id = $1
+    UNION ALL
+    SELECT
+        parent.id,
+        parent.name,
+        parent.parent_id,
+        a.depth + 1
+    FROM
+        organisations AS parent
+        INNER JOIN ancestors AS a ON parent.id = a.parent_id
+    WHERE
+        parent.deleted_at IS NULL
+)
+SELECT
+    id,
+    name,
+    parent_id,
+    depth::integer AS depth
+FROM
+    ancestors
+WHERE
+    depth > 0
+ORDER BY
+    depth;
+
+-- name: ListOrganisationDescendants :many
+WITH RECURSIVE descendants AS (
+    SELECT
+        o.id,
+        o.name,
+        o.parent_id,
+        1 AS depth
+    FROM
+        organisations AS o
+    WHERE
+        o.parent_id = $1
+        AND o.deleted_at IS NULL
+    UNION ALL
+    SELECT
+        child.id,
+        child.name,
+        child.parent_id,
+        d.depth + 1
+    FROM
+        organisations AS child
+        INNER JOIN descendants AS d ON child.parent_id = d.id
+    WHERE
+        child.deleted_at IS NULL
+)
+SELECT
+    id,
+    name,
+    parent_id,
+    depth::integer AS depth
+FROM
+    descendants
+ORDER BY
+    depth,
+    name,
+    id;
+
+-- name: CountOrganisationDescendants :one
+WITH RECURSIVE descendants AS (
+    SELECT
+        o.id
+    FROM
+        organisations AS o
+    WHERE
+        o.parent_id = $1
+        AND o.deleted_at IS NULL
+    UNION ALL
+    SELECT
+        child.id
+    FROM
+        organisations AS child
+        INNER JOIN descendants AS d ON child.parent_id = d.id
+    WHERE
+        child.deleted_at IS NULL
+)
+SELECT
+    COUNT(*)
+FROM
+    descendants;
+
+-- name: GetOrganisationBillingDetails :one
+SELECT
+    legal_name,
+    vat_number
+FROM
+    organisations
+WHERE
+    id = $1
+    AND deleted_at IS NULL;
+
+-- name: LockOrganisation :one
+SELECT
+    id
+FROM
+    organisations
+WHERE
+    id = $1
+    AND deleted_at IS NULL
+FOR UPDATE;
+
+-- name: UpdateOrganisationBillingDetails :exec
+UPDATE
+    organisations
+SET
+    legal_name = sqlc.narg(legal_name),
+    vat_number = sqlc.narg(vat_number)
+WHERE
+    id = sqlc.arg(id);
+
+-- name: ListOrganisationAddresses :many
+SELECT
+    sqlc.embed(a),
+    oa.type
+FROM
+    address AS a
+    INNER JOIN organisation_addresses AS oa ON a.id = oa.address_id
+WHERE
+    oa.organisation_id = $1
+ORDER BY
+    oa.type;
+
+-- name: AddOrganisationAddress :exec
+INSERT INTO organisation_addresses(organisation_id, address_id, type)
+    VALUES ($1, $2, $3);
+
+-- name: RemoveOrganisationAddress :one
+DELETE FROM organisation_addresses
+WHERE organisation_id = $1
+    AND type = $2
+RETURNING
+    address_id;
+
+-- name: DeleteOrganisationTreeAddresses :exec
+WITH RECURSIVE tree AS (
+    SELECT
+        o.id
+    FROM
+        organisations AS o
+    WHERE
+        o.id = $1
+    UNION ALL
+    SELECT
+        child.id
+    FROM
+        organisations AS child
+        INNER JOIN tree AS t ON child.parent_id = t.id
+)
+DELETE FROM address
+WHERE id IN (
+        SELECT
+            oa.address_id
+        FROM
+            organisation_addresses AS oa
+        WHERE
+            oa.organisation_id IN (
+                SELECT
+                    id
+                FROM
+                    tree));
+
+-- name: SearchOrganisations :many
+SELECT
+    sqlc.embed(o),
+    (ts_rank(o.search, websearch_to_tsquery('simple', sqlc.arg(query)::text)) + public.word_similarity(sqlc.arg(query)::text, o.name))::real AS rank
+FROM
+    organisations AS o
+WHERE (o.search @@ websearch_to_tsquery('simple', sqlc.arg(query)::text)
+    OR o.name ILIKE sqlc.arg(pattern)::text
+    OR sqlc.arg(query)::text OPERATOR(public.<%) o.name)
+AND o.deleted_at IS NULL
+AND (sqlc.narg(user_id)::integer IS NULL
+    OR EXISTS (
+        SELECT
+            1
+        FROM
+            organisation_users AS ou
+        WHERE
+            ou.organisation_id = o.id
+            AND ou.user_id = sqlc.narg(user_id)::integer
+            AND ou.status = 'active'))
+ORDER BY
+    rank DESC,
+    o.name,
+    o.id
+LIMIT sqlc.arg(max_results)::integer;
@@ -16,6 +16,17 @@ SELECT
 FROM
     permissiongroups;
 
+-- name: ListPermissionGroupsForOrganisation :many
+SELECT
+    permissiongroups.*
+FROM
+    permissiongroups
+WHERE
+    organisation_id IS NULL
+    OR organisation_id = $1
+ORDER BY
+    id;
+
 -- name: ListPermissionGroupsForUser :many
 SELECT
     pg.*
@@ -23,7 +34,11 @@ FROM
     permissiongroups pg
     INNER JOIN user_permissiongroup_membership usr ON usr.group_id = pg.id
 WHERE
-    usr.user_id = $1;
+    usr.user_id = $1
+    AND (usr.valid_from IS NULL
+        OR usr.valid_from <= NOW())
+    AND (usr.valid_until IS NULL
+        OR usr.valid_until > NOW());
 
 -- name: ListPermissionGroupsForUserForOrganisation :many
 SELECT
@@ -36,10 +51,17 @@ WHERE
         SELECT
             id
         FROM
-            organisation_users
+            organisation_users ou
+            INNER JOIN organisations o ON o.id = ou.organisation_id
+                AND o.deleted_at IS NULL
         WHERE
-            user_id = $1
-            AND organisation_id = $2);
+            ou.user_id = $1
+            AND ou.organisation_id = $2
+            AND ou.status = 'active')
+    AND (org_usr.valid_from IS NULL
+        OR org_usr.valid_from <= NOW())
+    AND (org_usr.valid_until IS NULL
+        OR org_usr.valid_until > NOW());
 
 -- name: GetPermissionsForGroup :many
 SELECT
@@ -58,15 +80,25 @@ FROM
 WHERE
     pg.id = $1;
 
+-- name: LockPermissionGroup :one
+-- Keeps the owner of the group from changing until the transaction ends
+SELECT
+    pg.organisation_id
+FROM
+    permissiongroups pg
+WHERE
+    pg.id = $1
+FOR SHARE;
+
 -- name: CreatePermissionGroup :one
-INSERT INTO permissiongroups (name)
-    VALUES ($1)
+INSERT INTO permissiongroups (name, organisation_id)
+    VALUES ($1, $2)
 RETURNING
     *;
 
 -- name: CreatePermissionGroupWithID :one
-INSERT INTO permissiongroups (id, name)
-    VALUES ($1, $2)
+INSERT INTO permissiongroups (id, name, organisation_id)
+    VALUES ($1, $2, $3)
 RETURNING
     *;
 
@@ -89,14 +121,21 @@ SET
 WHERE
     id = $1;
 
--- name: UpdatePermissionGroupPermission :exec
+-- name: RenamePermissionGroupForOrganisation :execrows
 UPDATE
-    permissiongroup_permissions
+    permissiongroups
 SET
-    enabled = $3
+    name = $3
 WHERE
-    group_id = $1
-    AND permission = $2;
+    id = $1
+    AND organisation_id = $2;
+
+-- name: UpdatePermissionGroupPermission :exec
+INSERT INTO permissiongroup_permissions (group_id, permission, enabled)
+    VALUES ($1, $2, $3)
+ON CONFLICT (group_id, permission)
+    DO UPDATE SET
+        enabled = EXCLUDED.enabled;
 
 -- name: AddUserToPermissionGroup :exec
 INSERT INTO user_permissiongroup_membership (group_id, user_id)
@@ -113,6 +152,136 @@ INSERT INTO organisation_users_permissiongroups (permission_group_id, organisati
                 user_id = $2
                 AND organisation_id = $3));
 
+-- name: AddUserToPermissionGroupWithValidity :exec
+INSERT INTO user_permissiongroup_membership (group_id, user_id, valid_from, valid_until)
+    VALUES ($1, $2, $3, $4)
+ON CONFLICT (group_id, user_id)
+    DO UPDATE SET
+        valid_from = EXCLUDED.valid_from,
+        valid_until = EXCLUDED.valid_until;
+
+-- name: AddUserToPermissionGroupForOrganisationWithValidity :exec
+INSERT INTO organisation_users_permissiongroups (permission_group_id, organisation_users_id, valid_from, valid_until)
+    VALUES (sqlc.arg(permission_group_id), (
+            SELECT
+                id
+            FROM
+                organisation_users
+            WHERE
+                user_id = sqlc.arg(user_id)
+                AND organisation_id = sqlc.arg(organisation_id)), sqlc.arg(valid_from), sqlc.arg(valid_until))
+ON CONFLICT (organisation_users_id, permission_group_id)
+    DO UPDATE SET
+        valid_from = EXCLUDED.valid_from,
+        valid_until = EXCLUDED.valid_until;
+
+-- name: DeleteExpiredPermissionGroupMemberships :execrows
+DELETE FROM user_permissiongroup_membership
+WHERE valid_until <= NOW();
+
+-- name: DeleteExpiredOrganisationPermissionGroupMemberships :execrows
+DELETE FROM organisation_users_permissiongroups
+WHERE valid_until <= NOW();
+
 -- name: DeletePermissionGroup :exec
 DELETE FROM permissiongroups
 WHERE permissiongroups.id = $1;
+
+-- name: DeletePermissionGroupForOrganisation :execrows
+DELETE FROM permissiongroups
+WHERE permissiongroups.id = $1
+    AND permissiongroups.organisation_id = $2;
+
+-- name: RemoveUserFromPermissionGroup :exec
+DELETE FROM user_permissiongroup_membership
+WHERE group_id = $1
+    AND user_id = $2;
+
+-- name: RemoveUserFromPermissionGroupForOrganisation :exec
+DELETE FROM organisation_users_permissiongroups
+WHERE permission_group_id = $1
+    AND organisation_users_id = (
+        SELECT
+            id
+        FROM
+            organisation_users
+        WHERE
+            user_id = $2
+            AND organisation_id = $3);
+
+-- name: RemoveUserFromAllPermissionGroups :exec
+DELETE FROM user_permissiongroup_membership
+WHERE user_id = $1;
+
+-- name: RemoveUserFromAllPermissionGroupsForOrganisation :exec
+DELETE FROM organisation_users_permissiongroups
+WHERE organisation_users_id = (
+        SELECT
+            id
+        FROM
+            organisation_users
+        WHERE
+            user_id = $1
+            AND organisation_id = $2);
+
+-- name: ListUsersInPermissionGroup :many
+SELECT
+    sqlc.embed(u),
+    usr.valid_from,
+    usr.valid_until
+FROM
+    users u
+    INNER JOIN user_permissiongroup_membership usr ON usr.user_id = u.id
+WHERE
+    usr.group_id = $1
+    AND u.deleted_at IS NULL
+ORDER BY
+    u.id;
+
+-- name: ListOrganisationUsersInPermissionGroup :many
+SELECT
+    sqlc.embed(u),
+    ou.organisation_id,
+    org_usr.valid_from,
+    org_usr.valid_until
+FROM
+    users u
+    INNER JOIN organisation_users ou ON ou.user_id = u.id
+    INNER JOIN organisation_users_permissiongroups org_usr ON org_usr.organisation_users_id = ou.id
+WHERE
+    org_usr.permission_group_id = $1
+    AND u.deleted_at IS NULL
+ORDER BY
+    ou.organisation_id,
+    u.id;
+
+-- name: ListDefaultPermissionGroupsForOrganisation :many
+SELECT
+    pg.*
+FROM
+    permissiongroups pg
+    INNER JOIN organisation_default_permissiongroups def ON def.permission_group_id = pg.id
+WHERE
+    def.organisation_id = $1;
+
+-- name: AddDefaultPermissionGroupForOrganisation :exec
+INSERT INTO organisation_default_permissiongroups (organisation_id, permission_group_id)
+    VALUES ($1, $2);
+
+-- name: RemoveAllDefaultPermissionGroupsForOrganisation :exec
+DELETE FROM organisation_default_permissiongroups
+WHERE organisation_id = $1;
+
+-- name: AddUserToDefaultPermissionGroupsForOrganisation :exec
+INSERT INTO organisation_users_permissiongroups (permission_group_id, organisation_users_id)
+SELECT
+    def.permission_group_id,
+    ou.id
+FROM
+    organisation_default_permissiongroups def
+    INNER JOIN organisation_users ou ON ou.organisation_id = def.organisation_id
+WHERE
+    ou.user_id = $1
+    AND ou.organisation_id = $2
+ON CONFLICT
+    DO NOTHING;
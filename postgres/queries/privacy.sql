@@ -0,0 +1,167 @@
+-- name: GetUserIncludingDeleted :one
+SELECT
+    *
+FROM
+    users
+WHERE
+    id = $1;
+
+-- name: ListAccountsForUser :many
+SELECT
+    provider,
+    provider_id
+FROM
+    accounts
+WHERE
+    user_id = $1
+ORDER BY
+    provider;
+
+-- name: ListUserDataMemberships :many
+SELECT
+    ou.organisation_id,
+    o.name AS organisation_name,
+    ou.status,
+    ou.role,
+    ou.joined_at,
+    ou.invited_by
+FROM
+    organisation_users AS ou
+    INNER JOIN organisations AS o ON o.id = ou.organisation_id
+WHERE
+    ou.user_id = $1
+ORDER BY
+    ou.organisation_id;
+
+-- name: ListUserDataPermissionGroups :many
+SELECT
+    pg.id,
+    pg.name,
+    NULL::integer AS organisation_id,
+    usr.valid_from,
+    usr.valid_until
+FROM
+    permissiongroups AS pg
+    INNER JOIN user_permissiongroup_membership AS usr ON usr.group_id = pg.id
+WHERE
+    usr.user_id = $1
+UNION ALL
+SELECT
+    pg.id,
+    pg.name,
+    ou.organisation_id,
+    org_usr.valid_from,
+    org_usr.valid_until
+FROM
+    permissiongroups AS pg
+    INNER JOIN organisation_users_permissiongroups AS org_usr ON org_usr.permission_group_id = pg.id
+    INNER JOIN organisation_users AS ou ON ou.id = org_usr.organisation_users_id
+WHERE
+    ou.user_id = $1
+ORDER BY
+    organisation_id NULLS FIRST,
+    id;
+
+-- name: ListUserDataAccountCache :many
+SELECT
+    ac.*
+FROM
+    account_cache AS ac
+WHERE
+    ac.email = sqlc.arg(email)::text
+    OR EXISTS (
+        SELECT
+            1
+        FROM
+            accounts AS a
+        WHERE
+            a.user_id = sqlc.arg(user_id)
+            AND a.provider = ac.provider
+            AND a.provider_id = ac.provider_id)
+ORDER BY
+    ac.created;
+
+-- name: ListUserDataAddresses :many
+SELECT
+    sqlc.embed(a),
+    oa.organisation_id,
+    oa.type
+FROM
+    address AS a
+    INNER JOIN organisation_addresses AS oa ON a.id = oa.address_id
+    INNER JOIN organisation_users AS ou ON ou.organisation_id = oa.organisation_id
+WHERE
+    ou.user_id = $1
+    AND ou.status = 'active'
+ORDER BY
+    oa.organisation_id,
+    oa.type;
+
+-- name: DeleteUserDataAccountCache :execrows
+DELETE FROM account_cache AS ac
+WHERE ac.email = sqlc.arg(email)::text
+    OR EXISTS (
+        SELECT
+            1
+        FROM
+            accounts AS a
+        WHERE
+            a.user_id = sqlc.arg(user_id)
+            AND a.provider = ac.provider
+            AND a.provider_id = ac.provider_id);
+
+-- name: DeleteAccountsForUser :exec
+DELETE FROM accounts
+WHERE user_id = $1;
+
+-- name: RemoveUserFromAllOrganisationPermissionGroups :exec
+DELETE FROM organisation_users_permissiongroups
+WHERE organisation_users_id IN (
+        SELECT
+            id
+        FROM
+            organisation_users
+        WHERE
+            user_id = $1);
+
+-- name: RevokeAllUserObjectPermissions :exec
+DELETE FROM user_object_permissions
+WHERE user_id = $1;
+
+-- name: LeaveAllOrganisations :exec
+UPDATE
+    organisation_users
+SET
+    status = 'left',
+    role = NULL
+WHERE
+    user_id = $1;
+
+-- name: AnonymiseUser :exec
+UPDATE
+    users
+SET
+    name = sqlc.arg(name)::text,
+    email = sqlc.arg(email)::text,
+    admin = FALSE,
+    deleted_at = COALESCE(deleted_at, NOW()),
+    anonymised_at = NOW()
+WHERE
+    id = sqlc.arg(id);
+
+-- name: CreateUserErasure :one
+INSERT INTO user_erasures(user_id, mode, requested_by, reason)
+    VALUES ($1, $2, $3, $4)
+RETURNING
+    *;
+
+-- name: ListUserErasures :many
+SELECT
+    *
+FROM
+    user_erasures
+WHERE
+    user_id = $1
+ORDER BY
+    erased_at,
+    id;
@@ -5,21 +5,16 @@ FROM
     users
 WHERE
     id = $1
+    AND deleted_at IS NULL
 LIMIT 1;
 
--- name: ListUsers :many
-SELECT
-    *
-FROM
-    users
-ORDER BY
-    RANDOM();
-
 -- name: GetAmountOfUsers :one
 SELECT
     COUNT(*)
 FROM
-    users;
+    users
+WHERE
+    deleted_at IS NULL;
 
 -- name: CreateUser :one
 INSERT INTO users (name, email, lang)
@@ -27,17 +22,43 @@ INSERT INTO users (name, email, lang)
 RETURNING
     *;
 
--- name: DeleteUser :exec
+-- name: DeleteUser :execrows
+UPDATE
+    users
+SET
+    deleted_at = NOW()
+WHERE
+    id = $1
+    AND deleted_at IS NULL;
+
+-- name: RestoreUser :execrows
+UPDATE
+    users
+SET
+    deleted_at = NULL
+WHERE
+    id = $1
+    AND deleted_at IS NOT NULL;
+
+-- name: PurgeUser :execrows
 DELETE FROM users
 WHERE id = $1;
 
+-- name: PurgeDeletedUsers :many
+DELETE FROM users
+WHERE deleted_at < $1
+    AND anonymised_at IS NULL
+RETURNING
+    id;
+
 -- name: UpdateUserAdmin :exec
 UPDATE
     users
 SET
     admin = $2
 WHERE
-    id = $1;
+    id = $1
+    AND deleted_at IS NULL;
 
 -- name: UpdateUser :one
 UPDATE
@@ -48,5 +69,43 @@ SET
     lang = COALESCE(sqlc.narg (lang), lang)
 WHERE
     id = $1
+    AND deleted_at IS NULL
 RETURNING
     *;
+
+-- name: GetUserByEmail :one
+SELECT
+    *
+FROM
+    users
+WHERE
+    email = $1
+    AND deleted_at IS NULL
+LIMIT 1;
+
+-- name: SearchUsers :many
+SELECT
+    sqlc.embed(u),
+    (ts_rank(u.search, websearch_to_tsquery('simple', sqlc.arg(query)::text)) + GREATEST(public.word_similarity(sqlc.arg(query)::text, u.name), public.word_similarity(sqlc.arg(query)::text, u.email)))::real AS rank
+FROM
+    users AS u
+WHERE (u.search @@ websearch_to_tsquery('simple', sqlc.arg(query)::text)
+    OR u.name ILIKE sqlc.arg(pattern)::text
+    OR u.email ILIKE sqlc.arg(pattern)::text
+    OR sqlc.arg(query)::text OPERATOR(public.<%) u.name)
+AND u.deleted_at IS NULL
+AND (sqlc.narg(organisation_id)::integer IS NULL
+    OR EXISTS (
+        SELECT
+            1
+        FROM
+            organisation_users AS ou
+        WHERE
+            ou.user_id = u.id
+            AND ou.organisation_id = sqlc.narg(organisation_id)::integer
+            AND ou.status = 'active'))
+ORDER BY
+    rank DESC,
+    u.name,
+    u.id
+LIMIT sqlc.arg(max_results)::integer;
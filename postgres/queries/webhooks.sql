@@ -0,0 +1,162 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints(organisation_id, url, secret, events)
+    VALUES ($1, $2, $3, $4)
+RETURNING
+    *;
+
+-- name: GetWebhookEndpoint :one
+SELECT
+    *
+FROM
+    webhook_endpoints
+WHERE
+    id = $1
+    AND organisation_id = $2;
+
+-- name: ListWebhookEndpoints :many
+SELECT
+    *
+FROM
+    webhook_endpoints
+WHERE
+    organisation_id = $1
+ORDER BY
+    id;
+
+-- name: ListWebhookEndpointsForEvent :many
+-- Only the enabled endpoints of organisations that were not deleted receive events
+SELECT
+    webhook_endpoints.*
+FROM
+    webhook_endpoints
+    INNER JOIN organisations ON webhook_endpoints.organisation_id = organisations.id
+WHERE
+    webhook_endpoints.organisation_id = $1
+    AND webhook_endpoints.enabled
+    AND organisations.deleted_at IS NULL
+    AND (cardinality(webhook_endpoints.events) = 0
+        OR @event_type::text = ANY (webhook_endpoints.events))
+ORDER BY
+    webhook_endpoints.id;
+
+-- name: UpdateWebhookEndpoint :one
+UPDATE
+    webhook_endpoints
+SET
+    url = $3,
+    events = $4,
+    enabled = $5
+WHERE
+    id = $1
+    AND organisation_id = $2
+RETURNING
+    *;
+
+-- name: DeleteWebhookEndpoint :exec
+DELETE FROM webhook_endpoints
+WHERE id = $1
+    AND organisation_id = $2;
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries(endpoint_id, event_id, event_type, payload)
+    VALUES ($1, $2, $3, $4)
+ON CONFLICT (endpoint_id, event_id)
+    DO NOTHING;
+
+-- name: GetWebhookDelivery :one
+SELECT
+    webhook_deliveries.*
+FROM
+    webhook_deliveries
+    INNER JOIN webhook_endpoints ON webhook_deliveries.endpoint_id = webhook_endpoints.id
+WHERE
+    webhook_deliveries.id = $1
+    AND webhook_endpoints.organisation_id = $2;
+
+-- name: ClaimWebhookDeliveries :many
+-- Leases the deliveries that are due, skipping the ones that another sender is claiming.
+-- Deliveries whose lease expired are claimed again. Deliveries to disabled endpoints or of deleted organisations wait
+-- until the endpoint is enabled or the organisation is restored.
+UPDATE
+    webhook_deliveries
+SET
+    status = 'sending',
+    locked_until = @locked_until
+FROM
+    webhook_endpoints
+WHERE
+    webhook_deliveries.endpoint_id = webhook_endpoints.id
+    AND webhook_deliveries.id IN (
+        SELECT
+            d.id
+        FROM
+            webhook_deliveries d
+            INNER JOIN webhook_endpoints e ON d.endpoint_id = e.id
+            INNER JOIN organisations o ON e.organisation_id = o.id
+        WHERE
+            e.enabled
+            AND o.deleted_at IS NULL
+            AND ((d.status = 'pending'
+                    AND d.next_attempt_at <= NOW())
+                OR (d.status = 'sending'
+                    AND d.locked_until < NOW()))
+        ORDER BY
+            d.next_attempt_at,
+            d.id
+        LIMIT @max_deliveries
+        FOR UPDATE
+            OF d SKIP LOCKED)
+RETURNING
+    webhook_deliveries.id,
+    webhook_deliveries.event_id,
+    webhook_deliveries.event_type,
+    webhook_deliveries.payload,
+    webhook_deliveries.attempts,
+    webhook_endpoints.url,
+    webhook_endpoints.secret;
+
+-- name: CreateWebhookAttempt :exec
+INSERT INTO webhook_attempts(delivery_id, response_code, error, duration_ms)
+    VALUES ($1, $2, $3, $4);
+
+-- name: UpdateWebhookDeliveryAttempt :execrows
+-- Only updates the delivery while the sender still holds its lease
+UPDATE
+    webhook_deliveries
+SET
+    attempts = attempts + 1,
+    status = @status,
+    locked_until = NULL,
+    response_code = @response_code,
+    last_error = @last_error,
+    next_attempt_at = @next_attempt_at,
+    delivered_at = @delivered_at
+WHERE
+    id = @id
+    AND status = 'sending'
+    AND locked_until = @locked_until;
+
+-- name: ReplayWebhookDelivery :one
+UPDATE
+    webhook_deliveries
+SET
+    status = 'pending',
+    attempts = 0,
+    next_attempt_at = NOW(),
+    locked_until = NULL,
+    delivered_at = NULL
+WHERE
+    id = $1
+RETURNING
+    *;
+
+-- name: ListWebhookAttempts :many
+SELECT
+    *
+FROM
+    webhook_attempts
+WHERE
+    delivery_id = $1
+ORDER BY
+    attempted_at,
+    id;
@@ -0,0 +1,271 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/postgres/internal/sqlc"
+)
+
+// DefaultCronHistory is how long the runs of scheduled tasks are kept.
+const DefaultCronHistory = 30 * 24 * time.Hour
+
+var ErrDuplicateCronTask = errors.New("duplicate cron task")
+
+type cronTask struct {
+	name     string
+	schedule *core.CronSchedule
+	task     core.CronTask
+}
+
+// Schedule registers a task that runs periodically according to the cron expression in spec, which is interpreted in
+// UTC (see core.ParseCronSchedule for the syntax). The name identifies the task in the run history and across all
+// instances of the application, so it should not change between deploys.
+// Every scheduled time of the task runs on exactly one instance, and a run that is still busy at the next scheduled
+// time makes that time be skipped instead of running the task twice at once.
+// You should schedule all tasks while bootstrapping, before a Scheduler is started.
+//
+// # Example
+//
+//	err := db.Schedule("reports.daily", "0 6 * * *", func(ctx context.Context) error {
+//		return reports.SendDaily(ctx)
+//	})
+func (db *DB) Schedule(name string, spec string, task core.CronTask) error {
+	schedule, err := core.ParseCronSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("could not schedule %q: %w", name, err)
+	}
+	for _, other := range db.cronTasks {
+		if other.name == name {
+			return fmt.Errorf("%w: %q", ErrDuplicateCronTask, name)
+		}
+	}
+	db.cronTasks = append(db.cronTasks, cronTask{name, schedule, task})
+	return nil
+}
+
+func NewScheduler(DB *DB) *Scheduler {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return &Scheduler{
+		db:       DB,
+		instance: hostname + ":" + strconv.Itoa(os.Getpid()),
+		history:  DefaultCronHistory,
+	}
+}
+
+// Scheduler runs the tasks that were registered with DB.Schedule. Every instance of the application can start a
+// scheduler: postgres advisory locks make sure that each scheduled time of a task only runs on one of them.
+// Every run is recorded, together with its error if it failed, and can be listed with ListCronRuns.
+// Scheduled times that pass while no scheduler is running are not caught up on.
+type Scheduler struct {
+	db       *DB
+	instance string
+	history  time.Duration
+	lock     sync.Mutex
+	// Stops waiting for the next scheduled times
+	stop context.CancelFunc
+	// Cancels the tasks that are running
+	cancelTasks context.CancelFunc
+	running     sync.WaitGroup
+}
+
+// Force struct to implement the core interface
+var _ core.CronHistory = &Scheduler{}
+
+// SetHistory changes how long the runs of scheduled tasks are kept. The default is DefaultCronHistory.
+func (s *Scheduler) SetHistory(history time.Duration) {
+	s.history = history
+}
+
+// Start runs every scheduled task in the background at its scheduled times, until the context is cancelled or the
+// scheduler is stopped. Call Stop to wait for the tasks that are running when the application stops.
+//
+// # Example
+//
+//	scheduler := postgres.NewScheduler(db)
+//	scheduler.Start(ctx)
+//	server.OnShutdown(scheduler.Stop)
+func (s *Scheduler) Start(ctx context.Context) {
+	waitCtx, stop := context.WithCancel(ctx)
+	// Running tasks are only cancelled when stopping takes too long, not when the scheduler stops waiting
+	tasksCtx, cancelTasks := context.WithCancel(context.WithoutCancel(ctx))
+	s.lock.Lock()
+	s.stop, s.cancelTasks = stop, cancelTasks
+	s.lock.Unlock()
+
+	for _, task := range s.db.cronTasks {
+		s.running.Add(1)
+		go func() {
+			defer s.running.Done()
+			for {
+				next := task.schedule.Next(time.Now().UTC())
+				if next.IsZero() {
+					spec := task.schedule.String()
+					slog.Warn("Scheduled task never runs", "task", task.name, "schedule", spec)
+					return
+				}
+				timer := time.NewTimer(time.Until(next))
+				select {
+				case <-waitCtx.Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+					if _, err := s.tick(tasksCtx, task, next); err != nil {
+						slog.Error("Could not run scheduled task", "task", task.name, "error", err)
+					}
+				}
+			}
+		}()
+	}
+}
+
+// Stop stops scheduling tasks and waits for the running tasks to finish. If the context ends first, the running tasks
+// are cancelled.
+func (s *Scheduler) Stop(ctx context.Context) {
+	s.lock.Lock()
+	stop, cancelTasks := s.stop, s.cancelTasks
+	s.lock.Unlock()
+	if stop == nil {
+		return
+	}
+	stop()
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("Cancelling running scheduled tasks", "error", ctx.Err())
+		cancelTasks()
+	}
+}
+
+// Tick runs the task with the specified name for a scheduled time, as if that time just arrived, and returns whether
+// it ran. The task does not run if another instance is running it or if it already ran for that time.
+// This is mostly useful in tests, use Start to run tasks at their scheduled times.
+func (s *Scheduler) Tick(ctx context.Context, name string, scheduledAt time.Time) (bool, error) {
+	for _, task := range s.db.cronTasks {
+		if task.name == name {
+			return s.tick(ctx, task, scheduledAt)
+		}
+	}
+	return false, fmt.Errorf("could not find scheduled task %q: %w", name, core.ErrNotFound)
+}
+
+// tick runs a task for a scheduled time while holding its advisory lock and records the run.
+func (s *Scheduler) tick(ctx context.Context, task cronTask, scheduledAt time.Time) (bool, error) {
+	// Advisory locks belong to a session, so the lock, the run and the unlock share a single connection
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("could not acquire connection: %w", err)
+	}
+	defer conn.Release()
+	queries := sqlc.New(conn)
+	locked, err := queries.TryCronLock(ctx, task.name)
+	if err != nil {
+		return false, fmt.Errorf("could not lock scheduled task: %w", ConvertPgError(err))
+	}
+	if !locked {
+		slog.Debug("Scheduled task is running elsewhere", "task", task.name)
+		return false, nil
+	}
+	defer func() {
+		ctx := context.WithoutCancel(ctx)
+		if _, err := queries.CronUnlock(ctx, task.name); err != nil {
+			err = ConvertPgError(err)
+			slog.Error("Could not unlock scheduled task", "task", task.name, "error", err)
+			// Closing the session releases its locks, and keeps the connection out of the pool
+			_ = conn.Conn().Close(ctx)
+		}
+	}()
+
+	id, err := queries.StartCronRun(ctx, sqlc.StartCronRunParams{
+		Task:        task.name,
+		ScheduledAt: toTimestamptz(&scheduledAt),
+		Instance:    s.instance,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Another instance already ran the task for this time
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not record scheduled task run: %w", ConvertPgError(err))
+	}
+
+	var message *string
+	if err := s.run(ctx, task); err != nil {
+		slog.Error("Scheduled task failed", "task", task.name, "error", err)
+		text := err.Error()
+		message = &text
+	}
+	// Record the result even if the task was cancelled
+	ctx = context.WithoutCancel(ctx)
+	if err := queries.FinishCronRun(ctx, id, message); err != nil {
+		return true, fmt.Errorf("could not record scheduled task result: %w", ConvertPgError(err))
+	}
+	before := time.Now().Add(-s.history)
+	if err := queries.DeleteOldCronRuns(ctx, task.name, toTimestamptz(&before)); err != nil {
+		return true, fmt.Errorf("could not delete old scheduled task runs: %w", ConvertPgError(err))
+	}
+	return true, nil
+}
+
+// run calls a scheduled task, turning panics into errors.
+func (s *Scheduler) run(ctx context.Context, task cronTask) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panicked: %v", r)
+		}
+	}()
+	return task.task(ctx)
+}
+
+// ListCronRuns implements core.CronHistory.ListCronRuns
+func (s *Scheduler) ListCronRuns(
+	ctx context.Context,
+	query core.ListQuery,
+) (*core.Page[core.CronRun], error) {
+	return cronRunList.list(ctx, s.db, query)
+}
+
+var cronRunList = &keysetList[core.CronRun, sqlc.CronRun]{
+	columns: "r.id, r.task, r.scheduled_at, r.started_at, r.finished_at, r.error, r.instance",
+	from:    "cron_runs AS r",
+	id:      "r.id",
+	sorts: map[string]sortColumn[core.CronRun]{
+		"id": {"r.id", "integer", func(run *core.CronRun) string { return run.ID.String() }},
+		"time": {"r.started_at", "timestamptz", func(run *core.CronRun) string {
+			return run.StartedAt.Format(time.RFC3339Nano)
+		}},
+	},
+	defaultSort: "time",
+	filters: map[string]listFilter{
+		"task":   equalsFilter("r.task", parseText),
+		"failed": equalsFilter("(r.error IS NOT NULL)", strconv.ParseBool),
+	},
+	convert: func(run sqlc.CronRun) (*core.CronRun, error) {
+		return &core.CronRun{
+			ID:          core.CronRunID(run.ID),
+			Task:        run.Task,
+			ScheduledAt: run.ScheduledAt.Time,
+			StartedAt:   run.StartedAt.Time,
+			FinishedAt:  fromTimestamptz(run.FinishedAt),
+			Error:       run.Error,
+			Instance:    run.Instance,
+		}, nil
+	},
+	itemID: func(run *core.CronRun) core.ID { return run.ID },
+}
@@ -0,0 +1,128 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/postgres"
+	"github.com/prior-it/apollo/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	db := tests.DB(t)
+	ctx := context.Background()
+
+	var runs atomic.Int32
+	tests.Check(db.Schedule("test.count", "@hourly", func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}))
+	tests.Check(db.Schedule("test.fail", "@daily", func(context.Context) error {
+		return errors.New("failure")
+	}))
+	tests.Check(db.Schedule("test.panic", "@daily", func(context.Context) error {
+		panic("oops")
+	}))
+	blocked := make(chan struct{})
+	release := make(chan struct{})
+	tests.Check(db.Schedule("test.block", "@daily", func(context.Context) error {
+		close(blocked)
+		<-release
+		return nil
+	}))
+	scheduler := postgres.NewScheduler(db)
+	other := postgres.NewScheduler(db)
+	scheduledAt := time.Now().UTC().Truncate(time.Minute)
+
+	t.Run("err: invalid schedule", func(t *testing.T) {
+		noop := func(context.Context) error { return nil }
+		err := db.Schedule("test.invalid", "every minute", noop)
+		assert.ErrorIs(t, err, core.ErrInvalidCronSchedule)
+	})
+
+	t.Run("err: duplicate task", func(t *testing.T) {
+		err := db.Schedule("test.count", "@daily", func(context.Context) error { return nil })
+		assert.ErrorIs(t, err, postgres.ErrDuplicateCronTask)
+	})
+
+	t.Run("err: unknown task", func(t *testing.T) {
+		_, err := scheduler.Tick(ctx, "test.unknown", scheduledAt)
+		assert.ErrorIs(t, err, core.ErrNotFound)
+	})
+
+	t.Run("ok: run every scheduled time once", func(t *testing.T) {
+		ran, err := scheduler.Tick(ctx, "test.count", scheduledAt)
+		assert.Nil(t, err)
+		assert.True(t, ran)
+		ran, err = other.Tick(ctx, "test.count", scheduledAt)
+		assert.Nil(t, err)
+		assert.False(t, ran, "Another instance should not run the same scheduled time again")
+		ran, err = other.Tick(ctx, "test.count", scheduledAt.Add(time.Hour))
+		assert.Nil(t, err)
+		assert.True(t, ran)
+		assert.Equal(t, int32(2), runs.Load())
+
+		query := core.ListQuery{Filters: map[string]string{"task": "test.count"}}
+		page, err := scheduler.ListCronRuns(ctx, query)
+		assert.Nil(t, err)
+		if assert.Len(t, page.Items, 2) {
+			for _, run := range page.Items {
+				assert.Equal(t, "test.count", run.Task)
+				assert.NotNil(t, run.FinishedAt)
+				assert.Nil(t, run.Error)
+				assert.NotEmpty(t, run.Instance)
+			}
+		}
+	})
+
+	t.Run("ok: record failures", func(t *testing.T) {
+		ran, err := scheduler.Tick(ctx, "test.fail", scheduledAt)
+		assert.Nil(t, err, "A failing task should only be recorded")
+		assert.True(t, ran)
+		ran, err = scheduler.Tick(ctx, "test.panic", scheduledAt)
+		assert.Nil(t, err, "A panicking task should only be recorded")
+		assert.True(t, ran)
+
+		query := core.ListQuery{Filters: map[string]string{"failed": "true"}}
+		page, err := scheduler.ListCronRuns(ctx, query)
+		assert.Nil(t, err)
+		if assert.Len(t, page.Items, 2) {
+			for _, run := range page.Items {
+				assert.NotNil(t, run.Error)
+			}
+		}
+	})
+
+	t.Run("ok: skip tasks that are running elsewhere", func(t *testing.T) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, err := scheduler.Tick(ctx, "test.block", scheduledAt)
+			tests.Check(err)
+		}()
+		<-blocked
+		ran, err := other.Tick(ctx, "test.block", scheduledAt.Add(24*time.Hour))
+		assert.Nil(t, err)
+		assert.False(t, ran, "A task should not run on two instances at once")
+		close(release)
+		<-done
+
+		query := core.ListQuery{Filters: map[string]string{"task": "test.block"}}
+		page, err := scheduler.ListCronRuns(ctx, query)
+		assert.Nil(t, err)
+		assert.Len(t, page.Items, 1)
+	})
+
+	t.Run("ok: stop", func(t *testing.T) {
+		scheduler.Start(ctx)
+		stopCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		scheduler.Stop(stopCtx)
+		assert.Nil(t, stopCtx.Err(), "Stop should not wait for the next scheduled time")
+	})
+}
@@ -0,0 +1,376 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prior-it/apollo/core"
+)
+
+// ErrUnknownTenant is returned when a request does not belong to an existing tenant.
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// TenantResolver returns the schema of the tenant that the request belongs to.
+// An empty schema means that the request does not belong to a tenant and should use the default schema.
+type TenantResolver func(r *http.Request) (string, error)
+
+// Tenancy stores the data of every tenant in its own postgres schema.
+//
+// Apollo's own tables, e.g. users, organisations and permissions, are shared by all tenants and stay in the default
+// schema. Tenant schemas only contain the tables of the app's tenant migrations.
+// Every request that belongs to a tenant acquires its own connection from the pool and sets the search path of that
+// connection to the tenant's schema followed by the default schema, so all services that use the DB transparently
+// read and write the tenant's tables and the shared tables. This means that a tenant request holds a connection for its entire duration, so the size of the pool
+// limits the amount of concurrent tenant requests. A tenant context uses a single connection, so it should not be
+// used by multiple goroutines at the same time.
+type Tenancy struct {
+	db         *DB
+	prefix     string
+	migrations *embed.FS
+	folder     string
+	isDebug    bool
+	// The search path of the pool, which contains the shared tables
+	shared string
+	// Schemas that are known to exist
+	known sync.Map
+}
+
+// NewTenancy creates a new tenancy for the database. Tenant schemas are named after their tenant, prefixed with the
+// specified prefix, e.g. "tenant_". This returns ErrInvalidSchemaName if the prefix is not a valid schema name.
+// The migrations are the app migrations that run in every tenant schema, pass them the same way as you would for
+// DB.Migrate. Unlike DB.Migrate, they do not include the Apollo migrations, which only run in the default schema.
+func NewTenancy(
+	db *DB,
+	prefix string,
+	migrations *embed.FS,
+	folder string,
+	isDebug bool,
+) (*Tenancy, error) {
+	if err := ValidateSchemaName(prefix); err != nil {
+		return nil, fmt.Errorf("invalid tenant prefix: %w", err)
+	}
+	tenancy := &Tenancy{
+		db:         db,
+		prefix:     prefix,
+		migrations: migrations,
+		folder:     folder,
+		isDebug:    isDebug,
+	}
+	if db != nil {
+		tenancy.shared = db.Pool.Config().ConnConfig.RuntimeParams["search_path"]
+	}
+	return tenancy, nil
+}
+
+// Schema returns the schema of the tenant with the specified name.
+// This returns ErrInvalidSchemaName if the resulting schema name would not be valid.
+func (t *Tenancy) Schema(tenant string) (string, error) {
+	schema := t.prefix + strings.ReplaceAll(strings.ToLower(tenant), "-", "_")
+	if err := ValidateSchemaName(schema); err != nil {
+		return "", err
+	}
+	return schema, nil
+}
+
+// OrganisationSchema returns the schema of the tenant that belongs to the specified organisation.
+// This returns ErrInvalidSchemaName if the resulting schema name would not be valid.
+func (t *Tenancy) OrganisationSchema(orgID core.OrganisationID) (string, error) {
+	schema := fmt.Sprintf("%s%d", t.prefix, orgID)
+	if err := ValidateSchemaName(schema); err != nil {
+		return "", err
+	}
+	return schema, nil
+}
+
+// CreateTenant creates the tenant's schema if it does not exist yet and runs the app's tenant migrations in it.
+func (t *Tenancy) CreateTenant(ctx context.Context, schema string) error {
+	if err := ValidateSchemaName(schema); err != nil {
+		return err
+	}
+	_, err := t.db.Pool.Exec(ctx, "CREATE SCHEMA IF NOT EXISTS "+QuoteIdentifier(schema))
+	if err != nil {
+		return fmt.Errorf("cannot create tenant schema %q: %w", schema, err)
+	}
+	return t.Migrate(ctx, schema)
+}
+
+// Migrate runs the app's tenant migrations in the schema of a single tenant.
+// The schema's migration history is stored in the schema itself.
+func (t *Tenancy) Migrate(ctx context.Context, schema string) error {
+	if t.migrations == nil {
+		t.known.Store(schema, true)
+		return nil
+	}
+	slog.Info("Migrating tenant", "schema", schema)
+	pool, err := t.tenantPool(ctx, schema)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	provider, err := createTenantGooseProvider(pool, schema, t.migrations, t.folder, t.isDebug)
+	if err != nil {
+		return fmt.Errorf("cannot create goose provider: %w", err)
+	}
+	if _, err := provider.Up(ctx); err != nil {
+		return fmt.Errorf("cannot run database migrations for tenant %q: %w", schema, err)
+	}
+	if err := provider.Close(); err != nil {
+		return fmt.Errorf("cannot close goose provider connection: %w", err)
+	}
+	t.known.Store(schema, true)
+	return nil
+}
+
+// MigrateAll runs the migrations in every existing tenant schema.
+func (t *Tenancy) MigrateAll(ctx context.Context) error {
+	schemas, err := t.ListTenants(ctx)
+	if err != nil {
+		return err
+	}
+	for _, schema := range schemas {
+		if err := t.Migrate(ctx, schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListTenants returns the schemas of all existing tenants.
+func (t *Tenancy) ListTenants(ctx context.Context) ([]string, error) {
+	rows, err := t.db.Pool.Query(
+		ctx,
+		"SELECT nspname FROM pg_namespace WHERE starts_with(nspname, $1) ORDER BY nspname",
+		t.prefix,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list tenant schemas: %w", err)
+	}
+	schemas, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("cannot list tenant schemas: %w", err)
+	}
+	return schemas, nil
+}
+
+// DeleteTenant deletes the tenant's schema, beware that this will delete all of the tenant's data.
+func (t *Tenancy) DeleteTenant(ctx context.Context, schema string) error {
+	t.known.Delete(schema)
+	return t.db.DeleteSchema(ctx, schema)
+}
+
+// WithTenant calls fn with a context in which all queries through the DB use the tenant's schema, and the default
+// schema for the shared tables.
+// The connection is acquired for the duration of fn and is returned to the pool with its original search path.
+// This returns ErrUnknownTenant if the tenant's schema does not exist.
+func (t *Tenancy) WithTenant(
+	ctx context.Context,
+	schema string,
+	fn func(ctx context.Context) error,
+) error {
+	if err := ValidateSchemaName(schema); err != nil {
+		return errors.Join(ErrUnknownTenant, err)
+	}
+	if err := t.checkExists(ctx, schema); err != nil {
+		return err
+	}
+	conn, err := t.db.Pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot acquire connection for tenant %q: %w", schema, err)
+	}
+	defer func() {
+		// Never hand out a connection that still uses the tenant's schema
+		if _, err := conn.Exec(context.Background(), "RESET search_path"); err != nil {
+			slog.Error("Cannot reset tenant search path, closing connection", "error", err)
+			raw := conn.Hijack()
+			raw.Close(context.Background()) //nolint:errcheck // The connection is broken anyway
+			return
+		}
+		conn.Release()
+	}()
+	if _, err := conn.Exec(ctx, "SET search_path TO "+t.searchPath(schema)); err != nil {
+		return fmt.Errorf("cannot set search path for tenant %q: %w", schema, err)
+	}
+	return fn(context.WithValue(ctx, ctxTenantConn, conn))
+}
+
+// Middleware returns a standard HTTP middleware that runs every request in the schema of the tenant that is returned
+// by the resolver. Requests for unknown tenants receive a 404 response.
+// If the resolver uses the active organisation, this should be attached after the session middleware.
+func (t *Tenancy) Middleware(resolve TenantResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			schema, err := resolve(r)
+			if errors.Is(err, ErrUnknownTenant) {
+				http.NotFound(w, r)
+				return
+			} else if err != nil {
+				slog.Error("Cannot resolve tenant", "error", err)
+				internalServerError(w)
+				return
+			}
+			if len(schema) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			err = t.WithTenant(r.Context(), schema, func(ctx context.Context) error {
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return nil
+			})
+			if errors.Is(err, ErrUnknownTenant) {
+				http.NotFound(w, r)
+			} else if err != nil {
+				slog.Error("Cannot switch to tenant", "schema", schema, "error", err)
+				internalServerError(w)
+			}
+		})
+	}
+}
+
+// SubdomainTenant resolves the tenant from the subdomain of the request's host, e.g. "acme.example.com" belongs to
+// the tenant "acme" if domain is "example.com". Requests to the domain itself do not belong to a tenant.
+// Hosts are compared case-insensitively and without their port, for the domain as well as the request.
+func (t *Tenancy) SubdomainTenant(domain string) TenantResolver {
+	if d, _, err := net.SplitHostPort(domain); err == nil {
+		domain = d
+	}
+	domain = strings.ToLower(domain)
+	return func(r *http.Request) (string, error) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(host)
+		if host == domain {
+			return "", nil
+		}
+		subdomain, ok := strings.CutSuffix(host, "."+domain)
+		if !ok || strings.Contains(subdomain, ".") {
+			return "", fmt.Errorf("%w: host %q", ErrUnknownTenant, host)
+		}
+		schema, err := t.Schema(subdomain)
+		if err != nil {
+			return "", errors.Join(ErrUnknownTenant, err)
+		}
+		return schema, nil
+	}
+}
+
+// OrganisationTenant resolves the tenant from the active organisation that is returned by the specified function.
+// Requests without an active organisation do not belong to a tenant.
+func (t *Tenancy) OrganisationTenant(
+	activeOrganisation func(r *http.Request) *core.OrganisationID,
+) TenantResolver {
+	return func(r *http.Request) (string, error) {
+		orgID := activeOrganisation(r)
+		if orgID == nil {
+			return "", nil
+		}
+		schema, err := t.OrganisationSchema(*orgID)
+		if err != nil {
+			return "", errors.Join(ErrUnknownTenant, err)
+		}
+		return schema, nil
+	}
+}
+
+func internalServerError(w http.ResponseWriter) {
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+func (t *Tenancy) checkExists(ctx context.Context, schema string) error {
+	if _, ok := t.known.Load(schema); ok {
+		return nil
+	}
+	var exists bool
+	err := t.db.Pool.QueryRow(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)",
+		schema,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("cannot check tenant schema %q: %w", schema, err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: %q", ErrUnknownTenant, schema)
+	}
+	t.known.Store(schema, true)
+	return nil
+}
+
+// searchPath returns the search path of a tenant: its own schema, followed by the schema with the shared tables.
+func (t *Tenancy) searchPath(schema string) string {
+	path := QuoteIdentifier(schema)
+	if len(t.shared) > 0 {
+		path += ", " + t.shared
+	}
+	return path
+}
+
+// tenantPool creates a separate, short-lived pool whose connections use the tenant's search path.
+func (t *Tenancy) tenantPool(ctx context.Context, schema string) (*pgxpool.Pool, error) {
+	if err := ValidateSchemaName(schema); err != nil {
+		return nil, err
+	}
+	cfg := t.db.Pool.Config()
+	cfg.ConnConfig.RuntimeParams["search_path"] = t.searchPath(schema)
+	cfg.MaxConns = 1
+	cfg.MinConns = 0
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to tenant schema %q: %w", schema, err)
+	}
+	return pool, nil
+}
+
+type tenantContextKey uint
+
+const ctxTenantConn tenantContextKey = iota
+
+// tenantConn returns the connection of the tenant that is active in the context, if any.
+func tenantConn(ctx context.Context) *pgxpool.Conn {
+	conn, _ := ctx.Value(ctxTenantConn).(*pgxpool.Conn)
+	return conn
+}
+
+// Exec executes the query on the connection of the context, see DB.InTx and Tenancy.WithTenant.
+func (db *DB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return db.conn(ctx).Exec(ctx, sql, args...)
+}
+
+// Query executes the query on the connection of the context, see DB.InTx and Tenancy.WithTenant.
+func (db *DB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return db.conn(ctx).Query(ctx, sql, args...)
+}
+
+// QueryRow executes the query on the connection of the context, see DB.InTx and Tenancy.WithTenant.
+func (db *DB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return db.conn(ctx).QueryRow(ctx, sql, args...)
+}
+
+// Begin starts a transaction on the connection of the context, see DB.InTx and Tenancy.WithTenant.
+// Inside the transaction of DB.InTx, this creates a savepoint instead.
+func (db *DB) Begin(ctx context.Context) (pgx.Tx, error) {
+	return db.conn(ctx).Begin(ctx)
+}
+
+// BeginTx starts a transaction with the specified options on the connection of the context, see DB.InTx and
+// Tenancy.WithTenant. Inside the transaction of DB.InTx, this creates a savepoint instead and ignores the options.
+func (db *DB) BeginTx(ctx context.Context, options pgx.TxOptions) (pgx.Tx, error) {
+	if tx := contextTx(ctx); tx != nil {
+		return tx.Begin(ctx)
+	}
+	if conn := tenantConn(ctx); conn != nil {
+		return conn.BeginTx(ctx, options)
+	}
+	return db.Pool.BeginTx(ctx, options)
+}
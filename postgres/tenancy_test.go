@@ -0,0 +1,212 @@
+package postgres_test
+
+import (
+	"context"
+	"embed"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/permissions"
+	"github.com/prior-it/apollo/postgres"
+	"github.com/prior-it/apollo/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+//go:embed testdata/tenant/*.sql
+var tenantMigrations embed.FS
+
+func TestSchemaNames(t *testing.T) {
+	for _, valid := range []string{"public", "tenant_1", "_private", "tests_123"} {
+		assert.Nil(t, postgres.ValidateSchemaName(valid), valid)
+	}
+	invalid := []string{"", "1tenant", "Tenant", "pg_catalog", "a; DROP TABLE users", `a"b`}
+	for _, schema := range invalid {
+		err := postgres.ValidateSchemaName(schema)
+		assert.ErrorIs(t, err, postgres.ErrInvalidSchemaName, schema)
+	}
+	assert.Equal(t, `"tenant_1"`, postgres.QuoteIdentifier("tenant_1"))
+}
+
+func TestTenantResolvers(t *testing.T) {
+	tenancy, err := postgres.NewTenancy(nil, "tenant_", nil, "", true)
+	tests.Check(err)
+
+	t.Run("err: invalid prefix", func(t *testing.T) {
+		t.Parallel()
+		for _, prefix := range []string{"", "1_", "pg_", `tenant"`} {
+			_, err := postgres.NewTenancy(nil, prefix, nil, "", true)
+			assert.ErrorIs(t, err, postgres.ErrInvalidSchemaName, prefix)
+		}
+	})
+
+	t.Run("ok: subdomain", func(t *testing.T) {
+		t.Parallel()
+		resolve := tenancy.SubdomainTenant("example.com")
+		for host, expected := range map[string]string{
+			"example.com":           "",
+			"acme.example.com":      "tenant_acme",
+			"big-corp.example.com":  "tenant_big_corp",
+			"ACME.example.com:3000": "tenant_acme",
+		} {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Host = host
+			schema, err := resolve(request)
+			assert.Nil(t, err, host)
+			assert.Equal(t, expected, schema, host)
+		}
+	})
+
+	t.Run("ok: subdomain of a domain with a port", func(t *testing.T) {
+		t.Parallel()
+		resolve := tenancy.SubdomainTenant("LocalHost:8080")
+		for host, expected := range map[string]string{
+			"localhost:8080":      "",
+			"acme.localhost:8080": "tenant_acme",
+			"acme.localhost":      "tenant_acme",
+		} {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Host = host
+			schema, err := resolve(request)
+			assert.Nil(t, err, host)
+			assert.Equal(t, expected, schema, host)
+		}
+	})
+
+	t.Run("err: invalid subdomain", func(t *testing.T) {
+		t.Parallel()
+		resolve := tenancy.SubdomainTenant("example.com")
+		for _, host := range []string{"other.com", "a.b.example.com", "x;y.example.com"} {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Host = host
+			_, err := resolve(request)
+			assert.ErrorIs(t, err, postgres.ErrUnknownTenant, host)
+		}
+	})
+
+	t.Run("ok: organisation", func(t *testing.T) {
+		t.Parallel()
+		orgID := core.OrganisationID(42)
+		resolve := tenancy.OrganisationTenant(func(_ *http.Request) *core.OrganisationID {
+			return &orgID
+		})
+		schema, err := resolve(httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Nil(t, err)
+		assert.Equal(t, "tenant_42", schema)
+	})
+
+	t.Run("err: organisation schema too long", func(t *testing.T) {
+		t.Parallel()
+		tenancy, err := postgres.NewTenancy(nil, strings.Repeat("t", 60), nil, "", true)
+		tests.Check(err)
+		orgID := core.OrganisationID(12345)
+		resolve := tenancy.OrganisationTenant(func(_ *http.Request) *core.OrganisationID {
+			return &orgID
+		})
+		_, err = resolve(httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.ErrorIs(t, err, postgres.ErrUnknownTenant)
+	})
+}
+
+func TestTenancy(t *testing.T) {
+	db := tests.DB(t)
+	ctx := context.Background()
+	tenancy, err := postgres.NewTenancy(
+		db,
+		"tenant_test_",
+		&tenantMigrations,
+		"testdata/tenant",
+		true,
+	)
+	tests.Check(err)
+	schema, err := tenancy.Schema(tests.Faker.LetterN(8))
+	assert.Nil(t, err)
+	assert.Nil(t, tenancy.CreateTenant(ctx, schema))
+	defer func() { tests.Check(tenancy.DeleteTenant(ctx, schema)) }()
+	userService := postgres.NewUserService(db)
+	orgService := postgres.NewOrganisationService(db)
+	permissionService := postgres.NewPermissionService(db)
+	defer tests.DeleteAllPermissions(permissionService)
+	defer tests.DeleteAllUsers(userService)
+	defer tests.DeleteAllOrganisations(orgService)
+
+	org, err := orgService.CreateOrganisation(ctx, tests.Faker.Company(), nil)
+	tests.Check(err)
+
+	t.Run("ok: tenant data is separated", func(t *testing.T) {
+		err := tenancy.WithTenant(ctx, schema, func(ctx context.Context) error {
+			query := "INSERT INTO notes (organisation_id, text) VALUES ($1, 'tenant')"
+			_, err := db.Exec(ctx, query, org.ID)
+			return err
+		})
+		assert.Nil(t, err)
+
+		_, err = db.Exec(ctx, "SELECT 1 FROM notes")
+		assert.NotNil(t, err, "Tenant tables should not exist in the default schema")
+
+		err = tenancy.WithTenant(ctx, schema, func(ctx context.Context) error {
+			var count int
+			err := db.QueryRow(ctx, "SELECT count(*) FROM notes").Scan(&count)
+			assert.Equal(t, 1, count)
+			return err
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("ok: apollo data is shared", func(t *testing.T) {
+		email, err := core.ParseEmailAddress(tests.Faker.Email())
+		tests.Check(err)
+		user, err := userService.CreateUser(ctx, tests.Faker.Name(), *email, "nl")
+		tests.Check(err)
+		tests.Check(orgService.AddUser(ctx, user.ID, org.ID))
+		group, err := permissionService.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+			Name:        "members",
+			Permissions: map[permissions.Permission]bool{permissions.PermViewOwnUser: true},
+		})
+		tests.Check(err)
+		tests.Check(permissionService.AddUserToPermissionGroup(ctx, user.ID, group.ID))
+
+		err = tenancy.WithTenant(ctx, schema, func(ctx context.Context) error {
+			tenantUser, err := userService.GetUser(ctx, user.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, user.ID, tenantUser.ID)
+			_, err = orgService.GetMembership(ctx, user.ID, org.ID)
+			assert.Nil(t, err)
+			ok, err := permissionService.HasAny(ctx, user.ID, permissions.PermViewOwnUser)
+			assert.Nil(t, err)
+			assert.True(t, ok, "Permission checks should use the shared permission groups")
+			return nil
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("ok: migrate all tenants", func(t *testing.T) {
+		assert.Nil(t, tenancy.MigrateAll(ctx))
+		// The tenant's migration history should not be mixed up with the history of the default schema
+		assert.Nil(t, db.Migrate(nil, "", true))
+	})
+
+	t.Run("ok: middleware", func(t *testing.T) {
+		handler := tenancy.Middleware(func(_ *http.Request) (string, error) {
+			return schema, nil
+		})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := db.Exec(r.Context(), "SELECT 1 FROM notes")
+			assert.Nil(t, err)
+			_, err = orgService.GetOrganisation(r.Context(), org.ID)
+			assert.Nil(t, err)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+	})
+
+	t.Run("err: unknown tenant", func(t *testing.T) {
+		err := tenancy.WithTenant(ctx, "tenant_test_missing", func(_ context.Context) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, postgres.ErrUnknownTenant)
+	})
+}
@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prior-it/apollo/postgres/internal/sqlc"
+)
+
+const (
+	// The amount of times that a transaction is retried after a serialization failure or deadlock
+	maxTxRetries = 3
+	// The maximum delay before a transaction is retried, multiplied by the attempt
+	txRetryDelay = 20 * time.Millisecond
+)
+
+type txContextKey uint
+
+const ctxTx txContextKey = iota
+
+// contextTx returns the transaction of DB.InTx that is active in the context, if any.
+func contextTx(ctx context.Context) pgx.Tx {
+	tx, _ := ctx.Value(ctxTx).(pgx.Tx)
+	return tx
+}
+
+// querier is a connection on which queries can run and transactions can start.
+type querier interface {
+	sqlc.DBTX
+	beginner
+}
+
+// conn returns the connection that queries with the specified context run on: the transaction of DB.InTx, the active
+// tenant's connection or the pool, in that order.
+func (db *DB) conn(ctx context.Context) querier {
+	if tx := contextTx(ctx); tx != nil {
+		return tx
+	}
+	if conn := tenantConn(ctx); conn != nil {
+		return conn
+	}
+	return db.Pool
+}
+
+// InTx runs fn in a transaction that is stored in the context that fn receives. Every query through the DB with that
+// context is part of the transaction, so fn can combine the methods of all postgres services into a single unit of
+// work. The transaction commits if fn returns nil and rolls back otherwise.
+//
+// Calling InTx again inside fn creates a savepoint instead, which only rolls back the nested function if it fails.
+// If the transaction fails with a serialization failure or a deadlock, fn runs again in a new transaction, up to three
+// times. fn should therefore return the errors of the database as-is (or wrapped) and should not have side effects
+// outside of the database, use events or background jobs for those instead.
+// A transaction uses a single connection, so fn should not run queries from multiple goroutines at the same time.
+//
+// # Example
+//
+//	err := db.InTx(ctx, func(ctx context.Context) error {
+//		user, err := users.CreateUser(ctx, userCreate)
+//		if err != nil {
+//			return err
+//		}
+//		return organisations.AddUser(ctx, user.ID, orgID)
+//	})
+func (db *DB) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return db.InTxWithOptions(ctx, pgx.TxOptions{}, fn)
+}
+
+// InTxWithOptions works like InTx, but starts the transaction with the specified options, e.g. to use serializable
+// isolation. The options are ignored when this creates a savepoint in a transaction that is already active.
+func (db *DB) InTxWithOptions(
+	ctx context.Context,
+	options pgx.TxOptions,
+	fn func(ctx context.Context) error,
+) error {
+	if tx := contextTx(ctx); tx != nil {
+		return runInContextTx(ctx, tx.Begin, fn)
+	}
+	begin := func(ctx context.Context) (pgx.Tx, error) {
+		return db.BeginTx(ctx, options)
+	}
+	for attempt := 1; ; attempt++ {
+		err := runInContextTx(ctx, begin, fn)
+		if err == nil || attempt > maxTxRetries || !isRetryableTxError(err) {
+			return err
+		}
+		slog.Debug("Retrying transaction", "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(rand.N(txRetryDelay) * time.Duration(attempt)):
+		}
+	}
+}
+
+// runInContextTx runs fn in a transaction that is started by begin and stored in the context.
+func runInContextTx(
+	ctx context.Context,
+	begin func(ctx context.Context) (pgx.Tx, error),
+	fn func(ctx context.Context) error,
+) error {
+	tx, err := begin(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // See tx.Rollback() documentation
+	if err := fn(context.WithValue(ctx, ctxTx, tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+// isRetryableTxError returns true if the error means that the transaction can succeed if it runs again.
+func isRetryableTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == pgerrcode.SerializationFailure || pgErr.Code == pgerrcode.DeadlockDetected
+}
@@ -0,0 +1,142 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/permissions"
+	"github.com/prior-it/apollo/postgres"
+	"github.com/prior-it/apollo/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInTx(t *testing.T) {
+	db := tests.DB(t)
+	userService := postgres.NewUserService(db)
+	orgService := postgres.NewOrganisationService(db)
+	permissionService := postgres.NewPermissionService(db)
+	defer tests.DeleteAllPermissions(permissionService)
+	defer tests.DeleteAllUsers(userService)
+	defer tests.DeleteAllOrganisations(orgService)
+	ctx := context.Background()
+
+	org, err := orgService.CreateOrganisation(ctx, tests.Faker.Company(), nil)
+	tests.Check(err)
+	group, err := permissionService.CreatePermissionGroup(ctx, &permissions.PermissionGroup{
+		Name:        "members",
+		Permissions: map[permissions.Permission]bool{permissions.PermViewOwnUser: true},
+	})
+	tests.Check(err)
+
+	createUser := func(ctx context.Context) (*core.User, error) {
+		email, err := core.ParseEmailAddress(tests.Faker.Email())
+		tests.Check(err)
+		return userService.CreateUser(ctx, tests.Faker.Name(), *email, "nl")
+	}
+
+	t.Run("ok: commit every service in a single transaction", func(t *testing.T) {
+		var user *core.User
+		err := db.InTx(ctx, func(ctx context.Context) error {
+			var err error
+			if user, err = createUser(ctx); err != nil {
+				return err
+			}
+			// Other contexts should not see uncommitted data
+			_, err = userService.GetUser(context.Background(), user.ID)
+			assert.ErrorIs(t, err, core.ErrNotFound)
+			if err := orgService.AddUser(ctx, user.ID, org.ID); err != nil {
+				return err
+			}
+			return permissionService.AddUserToPermissionGroup(ctx, user.ID, group.ID)
+		})
+		assert.Nil(t, err)
+
+		_, err = orgService.GetMembership(ctx, user.ID, org.ID)
+		assert.Nil(t, err)
+		ok, err := permissionService.HasAny(ctx, user.ID, permissions.PermViewOwnUser)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("ok: roll back every service on error", func(t *testing.T) {
+		var user *core.User
+		failure := errors.New("failure")
+		err := db.InTx(ctx, func(ctx context.Context) error {
+			var err error
+			if user, err = createUser(ctx); err != nil {
+				return err
+			}
+			if err := orgService.AddUser(ctx, user.ID, org.ID); err != nil {
+				return err
+			}
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+		_, err = userService.GetUser(ctx, user.ID)
+		assert.ErrorIs(t, err, core.ErrNotFound)
+	})
+
+	t.Run("ok: nested transactions use savepoints", func(t *testing.T) {
+		var outer, inner *core.User
+		err := db.InTx(ctx, func(ctx context.Context) error {
+			var err error
+			if outer, err = createUser(ctx); err != nil {
+				return err
+			}
+			err = db.InTx(ctx, func(ctx context.Context) error {
+				var err error
+				if inner, err = createUser(ctx); err != nil {
+					return err
+				}
+				return errors.New("failure")
+			})
+			assert.NotNil(t, err)
+			return nil
+		})
+		assert.Nil(t, err)
+		_, err = userService.GetUser(ctx, outer.ID)
+		assert.Nil(t, err, "The outer transaction should commit")
+		_, err = userService.GetUser(ctx, inner.ID)
+		assert.ErrorIs(t, err, core.ErrNotFound, "The savepoint should be rolled back")
+	})
+
+	t.Run("ok: retry serialization failures", func(t *testing.T) {
+		attempts := 0
+		err := db.InTx(ctx, func(ctx context.Context) error {
+			attempts++
+			if _, err := createUser(ctx); err != nil {
+				return err
+			}
+			if attempts == 1 {
+				return &pgconn.PgError{Code: pgerrcode.SerializationFailure}
+			}
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, attempts)
+	})
+
+	t.Run("err: stop retrying", func(t *testing.T) {
+		attempts := 0
+		err := db.InTx(ctx, func(context.Context) error {
+			attempts++
+			return &pgconn.PgError{Code: pgerrcode.DeadlockDetected}
+		})
+		assert.NotNil(t, err)
+		assert.Equal(t, 4, attempts, "A transaction should be retried three times")
+	})
+
+	t.Run("err: do not retry other errors", func(t *testing.T) {
+		attempts := 0
+		err := db.InTx(ctx, func(context.Context) error {
+			attempts++
+			return &pgconn.PgError{Code: pgerrcode.UniqueViolation}
+		})
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, attempts)
+	})
+}
@@ -2,20 +2,23 @@ package postgres
 
 import (
 	"context"
-	"errors"
+	"strconv"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/prior-it/apollo/core"
 	"github.com/prior-it/apollo/postgres/internal/sqlc"
 )
 
 func NewUserService(DB *DB) *UserService {
 	q := sqlc.New(DB)
-	return &UserService{q}
+	return &UserService{DB, q}
 }
 
 // Postgres implementation of the core UserService interface.
 type UserService struct {
-	q *sqlc.Queries
+	db *DB
+	q  *sqlc.Queries
 }
 
 // Force struct to implement the core interface
@@ -28,20 +31,90 @@ func (u *UserService) CreateUser(
 	email core.EmailAddress,
 	lang string,
 ) (*core.User, error) {
-	user, err := u.q.CreateUser(ctx, sqlc.CreateUserParams{
-		Name:  name,
-		Email: email.String(),
-		Lang:  lang,
+	var user *core.User
+	err := runInTx(ctx, u.db, func(tx pgx.Tx) error {
+		dbUser, err := u.q.WithTx(tx).CreateUser(ctx, sqlc.CreateUserParams{
+			Name:  name,
+			Email: email.String(),
+			Lang:  lang,
+		})
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		user, err = convertUser(dbUser)
+		if err != nil {
+			return err
+		}
+		return u.db.provisionUser(ctx, tx, user)
 	})
 	if err != nil {
-		return nil, ConvertPgError(err)
+		return nil, err
 	}
-	return convertUser(user)
+	return user, nil
 }
 
 // DeleteUser implements core.UserService.
 func (u *UserService) DeleteUser(ctx context.Context, id core.UserID) error {
-	return u.q.DeleteUser(ctx, int32(id))
+	return runInTx(ctx, u.db, func(tx pgx.Tx) error {
+		amount, err := sqlc.New(tx).DeleteUser(ctx, int32(id))
+		if err != nil || amount == 0 {
+			return ConvertPgError(err)
+		}
+		return recordAudit(ctx, tx, userAudit(core.AuditDelete, id))
+	})
+}
+
+// RestoreUser implements core.UserService.
+func (u *UserService) RestoreUser(ctx context.Context, id core.UserID) error {
+	return runInTx(ctx, u.db, func(tx pgx.Tx) error {
+		amount, err := sqlc.New(tx).RestoreUser(ctx, int32(id))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		if amount == 0 {
+			return core.ErrNotFound
+		}
+		return recordAudit(ctx, tx, userAudit(core.AuditRestore, id))
+	})
+}
+
+// PurgeUser implements core.UserService.
+func (u *UserService) PurgeUser(ctx context.Context, id core.UserID) error {
+	return runInTx(ctx, u.db, func(tx pgx.Tx) error {
+		amount, err := sqlc.New(tx).PurgeUser(ctx, int32(id))
+		if err != nil || amount == 0 {
+			return ConvertPgError(err)
+		}
+		return recordAudit(ctx, tx, userAudit(core.AuditPurge, id))
+	})
+}
+
+// PurgeDeletedUsers implements core.UserService.
+func (u *UserService) PurgeDeletedUsers(ctx context.Context, before time.Time) (uint64, error) {
+	var amount uint64
+	err := runInTx(ctx, u.db, func(tx pgx.Tx) error {
+		ids, err := sqlc.New(tx).PurgeDeletedUsers(ctx, toTimestamptz(&before))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		for _, id := range ids {
+			err := recordAudit(ctx, tx, userAudit(core.AuditPurge, core.UserID(id)))
+			if err != nil {
+				return err
+			}
+		}
+		amount = uint64(len(ids))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return amount, nil
+}
+
+// userAudit describes a mutation of a user that does not change any of its fields.
+func userAudit(action core.AuditAction, id core.UserID) core.AuditRecord {
+	return core.AuditRecord{Action: action, EntityType: core.AuditUser, EntityID: id.String()}
 }
 
 // GetAmountOfUsers implements core.UserService.
@@ -62,18 +135,81 @@ func (u *UserService) GetUser(ctx context.Context, id core.UserID) (*core.User,
 	return convertUser(user)
 }
 
-// ListUsers implements core.UserService.
-func (u *UserService) ListUsers(ctx context.Context) ([]core.User, error) {
-	users, err := u.q.ListUsers(ctx)
+// GetUserByEmail implements core.UserService.
+func (u *UserService) GetUserByEmail(
+	ctx context.Context,
+	email core.EmailAddress,
+) (*core.User, error) {
+	user, err := u.q.GetUserByEmail(ctx, email.String())
 	if err != nil {
 		return nil, ConvertPgError(err)
 	}
-	return convertUserList(users)
+	return convertUser(user)
+}
+
+// SearchUsers implements core.UserService.
+func (u *UserService) SearchUsers(
+	ctx context.Context,
+	query core.SearchQuery,
+) ([]core.SearchResult[core.User], error) {
+	if query.IsEmpty() {
+		return []core.SearchResult[core.User]{}, nil
+	}
+	var orgID *int32
+	if query.OrganisationID != nil {
+		id := int32(*query.OrganisationID)
+		orgID = &id
+	}
+	rows, err := u.q.SearchUsers(ctx, sqlc.SearchUsersParams{
+		Query:          query.Text,
+		Pattern:        containsPattern(query.Text),
+		OrganisationID: orgID,
+		MaxResults:     int32(query.PageSize()),
+	})
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	results := make([]core.SearchResult[core.User], len(rows))
+	for i, row := range rows {
+		user, err := convertUser(row.User)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = core.SearchResult[core.User]{
+			Item: *user,
+			Rank: row.Rank,
+			Highlights: map[string]core.Highlight{
+				"name":  core.HighlightText(user.Name, query.Text),
+				"email": core.HighlightText(user.Email.String(), query.Text),
+			},
+		}
+	}
+	return results, nil
+}
+
+// ListUsers implements core.UserService.
+func (u *UserService) ListUsers(
+	ctx context.Context,
+	query core.ListQuery,
+) (*core.Page[core.User], error) {
+	return userList("users AS u").list(ctx, u.db, query)
 }
 
 // UpdateUserAdmin implements core.UserService.
 func (u *UserService) UpdateUserAdmin(ctx context.Context, id core.UserID, admin bool) error {
-	return u.q.UpdateUserAdmin(ctx, int32(id), admin)
+	return runInTx(ctx, u.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		before, err := queries.GetUser(ctx, int32(id))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		if err := queries.UpdateUserAdmin(ctx, int32(id), admin); err != nil {
+			return ConvertPgError(err)
+		}
+		after := before
+		after.Admin = admin
+		return recordUserUpdate(ctx, tx, before, after)
+	})
 }
 
 // UpdateUser implements core.UserService.
@@ -82,16 +218,79 @@ func (u *UserService) UpdateUser(
 	id core.UserID,
 	data core.UserUpdate,
 ) (*core.User, error) {
-	dbUser, err := u.q.UpdateUser(ctx, sqlc.UpdateUserParams{
-		ID:    int32(id),
-		Name:  data.Name,
-		Email: data.Email,
-		Lang:  data.Lang,
+	var user sqlc.User
+	err := runInTx(ctx, u.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		before, err := queries.GetUser(ctx, int32(id))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		user, err = queries.UpdateUser(ctx, sqlc.UpdateUserParams{
+			ID:    int32(id),
+			Name:  data.Name,
+			Email: data.Email,
+			Lang:  data.Lang,
+		})
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		return recordUserUpdate(ctx, tx, before, user)
 	})
 	if err != nil {
 		return nil, err
 	}
-	return convertUser(dbUser)
+	return convertUser(user)
+}
+
+// recordUserUpdate records the changed fields of a user.
+func recordUserUpdate(ctx context.Context, tx pgx.Tx, before sqlc.User, after sqlc.User) error {
+	beforeUser, err := convertUser(before)
+	if err != nil {
+		return err
+	}
+	afterUser, err := convertUser(after)
+	if err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, core.AuditRecord{
+		Action:     core.AuditUpdate,
+		EntityType: core.AuditUser,
+		EntityID:   afterUser.ID.String(),
+		Before:     beforeUser,
+		After:      afterUser,
+	})
+}
+
+// userColumns are the columns of sqlc.User, in which the users table is aliased as u.
+const userColumns = "u.id, u.name, u.email, u.joined, u.admin, u.lang, u.search, u.deleted_at, " +
+	"u.anonymised_at"
+
+// userList returns the list of users in the specified FROM clause, in which the users table should be aliased as u.
+func userList(from string, where ...string) *keysetList[core.User, sqlc.User] {
+	return &keysetList[core.User, sqlc.User]{
+		columns: userColumns,
+		from:    from,
+		where:   where,
+		id:      "u.id",
+		sorts: map[string]sortColumn[core.User]{
+			"id":    {"u.id", "integer", func(u *core.User) string { return u.ID.String() }},
+			"name":  {"u.name", "text", func(u *core.User) string { return u.Name }},
+			"email": {"u.email", "text", func(u *core.User) string { return u.Email.String() }},
+			"joined": {"u.joined", "timestamptz", func(user *core.User) string {
+				return user.Joined.Format(time.RFC3339Nano)
+			}},
+		},
+		defaultSort: "name",
+		filters: map[string]listFilter{
+			"search":  containsFilter("u.name", "u.email"),
+			"admin":   equalsFilter("u.admin", strconv.ParseBool),
+			"lang":    equalsFilter("u.lang", parseText),
+			"deleted": deletedFilter("u.deleted_at"),
+		},
+		defaultFilters: map[string]string{"deleted": "false"},
+		convert:        convertUser,
+		itemID:         func(user *core.User) core.ID { return user.ID },
+	}
 }
 
 func convertUser(user sqlc.User) (*core.User, error) {
@@ -109,18 +308,3 @@ func convertUser(user sqlc.User) (*core.User, error) {
 		Joined: user.Joined.Time,
 	}, nil
 }
-
-func convertUserList(users []sqlc.User) ([]core.User, error) {
-	list := make([]core.User, len(users))
-	for i, v := range users {
-		u, err := convertUser(v)
-		if err != nil {
-			return nil, err
-		}
-		if u == nil {
-			return nil, errors.New("both user and error should never be nil")
-		}
-		list[i] = *u
-	}
-	return list, nil
-}
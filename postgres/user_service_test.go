@@ -2,9 +2,14 @@ package postgres_test
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/login"
 	"github.com/prior-it/apollo/postgres"
 	"github.com/prior-it/apollo/tests"
 	"github.com/stretchr/testify/assert"
@@ -92,6 +97,89 @@ func TestUserService(t *testing.T) {
 		assert.ErrorIs(t, err, core.ErrNotFound, "Getting a deleted user should return ErrNotFound")
 	})
 
+	t.Run("ok: restore deleted user", func(t *testing.T) {
+		email, err := core.ParseEmailAddress(tests.Faker.Email())
+		tests.Check(err)
+		user, err := service.CreateUser(ctx, tests.Faker.Name(), *email, "nl")
+		tests.Check(err)
+		tests.Check(service.DeleteUser(ctx, user.ID))
+
+		deleted, err := service.ListUsers(ctx, core.ListQuery{
+			Filters: map[string]string{"deleted": "true", "search": email.String()},
+		})
+		assert.Nil(t, err)
+		assert.Len(t, deleted.Items, 1, "Deleted users should be listed with the deleted filter")
+		active, err := service.ListUsers(ctx, core.ListQuery{
+			Filters: map[string]string{"search": email.String()},
+		})
+		assert.Nil(t, err)
+		assert.Empty(t, active.Items, "Deleted users should not be listed by default")
+
+		assert.Nil(t, service.RestoreUser(ctx, user.ID))
+		user2, err := service.GetUser(ctx, user.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, user, user2)
+
+		err = service.RestoreUser(ctx, user.ID)
+		assert.ErrorIs(t, err, core.ErrNotFound, "Users that are not deleted cannot be restored")
+	})
+
+	t.Run("err: restore user whose e-mail address was reused", func(t *testing.T) {
+		email, err := core.ParseEmailAddress(tests.Faker.Email())
+		tests.Check(err)
+		user, err := service.CreateUser(ctx, tests.Faker.Name(), *email, "nl")
+		tests.Check(err)
+		tests.Check(service.DeleteUser(ctx, user.ID))
+
+		_, err = service.CreateUser(ctx, tests.Faker.Name(), *email, "nl")
+		assert.Nil(t, err, "The e-mail address of a deleted user should be available again")
+
+		err = service.RestoreUser(ctx, user.ID)
+		assert.ErrorIs(t, err, core.ErrConflict)
+	})
+
+	t.Run("ok: log in again after deletion", func(t *testing.T) {
+		accounts := postgres.NewOauthAccountService(db)
+		data := &login.UserData{
+			Name:       tests.Faker.Name(),
+			Email:      tests.Faker.Email(),
+			Lang:       "nl",
+			Provider:   "test",
+			ProviderID: tests.Faker.UUID(),
+		}
+		user, err := accounts.CreateUserAccount(ctx, data)
+		tests.Check(err)
+		tests.Check(service.DeleteUser(ctx, user.ID))
+
+		_, err = accounts.FindUser(ctx, data)
+		assert.ErrorIs(t, err, core.ErrUserDoesNotExist, "Deleted users should not log in")
+		newUser, err := accounts.CreateUserAccount(ctx, data)
+		assert.Nil(t, err, "The account of a deleted user should not block a new account")
+		found, err := accounts.FindUser(ctx, data)
+		assert.Nil(t, err)
+		if assert.NotNil(t, newUser) && assert.NotNil(t, found) {
+			assert.NotEqual(t, user.ID, newUser.ID)
+			assert.Equal(t, newUser.ID, found.ID)
+		}
+	})
+
+	t.Run("ok: purge deleted users", func(t *testing.T) {
+		email, err := core.ParseEmailAddress(tests.Faker.Email())
+		tests.Check(err)
+		user, err := service.CreateUser(ctx, tests.Faker.Name(), *email, "nl")
+		tests.Check(err)
+		tests.Check(service.DeleteUser(ctx, user.ID))
+
+		purged, err := service.PurgeDeletedUsers(ctx, time.Now().Add(-time.Hour))
+		assert.Nil(t, err)
+		assert.Zero(t, purged, "Users within the retention period should be kept")
+
+		purged, err = service.PurgeDeletedUsers(ctx, time.Now().Add(time.Hour))
+		assert.Nil(t, err)
+		assert.GreaterOrEqual(t, purged, uint64(1))
+		assert.ErrorIs(t, service.RestoreUser(ctx, user.ID), core.ErrNotFound)
+	})
+
 	t.Run("ok: update user admin", func(t *testing.T) {
 		email, err := core.ParseEmailAddress("updateadminok@example.com")
 		tests.Check(err)
@@ -214,4 +302,114 @@ func TestUserService(t *testing.T) {
 		assert.Equal(t, *email, user.Email, "Email should not change after lang update")
 		assert.Equal(t, newLang, user.Lang, "Language should change after lang update")
 	})
+
+	t.Run("ok: list users page by page", func(t *testing.T) {
+		prefix := tests.Faker.LetterN(12)
+		for i := range 5 {
+			email, err := core.ParseEmailAddress(fmt.Sprintf("%s%d@example.com", prefix, i))
+			tests.Check(err)
+			_, err = service.CreateUser(ctx, fmt.Sprintf("%s %d", prefix, i%3), *email, "nl")
+			tests.Check(err)
+		}
+		query := core.ListQuery{
+			Limit:   2,
+			Filters: map[string]string{"search": strings.ToUpper(prefix)},
+		}
+
+		var names []string
+		var pages int
+		for {
+			page, err := service.ListUsers(ctx, query)
+			assert.Nil(t, err)
+			assert.Equal(t, uint64(5), page.Total, "The total should include every page")
+			assert.LessOrEqual(t, len(page.Items), 2)
+			for _, user := range page.Items {
+				names = append(names, user.Name)
+			}
+			pages++
+			if !page.HasNext() {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+		assert.Equal(t, 3, pages)
+		assert.Len(t, names, 5, "Users with equal names should not be skipped or repeated")
+		assert.True(t, slices.IsSorted(names), "Users should be sorted by name")
+
+		query = core.ListQuery{
+			Sort:      "email",
+			Direction: core.SortDescending,
+			Filters:   map[string]string{"search": prefix},
+		}
+		users, err := core.ListAll(ctx, query, service.ListUsers)
+		assert.Nil(t, err)
+		if assert.Len(t, users, 5) {
+			assert.Equal(t, prefix+"4@example.com", users[0].Email.String())
+		}
+	})
+
+	t.Run("err: invalid list query", func(t *testing.T) {
+		for _, query := range []core.ListQuery{
+			{Sort: "password"},
+			{Filters: map[string]string{"unknown": ""}},
+			{Filters: map[string]string{"admin": "maybe"}},
+			{Cursor: "invalid"},
+			{Sort: "email", Cursor: core.Cursor{Sort: "name", Value: "Name", ID: 1}.Encode()},
+		} {
+			_, err := service.ListUsers(ctx, query)
+			assert.ErrorIs(t, err, core.ErrInvalidListQuery)
+		}
+	})
+
+	t.Run("ok: get user by e-mail address", func(t *testing.T) {
+		user := tests.CreateRegularUser(service)
+		found, err := service.GetUserByEmail(ctx, user.Email)
+		assert.Nil(t, err)
+		assert.Equal(t, user.ID, found.ID)
+
+		email, err := core.ParseEmailAddress(tests.Faker.LetterN(12) + "@example.com")
+		tests.Check(err)
+		_, err = service.GetUserByEmail(ctx, *email)
+		assert.ErrorIs(t, err, core.ErrNotFound)
+	})
+
+	t.Run("ok: search users", func(t *testing.T) {
+		word := tests.Faker.LetterN(10)
+		email, err := core.ParseEmailAddress(word + "@example.com")
+		tests.Check(err)
+		byEmail, err := service.CreateUser(ctx, tests.Faker.Name(), *email, "nl")
+		tests.Check(err)
+		email, err = core.ParseEmailAddress(tests.Faker.LetterN(12) + "@example.com")
+		tests.Check(err)
+		byName, err := service.CreateUser(ctx, "Jane "+word, *email, "nl")
+		tests.Check(err)
+
+		results, err := service.SearchUsers(ctx, core.SearchQuery{Text: strings.ToUpper(word)})
+		assert.Nil(t, err)
+		assert.Len(t, results, 2, "Users should be found by name and e-mail address")
+		for _, result := range results {
+			switch result.Item.ID {
+			case byEmail.ID:
+				assert.True(t, result.Highlights["email"].Matches())
+			case byName.ID:
+				assert.True(t, result.Highlights["name"].Matches())
+			}
+		}
+
+		results, err = service.SearchUsers(ctx, core.SearchQuery{Text: word[1:7], Limit: 1})
+		assert.Nil(t, err)
+		assert.Len(t, results, 1, "Fragments should match and the limit should be applied")
+
+		organisations := postgres.NewOrganisationService(db)
+		org, err := organisations.CreateOrganisation(ctx, tests.Faker.Company(), nil)
+		tests.Check(err)
+		defer func() { tests.Check(organisations.DeleteOrganisation(ctx, org.ID)) }()
+		tests.Check(organisations.AddUser(ctx, byName.ID, org.ID))
+		query := core.SearchQuery{Text: word, OrganisationID: &org.ID}
+		results, err = service.SearchUsers(ctx, query)
+		assert.Nil(t, err)
+		if assert.Len(t, results, 1, "Only members of the organisation should be searched") {
+			assert.Equal(t, byName.ID, results[0].Item.ID)
+		}
+	})
 }
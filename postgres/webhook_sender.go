@@ -0,0 +1,234 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/postgres/internal/sqlc"
+)
+
+const (
+	// DefaultWebhookBatchSize is the maximum amount of webhooks that a sender sends at the same time.
+	DefaultWebhookBatchSize = 20
+	// DefaultWebhookMaxAttempts is the amount of attempts after which a delivery fails.
+	DefaultWebhookMaxAttempts = 10
+	// DefaultWebhookTimeout is the time an endpoint gets to respond when the sender uses its default HTTP client.
+	DefaultWebhookTimeout = 10 * time.Second
+	// The delay before a webhook is sent again after its first failed attempt, doubled for every next attempt
+	webhookRetryDelay = 30 * time.Second
+	// The maximum delay between two attempts to send a webhook
+	webhookMaxRetryDelay = 12 * time.Hour
+	// How long a sender can take to send a batch of webhooks before other senders claim them again
+	webhookLease = 5 * time.Minute
+)
+
+// NewWebhookSender creates a sender that sends webhooks with the specified client. If it is nil, the sender uses
+// core.NewWebhookClient with DefaultWebhookTimeout, which refuses local and private addresses and does not follow
+// redirects. A custom client should protect against requests to internal services in the same way.
+func NewWebhookSender(DB *DB, client *http.Client) *WebhookSender {
+	if client == nil {
+		client = core.NewWebhookClient(DefaultWebhookTimeout)
+	}
+	return &WebhookSender{
+		db:          DB,
+		client:      client,
+		batchSize:   DefaultWebhookBatchSize,
+		maxAttempts: DefaultWebhookMaxAttempts,
+	}
+}
+
+// WebhookSender sends the webhooks that a WebhookService queued to their endpoints.
+// Every request is signed with the endpoint's secret, see core.SignWebhook. A delivery succeeds when the endpoint
+// responds with a 2xx status code, otherwise it is retried with an exponential back-off until it runs out of
+// attempts.
+type WebhookSender struct {
+	db          *DB
+	client      *http.Client
+	batchSize   int
+	maxAttempts int
+}
+
+// SetBatchSize changes the maximum amount of webhooks that are sent at the same time.
+// The default is DefaultWebhookBatchSize.
+func (s *WebhookSender) SetBatchSize(size int) {
+	s.batchSize = size
+}
+
+// SetMaxAttempts changes the amount of attempts after which a delivery fails.
+// The default is DefaultWebhookMaxAttempts.
+func (s *WebhookSender) SetMaxAttempts(attempts int) {
+	s.maxAttempts = attempts
+}
+
+// Run sends the pending webhooks every interval until the context is cancelled.
+//
+// # Example
+//
+//	go postgres.NewWebhookSender(db, nil).Run(ctx, 5*time.Second)
+func (s *WebhookSender) Run(ctx context.Context, interval time.Duration) {
+	pollLoop(ctx, interval, func(ctx context.Context) bool {
+		sent, err := s.Send(ctx)
+		if err != nil {
+			slog.Error("Could not send webhooks", "error", err)
+			return false
+		}
+		return sent >= s.batchSize
+	})
+}
+
+// webhookResult is the outcome of a single attempt to send a webhook.
+type webhookResult struct {
+	responseCode *int32
+	err          error
+	duration     time.Duration
+}
+
+// Send sends a single batch of pending webhooks and returns the amount of webhooks it attempted to send.
+// Webhooks whose delivery fails are scheduled to be sent again later.
+//
+// The webhooks are claimed with a lease, so no transaction or connection is held while the requests run. If the
+// sender does not record the results before the lease ends, another sender claims the webhooks again.
+func (s *WebhookSender) Send(ctx context.Context) (int, error) {
+	lockedUntil := time.Now().Add(webhookLease)
+	deliveries, err := sqlc.New(s.db).ClaimWebhookDeliveries(
+		ctx,
+		toTimestamptz(&lockedUntil),
+		int32(s.batchSize),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("could not claim webhooks: %w", ConvertPgError(err))
+	}
+
+	results := make([]webhookResult, len(deliveries))
+	var wg sync.WaitGroup
+	for i, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = s.post(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+
+	// Record the results even if the context was cancelled, so the webhooks are not sent again needlessly
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	for i, delivery := range deliveries {
+		err := runInTx(ctx, s.db, func(tx pgx.Tx) error {
+			return s.record(ctx, sqlc.New(tx), delivery, lockedUntil, results[i])
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(deliveries), errors.Join(errs...)
+}
+
+// post sends a single webhook to its endpoint.
+func (s *WebhookSender) post(
+	ctx context.Context,
+	delivery sqlc.ClaimWebhookDeliveriesRow,
+) webhookResult {
+	start := time.Now()
+	payload := delivery.Payload
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		delivery.Url,
+		bytes.NewReader(payload),
+	)
+	if err != nil {
+		return webhookResult{err: fmt.Errorf("could not create request: %w", err)}
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "Apollo-Webhooks")
+	signature := core.SignWebhook(delivery.Secret, start, payload)
+	request.Header.Set(core.WebhookSignatureHeader, signature)
+	request.Header.Set(core.WebhookEventHeader, delivery.EventType)
+	request.Header.Set(core.WebhookEventIDHeader, delivery.EventID)
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return webhookResult{err: err, duration: time.Since(start)}
+	}
+	defer response.Body.Close()
+	code := int32(response.StatusCode)
+	result := webhookResult{responseCode: &code, duration: time.Since(start)}
+	// The body is not stored, so endpoints cannot use the delivery log to leak the responses of internal services
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		result.err = fmt.Errorf("endpoint responded with %s", response.Status)
+	}
+	return result
+}
+
+// record logs an attempt to send a webhook and completes its delivery or schedules the next attempt, as long as the
+// sender still holds the delivery's lease.
+func (s *WebhookSender) record(
+	ctx context.Context,
+	queries *sqlc.Queries,
+	delivery sqlc.ClaimWebhookDeliveriesRow,
+	lockedUntil time.Time,
+	result webhookResult,
+) error {
+	var message *string
+	if result.err != nil {
+		text := result.err.Error()
+		message = &text
+	}
+	err := queries.CreateWebhookAttempt(ctx, sqlc.CreateWebhookAttemptParams{
+		DeliveryID:   delivery.ID,
+		ResponseCode: result.responseCode,
+		Error:        message,
+		DurationMs:   int32(result.duration.Milliseconds()),
+	})
+	if err != nil {
+		return fmt.Errorf(
+			"could not log attempt of webhook %v: %w",
+			delivery.ID,
+			ConvertPgError(err),
+		)
+	}
+
+	now := time.Now()
+	params := sqlc.UpdateWebhookDeliveryAttemptParams{
+		ID:           delivery.ID,
+		LockedUntil:  toTimestamptz(&lockedUntil),
+		ResponseCode: result.responseCode,
+		LastError:    message,
+	}
+	switch {
+	case result.err == nil:
+		params.Status = string(core.WebhookSucceeded)
+		params.DeliveredAt = toTimestamptz(&now)
+	case int(delivery.Attempts)+1 >= s.maxAttempts:
+		params.Status = string(core.WebhookFailed)
+		slog.Warn(
+			"Webhook delivery failed",
+			"delivery_id", delivery.ID,
+			"event", delivery.EventType,
+			"attempts", delivery.Attempts+1,
+			"error", result.err,
+		)
+	default:
+		next := now.Add(backoff(delivery.Attempts+1, webhookRetryDelay, webhookMaxRetryDelay))
+		params.Status = string(core.WebhookPending)
+		params.NextAttemptAt = toTimestamptz(&next)
+	}
+	updated, err := queries.UpdateWebhookDeliveryAttempt(ctx, params)
+	if err != nil {
+		return fmt.Errorf("could not update webhook %v: %w", delivery.ID, ConvertPgError(err))
+	}
+	if updated == 0 {
+		// The lease expired or the delivery was replayed, so the result belongs to an outdated attempt
+		slog.Warn("Webhook delivery lease was lost", "delivery_id", delivery.ID)
+	}
+	return nil
+}
@@ -0,0 +1,412 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/postgres/internal/sqlc"
+)
+
+func NewWebhookService(DB *DB) *WebhookService {
+	return &WebhookService{db: DB, q: sqlc.New(DB)}
+}
+
+// Postgres implementation of the core WebhookService interface.
+// Call Subscribe to queue deliveries for the events on a bus, a WebhookSender sends them.
+type WebhookService struct {
+	db           *DB
+	q            *sqlc.Queries
+	allowPrivate bool
+}
+
+// Force struct to implement the core interface
+var _ core.WebhookService = &WebhookService{}
+
+// SetAllowPrivateAddresses makes the service accept endpoints on local and private addresses, which should only be
+// used in development and tests. The WebhookSender needs a client that can reach those addresses as well.
+func (w *WebhookService) SetAllowPrivateAddresses(allow bool) {
+	w.allowPrivate = allow
+}
+
+// validateURL validates the URL of an endpoint, see core.ValidateWebhookURL.
+func (w *WebhookService) validateURL(rawURL string) error {
+	err := core.ValidateWebhookURL(rawURL)
+	if w.allowPrivate && errors.Is(err, core.ErrForbiddenWebhookAddress) {
+		return nil
+	}
+	return err
+}
+
+// Subscribe queues a delivery to every matching endpoint whenever an organisation event is published on the bus.
+// Deliveries are queued synchronously, so if that fails the event dispatcher delivers the event again later.
+// Each endpoint receives every event once, no matter how often the dispatcher delivers it.
+func (w *WebhookService) Subscribe(bus *core.EventBus) {
+	bus.SubscribeAll(w.queueDeliveries)
+}
+
+func (w *WebhookService) queueDeliveries(ctx context.Context, event core.Event) error {
+	orgEvent, ok := event.(core.OrganisationEvent)
+	if !ok {
+		return nil
+	}
+	orgID := orgEvent.EventOrganisation()
+	if orgID == nil {
+		return nil
+	}
+	info, ok := core.EventInfoFromContext(ctx)
+	if !ok {
+		// The event was published directly instead of from an outbox, so it will never be delivered again
+		info = core.EventInfo{ID: uuid.NewString(), OccurredAt: time.Now()}
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("could not encode %q event: %w", event.EventType(), err)
+	}
+	payload, err := json.Marshal(core.WebhookPayload{
+		ID:             info.ID,
+		Type:           event.EventType(),
+		OccurredAt:     info.OccurredAt,
+		OrganisationID: *orgID,
+		Data:           data,
+	})
+	if err != nil {
+		return fmt.Errorf("could not encode webhook payload: %w", err)
+	}
+
+	return runInTx(ctx, w.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		endpoints, err := queries.ListWebhookEndpointsForEvent(
+			ctx,
+			int32(*orgID),
+			string(event.EventType()),
+		)
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		for _, endpoint := range endpoints {
+			err := queries.CreateWebhookDelivery(ctx, sqlc.CreateWebhookDeliveryParams{
+				EndpointID: endpoint.ID,
+				EventID:    info.ID,
+				EventType:  string(event.EventType()),
+				Payload:    payload,
+			})
+			if err != nil {
+				return fmt.Errorf(
+					"could not queue webhook for endpoint %v: %w",
+					endpoint.ID,
+					ConvertPgError(err),
+				)
+			}
+		}
+		return nil
+	})
+}
+
+// CreateWebhookEndpoint implements core.WebhookService.CreateWebhookEndpoint
+func (w *WebhookService) CreateWebhookEndpoint(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+	create core.WebhookEndpointCreate,
+) (*core.WebhookEndpoint, error) {
+	if err := w.validateURL(create.URL); err != nil {
+		return nil, err
+	}
+	secret := create.Secret
+	if len(secret) == 0 {
+		var err error
+		if secret, err = core.NewWebhookSecret(); err != nil {
+			return nil, err
+		}
+	}
+	var endpoint sqlc.WebhookEndpoint
+	err := runInTx(ctx, w.db, func(tx pgx.Tx) error {
+		var err error
+		endpoint, err = sqlc.New(tx).CreateWebhookEndpoint(ctx, sqlc.CreateWebhookEndpointParams{
+			OrganisationID: int32(OrgID),
+			Url:            create.URL,
+			Secret:         secret,
+			Events:         fromEventTypes(create.Events),
+		})
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		return recordWebhookEndpointChange(ctx, tx, core.AuditCreate, nil, &endpoint)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return convertWebhookEndpoint(endpoint), nil
+}
+
+// GetWebhookEndpoint implements core.WebhookService.GetWebhookEndpoint
+func (w *WebhookService) GetWebhookEndpoint(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+	id core.WebhookEndpointID,
+) (*core.WebhookEndpoint, error) {
+	endpoint, err := w.q.GetWebhookEndpoint(ctx, int32(id), int32(OrgID))
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	return convertWebhookEndpoint(endpoint), nil
+}
+
+// ListWebhookEndpoints implements core.WebhookService.ListWebhookEndpoints
+func (w *WebhookService) ListWebhookEndpoints(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+) ([]core.WebhookEndpoint, error) {
+	rows, err := w.q.ListWebhookEndpoints(ctx, int32(OrgID))
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	endpoints := make([]core.WebhookEndpoint, len(rows))
+	for i, row := range rows {
+		endpoints[i] = *convertWebhookEndpoint(row)
+	}
+	return endpoints, nil
+}
+
+// UpdateWebhookEndpoint implements core.WebhookService.UpdateWebhookEndpoint
+func (w *WebhookService) UpdateWebhookEndpoint(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+	id core.WebhookEndpointID,
+	update core.WebhookEndpointUpdate,
+) (*core.WebhookEndpoint, error) {
+	if update.URL != nil {
+		if err := w.validateURL(*update.URL); err != nil {
+			return nil, err
+		}
+	}
+	var endpoint sqlc.WebhookEndpoint
+	err := runInTx(ctx, w.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		before, err := queries.GetWebhookEndpoint(ctx, int32(id), int32(OrgID))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		params := sqlc.UpdateWebhookEndpointParams{
+			ID:             before.ID,
+			OrganisationID: before.OrganisationID,
+			Url:            before.Url,
+			Events:         before.Events,
+			Enabled:        before.Enabled,
+		}
+		if update.URL != nil {
+			params.Url = *update.URL
+		}
+		if update.Events != nil {
+			params.Events = fromEventTypes(*update.Events)
+		}
+		if update.Enabled != nil {
+			params.Enabled = *update.Enabled
+		}
+		endpoint, err = queries.UpdateWebhookEndpoint(ctx, params)
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		return recordWebhookEndpointChange(ctx, tx, core.AuditUpdate, &before, &endpoint)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return convertWebhookEndpoint(endpoint), nil
+}
+
+// DeleteWebhookEndpoint implements core.WebhookService.DeleteWebhookEndpoint
+func (w *WebhookService) DeleteWebhookEndpoint(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+	id core.WebhookEndpointID,
+) error {
+	return runInTx(ctx, w.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		before, err := queries.GetWebhookEndpoint(ctx, int32(id), int32(OrgID))
+		if err != nil {
+			return ConvertPgError(err)
+		}
+		if err := queries.DeleteWebhookEndpoint(ctx, before.ID, before.OrganisationID); err != nil {
+			return ConvertPgError(err)
+		}
+		return recordWebhookEndpointChange(ctx, tx, core.AuditDelete, &before, nil)
+	})
+}
+
+// webhookEndpointAuditState is the audited state of an endpoint, which leaves out its secret.
+type webhookEndpointAuditState struct {
+	OrganisationID core.OrganisationID
+	URL            string
+	Events         []string
+	Enabled        bool
+}
+
+func newWebhookEndpointAuditState(endpoint *sqlc.WebhookEndpoint) *webhookEndpointAuditState {
+	return &webhookEndpointAuditState{
+		OrganisationID: core.OrganisationID(endpoint.OrganisationID),
+		URL:            endpoint.Url,
+		Events:         endpoint.Events,
+		Enabled:        endpoint.Enabled,
+	}
+}
+
+func recordWebhookEndpointChange(
+	ctx context.Context,
+	tx pgx.Tx,
+	action core.AuditAction,
+	before *sqlc.WebhookEndpoint,
+	after *sqlc.WebhookEndpoint,
+) error {
+	record := core.AuditRecord{Action: action, EntityType: core.AuditWebhookEndpoint}
+	if before != nil {
+		record.EntityID = strconv.Itoa(int(before.ID))
+		record.Before = newWebhookEndpointAuditState(before)
+	}
+	if after != nil {
+		record.EntityID = strconv.Itoa(int(after.ID))
+		record.After = newWebhookEndpointAuditState(after)
+	}
+	return recordAudit(ctx, tx, record)
+}
+
+// ListWebhookDeliveries implements core.WebhookService.ListWebhookDeliveries
+func (w *WebhookService) ListWebhookDeliveries(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+	id core.WebhookEndpointID,
+	query core.ListQuery,
+) (*core.Page[core.WebhookDelivery], error) {
+	list := *webhookDeliveryList
+	list.args = []any{int32(id), int32(OrgID)}
+	return list.list(ctx, w.db, query)
+}
+
+var webhookDeliveryList = &keysetList[core.WebhookDelivery, sqlc.WebhookDelivery]{
+	columns: "d.id, d.endpoint_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, " +
+		"d.next_attempt_at, d.locked_until, d.response_code, d.last_error, d.created_at, " +
+		"d.delivered_at",
+	from:  "webhook_deliveries AS d INNER JOIN webhook_endpoints AS e ON d.endpoint_id = e.id",
+	where: []string{"d.endpoint_id = $1", "e.organisation_id = $2"},
+	id:    "d.id",
+	sorts: map[string]sortColumn[core.WebhookDelivery]{
+		"id": {"d.id", "integer", func(d *core.WebhookDelivery) string { return d.ID.String() }},
+		"time": {"d.created_at", "timestamptz", func(d *core.WebhookDelivery) string {
+			return d.CreatedAt.Format(time.RFC3339Nano)
+		}},
+	},
+	defaultSort: "time",
+	filters: map[string]listFilter{
+		"status":     equalsFilter("d.status", parseText),
+		"event_type": equalsFilter("d.event_type", parseText),
+	},
+	convert: func(delivery sqlc.WebhookDelivery) (*core.WebhookDelivery, error) {
+		return convertWebhookDelivery(delivery), nil
+	},
+	itemID: func(d *core.WebhookDelivery) core.ID { return d.ID },
+}
+
+// ListWebhookAttempts implements core.WebhookService.ListWebhookAttempts
+func (w *WebhookService) ListWebhookAttempts(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+	id core.WebhookDeliveryID,
+) ([]core.WebhookAttempt, error) {
+	// Make sure the delivery belongs to the organisation
+	if _, err := w.q.GetWebhookDelivery(ctx, int32(id), int32(OrgID)); err != nil {
+		return nil, ConvertPgError(err)
+	}
+	rows, err := w.q.ListWebhookAttempts(ctx, int32(id))
+	if err != nil {
+		return nil, ConvertPgError(err)
+	}
+	attempts := make([]core.WebhookAttempt, len(rows))
+	for i, row := range rows {
+		attempts[i] = core.WebhookAttempt{
+			ID:           core.ID(row.ID),
+			DeliveryID:   core.WebhookDeliveryID(row.DeliveryID),
+			AttemptedAt:  row.AttemptedAt.Time,
+			ResponseCode: fromOptionalInt(row.ResponseCode),
+			Error:        row.Error,
+			Duration:     time.Duration(row.DurationMs) * time.Millisecond,
+		}
+	}
+	return attempts, nil
+}
+
+// ReplayWebhookDelivery implements core.WebhookService.ReplayWebhookDelivery
+func (w *WebhookService) ReplayWebhookDelivery(
+	ctx context.Context,
+	OrgID core.OrganisationID,
+	id core.WebhookDeliveryID,
+) (*core.WebhookDelivery, error) {
+	var delivery sqlc.WebhookDelivery
+	err := runInTx(ctx, w.db, func(tx pgx.Tx) error {
+		queries := sqlc.New(tx)
+		if _, err := queries.GetWebhookDelivery(ctx, int32(id), int32(OrgID)); err != nil {
+			return ConvertPgError(err)
+		}
+		var err error
+		delivery, err = queries.ReplayWebhookDelivery(ctx, int32(id))
+		return ConvertPgError(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return convertWebhookDelivery(delivery), nil
+}
+
+func convertWebhookEndpoint(endpoint sqlc.WebhookEndpoint) *core.WebhookEndpoint {
+	events := make([]core.EventType, len(endpoint.Events))
+	for i, event := range endpoint.Events {
+		events[i] = core.EventType(event)
+	}
+	return &core.WebhookEndpoint{
+		ID:             core.WebhookEndpointID(endpoint.ID),
+		OrganisationID: core.OrganisationID(endpoint.OrganisationID),
+		URL:            endpoint.Url,
+		Secret:         endpoint.Secret,
+		Events:         events,
+		Enabled:        endpoint.Enabled,
+		CreatedAt:      endpoint.CreatedAt.Time,
+	}
+}
+
+func fromEventTypes(eventTypes []core.EventType) []string {
+	events := make([]string, len(eventTypes))
+	for i, eventType := range eventTypes {
+		events[i] = string(eventType)
+	}
+	return events
+}
+
+func convertWebhookDelivery(delivery sqlc.WebhookDelivery) *core.WebhookDelivery {
+	return &core.WebhookDelivery{
+		ID:            core.WebhookDeliveryID(delivery.ID),
+		EndpointID:    core.WebhookEndpointID(delivery.EndpointID),
+		EventID:       delivery.EventID,
+		EventType:     core.EventType(delivery.EventType),
+		Payload:       delivery.Payload,
+		Status:        core.WebhookDeliveryStatus(delivery.Status),
+		Attempts:      int(delivery.Attempts),
+		NextAttemptAt: fromTimestamptz(delivery.NextAttemptAt),
+		ResponseCode:  fromOptionalInt(delivery.ResponseCode),
+		LastError:     delivery.LastError,
+		CreatedAt:     delivery.CreatedAt.Time,
+		DeliveredAt:   fromTimestamptz(delivery.DeliveredAt),
+	}
+}
+
+func fromOptionalInt(i *int32) *int {
+	if i == nil {
+		return nil
+	}
+	value := int(*i)
+	return &value
+}
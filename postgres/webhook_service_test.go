@@ -0,0 +1,294 @@
+package postgres_test
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/postgres"
+	"github.com/prior-it/apollo/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookEndpoints(t *testing.T) {
+	db := tests.DB(t)
+	service := postgres.NewWebhookService(db)
+	orgService := postgres.NewOrganisationService(db)
+	tests.DeleteAllOrganisations(orgService)
+	defer tests.DeleteAllOrganisations(orgService)
+	ctx := context.Background()
+
+	org, err := orgService.CreateOrganisation(ctx, tests.Faker.Company(), nil)
+	tests.Check(err)
+	other, err := orgService.CreateOrganisation(ctx, tests.Faker.Company(), nil)
+	tests.Check(err)
+
+	t.Run("ok: create with generated secret", func(t *testing.T) {
+		endpoint, err := service.CreateWebhookEndpoint(ctx, org.ID, core.WebhookEndpointCreate{
+			URL: "https://example.com/webhooks",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, org.ID, endpoint.OrganisationID)
+		assert.True(t, endpoint.Enabled)
+		assert.Empty(t, endpoint.Events)
+		assert.NotEmpty(t, endpoint.Secret)
+
+		found, err := service.GetWebhookEndpoint(ctx, org.ID, endpoint.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, endpoint, found)
+	})
+
+	t.Run("err: invalid url", func(t *testing.T) {
+		_, err := service.CreateWebhookEndpoint(ctx, org.ID, core.WebhookEndpointCreate{
+			URL: "example.com",
+		})
+		assert.ErrorIs(t, err, core.ErrInvalidWebhookURL)
+	})
+
+	t.Run("err: private address", func(t *testing.T) {
+		for _, url := range []string{"https://169.254.169.254/latest", "https://localhost:8080"} {
+			create := core.WebhookEndpointCreate{URL: url}
+			_, err := service.CreateWebhookEndpoint(ctx, org.ID, create)
+			assert.ErrorIs(t, err, core.ErrForbiddenWebhookAddress, url)
+		}
+	})
+
+	t.Run("ok: update", func(t *testing.T) {
+		endpoint, err := service.CreateWebhookEndpoint(ctx, org.ID, core.WebhookEndpointCreate{
+			URL:    "https://example.com/webhooks",
+			Secret: "secret",
+		})
+		tests.Check(err)
+		events := []core.EventType{core.EventUserAddedToOrganisation}
+		disabled := false
+		update := core.WebhookEndpointUpdate{Events: &events, Enabled: &disabled}
+		updated, err := service.UpdateWebhookEndpoint(ctx, org.ID, endpoint.ID, update)
+		assert.Nil(t, err)
+		assert.Equal(t, events, updated.Events)
+		assert.False(t, updated.Enabled)
+		assert.Equal(t, endpoint.URL, updated.URL)
+		assert.Equal(t, "secret", updated.Secret)
+	})
+
+	t.Run("err: endpoint of another organisation", func(t *testing.T) {
+		endpoint, err := service.CreateWebhookEndpoint(ctx, org.ID, core.WebhookEndpointCreate{
+			URL: "https://example.com/webhooks",
+		})
+		tests.Check(err)
+		_, err = service.GetWebhookEndpoint(ctx, other.ID, endpoint.ID)
+		assert.ErrorIs(t, err, core.ErrNotFound)
+		err = service.DeleteWebhookEndpoint(ctx, other.ID, endpoint.ID)
+		assert.ErrorIs(t, err, core.ErrNotFound)
+		endpoints, err := service.ListWebhookEndpoints(ctx, other.ID)
+		assert.Nil(t, err)
+		assert.Empty(t, endpoints)
+	})
+
+	t.Run("ok: delete", func(t *testing.T) {
+		endpoint, err := service.CreateWebhookEndpoint(ctx, org.ID, core.WebhookEndpointCreate{
+			URL: "https://example.com/webhooks",
+		})
+		tests.Check(err)
+		assert.Nil(t, service.DeleteWebhookEndpoint(ctx, org.ID, endpoint.ID))
+		_, err = service.GetWebhookEndpoint(ctx, org.ID, endpoint.ID)
+		assert.ErrorIs(t, err, core.ErrNotFound)
+	})
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	db := tests.DB(t)
+	bus := core.NewEventBus()
+	dispatcher := postgres.NewEventDispatcher(db, bus)
+	service := postgres.NewWebhookService(db)
+	// The receiver listens on a local address
+	service.SetAllowPrivateAddresses(true)
+	service.Subscribe(bus)
+	receiver := tests.NewWebhookReceiver(t, "secret")
+	sender := postgres.NewWebhookSender(db, receiver.Client())
+	userService := postgres.NewUserService(db)
+	orgService := postgres.NewOrganisationService(db)
+	tests.DeleteAllUsers(userService)
+	defer tests.DeleteAllUsers(userService)
+	tests.DeleteAllOrganisations(orgService)
+	defer tests.DeleteAllOrganisations(orgService)
+	ctx := context.Background()
+
+	org, err := orgService.CreateOrganisation(ctx, tests.Faker.Company(), nil)
+	tests.Check(err)
+	endpoint, err := service.CreateWebhookEndpoint(ctx, org.ID, core.WebhookEndpointCreate{
+		URL:    receiver.URL(),
+		Events: []core.EventType{core.EventUserAddedToOrganisation},
+		Secret: "secret",
+	})
+	tests.Check(err)
+
+	// addUser adds a new user to the organisation and queues the webhooks of the events that causes
+	addUser := func() *core.User {
+		user := tests.CreateRegularUser(userService)
+		tests.Check(orgService.AddUser(ctx, user.ID, org.ID))
+		_, err := dispatcher.Dispatch(ctx)
+		tests.Check(err)
+		return user
+	}
+	// Start with an empty outbox
+	_, err = dispatcher.Dispatch(ctx)
+	tests.Check(err)
+
+	t.Run("ok: deliver signed webhook", func(t *testing.T) {
+		user := addUser()
+		sent, err := sender.Send(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, sent, "Only the subscribed event should be delivered")
+
+		webhook := receiver.AssertReceived(core.EventUserAddedToOrganisation)
+		if webhook == nil {
+			return
+		}
+		assert.Equal(t, org.ID, webhook.Payload.OrganisationID)
+		assert.Equal(t, "organisation.user_added", webhook.Header.Get(core.WebhookEventHeader))
+		assert.Equal(t, webhook.Payload.ID, webhook.Header.Get(core.WebhookEventIDHeader))
+		var data core.UserAddedToOrganisation
+		tests.Check(json.Unmarshal(webhook.Payload.Data, &data))
+		assert.Equal(t, user.ID, data.UserID)
+
+		page, err := service.ListWebhookDeliveries(ctx, org.ID, endpoint.ID, core.ListQuery{})
+		assert.Nil(t, err)
+		if assert.Len(t, page.Items, 1) {
+			delivery := page.Items[0]
+			assert.Equal(t, core.WebhookSucceeded, delivery.Status)
+			assert.Equal(t, 1, delivery.Attempts)
+			assert.Equal(t, 204, *delivery.ResponseCode)
+			assert.NotNil(t, delivery.DeliveredAt)
+		}
+	})
+
+	t.Run("ok: retry and replay failed delivery", func(t *testing.T) {
+		receiver.FailNext(1)
+		addUser()
+		_, err := sender.Send(ctx)
+		assert.Nil(t, err)
+
+		query := core.ListQuery{Filters: map[string]string{"status": string(core.WebhookPending)}}
+		page, err := service.ListWebhookDeliveries(ctx, org.ID, endpoint.ID, query)
+		assert.Nil(t, err)
+		if !assert.Len(t, page.Items, 1) {
+			return
+		}
+		delivery := page.Items[0]
+		assert.Equal(t, 1, delivery.Attempts)
+		assert.Equal(t, 500, *delivery.ResponseCode)
+		assert.NotNil(t, delivery.LastError)
+
+		sent, err := sender.Send(ctx)
+		assert.Nil(t, err)
+		assert.Zero(t, sent, "Failed deliveries should only be retried after a delay")
+
+		replayed, err := service.ReplayWebhookDelivery(ctx, org.ID, delivery.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, core.WebhookPending, replayed.Status)
+		assert.Zero(t, replayed.Attempts)
+		sent, err = sender.Send(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, sent)
+
+		attempts, err := service.ListWebhookAttempts(ctx, org.ID, delivery.ID)
+		assert.Nil(t, err)
+		if assert.Len(t, attempts, 2) {
+			assert.Equal(t, 500, *attempts[0].ResponseCode)
+			assert.Equal(t, 204, *attempts[1].ResponseCode)
+			assert.Nil(t, attempts[1].Error)
+		}
+		received := receiver.Received()
+		assert.Equal(t, received[len(received)-2].Body, received[len(received)-1].Body,
+			"A replay should send the same payload")
+	})
+
+	t.Run("ok: fail after the last attempt", func(t *testing.T) {
+		sender := postgres.NewWebhookSender(db, receiver.Client())
+		sender.SetMaxAttempts(1)
+		receiver.FailNext(1)
+		addUser()
+		_, err := sender.Send(ctx)
+		assert.Nil(t, err)
+
+		query := core.ListQuery{Filters: map[string]string{"status": string(core.WebhookFailed)}}
+		page, err := service.ListWebhookDeliveries(ctx, org.ID, endpoint.ID, query)
+		assert.Nil(t, err)
+		if assert.Len(t, page.Items, 1) {
+			assert.Nil(t, page.Items[0].NextAttemptAt)
+		}
+	})
+
+	t.Run("ok: no deliveries to disabled endpoints", func(t *testing.T) {
+		enabled := false
+		update := core.WebhookEndpointUpdate{Enabled: &enabled}
+		_, err := service.UpdateWebhookEndpoint(ctx, org.ID, endpoint.ID, update)
+		tests.Check(err)
+		defer func() {
+			enabled = true
+			_, err := service.UpdateWebhookEndpoint(ctx, org.ID, endpoint.ID, update)
+			tests.Check(err)
+		}()
+		addUser()
+		sent, err := sender.Send(ctx)
+		assert.Nil(t, err)
+		assert.Zero(t, sent)
+	})
+
+	t.Run("ok: hold deliveries of disabled endpoints and deleted orgs", func(t *testing.T) {
+		addUser()
+		enabled := false
+		update := core.WebhookEndpointUpdate{Enabled: &enabled}
+		_, err := service.UpdateWebhookEndpoint(ctx, org.ID, endpoint.ID, update)
+		tests.Check(err)
+		sent, err := sender.Send(ctx)
+		assert.Nil(t, err)
+		assert.Zero(t, sent, "Deliveries to a disabled endpoint should not be sent")
+
+		enabled = true
+		_, err = service.UpdateWebhookEndpoint(ctx, org.ID, endpoint.ID, update)
+		tests.Check(err)
+		tests.Check(orgService.DeleteOrganisation(ctx, org.ID))
+		sent, err = sender.Send(ctx)
+		assert.Nil(t, err)
+		assert.Zero(t, sent, "Deliveries of a deleted organisation should not be sent")
+
+		tests.Check(orgService.RestoreOrganisation(ctx, org.ID))
+		sent, err = sender.Send(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, sent)
+	})
+
+	t.Run("err: default client refuses private addresses", func(t *testing.T) {
+		sender := postgres.NewWebhookSender(db, nil)
+		sender.SetMaxAttempts(1)
+		before := len(receiver.Received())
+		addUser()
+		_, err := sender.Send(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, receiver.Received(), before, "The webhook should not reach the receiver")
+
+		query := core.ListQuery{Filters: map[string]string{"status": string(core.WebhookFailed)}}
+		page, err := service.ListWebhookDeliveries(ctx, org.ID, endpoint.ID, query)
+		assert.Nil(t, err)
+		refused := slices.ContainsFunc(page.Items, func(delivery core.WebhookDelivery) bool {
+			return delivery.ResponseCode == nil && delivery.LastError != nil &&
+				strings.Contains(*delivery.LastError, "local or private address")
+		})
+		assert.True(t, refused, "The delivery should fail without a response")
+	})
+
+	t.Run("err: replay delivery of another organisation", func(t *testing.T) {
+		other, err := orgService.CreateOrganisation(ctx, tests.Faker.Company(), nil)
+		tests.Check(err)
+		page, err := service.ListWebhookDeliveries(ctx, org.ID, endpoint.ID, core.ListQuery{})
+		tests.Check(err)
+		_, err = service.ReplayWebhookDelivery(ctx, other.ID, page.Items[0].ID)
+		assert.ErrorIs(t, err, core.ErrNotFound)
+		_, err = service.ListWebhookAttempts(ctx, other.ID, page.Items[0].ID)
+		assert.ErrorIs(t, err, core.ErrNotFound)
+	})
+}
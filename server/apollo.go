@@ -33,6 +33,7 @@ type Apollo struct {
 	Cfg          *config.Config
 	Organisation *core.Organisation
 	permissions  permissions.Service
+	users        core.UserService
 	store        sessions.Store
 	ctx          context.Context
 	decoder      *schema.Decoder
@@ -41,7 +42,7 @@ type Apollo struct {
 // Populate populates the Apollo object with fields that need to be retrieved after initialisation.
 // E.g. fields that are stored in the active session.
 func (apollo *Apollo) populate() {
-	apollo.ctx = apollo.Request.Context()
+	apollo.ctx = withPermissionSet(apollo.Request.Context(), apollo)
 
 	if apollo.store != nil {
 		apollo.populateUser()
@@ -78,6 +79,12 @@ func (apollo *Apollo) populateUser() {
 }
 
 func (apollo *Apollo) populateOrganisation() {
+	// An organisation that was resolved from the request takes precedence over the session
+	if org := requestOrganisationFromContext(apollo.ctx); org != nil {
+		apollo.Organisation = org.organisation
+		apollo.LogField("active_organisation_id", slog.AnyValue(apollo.Organisation.ID))
+		return
+	}
 	organisation, err := apollo.retrieveOrganisation()
 	if errors.Is(err, core.ErrUnauthenticated) || errors.Is(err, core.ErrNoActiveOrganisation) {
 		apollo.Organisation = nil
@@ -165,7 +172,7 @@ func (apollo *Apollo) rebuildContext() {
 	ctx := apollo.ctx
 	session := apollo.Session()
 	ctx = buildSessionContext(ctx, session)
-	apollo.ctx = ctx
+	apollo.ctx = withOrganisationContext(ctx)
 }
 
 // Host specifies the host on which the URL is sought.
@@ -253,19 +260,26 @@ func (apollo *Apollo) Protocol() string {
 }
 
 // CreateURL will return the url for the given endpoint. If you need to include the current protocol as well, use [CreateProtocolURL] instead.
+// If the active organisation was resolved from the request's path, the url points inside that organisation's path.
 func (apollo *Apollo) CreateURL(endpoint string) string {
 	if !strings.HasPrefix(endpoint, "/") {
 		endpoint = "/" + endpoint
 	}
-	return fmt.Sprintf("%s%s", apollo.Request.Host, endpoint)
+	return fmt.Sprintf("%s%s", apollo.Request.Host, OrganisationPath(apollo.ctx, endpoint))
 }
 
 // CreateProtocolURL will return the full url for the given endpoint, including its protocol. If you don't want the current protocol to be included, use [CreateURL] instead.
+// If the active organisation was resolved from the request's path, the url points inside that organisation's path.
 func (apollo *Apollo) CreateProtocolURL(endpoint string) string {
 	if !strings.HasPrefix(endpoint, "/") {
 		endpoint = "/" + endpoint
 	}
-	return fmt.Sprintf("%s%s%s", apollo.Protocol(), apollo.Request.Host, endpoint)
+	return fmt.Sprintf(
+		"%s%s%s",
+		apollo.Protocol(),
+		apollo.Request.Host,
+		OrganisationPath(apollo.ctx, endpoint),
+	)
 }
 
 // RequiresLogin will return core.ErrUnauthenticated if there is no user logged in and nil otherwise.
@@ -333,6 +347,23 @@ func (apollo *Apollo) RequiresInOrganisationStrict(
 	return nil
 }
 
+// RequiresOn will return core.ErrForbidden if the current user does not have the specified permission on the specified
+// resource and nil otherwise.
+// If no user is logged in at all, this will return core.ErrUnauthenticated.
+func (apollo *Apollo) RequiresOn(
+	permission permissions.Permission,
+	resourceType permissions.ResourceType,
+	id core.ID,
+) error {
+	if err := apollo.RequiresLogin(); err != nil {
+		return err
+	}
+	if !apollo.HasOn(permission, resourceType, id) {
+		return core.ErrForbidden
+	}
+	return nil
+}
+
 // Has returns a boolean indicating whether or not the currently logged in user has the specified permission in any
 // of their permission groups or not. If no user is logged in, this will return false.
 // If there is an active organisation set, this will recursively check the permissions in that organisation's lineage.
@@ -453,6 +484,94 @@ func (apollo *Apollo) HasInOrganisationStrict(
 	return ok
 }
 
+// HasOn returns a boolean indicating whether or not the currently logged in user has the specified permission on the
+// resource with the specified type and id, either directly or through any of their permission groups.
+// Global and organisation permissions are not taken into account. If no user is logged in, this will return false.
+func (apollo *Apollo) HasOn(
+	permission permissions.Permission,
+	resourceType permissions.ResourceType,
+	id core.ID,
+) bool {
+	if apollo.permissions == nil {
+		slog.Warn(
+			"Trying to use permission system while Apollo does not have access to a permissions.Service!",
+		)
+		return false
+	}
+	if apollo.User == nil {
+		slog.Warn(
+			"Trying to use permission system while no user is logged in!",
+		)
+		return false
+	}
+	if apollo.User.Admin {
+		return true
+	}
+	ok, err := apollo.permissions.HasAnyOn(
+		apollo.Context(),
+		apollo.User.ID,
+		permission,
+		permissions.Resource{Type: resourceType, ID: id},
+	)
+	if err != nil {
+		slog.Error(
+			"Error while checking resource permissions",
+			"error",
+			err,
+			"resource_type",
+			resourceType,
+			"resource_id",
+			id,
+		)
+		return false
+	}
+	return ok
+}
+
+// ResourceIDsWith returns the ids of all resources of the specified type on which the currently logged in user has
+// the specified permission. This can be used to filter queries down to the resources a user can access.
+// Admins are not special-cased here since Apollo cannot enumerate application resources.
+// If no user is logged in, this will return core.ErrUnauthenticated.
+func (apollo *Apollo) ResourceIDsWith(
+	permission permissions.Permission,
+	resourceType permissions.ResourceType,
+) ([]core.ID, error) {
+	if err := apollo.RequiresLogin(); err != nil {
+		return nil, err
+	}
+	if apollo.permissions == nil {
+		return nil, errors.New("Apollo does not have access to a permissions.Service")
+	}
+	return apollo.permissions.ListResourceIDsForUser(
+		apollo.Context(),
+		apollo.User.ID,
+		permission,
+		resourceType,
+	)
+}
+
+// CanViewPermissionGroup returns a boolean indicating whether or not the currently logged in user can view the
+// specified permission group. Any group can be viewed with PermViewAllPermissionGroups, groups that are owned by an
+// organisation can also be viewed with PermViewOwnPermissionGroups in that organisation.
+func (apollo *Apollo) CanViewPermissionGroup(group *permissions.PermissionGroup) bool {
+	if apollo.Has(permissions.PermViewAllPermissionGroups) {
+		return true
+	}
+	return !group.IsGlobal() &&
+		apollo.HasInOrganisation(permissions.PermViewOwnPermissionGroups, *group.OrganisationID)
+}
+
+// CanEditPermissionGroup returns a boolean indicating whether or not the currently logged in user can edit the
+// specified permission group. Any group can be edited with PermEditAllPermissionGroups, groups that are owned by an
+// organisation can also be edited with PermEditOwnPermissionGroups in that organisation.
+func (apollo *Apollo) CanEditPermissionGroup(group *permissions.PermissionGroup) bool {
+	if apollo.Has(permissions.PermEditAllPermissionGroups) {
+		return true
+	}
+	return !group.IsGlobal() &&
+		apollo.HasInOrganisation(permissions.PermEditOwnPermissionGroups, *group.OrganisationID)
+}
+
 // CheckCSRF will check if a CSRF token was added to the requests form body and if that token matches the
 // token specified in the CSRF cookie. If either of these are false, this will return an error. If the correct CSRF
 // token was specified, this will return nil.
@@ -6,6 +6,7 @@ import (
 	"github.com/gorilla/sessions"
 	"github.com/prior-it/apollo/config"
 	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/permissions"
 )
 
 type contextKey uint
@@ -25,6 +26,8 @@ const (
 	ctxOldCSRFToken
 	ctxFlags
 	ctxEnableAll
+	ctxPermissions
+	ctxRequestOrganisation
 )
 
 func allFeatureFlagsEnabled(ctx context.Context) bool {
@@ -50,6 +53,26 @@ func IsAdmin(ctx context.Context) bool {
 	return ok && isAdmin
 }
 
+// Can returns true if the current user has the specified permission, either globally or in the lineage of the
+// active organisation. This behaves the same as [Apollo.Has] but results are cached for the rest of the request,
+// so it is safe to call from templates. If no user is logged in, this will return false.
+func Can(ctx context.Context, permission permissions.Permission) bool {
+	set := permissionSetFromContext(ctx)
+	return set != nil && set.has(permission, nil)
+}
+
+// CanInOrganisation returns true if the current user has the specified permission, either globally or in the lineage
+// of the specified organisation. This behaves the same as [Apollo.HasInOrganisation] but results are cached for the
+// rest of the request, so it is safe to call from templates. If no user is logged in, this will return false.
+func CanInOrganisation(
+	ctx context.Context,
+	permission permissions.Permission,
+	organisation core.OrganisationID,
+) bool {
+	set := permissionSetFromContext(ctx)
+	return set != nil && set.has(permission, &organisation)
+}
+
 func UserID(ctx context.Context) core.UserID {
 	return ctx.Value(ctxUserID).(core.UserID)
 }
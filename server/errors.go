@@ -20,6 +20,8 @@ func DefaultErrorHandler(apollo *Apollo, err error) {
 			return http.StatusConflict, "conflict"
 		case errors.Is(err, core.ErrNotFound):
 			return http.StatusNotFound, "not found"
+		case errors.Is(err, core.ErrInvalidListQuery):
+			return http.StatusBadRequest, "bad request"
 		}
 		return http.StatusInternalServerError, "internal server error"
 	}()
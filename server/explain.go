@@ -0,0 +1,63 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-chi/render"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/permissions"
+)
+
+// ExplainPermissions adds a debug-only GET route at `pattern` that explains why a user does or does not have a
+// permission, see [permissions.Service.Explain]. The route is not registered at all outside of debug mode.
+//
+// The user, permission and (optional) organisation are taken from the "user", "permission" and "organisation" query
+// parameters and the explanation is rendered as JSON.
+// Only users that can view all users and all permission groups can access this route.
+//
+// # Example
+//
+//	server.ExplainPermissions("/debug/permissions")
+//	// GET /debug/permissions?user=4&permission=view_own_organisation&organisation=2
+func (server *Server[state]) ExplainPermissions(pattern string) *Server[state] {
+	if !server.cfg.App.Debug {
+		return server
+	}
+	return server.Get(
+		pattern,
+		func(apollo *Apollo, _ state) error {
+			explanation, err := apollo.explainPermission()
+			if err != nil {
+				return err
+			}
+			render.JSON(apollo.Writer, apollo.Request, explanation)
+			return nil
+		},
+		Requires(permissions.PermViewAllUsers),
+		Requires(permissions.PermViewAllPermissionGroups),
+	)
+}
+
+func (apollo *Apollo) explainPermission() (*permissions.Explanation, error) {
+	if apollo.permissions == nil {
+		return nil, errors.New("cannot explain permissions without a permissions.Service")
+	}
+	userID, err := core.ParseID(apollo.GetQuery("user"))
+	if err != nil {
+		return nil, errors.Join(core.ErrNotFound, fmt.Errorf("invalid user id: %w", err))
+	}
+	permission := permissions.Permission(apollo.GetQuery("permission"))
+	var orgID *core.OrganisationID
+	if org := apollo.GetQuery("organisation"); len(org) > 0 {
+		id, err := core.ParseID(org)
+		if err != nil {
+			return nil, errors.Join(
+				core.ErrNotFound,
+				fmt.Errorf("invalid organisation id: %w", err),
+			)
+		}
+		orgID = &id
+	}
+	return apollo.permissions.Explain(apollo.Context(), userID, permission, orgID)
+}
@@ -0,0 +1,94 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prior-it/apollo/config"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/permissions"
+	"github.com/prior-it/apollo/server"
+	"github.com/stretchr/testify/assert"
+)
+
+type explainPermissions struct {
+	fakePermissions
+}
+
+func (e *explainPermissions) Explain(
+	_ context.Context,
+	userID core.UserID,
+	permission permissions.Permission,
+	orgID *core.OrganisationID,
+) (*permissions.Explanation, error) {
+	explanation := &permissions.Explanation{
+		UserID:         userID,
+		Permission:     permission,
+		OrganisationID: orgID,
+	}
+	explanation.AddStep(permissions.ExplanationStep{
+		Source:  permissions.SourceAdmin,
+		Granted: true,
+	})
+	return explanation, nil
+}
+
+func TestExplainPermissions(t *testing.T) {
+	newServer := func(debug bool) *server.Server[State] {
+		cfg := &config.Config{
+			App: config.AppConfig{
+				Debug:             debug,
+				AuthenticationKey: "01234567890123456789012345678901",
+				EncryptionKey:     "01234567890123456789012345678901",
+			},
+		}
+		s := server.New(State{}, cfg).WithPermissionService(&explainPermissions{})
+		s.UseStd(s.SessionMiddleware())
+		// Log in as an admin before every request
+		s.Use(func(apollo *server.Apollo, _ State) (context.Context, error) {
+			email, err := core.ParseEmailAddress("admin@example.com")
+			if err != nil {
+				return nil, err
+			}
+			err = apollo.Login(&core.User{ID: 1, Email: *email, Admin: true})
+			return apollo.Context(), err
+		})
+		return s.ExplainPermissions("/debug/permissions")
+	}
+
+	t.Run("ok: route is disabled outside of debug mode", func(t *testing.T) {
+		t.Parallel()
+		s := newServer(false)
+		assert.Empty(t, s.Routes())
+	})
+
+	t.Run("ok: explanation is rendered as json", func(t *testing.T) {
+		t.Parallel()
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(
+			http.MethodGet,
+			"/debug/permissions?user=4&permission=view_all_users&organisation=2",
+			nil,
+		)
+		newServer(true).ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var explanation map[string]any
+		assert.Nil(t, json.NewDecoder(recorder.Body).Decode(&explanation))
+		assert.InDelta(t, 4, explanation["user_id"], 0)
+		assert.Equal(t, permissions.PermViewAllUsers.String(), explanation["permission"])
+		assert.InDelta(t, 2, explanation["organisation_id"], 0)
+		assert.Equal(t, true, explanation["granted"])
+	})
+
+	t.Run("err: invalid user id", func(t *testing.T) {
+		t.Parallel()
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/debug/permissions?user=abc", nil)
+		newServer(true).ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/go-chi/httplog/v2"
+	"github.com/gorilla/sessions"
 	"github.com/prior-it/apollo/config"
 	"github.com/prior-it/apollo/core"
 )
@@ -160,6 +162,8 @@ func (server *Server[state]) SessionMiddleware() func(http.Handler) http.Handler
 				configureCookie(server.cfg, session)
 			}
 
+			server.logoutDeletedUser(w, r, session)
+
 			ctx = context.WithValue(ctx, ctxSession, session)
 			ctx = buildSessionContext(ctx, session)
 
@@ -168,6 +172,37 @@ func (server *Server[state]) SessionMiddleware() func(http.Handler) http.Handler
 	}
 }
 
+// logoutDeletedUser logs out the session if its user has been deleted since the session was created.
+// This runs once per request, before any tenant middleware, so the user is looked up in the shared schema.
+// Without a user service, the session is trusted as is.
+func (server *Server[state]) logoutDeletedUser(
+	w http.ResponseWriter,
+	r *http.Request,
+	session *sessions.Session,
+) {
+	if server.userService == nil {
+		return
+	}
+	loggedIn, _ := session.Values[sessionLoggedIn].(bool)
+	id, ok := session.Values[sessionUserID].(core.UserID)
+	if !loggedIn || !ok {
+		return
+	}
+	_, err := server.userService.GetUser(r.Context(), id)
+	if err == nil {
+		return
+	} else if !errors.Is(err, core.ErrNotFound) && !errors.Is(err, core.ErrUserDoesNotExist) {
+		// Logging everyone out whenever the database is unavailable would do more harm than good
+		slog.Error("Could not check whether the session's user still exists", "error", err)
+		return
+	}
+	slog.Info("Logging out the session of a deleted user", "user_id", id)
+	clearSession(session)
+	if err := server.sessionStore.Save(r, w, session); err != nil {
+		slog.Error("Could not log out the session of a deleted user", "error", err)
+	}
+}
+
 func (server *Server[state]) FeatureFlagMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/prior-it/apollo/core"
+)
+
+// DefaultOrganisationPrefix is the path prefix that is used by PathOrganisation if no prefix is specified.
+const DefaultOrganisationPrefix = "/o"
+
+// OrganisationResolver extracts the organisation that a request is made for, so users can work in multiple
+// organisations at the same time, e.g. in two tabs. Use [PathOrganisation] or [SubdomainOrganisation] to create one.
+type OrganisationResolver interface {
+	// resolve returns the organisation of the request together with the request that should be handled instead and
+	// the path that should be prepended to organisation-aware URLs.
+	// ok is false if the request does not specify an organisation.
+	resolve(r *http.Request) (resolved *resolvedOrganisation, ok bool, err error)
+}
+
+type resolvedOrganisation struct {
+	id      core.OrganisationID
+	request *http.Request
+	base    string
+}
+
+// requestOrganisation is the organisation that was resolved for the current request.
+type requestOrganisation struct {
+	organisation *core.Organisation
+	base         string
+}
+
+type pathOrganisation struct {
+	prefix string
+}
+
+// PathOrganisation resolves the organisation from a path prefix followed by the organisation id, e.g.
+// "/o/42/projects" is the "/projects" page of organisation 42. The prefix and id are stripped before routing, so
+// routes do not need to be registered separately for every organisation. An empty prefix uses
+// [DefaultOrganisationPrefix].
+func PathOrganisation(prefix string) OrganisationResolver {
+	if len(prefix) == 0 {
+		prefix = DefaultOrganisationPrefix
+	}
+	return &pathOrganisation{prefix: "/" + strings.Trim(prefix, "/")}
+}
+
+func (p *pathOrganisation) resolve(r *http.Request) (*resolvedOrganisation, bool, error) {
+	rest, ok := strings.CutPrefix(r.URL.Path, p.prefix+"/")
+	if !ok {
+		return nil, false, nil
+	}
+	rawID, path, _ := strings.Cut(rest, "/")
+	id, err := core.ParseID(rawID)
+	if err != nil {
+		err = fmt.Errorf("invalid organisation id %q: %w", rawID, err)
+		return nil, false, errors.Join(core.ErrNotFound, err)
+	}
+	base := fmt.Sprintf("%s/%v", p.prefix, id)
+
+	request := r.Clone(r.Context())
+	request.URL.Path = "/" + path
+	request.URL.RawPath = ""
+	return &resolvedOrganisation{id: id, request: request, base: base}, true, nil
+}
+
+// SubdomainLookup returns the organisation that belongs to the specified subdomain or core.ErrNotFound.
+type SubdomainLookup func(ctx context.Context, subdomain string) (core.OrganisationID, error)
+
+type subdomainOrganisation struct {
+	domain string
+	lookup SubdomainLookup
+}
+
+// SubdomainOrganisation resolves the organisation from the subdomain of the request's host, e.g.
+// "acme.app.example" is a request for the organisation that the lookup returns for "acme" if domain is
+// "app.example". If lookup is nil, the subdomain should be the organisation id, e.g. "42.app.example".
+// Requests to the domain itself do not specify an organisation.
+func SubdomainOrganisation(domain string, lookup SubdomainLookup) OrganisationResolver {
+	if lookup == nil {
+		lookup = func(_ context.Context, subdomain string) (core.OrganisationID, error) {
+			id, err := core.ParseID(subdomain)
+			if err != nil {
+				return 0, errors.Join(core.ErrNotFound, err)
+			}
+			return id, nil
+		}
+	}
+	return &subdomainOrganisation{domain: strings.ToLower(domain), lookup: lookup}
+}
+
+func (s *subdomainOrganisation) resolve(r *http.Request) (*resolvedOrganisation, bool, error) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	subdomain, ok := strings.CutSuffix(strings.ToLower(host), "."+s.domain)
+	if !ok || len(subdomain) == 0 {
+		return nil, false, nil
+	}
+	id, err := s.lookup(r.Context(), subdomain)
+	if err != nil {
+		err = fmt.Errorf("cannot find organisation for subdomain %q: %w", subdomain, err)
+		return nil, false, err
+	}
+	// The host already identifies the organisation, so URLs on the same host need no prefix
+	return &resolvedOrganisation{id: id, request: r}, true, nil
+}
+
+// OrganisationMiddleware resolves the organisation of every request and uses it as the active organisation for
+// that request only, instead of the organisation that is stored in the session. The user needs to be logged in and
+// be a member of the organisation, otherwise the request fails with core.ErrUnauthenticated or core.ErrNotFound.
+// Requests that do not specify an organisation keep using the session's active organisation.
+//
+// This should be attached after the session middleware, e.g. after AttachDefaultMiddleware.
+func (server *Server[state]) OrganisationMiddleware(
+	service core.OrganisationService,
+	resolver OrganisationResolver,
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			resolved, ok, err := resolver.resolve(r)
+			if err != nil {
+				server.errorHandler(server.NewApollo(w, r), err)
+				return
+			} else if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := r.Context()
+			org, err := authoriseOrganisation(ctx, service, resolved.id)
+			if err != nil {
+				server.errorHandler(server.NewApollo(w, r), err)
+				return
+			}
+
+			ctx = withRequestOrganisation(ctx, &requestOrganisation{
+				organisation: org,
+				base:         resolved.base,
+			})
+			request := resolved.request.WithContext(ctx)
+			// Apollo objects that were injected earlier still refer to the session's organisation
+			if _, ok := ctx.Value(ctxApollo).(*Apollo); ok {
+				ctx = context.WithValue(ctx, ctxApollo, server.NewApollo(w, request))
+				request = request.WithContext(ctx)
+			}
+			next.ServeHTTP(w, request)
+		})
+	}
+}
+
+// authoriseOrganisation returns the organisation if the logged in user is a member.
+// This returns core.ErrNotFound for organisations that the user is not a member of, so their existence is not
+// leaked.
+func authoriseOrganisation(
+	ctx context.Context,
+	service core.OrganisationService,
+	id core.OrganisationID,
+) (*core.Organisation, error) {
+	if !IsLoggedIn(ctx) {
+		return nil, core.ErrUnauthenticated
+	}
+	if _, err := service.GetMember(ctx, UserID(ctx), id); err != nil {
+		return nil, fmt.Errorf("cannot verify membership of organisation %v: %w", id, err)
+	}
+	org, err := service.GetOrganisation(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve organisation %v: %w", id, err)
+	}
+	return org, nil
+}
+
+func withRequestOrganisation(ctx context.Context, org *requestOrganisation) context.Context {
+	ctx = context.WithValue(ctx, ctxRequestOrganisation, org)
+	return withOrganisationContext(ctx)
+}
+
+// withOrganisationContext overrides the session's organisation with the request's organisation, if there is one.
+func withOrganisationContext(ctx context.Context) context.Context {
+	org := requestOrganisationFromContext(ctx)
+	if org == nil {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, ctxOrganisationID, org.organisation.ID)
+	ctx = context.WithValue(ctx, ctxOrganisationName, org.organisation.Name)
+	var parent any
+	if org.organisation.ParentID != nil {
+		parent = *org.organisation.ParentID
+	}
+	return context.WithValue(ctx, ctxOrganisationParent, parent)
+}
+
+func requestOrganisationFromContext(ctx context.Context) *requestOrganisation {
+	org, _ := ctx.Value(ctxRequestOrganisation).(*requestOrganisation)
+	return org
+}
+
+// OrganisationPath prepends the path of the request's organisation to the endpoint, e.g. "/projects" becomes
+// "/o/42/projects" if the organisation was resolved with PathOrganisation.
+// The endpoint is returned unchanged if the organisation was not resolved from the request's path.
+func OrganisationPath(ctx context.Context, endpoint string) string {
+	if !strings.HasPrefix(endpoint, "/") {
+		endpoint = "/" + endpoint
+	}
+	if org := requestOrganisationFromContext(ctx); org != nil {
+		return org.base + endpoint
+	}
+	return endpoint
+}
@@ -0,0 +1,167 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prior-it/apollo/config"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/server"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOrganisations contains a fixed set of organisations, of which user 1 is a member of organisation 1 only.
+type fakeOrganisations struct {
+	core.OrganisationService
+}
+
+func (f *fakeOrganisations) GetOrganisation(
+	_ context.Context,
+	id core.OrganisationID,
+) (*core.Organisation, error) {
+	if id != 1 && id != 2 {
+		return nil, core.ErrNotFound
+	}
+	return &core.Organisation{ID: id, Name: "Organisation"}, nil
+}
+
+func (f *fakeOrganisations) GetMember(
+	_ context.Context,
+	userID core.UserID,
+	orgID core.OrganisationID,
+) (*core.User, error) {
+	if userID != 1 || orgID != 1 {
+		return nil, core.ErrNotFound
+	}
+	return &core.User{ID: userID}, nil
+}
+
+func TestOrganisationMiddleware(t *testing.T) {
+	cfg := &config.Config{
+		App: config.AppConfig{
+			AuthenticationKey: "01234567890123456789012345678901",
+			EncryptionKey:     "01234567890123456789012345678901",
+		},
+	}
+
+	type result struct {
+		status       int
+		organisation *core.Organisation
+		url          string
+	}
+
+	// run logs in and then requests the target, returning what the handler observed
+	run := func(
+		resolver server.OrganisationResolver,
+		host string,
+		target string,
+		login bool,
+	) result {
+		var res result
+		s := server.New(State{}, cfg)
+		s.UseStd(s.SessionMiddleware())
+		s.UseStd(s.OrganisationMiddleware(&fakeOrganisations{}, resolver))
+		s.Get("/login", func(apollo *server.Apollo, _ State) error {
+			return apollo.Login(&core.User{ID: 1})
+		})
+		s.Get("/projects", func(apollo *server.Apollo, _ State) error {
+			res.organisation = apollo.Organisation
+			res.url = apollo.CreateProtocolURL("/projects/new")
+			return nil
+		})
+
+		var cookies []*http.Cookie
+		if login {
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/login", nil))
+			cookies = recorder.Result().Cookies()
+		}
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		request.Host = host
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		s.ServeHTTP(recorder, request)
+		res.status = recorder.Code
+		return res
+	}
+
+	numeric := server.SubdomainOrganisation("app.example", nil)
+
+	t.Run("ok: path", func(t *testing.T) {
+		res := run(server.PathOrganisation(""), "app.example", "/o/1/projects", true)
+		assert.Equal(t, http.StatusOK, res.status)
+		if assert.NotNil(t, res.organisation) {
+			assert.Equal(t, core.OrganisationID(1), res.organisation.ID)
+		}
+		assert.Equal(t, "http://app.example/o/1/projects/new", res.url)
+	})
+
+	t.Run("ok: custom path prefix", func(t *testing.T) {
+		res := run(server.PathOrganisation("/org/"), "app.example", "/org/1/projects", true)
+		assert.Equal(t, http.StatusOK, res.status)
+		assert.Equal(t, "http://app.example/org/1/projects/new", res.url)
+	})
+
+	t.Run("ok: subdomain", func(t *testing.T) {
+		lookup := func(_ context.Context, subdomain string) (core.OrganisationID, error) {
+			if subdomain == "acme" {
+				return 1, nil
+			}
+			return 0, core.ErrNotFound
+		}
+		resolver := server.SubdomainOrganisation("app.example", lookup)
+		res := run(resolver, "acme.app.example:8080", "/projects", true)
+		assert.Equal(t, http.StatusOK, res.status)
+		if assert.NotNil(t, res.organisation) {
+			assert.Equal(t, core.OrganisationID(1), res.organisation.ID)
+		}
+		assert.Equal(t, "http://acme.app.example:8080/projects/new", res.url)
+	})
+
+	t.Run("ok: no organisation in request", func(t *testing.T) {
+		res := run(server.PathOrganisation(""), "app.example", "/projects", true)
+		assert.Equal(t, http.StatusOK, res.status)
+		assert.Nil(t, res.organisation)
+		assert.Equal(t, "http://app.example/projects/new", res.url)
+
+		res = run(numeric, "app.example", "/projects", true)
+		assert.Equal(t, http.StatusOK, res.status)
+		assert.Nil(t, res.organisation)
+	})
+
+	t.Run("ok: numeric subdomain", func(t *testing.T) {
+		res := run(numeric, "1.app.example", "/projects", true)
+		assert.Equal(t, http.StatusOK, res.status)
+		if assert.NotNil(t, res.organisation) {
+			assert.Equal(t, core.OrganisationID(1), res.organisation.ID)
+		}
+	})
+
+	t.Run("err: not logged in", func(t *testing.T) {
+		res := run(server.PathOrganisation(""), "app.example", "/o/1/projects", false)
+		assert.Equal(t, http.StatusUnauthorized, res.status)
+		assert.Nil(t, res.organisation)
+	})
+
+	t.Run("err: not a member", func(t *testing.T) {
+		res := run(server.PathOrganisation(""), "app.example", "/o/2/projects", true)
+		assert.Equal(t, http.StatusNotFound, res.status)
+		assert.Nil(t, res.organisation)
+	})
+
+	t.Run("err: invalid organisation", func(t *testing.T) {
+		res := run(server.PathOrganisation(""), "app.example", "/o/acme/projects", true)
+		assert.Equal(t, http.StatusNotFound, res.status)
+
+		res = run(numeric, "acme.app.example", "/projects", true)
+		assert.Equal(t, http.StatusNotFound, res.status)
+	})
+}
+
+func TestOrganisationPath(t *testing.T) {
+	assert.Equal(t, "/projects", server.OrganisationPath(context.Background(), "projects"))
+}
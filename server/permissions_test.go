@@ -0,0 +1,132 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a-h/templ"
+	"github.com/prior-it/apollo/config"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/permissions"
+	"github.com/prior-it/apollo/server"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePermissions grants a fixed set of permissions and counts how often it is queried.
+type fakePermissions struct {
+	permissions.Service
+	global map[permissions.Permission]bool
+	orgs   map[core.OrganisationID]map[permissions.Permission]bool
+	calls  int
+}
+
+func (f *fakePermissions) RegisterPermission(_ context.Context, _ permissions.Permission) error {
+	return nil
+}
+
+func (f *fakePermissions) HasAny(
+	_ context.Context,
+	_ core.UserID,
+	permission permissions.Permission,
+) (bool, error) {
+	f.calls++
+	return f.global[permission], nil
+}
+
+func (f *fakePermissions) HasAnyForOrgTree(
+	_ context.Context,
+	_ core.UserID,
+	orgID core.OrganisationID,
+	permission permissions.Permission,
+) (bool, error) {
+	f.calls++
+	return f.orgs[orgID][permission], nil
+}
+
+func TestCan(t *testing.T) {
+	cfg := &config.Config{
+		App: config.AppConfig{
+			AuthenticationKey: "01234567890123456789012345678901",
+			EncryptionKey:     "01234567890123456789012345678901",
+		},
+	}
+
+	run := func(
+		service *fakePermissions,
+		login bool,
+		handler func(ctx context.Context, apollo *server.Apollo),
+	) {
+		s := server.New(State{}, cfg).WithPermissionService(service)
+		s.UseStd(s.SessionMiddleware())
+		s.Get("/", func(apollo *server.Apollo, _ State) error {
+			if login {
+				err := apollo.Login(&core.User{ID: 1})
+				assert.Nil(t, err)
+			}
+			handler(apollo.Context(), apollo)
+			return nil
+		})
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	render := func(ctx context.Context, component templ.Component) string {
+		var buf bytes.Buffer
+		ctx = templ.WithChildren(ctx, templ.Raw("visible"))
+		err := component.Render(ctx, &buf)
+		assert.Nil(t, err)
+		return buf.String()
+	}
+
+	t.Run("ok: anonymous users cannot do anything", func(t *testing.T) {
+		t.Parallel()
+		service := &fakePermissions{
+			global: map[permissions.Permission]bool{permissions.PermViewAllUsers: true},
+		}
+		run(service, false, func(ctx context.Context, _ *server.Apollo) {
+			assert.False(t, server.Can(ctx, permissions.PermViewAllUsers))
+			assert.Empty(t, render(ctx, server.IfCan(permissions.PermViewAllUsers)))
+			assert.Equal(t, "visible", render(ctx, server.IfCannot(permissions.PermViewAllUsers)))
+		})
+		assert.Zero(t, service.calls)
+	})
+
+	t.Run("ok: permissions are checked once per request", func(t *testing.T) {
+		t.Parallel()
+		service := &fakePermissions{
+			global: map[permissions.Permission]bool{permissions.PermViewAllUsers: true},
+		}
+		run(service, true, func(ctx context.Context, _ *server.Apollo) {
+			assert.True(t, server.Can(ctx, permissions.PermViewAllUsers))
+			assert.True(t, server.Can(ctx, permissions.PermViewAllUsers))
+			assert.Equal(t, "visible", render(ctx, server.IfCan(permissions.PermViewAllUsers)))
+			assert.False(t, server.Can(ctx, permissions.PermEditAllUsers))
+			assert.Empty(t, render(ctx, server.IfCan(permissions.PermEditAllUsers)))
+		})
+		assert.Equal(t, 2, service.calls)
+	})
+
+	t.Run("ok: organisation permissions", func(t *testing.T) {
+		t.Parallel()
+		perm := permissions.PermEditOwnOrganisation
+		service := &fakePermissions{
+			orgs: map[core.OrganisationID]map[permissions.Permission]bool{
+				1: {perm: true},
+			},
+		}
+		run(service, true, func(ctx context.Context, _ *server.Apollo) {
+			assert.True(t, server.CanInOrganisation(ctx, perm, 1))
+			assert.False(t, server.CanInOrganisation(ctx, perm, 2))
+			assert.Equal(t, "visible", render(ctx, server.IfCanInOrganisation(perm, 1)))
+			assert.Empty(t, render(ctx, server.IfCanInOrganisation(perm, 2)))
+		})
+		assert.Equal(t, 2, service.calls)
+	})
+
+	t.Run("ok: contexts without apollo cannot do anything", func(t *testing.T) {
+		t.Parallel()
+		assert.False(t, server.Can(context.Background(), permissions.PermViewAllUsers))
+	})
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/permissions"
+)
+
+// permissionSet memoises the permission checks of a single request, so templates can freely check permissions
+// without hitting the permission service more than once for the same permission.
+type permissionSet struct {
+	apollo *Apollo
+	mu     sync.Mutex
+	checks map[permissionCheck]bool
+}
+
+// permissionCheck identifies a single permission check.
+// The active user and organisation are part of the key so logging in or switching organisations during a request
+// never returns stale results.
+type permissionCheck struct {
+	userID       core.UserID
+	activeOrgID  core.OrganisationID
+	permission   permissions.Permission
+	organisation core.OrganisationID
+	inOrg        bool
+}
+
+func withPermissionSet(ctx context.Context, apollo *Apollo) context.Context {
+	return context.WithValue(ctx, ctxPermissions, &permissionSet{
+		apollo: apollo,
+		checks: make(map[permissionCheck]bool),
+	})
+}
+
+func permissionSetFromContext(ctx context.Context) *permissionSet {
+	set, ok := ctx.Value(ctxPermissions).(*permissionSet)
+	if !ok {
+		return nil
+	}
+	return set
+}
+
+func (set *permissionSet) has(
+	permission permissions.Permission,
+	organisation *core.OrganisationID,
+) bool {
+	if set.apollo.User == nil {
+		return false
+	}
+	check := permissionCheck{userID: set.apollo.User.ID, permission: permission}
+	if set.apollo.Organisation != nil {
+		check.activeOrgID = set.apollo.Organisation.ID
+	}
+	if organisation != nil {
+		check.organisation = *organisation
+		check.inOrg = true
+	}
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if ok, found := set.checks[check]; found {
+		return ok
+	}
+	var ok bool
+	if organisation != nil {
+		ok = set.apollo.HasInOrganisation(permission, *organisation)
+	} else {
+		ok = set.apollo.Has(permission)
+	}
+	set.checks[check] = ok
+	return ok
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/permissions"
+)
+
+type requirementScope int
+
+const (
+	scopeAny requirementScope = iota
+	scopeActiveOrganisation
+	scopePathOrganisation
+)
+
+// Requirement is a declarative access requirement for a route or group of routes.
+// Requirements are checked before the handler runs, if a requirement is not met the server's error handler is
+// called with core.ErrUnauthenticated or core.ErrForbidden instead.
+//
+// # Example
+//
+//	server.Get("/users", handlers.ListUsers, server.Requires(permissions.PermViewAllUsers))
+//	server.Group("/organisations/{orgID}", server.RequiresInPathOrganisation(perm, "orgID").Strict())
+type Requirement struct {
+	permission permissions.Permission
+	scope      requirementScope
+	param      string
+	strict     bool
+}
+
+// Requires returns a requirement that the current user has the specified permission, either globally or in the
+// lineage of the active organisation. This behaves the same as [Apollo.Requires].
+func Requires(permission permissions.Permission) Requirement {
+	return Requirement{permission: permission, scope: scopeAny}
+}
+
+// RequiresInActiveOrganisation returns a requirement that the current user has the specified permission within the
+// lineage of the active organisation. Global permissions are ignored. If there is no active organisation, the
+// requirement fails with core.ErrForbidden.
+func RequiresInActiveOrganisation(permission permissions.Permission) Requirement {
+	return Requirement{permission: permission, scope: scopeActiveOrganisation}
+}
+
+// RequiresInPathOrganisation returns a requirement that the current user has the specified permission, either
+// globally or within the lineage of the organisation whose id is in the specified path parameter.
+// This behaves the same as calling [Apollo.RequiresInOrganisation] with the id from [Apollo.GetPath].
+// If the path parameter does not contain a valid id, the requirement fails with core.ErrNotFound.
+func RequiresInPathOrganisation(permission permissions.Permission, param string) Requirement {
+	return Requirement{permission: permission, scope: scopePathOrganisation, param: param}
+}
+
+// Strict returns a copy of the requirement that uses the strict permission check: global permissions and parent
+// organisations are ignored and only the organisation itself is checked.
+// A strict version of [Requires] only checks the active organisation, the same as [Apollo.RequiresStrict].
+func (r Requirement) Strict() Requirement {
+	r.strict = true
+	return r
+}
+
+// Permission returns the permission that is required.
+func (r Requirement) Permission() permissions.Permission {
+	return r.permission
+}
+
+// String returns a human-readable description of the requirement, e.g. for route listings.
+func (r Requirement) String() string {
+	var b strings.Builder
+	b.WriteString(r.permission.String())
+	switch r.scope {
+	case scopeActiveOrganisation:
+		b.WriteString(" in active organisation")
+	case scopePathOrganisation:
+		fmt.Fprintf(&b, " in organisation {%s}", r.param)
+	case scopeAny:
+	}
+	if r.strict {
+		b.WriteString(" (strict)")
+	}
+	return b.String()
+}
+
+// Check returns nil if the current user meets the requirement.
+// If no user is logged in at all, this will return core.ErrUnauthenticated.
+func (r Requirement) Check(apollo *Apollo) error {
+	switch r.scope {
+	case scopeActiveOrganisation:
+		if err := apollo.RequiresLogin(); err != nil {
+			return err
+		}
+		if apollo.Organisation == nil {
+			return errors.Join(core.ErrForbidden, core.ErrNoActiveOrganisation)
+		}
+		return r.checkOrganisation(apollo, apollo.Organisation.ID)
+
+	case scopePathOrganisation:
+		if err := apollo.RequiresLogin(); err != nil {
+			return err
+		}
+		orgID, err := core.ParseID(apollo.GetPath(r.param))
+		if err != nil {
+			return errors.Join(
+				core.ErrNotFound,
+				fmt.Errorf("invalid organisation id in path parameter %q: %w", r.param, err),
+			)
+		}
+		return r.checkOrganisation(apollo, orgID)
+
+	case scopeAny:
+	}
+	if r.strict {
+		return apollo.RequiresStrict(r.permission)
+	}
+	return apollo.Requires(r.permission)
+}
+
+func (r Requirement) checkOrganisation(apollo *Apollo, orgID core.OrganisationID) error {
+	if r.strict {
+		return apollo.RequiresInOrganisationStrict(r.permission, orgID)
+	}
+	return apollo.RequiresInOrganisation(r.permission, orgID)
+}
+
+func checkRequirements(apollo *Apollo, requirements []Requirement) error {
+	for _, requirement := range requirements {
+		if err := requirement.Check(apollo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RequirePermissions is middleware that checks all specified requirements before continuing on.
+// Note that middleware runs before the route itself is matched, so path parameters are only available if they are
+// part of a Group pattern. Pass the requirements to the route itself if you need its path parameters.
+func RequirePermissions[state any](requirements ...Requirement) Middleware[state] {
+	return func(apollo *Apollo, _ state) (context.Context, error) {
+		return apollo.Context(), checkRequirements(apollo, requirements)
+	}
+}
+
+// RouteInfo describes a single route that was registered on a server.
+type RouteInfo struct {
+	// The HTTP method of the route, or "*" if the route matches any method
+	Method string
+	// The full route pattern, including the patterns of all parent groups
+	Pattern string
+	// All requirements that are checked before the handler runs, including those of all parent groups
+	Requirements []Requirement
+}
+
+type routeRegistry struct {
+	routes []RouteInfo
+}
+
+func (server *Server[state]) registerRoute(
+	method string,
+	pattern string,
+	requirements []Requirement,
+) {
+	if server.routes == nil {
+		return
+	}
+	combined := slices.Concat(server.requirements, requirements)
+	server.routes.routes = append(server.routes.routes, RouteInfo{
+		Method:       method,
+		Pattern:      joinRoutePattern(server.prefix, pattern),
+		Requirements: combined,
+	})
+}
+
+// Routes returns all routes that were registered on this server and all of its groups, sorted by pattern.
+// This can be used to introspect the permissions that each route requires.
+func (server *Server[state]) Routes() []RouteInfo {
+	if server.routes == nil {
+		return nil
+	}
+	routes := slices.Clone(server.routes.routes)
+	slices.SortStableFunc(routes, func(a, b RouteInfo) int {
+		if c := strings.Compare(a.Pattern, b.Pattern); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Method, b.Method)
+	})
+	return routes
+}
+
+func joinRoutePattern(prefix string, pattern string) string {
+	if len(prefix) == 0 {
+		return pattern
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(pattern, "/")
+}
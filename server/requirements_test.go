@@ -0,0 +1,97 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prior-it/apollo/config"
+	"github.com/prior-it/apollo/permissions"
+	"github.com/prior-it/apollo/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequirements(t *testing.T) {
+	newServer := func() (*server.Server[State], *bool) {
+		called := false
+		handler := func(_ *server.Apollo, _ State) error {
+			called = true
+			return nil
+		}
+		s := server.New(State{}, &config.Config{})
+		s.Get("/public", handler)
+		s.Get("/users", handler, server.Requires(permissions.PermViewAllUsers))
+		orgs := s.Group(
+			"/organisations/{orgID}",
+			server.RequiresInPathOrganisation(permissions.PermViewOwnOrganisation, "orgID"),
+		)
+		orgs.Post("/users", handler, server.Requires(permissions.PermEditOwnOrganisation).Strict())
+		return s, &called
+	}
+
+	serve := func(s *server.Server[State], method string, path string) int {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+		return recorder.Code
+	}
+
+	t.Run("ok: routes without requirements are accessible", func(t *testing.T) {
+		t.Parallel()
+		s, called := newServer()
+		assert.Equal(t, http.StatusOK, serve(s, http.MethodGet, "/public"))
+		assert.True(t, *called)
+	})
+
+	t.Run("err: route requirements are checked before the handler", func(t *testing.T) {
+		t.Parallel()
+		s, called := newServer()
+		assert.Equal(t, http.StatusUnauthorized, serve(s, http.MethodGet, "/users"))
+		assert.False(t, *called, "The handler should not run if a requirement is not met")
+	})
+
+	t.Run("err: group requirements are checked before the handler", func(t *testing.T) {
+		t.Parallel()
+		s, called := newServer()
+		code := serve(s, http.MethodPost, "/organisations/1/users")
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.False(t, *called, "The handler should not run if a requirement is not met")
+	})
+
+	t.Run("ok: routes list their requirements", func(t *testing.T) {
+		t.Parallel()
+		s, _ := newServer()
+		routes := s.Routes()
+		assert.Len(t, routes, 3)
+
+		assert.Equal(t, "/organisations/{orgID}/users", routes[0].Pattern)
+		assert.Equal(t, http.MethodPost, routes[0].Method)
+		assert.Equal(t, []server.Requirement{
+			server.RequiresInPathOrganisation(permissions.PermViewOwnOrganisation, "orgID"),
+			server.Requires(permissions.PermEditOwnOrganisation).Strict(),
+		}, routes[0].Requirements)
+
+		assert.Equal(t, "/public", routes[1].Pattern)
+		assert.Empty(t, routes[1].Requirements)
+
+		assert.Equal(t, "/users", routes[2].Pattern)
+		assert.Equal(t, []server.Requirement{
+			server.Requires(permissions.PermViewAllUsers),
+		}, routes[2].Requirements)
+	})
+
+	t.Run("ok: requirements have a readable description", func(t *testing.T) {
+		t.Parallel()
+		perm := permissions.PermViewOwnOrganisation
+		assert.Equal(t, perm.String(), server.Requires(perm).String())
+		assert.Equal(
+			t,
+			perm.String()+" in organisation {orgID} (strict)",
+			server.RequiresInPathOrganisation(perm, "orgID").Strict().String(),
+		)
+		assert.Equal(
+			t,
+			perm.String()+" in active organisation",
+			server.RequiresInActiveOrganisation(perm).String(),
+		)
+	})
+}
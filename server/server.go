@@ -9,6 +9,7 @@ import (
 	"net"
 	"net/http"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
@@ -20,6 +21,7 @@ import (
 	"github.com/go-chi/render"
 	"github.com/gorilla/sessions"
 	"github.com/prior-it/apollo/config"
+	"github.com/prior-it/apollo/core"
 	"github.com/prior-it/apollo/permissions"
 	"github.com/vearutop/statigz"
 )
@@ -40,8 +42,14 @@ type Server[state State] struct {
 	layout            templ.Component
 	errorHandler      ErrorHandler
 	permissionService permissions.Service
+	userService       core.UserService
 	sessionStore      sessions.Store
 	cfg               *config.Config
+	routes            *routeRegistry
+	prefix            string
+	requirements      []Requirement
+	// Shared by all groups, so hooks that are registered on a group run as well
+	shutdown *shutdownHooks
 }
 
 type (
@@ -58,6 +66,8 @@ func New[state State](s state, cfg *config.Config) *Server[state] {
 		layout:       defaultLayout(),
 		errorHandler: DefaultErrorHandler,
 		cfg:          cfg,
+		routes:       &routeRegistry{},
+		shutdown:     &shutdownHooks{},
 	}
 
 	if len(cfg.App.AuthenticationKey) > 0 && len(cfg.App.EncryptionKey) > 0 {
@@ -110,6 +120,14 @@ func (server *Server[state]) WithPermissionService(service permissions.Service)
 	return server
 }
 
+// WithUserService makes the server check that the user of every session still exists, logging out sessions of users
+// that have been (soft) deleted. SessionMiddleware checks this once per request, which costs a single query.
+// The user service is also used to stop impersonating users.
+func (server *Server[state]) WithUserService(service core.UserService) *Server[state] {
+	server.userService = service
+	return server
+}
+
 func (server *Server[state]) WithSessionStore(store sessions.Store) *Server[state] {
 	server.sessionStore = store
 	return server
@@ -120,6 +138,18 @@ func (server *Server[state]) WithConfig(cfg *config.Config) *Server[state] {
 	return server
 }
 
+type shutdownHooks struct {
+	hooks []func(ctx context.Context)
+}
+
+// OnShutdown registers a hook that runs when the server shuts down, before the state is closed, e.g. to drain a job
+// queue. Hooks run in the order in which they were registered and should return when the context ends.
+// Hooks that are registered on a group run when the server that created the group shuts down.
+func (server *Server[state]) OnShutdown(hook func(ctx context.Context)) *Server[state] {
+	server.shutdown.hooks = append(server.shutdown.hooks, hook)
+	return server
+}
+
 func (server *Server[state]) NewApollo(w http.ResponseWriter, r *http.Request) *Apollo {
 	apollo := Apollo{
 		Writer:      w,
@@ -127,6 +157,7 @@ func (server *Server[state]) NewApollo(w http.ResponseWriter, r *http.Request) *
 		logger:      server.logger,
 		layout:      server.layout,
 		permissions: server.permissionService,
+		users:       server.userService,
 		store:       server.sessionStore,
 		Cfg:         server.cfg,
 	}
@@ -134,10 +165,16 @@ func (server *Server[state]) NewApollo(w http.ResponseWriter, r *http.Request) *
 	return &apollo
 }
 
-func (server *Server[state]) handle(handler Handler[state]) http.HandlerFunc {
+func (server *Server[state]) handle(
+	handler Handler[state],
+	requirements ...Requirement,
+) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		apollo := server.NewApollo(w, r)
-		err := handler(apollo, server.state)
+		err := checkRequirements(apollo, requirements)
+		if err == nil {
+			err = handler(apollo, server.state)
+		}
 		if err != nil {
 			server.errorHandler(apollo, err)
 		}
@@ -267,6 +304,9 @@ loop:
 func (server *Server[state]) Shutdown(ctx context.Context) {
 	sentryTimeout := max(0, time.Duration(server.cfg.App.ShutdownTimeout-1))
 	sentry.Flush(sentryTimeout * time.Second)
+	for _, hook := range server.shutdown.hooks {
+		hook(ctx)
+	}
 	server.state.Close(ctx)
 }
 
@@ -305,6 +345,7 @@ func (server *Server[state]) Use(
 // execute the `handler` [net/http.Handler].
 func (server *Server[state]) Handle(pattern string, handler http.Handler) *Server[state] {
 	server.mux.Handle(pattern, handler)
+	server.registerRoute("*", pattern, nil)
 	return server
 }
 
@@ -348,70 +389,96 @@ func (server *Server[state]) StaticFiles(pattern string, dir string, files fs.Re
 // This simply sets a wildcard along the `pattern` that will continue
 // routing at return subroute server. As a result, if you define two Group() routes on
 // the exact same pattern, the second group will panic.
+//
+// Any requirements are checked for every route in the group before the route's handler runs.
 func (server *Server[state]) Group(
 	pattern string,
+	requirements ...Requirement,
 ) *Server[state] {
 	srv := Server[state](*server) //nolint:unconvert // shallow copy
 	srv.mux = chi.NewMux()
+	srv.prefix = joinRoutePattern(server.prefix, pattern)
+	srv.requirements = slices.Concat(server.requirements, requirements)
+	if len(requirements) > 0 {
+		srv.Use(RequirePermissions[state](requirements...))
+	}
 	server.mux.Mount(pattern, srv.mux)
 	return &srv
 }
 
 // Get adds the route `pattern` that matches a GET http method to execute the `handlerFn` HandlerFunc.
+// Any requirements are checked before the handler runs.
 func (server *Server[state]) Get(
 	pattern string,
 	handlerFn func(apollo *Apollo, state state) error,
+	requirements ...Requirement,
 ) *Server[state] {
-	server.mux.Get(pattern, server.handle(handlerFn))
+	server.mux.Get(pattern, server.handle(handlerFn, requirements...))
+	server.registerRoute(http.MethodGet, pattern, requirements)
 	return server
 }
 
 // Post adds the route `pattern` that matches a POST http method to execute the `handlerFn` http.HandlerFunc.
+// Any requirements are checked before the handler runs.
 func (server *Server[state]) Post(
 	pattern string,
 	handlerFn func(apollo *Apollo, state state) error,
+	requirements ...Requirement,
 ) *Server[state] {
-	server.mux.Post(pattern, server.handle(handlerFn))
+	server.mux.Post(pattern, server.handle(handlerFn, requirements...))
+	server.registerRoute(http.MethodPost, pattern, requirements)
 	return server
 }
 
 // Put adds the route `pattern` that matches a POST http method to execute the `handlerFn` http.HandlerFunc.
+// Any requirements are checked before the handler runs.
 func (server *Server[state]) Put(
 	pattern string,
 	handlerFn func(apollo *Apollo, state state) error,
+	requirements ...Requirement,
 ) *Server[state] {
-	server.mux.Put(pattern, server.handle(handlerFn))
+	server.mux.Put(pattern, server.handle(handlerFn, requirements...))
+	server.registerRoute(http.MethodPut, pattern, requirements)
 	return server
 }
 
 // Delete adds the route `pattern` that matches a POST http method to execute the `handlerFn` http.HandlerFunc.
+// Any requirements are checked before the handler runs.
 func (server *Server[state]) Delete(
 	pattern string,
 	handlerFn func(apollo *Apollo, state state) error,
+	requirements ...Requirement,
 ) *Server[state] {
-	server.mux.Delete(pattern, server.handle(handlerFn))
+	server.mux.Delete(pattern, server.handle(handlerFn, requirements...))
+	server.registerRoute(http.MethodDelete, pattern, requirements)
 	return server
 }
 
 // Page adds the route `pattern` that matches a GET http method to render the specified templ component in the default layout.
+// Any requirements are checked before the handler runs.
 func (server *Server[state]) Page(
 	pattern string,
 	component templ.Component,
 	options *RenderOptions,
+	requirements ...Requirement,
 ) *Server[state] {
 	server.mux.Get(pattern, server.handle(func(apollo *Apollo, _ state) error {
 		return apollo.RenderPage(component, options)
-	}))
+	}, requirements...))
+	server.registerRoute(http.MethodGet, pattern, requirements)
 	return server
 }
 
 // Component adds the route `pattern` that matches a GET http method to render the specified templ component without any layout.
+// Any requirements are checked before the handler runs.
 func (server *Server[state]) Component(
 	pattern string,
 	component templ.Component,
+	requirements ...Requirement,
 ) *Server[state] {
 	server.mux.Get(pattern, server.handle(func(apollo *Apollo, _ state) error {
 		return apollo.RenderComponent(component)
-	}))
+	}, requirements...))
+	server.registerRoute(http.MethodGet, pattern, requirements)
 	return server
 }
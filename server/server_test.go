@@ -0,0 +1,30 @@
+package server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prior-it/apollo/config"
+	"github.com/prior-it/apollo/server"
+	"github.com/stretchr/testify/assert"
+)
+
+type closingState struct {
+	calls *[]string
+}
+
+func (s closingState) Close(_ context.Context) {
+	*s.calls = append(*s.calls, "close")
+}
+
+func TestShutdownHooks(t *testing.T) {
+	var calls []string
+	s := server.New(closingState{&calls}, &config.Config{})
+	s.OnShutdown(func(_ context.Context) { calls = append(calls, "first") }).
+		OnShutdown(func(_ context.Context) { calls = append(calls, "second") })
+	s.Group("/admin").OnShutdown(func(_ context.Context) { calls = append(calls, "group") })
+
+	s.Shutdown(context.Background())
+	assert.Equal(t, []string{"first", "second", "group", "close"}, calls,
+		"Hooks should run in order, including the ones of groups, before the state is closed")
+}
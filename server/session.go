@@ -26,6 +26,7 @@ const (
 	sessionLanguage           = "apollo-user-lang"
 	sessionJoined             = "apollo-user-joined"
 	sessionUserID             = "apollo-user-id"
+	sessionImpersonatorID     = "apollo-impersonator-id"
 	sessionOrganisationID     = "apollo-organisation-id"
 	sessionOrganisationName   = "apollo-organisation-name"
 	sessionOrganisationParent = "apollo-organisation-parent"
@@ -68,10 +69,20 @@ func buildSessionContext(ctx context.Context, session *sessions.Session) context
 		ctx = context.WithValue(ctx, ctxUserName, userName)
 	}
 
+	// Mutations are attributed to the logged in user, or to the system if nobody is logged in
+	var actor core.Actor
 	userID, ok := session.Values[sessionUserID].(core.UserID)
 	if ok {
 		ctx = context.WithValue(ctx, ctxUserID, userID)
+		if loggedIn {
+			actor.UserID = &userID
+		}
+	}
+	impersonatorID, ok := session.Values[sessionImpersonatorID].(core.UserID)
+	if ok && actor.UserID != nil {
+		actor.ImpersonatorID = &impersonatorID
 	}
+	ctx = core.WithActor(ctx, actor)
 
 	organisationID, ok := session.Values[sessionOrganisationID].(core.OrganisationID)
 	if ok {
@@ -93,6 +104,47 @@ func buildSessionContext(ctx context.Context, session *sessions.Session) context
 
 // Login will log in with the specified user.
 func (apollo *Apollo) Login(user *core.User) error {
+	return apollo.login(user, nil)
+}
+
+// Impersonate logs in as the specified user on behalf of the current user, e.g. so that support staff can see what
+// a customer sees. Every mutation is attributed to the impersonated user together with the impersonator.
+// Make sure to check that the current user is allowed to do this before calling it.
+// If the current user is already impersonating someone, the original impersonator is kept.
+func (apollo *Apollo) Impersonate(user *core.User) error {
+	if apollo.User == nil {
+		return core.ErrUnauthenticated
+	}
+	impersonatorID := apollo.User.ID
+	if current := apollo.Impersonator(); current != nil {
+		impersonatorID = *current
+	}
+	return apollo.login(user, &impersonatorID)
+}
+
+// StopImpersonating logs back in as the user that started impersonating the current user.
+// This returns core.ErrUnauthenticated if the current user is not being impersonated.
+func (apollo *Apollo) StopImpersonating() error {
+	impersonatorID := apollo.Impersonator()
+	if impersonatorID == nil {
+		return core.ErrUnauthenticated
+	}
+	if apollo.users == nil {
+		panic("you need to specify a user service before you can stop impersonating")
+	}
+	impersonator, err := apollo.users.GetUser(apollo.Context(), *impersonatorID)
+	if err != nil {
+		return fmt.Errorf("could not retrieve impersonator: %w", err)
+	}
+	return apollo.login(impersonator, nil)
+}
+
+// Impersonator returns the id of the user that is impersonating the current user, or nil if there is none.
+func (apollo *Apollo) Impersonator() *core.UserID {
+	return core.ActorFromContext(apollo.Context()).ImpersonatorID
+}
+
+func (apollo *Apollo) login(user *core.User, impersonatorID *core.UserID) error {
 	if user == nil {
 		panic("you cannot log in with a nil user")
 	}
@@ -100,6 +152,11 @@ func (apollo *Apollo) Login(user *core.User) error {
 		panic("you need to specify a session store before logging in")
 	}
 	session := apollo.Session()
+	if impersonatorID != nil {
+		session.Values[sessionImpersonatorID] = *impersonatorID
+	} else {
+		session.Values[sessionImpersonatorID] = nil
+	}
 	session.Values[sessionLoggedIn] = true
 	session.Values[sessionIsAdmin] = user.Admin
 	session.Values[sessionUserName] = user.Name
@@ -282,14 +339,20 @@ func (apollo *Apollo) retrieveOrganisation() (*core.Organisation, error) {
 // Logout will log the current user out.
 func (apollo *Apollo) Logout() error {
 	session := apollo.Session()
+	clearSession(session)
+	return session.Store().Save(apollo.Request, apollo.Writer, session)
+}
+
+// clearSession removes the user and organisation from the session.
+func clearSession(session *sessions.Session) {
 	session.Values[sessionLoggedIn] = false
 	session.Values[sessionIsAdmin] = false
 	session.Values[sessionUserName] = nil
 	session.Values[sessionLanguage] = nil
 	session.Values[sessionUserID] = nil
+	session.Values[sessionImpersonatorID] = nil
 	session.Values[sessionOrganisationName] = nil
 	session.Values[sessionOrganisationID] = nil
 	session.Values[sessionOrganisationParent] = nil
 	session.Values[sessionEmail] = nil
-	return session.Store().Save(apollo.Request, apollo.Writer, session)
 }
@@ -0,0 +1,154 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prior-it/apollo/config"
+	"github.com/prior-it/apollo/core"
+	"github.com/prior-it/apollo/server"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeUsers only contains the users that have not been deleted.
+type fakeUsers struct {
+	core.UserService
+	deleted map[core.UserID]bool
+	lookups int
+}
+
+func (f *fakeUsers) GetUser(_ context.Context, id core.UserID) (*core.User, error) {
+	f.lookups++
+	if f.deleted[id] {
+		return nil, core.ErrNotFound
+	}
+	email, err := core.ParseEmailAddress(fmt.Sprintf("user%v@example.com", id))
+	if err != nil {
+		return nil, err
+	}
+	return &core.User{ID: id, Email: *email}, nil
+}
+
+func TestDeletedUserSession(t *testing.T) {
+	cfg := &config.Config{
+		App: config.AppConfig{
+			AuthenticationKey: "01234567890123456789012345678901",
+			EncryptionKey:     "01234567890123456789012345678901",
+		},
+	}
+	users := &fakeUsers{deleted: make(map[core.UserID]bool)}
+	var user *core.User
+	s := server.New(State{}, cfg).WithUserService(users)
+	s.UseStd(s.SessionMiddleware())
+	s.Use(server.InjectApollo[State], server.InjectApollo[State])
+	s.Get("/login", func(apollo *server.Apollo, _ State) error {
+		email, err := core.ParseEmailAddress("user@example.com")
+		if err != nil {
+			return err
+		}
+		return apollo.Login(&core.User{ID: 1, Email: *email})
+	})
+	s.Get("/me", func(apollo *server.Apollo, _ State) error {
+		user = apollo.User
+		return nil
+	})
+
+	// request performs a request with the cookies and returns the cookies of the response
+	request := func(target string, cookies []*http.Cookie) []*http.Cookie {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		s.ServeHTTP(recorder, request)
+		return recorder.Result().Cookies()
+	}
+
+	cookies := request("/login", nil)
+	users.lookups = 0
+	request("/me", cookies)
+	if assert.NotNil(t, user, "The session's user should be logged in") {
+		assert.Equal(t, core.UserID(1), user.ID)
+	}
+	assert.Equal(t, 1, users.lookups, "The user should only be looked up once per request")
+
+	users.deleted[1] = true
+	cookies = request("/me", cookies)
+	assert.Nil(t, user, "The session of a deleted user should not be logged in")
+
+	users.deleted[1] = false
+	request("/me", cookies)
+	assert.Nil(t, user, "The session of a deleted user should be logged out permanently")
+}
+
+func TestImpersonation(t *testing.T) {
+	cfg := &config.Config{
+		App: config.AppConfig{
+			AuthenticationKey: "01234567890123456789012345678901",
+			EncryptionKey:     "01234567890123456789012345678901",
+		},
+	}
+	users := &fakeUsers{deleted: make(map[core.UserID]bool)}
+	var actor core.Actor
+	s := server.New(State{}, cfg).WithUserService(users)
+	s.UseStd(s.SessionMiddleware())
+	s.Get("/login", func(apollo *server.Apollo, _ State) error {
+		user, err := users.GetUser(apollo.Context(), 1)
+		if err != nil {
+			return err
+		}
+		return apollo.Login(user)
+	})
+	s.Get("/impersonate", func(apollo *server.Apollo, _ State) error {
+		user, err := users.GetUser(apollo.Context(), 2)
+		if err != nil {
+			return err
+		}
+		return apollo.Impersonate(user)
+	})
+	s.Get("/stop", func(apollo *server.Apollo, _ State) error {
+		return apollo.StopImpersonating()
+	})
+	s.Get("/me", func(apollo *server.Apollo, _ State) error {
+		actor = core.ActorFromContext(apollo.Context())
+		return nil
+	})
+
+	// request performs a request with the cookies and returns the cookies of the response
+	request := func(target string, cookies []*http.Cookie) []*http.Cookie {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		s.ServeHTTP(recorder, request)
+		return recorder.Result().Cookies()
+	}
+
+	request("/me", nil)
+	assert.True(t, actor.IsSystem(), "Anonymous requests should be attributed to the system")
+
+	cookies := request("/login", nil)
+	request("/me", cookies)
+	if assert.NotNil(t, actor.UserID) {
+		assert.Equal(t, core.UserID(1), *actor.UserID)
+	}
+	assert.Nil(t, actor.ImpersonatorID)
+
+	cookies = request("/impersonate", cookies)
+	request("/me", cookies)
+	if assert.NotNil(t, actor.UserID) && assert.NotNil(t, actor.ImpersonatorID) {
+		assert.Equal(t, core.UserID(2), *actor.UserID)
+		assert.Equal(t, core.UserID(1), *actor.ImpersonatorID)
+	}
+
+	cookies = request("/stop", cookies)
+	request("/me", cookies)
+	if assert.NotNil(t, actor.UserID) {
+		assert.Equal(t, core.UserID(1), *actor.UserID)
+	}
+	assert.Nil(t, actor.ImpersonatorID, "The impersonator should be cleared")
+}
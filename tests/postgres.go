@@ -39,27 +39,35 @@ func DB(t *testing.T) *postgres.DB {
 	return db
 }
 
+// DeleteAllUsers permanently deletes all users, including the ones that were soft deleted.
 func DeleteAllUsers(service core.UserService) {
 	ctx := context.Background()
-	users, err := service.ListUsers(ctx)
-	Check(err)
-	for _, user := range users {
-		Check(service.DeleteUser(ctx, user.ID))
+	for _, deleted := range []string{"false", "true"} {
+		query := core.ListQuery{Filters: map[string]string{"deleted": deleted}}
+		users, err := core.ListAll(ctx, query, service.ListUsers)
+		Check(err)
+		for _, user := range users {
+			Check(service.PurgeUser(ctx, user.ID))
+		}
 	}
 }
 
+// DeleteAllOrganisations permanently deletes all organisations, including the ones that were soft deleted.
 func DeleteAllOrganisations(service core.OrganisationService) {
 	ctx := context.Background()
-	organisations, err := service.ListOrganisations(ctx)
-	Check(err)
-	for _, organisation := range organisations {
-		Check(service.DeleteOrganisation(ctx, organisation.ID))
+	for _, deleted := range []string{"false", "true"} {
+		query := core.ListQuery{Filters: map[string]string{"deleted": deleted}}
+		organisations, err := core.ListAll(ctx, query, service.ListOrganisations)
+		Check(err)
+		for _, organisation := range organisations {
+			Check(service.PurgeOrganisation(ctx, organisation.ID))
+		}
 	}
 }
 
 func DeleteAllAddresses(service core.AddressService) {
 	ctx := context.Background()
-	addresss, err := service.ListAddresses(ctx)
+	addresss, err := core.ListAll(ctx, core.ListQuery{}, service.ListAddresses)
 	Check(err)
 	for _, address := range addresss {
 		Check(service.DeleteAddress(ctx, address.ID))
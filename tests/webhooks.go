@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/prior-it/apollo/core"
+)
+
+// ReceivedWebhook is a single request that a WebhookReceiver received.
+type ReceivedWebhook struct {
+	Header  http.Header
+	Body    []byte
+	Payload core.WebhookPayload
+	// The result of verifying the request's signature with the receiver's secret
+	SignatureErr error
+}
+
+// WebhookReceiver is a local HTTP server that records every webhook it receives, to assert what was delivered.
+// It responds with 401 to webhooks with an invalid signature and with 204 to all others, unless it was told to fail.
+type WebhookReceiver struct {
+	server   *httptest.Server
+	t        *testing.T
+	secret   string
+	lock     sync.Mutex
+	received []ReceivedWebhook
+	failures int
+}
+
+// NewWebhookReceiver starts a receiver that verifies signatures with the specified secret.
+// It is closed automatically at the end of the test.
+//
+// # Example
+//
+//	receiver := tests.NewWebhookReceiver(t, "secret")
+//	webhooks.SetAllowPrivateAddresses(true)
+//	endpoint, err := webhooks.CreateWebhookEndpoint(ctx, orgID, core.WebhookEndpointCreate{
+//		URL:    receiver.URL(),
+//		Secret: "secret",
+//	})
+//	sender := postgres.NewWebhookSender(db, receiver.Client())
+func NewWebhookReceiver(t *testing.T, secret string) *WebhookReceiver {
+	t.Helper()
+	receiver := &WebhookReceiver{t: t, secret: secret}
+	receiver.server = httptest.NewTLSServer(http.HandlerFunc(receiver.handle))
+	t.Cleanup(receiver.server.Close)
+	return receiver
+}
+
+// Client returns a client that can send webhooks to the receiver, which listens on a local address.
+func (r *WebhookReceiver) Client() *http.Client {
+	return r.server.Client()
+}
+
+// URL returns the address that webhooks should be sent to.
+func (r *WebhookReceiver) URL() string {
+	return r.server.URL
+}
+
+// FailNext makes the receiver respond with 500 to the next amount of webhooks, which are still recorded.
+func (r *WebhookReceiver) FailNext(amount int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.failures = amount
+}
+
+// Received returns every webhook that was received so far, in the order in which they arrived.
+func (r *WebhookReceiver) Received() []ReceivedWebhook {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]ReceivedWebhook(nil), r.received...)
+}
+
+// AssertReceived fails the test unless a correctly signed webhook of the specified type was received, and returns
+// the last one.
+func (r *WebhookReceiver) AssertReceived(eventType core.EventType) *ReceivedWebhook {
+	r.t.Helper()
+	received := r.Received()
+	for i := len(received) - 1; i >= 0; i-- {
+		if received[i].Payload.Type == eventType && received[i].SignatureErr == nil {
+			return &received[i]
+		}
+	}
+	r.t.Errorf("Expected a signed %q webhook, received %v webhooks", eventType, len(received))
+	return nil
+}
+
+func (r *WebhookReceiver) handle(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	webhook := ReceivedWebhook{Header: req.Header.Clone(), Body: body}
+	webhook.SignatureErr = core.VerifyWebhookSignature(
+		r.secret,
+		req.Header.Get(core.WebhookSignatureHeader),
+		body,
+		core.DefaultWebhookTolerance,
+	)
+	if err := json.Unmarshal(body, &webhook.Payload); err != nil {
+		r.t.Errorf("Received webhook with an invalid payload: %v", err)
+	}
+
+	r.lock.Lock()
+	r.received = append(r.received, webhook)
+	fail := r.failures > 0
+	if fail {
+		r.failures--
+	}
+	r.lock.Unlock()
+
+	switch {
+	case webhook.SignatureErr != nil:
+		http.Error(w, webhook.SignatureErr.Error(), http.StatusUnauthorized)
+	case fail:
+		http.Error(w, "failing on purpose", http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}